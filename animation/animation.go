@@ -0,0 +1,264 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package animation renders the frames of an animation loop with the
+// software renderer of package raster, and encodes them as an animated
+// GIF, an animated PNG (APNG) or a numbered sequence of PNG files, for
+// example to create previews of a visualization without recording the
+// screen:
+//
+//	anim := animation.Render(func(ctx *canvas.Context, clock *animation.Clock) {
+//		for {
+//			select {
+//			case event := <-ctx.Events():
+//				if _, ok := event.(canvas.CloseEvent); ok {
+//					return
+//				}
+//			default:
+//				drawClock(ctx, clock.Now())
+//				ctx.Flush()
+//				clock.Sleep(time.Second / 2)
+//			}
+//		}
+//	}, &animation.Options{Width: 150, Height: 150, Duration: 10 * time.Second})
+//	err := anim.EncodeGIF(w)
+//
+// The animation loop runs on a virtual clock instead of the real time: it
+// must use the Clock passed to the run function instead of the time
+// package to get the current time and to wait for the next frame. Sleeping
+// on the virtual clock returns immediately, so an animation is rendered as
+// fast as possible, and the result does not depend on the speed of the
+// machine.
+//
+// The animation is sampled at a fixed frame rate: each frame shows the
+// canvas with the frames flushed up to the time of the frame, regardless
+// of how often the loop flushes. Like the run function of a canvas server,
+// the run function may return when it receives a canvas.CloseEvent, which
+// is sent at the end of the animation. A run function that does not read
+// the events is stopped when it calls Clock.Sleep after the end of the
+// animation, so a simple loop like
+//
+//	for {
+//		drawClock(ctx, clock.Now())
+//		ctx.Flush()
+//		clock.Sleep(time.Second / 2)
+//	}
+//
+// works as well.
+package animation
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/raster"
+)
+
+// Options configure the rendering of an animation.
+type Options struct {
+	// Width and Height are the size of the canvas in pixels. The defaults
+	// are the same as for canvas.Options.
+	Width, Height int
+	// FrameRate is the number of frames per second. If FrameRate is not
+	// set (i.e. 0) a default value of 30 will be used.
+	FrameRate float64
+	// Duration is the length of the animation. At the end of the animation
+	// a canvas.CloseEvent is sent to the run function, and the run
+	// function is stopped if it calls Clock.Sleep. If the run function
+	// returns earlier, the last frame is held until the end. If Duration
+	// is not set (i.e. 0), the animation ends when the run function
+	// returns.
+	Duration time.Duration
+	// Start is the time of the virtual clock at the start of the
+	// animation. If Start is not set, the clock starts at the current
+	// time. A fixed start time makes animations that show the current
+	// time reproducible.
+	Start time.Time
+	// Background is the color below the canvas. If Background is not set,
+	// the frames are transparent where nothing was drawn.
+	Background color.Color
+}
+
+func (o *Options) applyDefaults() {
+	if o.FrameRate <= 0 {
+		o.FrameRate = 30
+	}
+	if o.Start.IsZero() {
+		o.Start = time.Now()
+	}
+}
+
+// Animation is a sequence of frames with a fixed frame rate.
+type Animation struct {
+	// Frames are the frames of the animation. Consecutive frames that do
+	// not differ may be the same image.
+	Frames []*image.RGBA
+	// FrameRate is the number of frames per second.
+	FrameRate float64
+}
+
+// Render runs an animation loop with a canvas.Context and a virtual Clock,
+// and returns its frames rendered at the frame rate of the options. The
+// options may be nil, in which case the defaults of Options apply.
+func Render(run func(ctx *canvas.Context, clock *Clock), opts *Options) *Animation {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.applyDefaults()
+
+	draws := make(chan []byte)
+	events := make(chan canvas.Event)
+	ctx := canvas.NewContext(draws, events, &canvas.Options{Width: o.Width, Height: o.Height})
+	clock := &Clock{
+		start:  o.Start,
+		sleeps: make(chan time.Duration),
+		woken:  make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx, clock)
+	}()
+
+	s := &sampler{
+		renderer:   raster.NewRenderer(ctx.CanvasWidth(), ctx.CanvasHeight()),
+		background: o.Background,
+		frameRate:  o.FrameRate,
+		end:        o.Duration,
+	}
+	// closeEvents is set to the events channel at the end of the
+	// animation, to send the CloseEvent.
+	var closeEvents chan<- canvas.Event
+	closed := false
+	for {
+		select {
+		case frame := <-draws:
+			if err := s.renderer.RenderFrame(frame); err != nil {
+				panic(fmt.Sprintf("animation: %v", err))
+			}
+			s.dirty = true
+		case d := <-clock.sleeps:
+			s.advance(d)
+			clock.elapsed = s.now
+			clock.ended = o.Duration > 0 && s.now >= o.Duration
+			clock.woken <- struct{}{}
+		case closeEvents <- canvas.CloseEvent{}:
+			closeEvents = nil
+		case <-done:
+			s.finish()
+			return &Animation{Frames: s.frames, FrameRate: o.FrameRate}
+		}
+		if o.Duration > 0 && s.now >= o.Duration && !closed {
+			closeEvents = events
+			closed = true
+		}
+	}
+}
+
+// sampler collects the frames of an animation at a fixed frame rate.
+type sampler struct {
+	renderer   *raster.Renderer
+	background color.Color
+	frameRate  float64
+	// end is the duration of the animation, or 0 if the animation ends
+	// when the run function returns.
+	end time.Duration
+
+	// now is the time of the virtual clock since the start.
+	now    time.Duration
+	frames []*image.RGBA
+	// dirty reports whether frames were rendered since the last snapshot.
+	dirty bool
+}
+
+// frameTime returns the time of the i-th frame.
+func (s *sampler) frameTime(i int) time.Duration {
+	return time.Duration(math.Round(float64(i) * float64(time.Second) / s.frameRate))
+}
+
+// advance advances the virtual clock by d, and adds the frames whose time
+// is between the current time and the new time.
+func (s *sampler) advance(d time.Duration) {
+	next := s.now + d
+	if s.end > 0 {
+		next = min(next, s.end)
+	}
+	for s.frameTime(len(s.frames)) < next {
+		s.frames = append(s.frames, s.snapshot())
+	}
+	s.now = max(s.now, next)
+}
+
+// finish adds the remaining frames after the run function has returned.
+func (s *sampler) finish() {
+	if s.end > 0 {
+		s.advance(s.end - s.now)
+		return
+	}
+	for s.frameTime(len(s.frames)) <= s.now {
+		s.frames = append(s.frames, s.snapshot())
+	}
+}
+
+// snapshot returns a copy of the rendered canvas, or the previous frame
+// if nothing was rendered since.
+func (s *sampler) snapshot() *image.RGBA {
+	if !s.dirty && len(s.frames) > 0 {
+		return s.frames[len(s.frames)-1]
+	}
+	s.dirty = false
+	src := s.renderer.Image()
+	img := image.NewRGBA(src.Bounds())
+	if s.background != nil {
+		draw.Draw(img, img.Bounds(), image.NewUniform(s.background), image.Point{}, draw.Src)
+	}
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Over)
+	return img
+}
+
+// Clock is the virtual clock of an animation loop. It must only be used
+// by the goroutine of the run function.
+type Clock struct {
+	start   time.Time
+	elapsed time.Duration
+	// ended is set when the clock has reached the end of the animation.
+	ended  bool
+	sleeps chan time.Duration
+	woken  chan struct{}
+}
+
+// Now returns the current time of the virtual clock.
+func (c *Clock) Now() time.Time {
+	return c.start.Add(c.elapsed)
+}
+
+// Since returns the time elapsed on the virtual clock since t.
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep advances the virtual clock by the duration d. The frames of the
+// animation up to the new time show what was flushed before. A negative
+// or zero duration causes Sleep to return immediately.
+//
+// If the clock has already reached the end of the animation, Sleep does
+// not return, but stops the run function like runtime.Goexit: its
+// deferred calls run, and the animation ends.
+func (c *Clock) Sleep(d time.Duration) {
+	if c.ended {
+		runtime.Goexit()
+	}
+	if d <= 0 {
+		return
+	}
+	c.sleeps <- d
+	<-c.woken
+}
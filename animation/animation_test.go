@@ -0,0 +1,284 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fzipp/canvas"
+)
+
+var (
+	red  = color.RGBA{R: 0xff, A: 0xff}
+	blue = color.RGBA{B: 0xff, A: 0xff}
+)
+
+// blink fills the canvas alternately with red and blue, switching every
+// interval, until it receives a CloseEvent.
+func blink(interval time.Duration) func(*canvas.Context, *Clock) {
+	return func(ctx *canvas.Context, clock *Clock) {
+		colors := []color.Color{red, blue}
+		for i := 0; ; i++ {
+			select {
+			case event := <-ctx.Events():
+				if _, ok := event.(canvas.CloseEvent); ok {
+					return
+				}
+			default:
+				ctx.SetFillStyle(colors[i%2])
+				ctx.FillRect(0, 0, 4, 4)
+				ctx.Flush()
+				clock.Sleep(interval)
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	anim := Render(blink(100*time.Millisecond), &Options{
+		Width:     4,
+		Height:    4,
+		FrameRate: 20,
+		Duration:  400 * time.Millisecond,
+	})
+	if anim.FrameRate != 20 {
+		t.Errorf("frame rate: got %g, want 20", anim.FrameRate)
+	}
+	want := []color.RGBA{red, red, blue, blue, red, red, blue, blue}
+	if len(anim.Frames) != len(want) {
+		t.Fatalf("got %d frames, want %d", len(anim.Frames), len(want))
+	}
+	for i, img := range anim.Frames {
+		if got := img.RGBAAt(1, 1); got != want[i] {
+			t.Errorf("frame %d: got color %v, want %v", i, got, want[i])
+		}
+	}
+	if anim.Frames[0] != anim.Frames[1] {
+		t.Errorf("identical consecutive frames are not shared")
+	}
+}
+
+func TestRenderUntilReturn(t *testing.T) {
+	var times []time.Time
+	start := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	anim := Render(func(ctx *canvas.Context, clock *Clock) {
+		for range 3 {
+			times = append(times, clock.Now())
+			ctx.SetFillStyle(red)
+			ctx.FillRect(0, 0, 1, 1)
+			ctx.Flush()
+			clock.Sleep(time.Second)
+		}
+		if got := clock.Since(start); got != 3*time.Second {
+			t.Errorf("Since(start): got %v, want 3s", got)
+		}
+	}, &Options{Width: 1, Height: 1, FrameRate: 2, Start: start})
+
+	for i, tm := range times {
+		if want := start.Add(time.Duration(i) * time.Second); !tm.Equal(want) {
+			t.Errorf("Now() in iteration %d: got %v, want %v", i, tm, want)
+		}
+	}
+	// Frames at 0s, 0.5s, ..., 3s, including the end.
+	if len(anim.Frames) != 7 {
+		t.Errorf("got %d frames, want 7", len(anim.Frames))
+	}
+}
+
+func TestRenderWithoutEvents(t *testing.T) {
+	stopped := false
+	anim := Render(func(ctx *canvas.Context, clock *Clock) {
+		defer func() { stopped = true }()
+		for {
+			ctx.SetFillStyle(red)
+			ctx.FillRect(0, 0, 1, 1)
+			ctx.Flush()
+			clock.Sleep(300 * time.Millisecond)
+		}
+	}, &Options{Width: 1, Height: 1, FrameRate: 10, Duration: time.Second})
+
+	if !stopped {
+		t.Errorf("run function was not stopped")
+	}
+	if len(anim.Frames) != 10 {
+		t.Errorf("got %d frames, want 10", len(anim.Frames))
+	}
+}
+
+func TestRenderHoldsLastFrame(t *testing.T) {
+	anim := Render(func(ctx *canvas.Context, clock *Clock) {
+		ctx.SetFillStyle(red)
+		ctx.FillRect(0, 0, 1, 1)
+		ctx.Flush()
+	}, &Options{Width: 1, Height: 1, FrameRate: 10, Duration: time.Second, Background: color.White})
+
+	if len(anim.Frames) != 10 {
+		t.Fatalf("got %d frames, want 10", len(anim.Frames))
+	}
+	for i, img := range anim.Frames {
+		if got := img.RGBAAt(0, 0); got != red {
+			t.Errorf("frame %d: got color %v, want %v", i, got, red)
+		}
+	}
+}
+
+func TestRenderBackground(t *testing.T) {
+	anim := Render(func(*canvas.Context, *Clock) {}, &Options{
+		Width:      2,
+		Height:     2,
+		Background: blue,
+	})
+	if len(anim.Frames) != 1 {
+		t.Fatalf("got %d frames, want 1", len(anim.Frames))
+	}
+	if got := anim.Frames[0].RGBAAt(0, 0); got != blue {
+		t.Errorf("got color %v, want %v", got, blue)
+	}
+}
+
+func testAnimation() *Animation {
+	return Render(blink(100*time.Millisecond), &Options{
+		Width:     4,
+		Height:    4,
+		FrameRate: 30,
+		Duration:  time.Second,
+	})
+}
+
+func TestEncodeGIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testAnimation().EncodeGIF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 10 {
+		t.Errorf("got %d images, want 10", len(g.Image))
+	}
+	total := 0
+	for _, d := range g.Delay {
+		total += d
+	}
+	if total != 100 {
+		t.Errorf("total delay: got %d, want 100", total)
+	}
+	if got := color.RGBAModel.Convert(g.Image[1].At(1, 1)); got != blue {
+		t.Errorf("second image: got color %v, want %v", got, blue)
+	}
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testAnimation().EncodeAPNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// The default image is the first frame.
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.RGBAModel.Convert(img.At(1, 1)); got != red {
+		t.Errorf("default image: got color %v, want %v", got, red)
+	}
+
+	chunks := map[string]int{}
+	var totalDelay int
+	for p := data[len(pngSignature):]; len(p) >= 12; {
+		n := int(be32ToInt(p))
+		typ := string(p[4:8])
+		chunks[typ]++
+		if typ == "acTL" {
+			if frames := be32ToInt(p[8:]); frames != 10 {
+				t.Errorf("acTL: got %d frames, want 10", frames)
+			}
+		}
+		if typ == "fcTL" {
+			totalDelay += int(p[28])<<8 | int(p[29])
+		}
+		p = p[12+n:]
+	}
+	if chunks["fcTL"] != 10 || chunks["fdAT"] != 9 || chunks["IDAT"] != 1 {
+		t.Errorf("got chunks %v, want 10 fcTL, 9 fdAT and 1 IDAT", chunks)
+	}
+	if totalDelay != 1000 {
+		t.Errorf("total delay: got %d ms, want 1000 ms", totalDelay)
+	}
+}
+
+func be32ToInt(p []byte) int {
+	return int(p[0])<<24 | int(p[1])<<16 | int(p[2])<<8 | int(p[3])
+}
+
+func TestWritePNGs(t *testing.T) {
+	dir := t.TempDir()
+	anim := testAnimation()
+	if err := anim.WritePNGs(filepath.Join(dir, "frame%03d.png")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 30 {
+		t.Fatalf("got %d files, want 30", len(entries))
+	}
+	f, err := os.Open(filepath.Join(dir, "frame003.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.RGBAModel.Convert(img.At(1, 1)); got != blue {
+		t.Errorf("frame 3: got color %v, want %v", got, blue)
+	}
+}
+
+func TestEncodeNoFrames(t *testing.T) {
+	anim := &Animation{FrameRate: 30}
+	if err := anim.EncodeGIF(&bytes.Buffer{}); err != errNoFrames {
+		t.Errorf("EncodeGIF: got error %v, want %v", err, errNoFrames)
+	}
+	if err := anim.EncodeAPNG(&bytes.Buffer{}); err != errNoFrames {
+		t.Errorf("EncodeAPNG: got error %v, want %v", err, errNoFrames)
+	}
+}
+
+func TestCompressImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.SetRGBA(0, 0, red)
+	img.SetRGBA(2, 1, color.RGBA{G: 0x40, A: 0x80})
+	anim := &Animation{Frames: []*image.RGBA{img}, FrameRate: 1}
+	var buf bytes.Buffer
+	if err := anim.EncodeAPNG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := range 2 {
+		for x := range 3 {
+			want := color.NRGBAModel.Convert(img.At(x, y))
+			if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+				t.Errorf("pixel (%d, %d): got %v, want %v", x, y, c, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"io"
+)
+
+// EncodeAPNG writes the animation to w as an animated PNG (APNG) that
+// loops forever. Viewers that do not support APNG show the first frame.
+// Consecutive identical frames are combined into one frame with a longer
+// delay.
+func (a *Animation) EncodeAPNG(w io.Writer) error {
+	if len(a.Frames) == 0 {
+		return errNoFrames
+	}
+	spans := a.spans(1000)
+	e := &apngEncoder{w: w}
+	b := spans[0].img.Bounds()
+	e.write(pngSignature)
+	e.writeChunk("IHDR",
+		be32(uint32(b.Dx())), be32(uint32(b.Dy())),
+		[]byte{
+			8, // bit depth
+			6, // color type: truecolor with alpha
+			0, // compression method
+			0, // filter method
+			0, // interlace method
+		})
+	e.writeChunk("acTL",
+		be32(uint32(len(spans))), // number of frames
+		be32(0),                  // number of plays: forever
+	)
+	for i, s := range spans {
+		e.writeChunk("fcTL",
+			be32(e.nextSeq()),
+			be32(uint32(b.Dx())), be32(uint32(b.Dy())),
+			be32(0), be32(0), // offset
+			be16(uint16(a.delay(s, 1000))), be16(1000),
+			[]byte{
+				0, // dispose op: none
+				0, // blend op: source
+			})
+		data, err := compressImage(s.img)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			e.writeChunk("IDAT", data)
+		} else {
+			e.writeChunk("fdAT", be32(e.nextSeq()), data)
+		}
+	}
+	e.writeChunk("IEND")
+	return e.err
+}
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// apngEncoder writes the chunks of an APNG file. After an error, writing
+// does nothing and the error is kept in err.
+type apngEncoder struct {
+	w   io.Writer
+	err error
+	// seq is the sequence number of the next fcTL or fdAT chunk.
+	seq uint32
+}
+
+func (e *apngEncoder) nextSeq() uint32 {
+	seq := e.seq
+	e.seq++
+	return seq
+}
+
+func (e *apngEncoder) write(s string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = io.WriteString(e.w, s)
+}
+
+// writeChunk writes a chunk with the given type and the concatenation of
+// the given fields as data.
+func (e *apngEncoder) writeChunk(typ string, fields ...[]byte) {
+	if e.err != nil {
+		return
+	}
+	var data []byte
+	for _, f := range fields {
+		data = append(data, f...)
+	}
+	buf := make([]byte, 0, 12+len(data))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	buf = append(buf, typ...)
+	buf = append(buf, data...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	_, e.err = e.w.Write(buf)
+}
+
+func be32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func be16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+// compressImage returns the zlib compressed, filtered scanlines of an
+// image in the PNG truecolor with alpha format.
+func compressImage(img *image.RGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	b := img.Bounds()
+	rowLen := 4 * b.Dx()
+	prev := make([]byte, rowLen)
+	cur := make([]byte, rowLen)
+	filtered := make([]byte, 1+rowLen)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.RGBAAt(x, y)).(color.NRGBA)
+			i := 4 * (x - b.Min.X)
+			cur[i], cur[i+1], cur[i+2], cur[i+3] = c.R, c.G, c.B, c.A
+		}
+		filterRow(filtered, cur, prev)
+		if _, err := zw.Write(filtered); err != nil {
+			return nil, err
+		}
+		prev, cur = cur, prev
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// filterRow writes the filter type and the filtered bytes of a scanline to
+// dst. Like the encoder of package image/png, it chooses the filter with
+// the smallest sum of absolute differences.
+func filterRow(dst, cur, prev []byte) {
+	const bpp = 4
+	best := -1
+	var bestSum int
+	out := make([]byte, len(cur))
+	for ft := range 5 {
+		sum := 0
+		for i, c := range cur {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left = cur[i-bpp]
+				upLeft = prev[i-bpp]
+			}
+			up = prev[i]
+			var p byte
+			switch ft {
+			case 0:
+				p = 0
+			case 1:
+				p = left
+			case 2:
+				p = up
+			case 3:
+				p = byte((int(left) + int(up)) / 2)
+			case 4:
+				p = paeth(left, up, upLeft)
+			}
+			d := c - p
+			out[i] = d
+			sum += abs(int(int8(d)))
+		}
+		if best < 0 || sum < bestSum {
+			best, bestSum = ft, sum
+			dst[0] = byte(ft)
+			copy(dst[1:], out)
+		}
+	}
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa := abs(p - int(a))
+	pb := abs(p - int(b))
+	pc := abs(p - int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package animation
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+)
+
+var errNoFrames = errors.New("animation: no frames")
+
+// EncodeGIF writes the animation to w as an animated GIF that loops
+// forever. The colors are reduced to the Plan 9 palette with
+// Floyd-Steinberg dithering. GIF has no partial transparency, so frames
+// that are not opaque are drawn on white. Consecutive identical frames
+// are combined into one frame with a longer delay.
+func (a *Animation) EncodeGIF(w io.Writer) error {
+	if len(a.Frames) == 0 {
+		return errNoFrames
+	}
+	g := &gif.GIF{}
+	for _, s := range a.spans(100) {
+		g.Image = append(g.Image, paletted(s.img))
+		g.Delay = append(g.Delay, a.delay(s, 100))
+	}
+	return gif.EncodeAll(w, g)
+}
+
+func paletted(img *image.RGBA) *image.Paletted {
+	b := img.Bounds()
+	var src image.Image = img
+	if !img.Opaque() {
+		bg := image.NewRGBA(b)
+		draw.Draw(bg, b, image.White, image.Point{}, draw.Src)
+		draw.Draw(bg, b, img, b.Min, draw.Over)
+		src = bg
+	}
+	p := image.NewPaletted(b, palette.Plan9)
+	draw.FloydSteinberg.Draw(p, b, src, b.Min)
+	return p
+}
+
+// WritePNGs writes each frame of the animation to a PNG file. The file
+// names are created by formatting the frame index, starting at 0, with
+// the pattern, for example "frame%05d.png" for "frame00000.png",
+// "frame00001.png", and so on. This is the file name pattern of the
+// image sequence input of tools like ffmpeg:
+//
+//	ffmpeg -framerate 30 -i frame%05d.png preview.mp4
+func (a *Animation) WritePNGs(pattern string) error {
+	for i, img := range a.Frames {
+		if err := writePNG(fmt.Sprintf(pattern, i), img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// A span is a sequence of consecutive frames with the same image.
+type span struct {
+	img        *image.RGBA
+	start, end int
+}
+
+// spans groups consecutive identical frames. The delay of a span, in
+// units of 1/unit seconds, must fit into an uint16.
+func (a *Animation) spans(unit int) []span {
+	maxLen := max(int(math.MaxUint16*a.FrameRate/float64(unit))-1, 1)
+	var spans []span
+	for i, img := range a.Frames {
+		if n := len(spans); n > 0 && spans[n-1].img == img && spans[n-1].end-spans[n-1].start < maxLen {
+			spans[n-1].end = i + 1
+			continue
+		}
+		spans = append(spans, span{img: img, start: i, end: i + 1})
+	}
+	return spans
+}
+
+// delay returns the display duration of a span in units of 1/unit
+// seconds. The durations are rounded so that rounding errors do not
+// accumulate over the frames.
+func (a *Animation) delay(s span, unit int) int {
+	at := func(i int) int {
+		return int(math.Round(float64(i) * float64(unit) / a.FrameRate))
+	}
+	return at(s.end) - at(s.start)
+}
@@ -7,6 +7,7 @@ package canvas
 import (
 	"image"
 	"image/color"
+	"math"
 	"testing"
 )
 
@@ -84,3 +85,62 @@ func BenchmarkContext(b *testing.B) {
 	}
 	close(draws)
 }
+
+// BenchmarkContextFlush measures drawing and flushing a typical animation
+// frame to a connection that recycles the frame buffers, in a steady
+// state without allocations.
+func BenchmarkContextFlush(b *testing.B) {
+	ctx, written := newPooledContext()
+	drawAnimationFrame(ctx)
+	ctx.Flush()
+	<-written
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		drawAnimationFrame(ctx)
+		ctx.Flush()
+		<-written
+	}
+	b.StopTimer()
+	close(ctx.draws)
+}
+
+// newPooledContext returns a Context with a frame pool, like a Context
+// that is connected to a client. A value is sent to the written channel
+// after each flushed frame was returned to the pool.
+func newPooledContext() (*Context, <-chan struct{}) {
+	draws := make(chan []byte)
+	written := make(chan struct{})
+	frames := newFramePool()
+	go func() {
+		for frame := range draws {
+			frames.put(frame)
+			written <- struct{}{}
+		}
+	}()
+	ctx := newContext(draws, nil, &Options{})
+	ctx.frames = frames
+	return ctx, written
+}
+
+func drawAnimationFrame(ctx *Context) {
+	ctx.ClearRect(0, 0, 800, 600)
+	ctx.SetFillStyle(color.RGBA{R: 0xf3, G: 0x5d, B: 0x4f, A: 0xff})
+	ctx.SetStrokeStyleString("#6ddaf1")
+	ctx.SetLineWidth(0.5)
+	ctx.SetFont("bold 12px sans-serif")
+	for i := range 100 {
+		x := float64(i * 8)
+		ctx.BeginPath()
+		ctx.Arc(x, 300, 4, 0, 2*math.Pi, false)
+		ctx.Fill()
+		ctx.BeginPath()
+		ctx.MoveTo(x, 0)
+		ctx.LineTo(x, 600)
+		ctx.Stroke()
+	}
+	ctx.Save()
+	ctx.Translate(10, 10)
+	ctx.FillText("score: 42", 0, 0)
+	ctx.Restore()
+}
@@ -13,6 +13,9 @@ import (
 type buffer struct {
 	bytes []byte
 	error error
+	// compact selects the compact encoding of numbers, see
+	// EncodingCompact. It only affects the add methods.
+	compact bool
 }
 
 var byteOrder = binary.BigEndian
@@ -22,11 +25,20 @@ func (buf *buffer) addByte(b byte) {
 }
 
 func (buf *buffer) addFloat64(f float64) {
+	if buf.compact {
+		buf.bytes = byteOrder.AppendUint32(buf.bytes, math.Float32bits(float32(f)))
+		return
+	}
 	buf.bytes = append(buf.bytes, 0, 0, 0, 0, 0, 0, 0, 0)
 	byteOrder.PutUint64(buf.bytes[len(buf.bytes)-8:], math.Float64bits(f))
 }
 
+// addUint32 adds an ID or a length.
 func (buf *buffer) addUint32(i uint32) {
+	if buf.compact {
+		buf.bytes = binary.AppendUvarint(buf.bytes, uint64(i))
+		return
+	}
 	buf.bytes = append(buf.bytes, 0, 0, 0, 0)
 	byteOrder.PutUint32(buf.bytes[len(buf.bytes)-4:], i)
 }
@@ -101,6 +113,17 @@ func (buf *buffer) readString() string {
 	return s
 }
 
+func (buf *buffer) readBytes(length int) []byte {
+	if len(buf.bytes) < length {
+		buf.dataTooShort()
+		return nil
+	}
+	p := make([]byte, length)
+	copy(p, buf.bytes)
+	buf.bytes = buf.bytes[length:]
+	return p
+}
+
 func (buf *buffer) reset() {
 	buf.bytes = make([]byte, 0, cap(buf.bytes))
 }
@@ -115,3 +138,41 @@ type errDataTooShort struct{}
 func (err errDataTooShort) Error() string {
 	return "data too short"
 }
+
+// framePool recycles the buffers of flushed frames. The connection returns
+// a frame's buffer to the pool after the frame was written, and the
+// Context takes it for the frame after next. This double buffering lets a
+// Context flush frames without allocating, once the buffers have grown to
+// the size of the frames.
+type framePool struct {
+	free chan []byte
+}
+
+func newFramePool() *framePool {
+	return &framePool{free: make(chan []byte, 2)}
+}
+
+// get returns a recycled empty buffer, or a new one with the given
+// capacity if none is available. A nil pool always returns a new buffer.
+func (p *framePool) get(capacity int) []byte {
+	if p != nil {
+		select {
+		case b := <-p.free:
+			return b[:0]
+		default:
+		}
+	}
+	return make([]byte, 0, capacity)
+}
+
+// put returns the buffer of a frame that is no longer used to the pool.
+// The buffer is dropped if the pool is full or nil.
+func (p *framePool) put(b []byte) {
+	if p == nil {
+		return
+	}
+	select {
+	case p.free <- b:
+	default:
+	}
+}
@@ -53,6 +53,34 @@ func TestBufferWrite(t *testing.T) {
 				0x00, 0x12, 0x4f, 0x80,
 			},
 		},
+		{
+			"addFloat64 compact",
+			func(buf *buffer) {
+				buf.compact = true
+				buf.addFloat64(3.1415)
+				buf.addFloat64(362.5)
+			},
+			[]byte{
+				0x40, 0x49, 0x0e, 0x56,
+				0x43, 0xb5, 0x40, 0x00,
+			},
+		},
+		{
+			"addUint32 compact",
+			func(buf *buffer) {
+				buf.compact = true
+				buf.addUint32(12)
+				buf.addUint32(4096)
+				buf.addUint32(60000)
+				buf.addUint32(1200000)
+			},
+			[]byte{
+				0x0c,
+				0x80, 0x20,
+				0xe0, 0xd4, 0x03,
+				0x80, 0x9f, 0x49,
+			},
+		},
 		{
 			"addBool",
 			func(buf *buffer) {
@@ -83,6 +111,17 @@ func TestBufferWrite(t *testing.T) {
 				0xc3, 0xa4, 0xc3, 0xb6, 0xc3, 0xbc,
 			},
 		},
+		{
+			"addString compact",
+			func(buf *buffer) {
+				buf.compact = true
+				buf.addString("hello")
+			},
+			[]byte{
+				0x05, // len(s)
+				0x68, 0x65, 0x6c, 0x6c, 0x6f,
+			},
+		},
 		{
 			"addColor",
 			func(buf *buffer) {
@@ -171,6 +210,15 @@ func TestBufferRead(t *testing.T) {
 			"Test",
 			[]byte{0x42},
 		},
+		{
+			"readBytes",
+			[]byte{0x01, 0x02, 0x03, 0x04},
+			func(buf *buffer) any {
+				return buf.readBytes(3)
+			},
+			[]byte{0x01, 0x02, 0x03},
+			[]byte{0x04},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -244,6 +292,14 @@ func TestBufferReadErrors(t *testing.T) {
 			},
 			"",
 		},
+		{
+			"readBytes",
+			[]byte{0x01, 0x02},
+			func(buf *buffer) any {
+				return buf.readBytes(3)
+			},
+			[]byte(nil),
+		},
 	}
 	wantErrorMessage := "data too short"
 	for _, tt := range tests {
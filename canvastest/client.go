@@ -0,0 +1,431 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvastest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/fzipp/canvas/internal/protocol"
+	"github.com/fzipp/canvas/raster"
+	"github.com/gorilla/websocket"
+)
+
+// msgSnapshot is the type of the message with which the client replies to
+// a snapshot request.
+const msgSnapshot = protocol.MsgSnapshot
+
+// The handshake message that the client sends first after it connected,
+// with the protocol version and the capability flags of the JavaScript
+// client. The client supports the compact encoding.
+const (
+	msgHello           = protocol.MsgHello
+	protocolVersion    = protocol.Version
+	capCompactEncoding = protocol.CapCompactEncoding
+)
+
+// ErrClosed is returned by the methods of a Client after the connection
+// to the server was closed.
+var ErrClosed = errors.New("canvastest: connection closed")
+
+// Client is a headless client for a canvas server, for end-to-end tests
+// without a web browser. Like the JavaScript client in a web browser, it
+// loads the HTML page of the server, reads the canvas configuration from
+// it, and connects to the draw endpoint via a WebSocket:
+//
+//	srv := httptest.NewServer(canvas.NewServeMux(run, opts))
+//	defer srv.Close()
+//	client, err := canvastest.Dial(srv.URL)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer client.Close()
+//	err = client.SendEvent(canvas.MouseDownEvent{MouseEvent: canvas.MouseEvent{X: 10, Y: 20}})
+//	frames, err := client.WaitFrames(2)
+//
+// The client records the frames it receives, and renders them with the
+// software renderer of package raster. It replies to the snapshot requests
+// of canvas.Context.Snapshot with the rendered image encoded as PNG.
+// Like a web browser, it loads the images of canvas.Context.LoadImage
+// via HTTP, relative to the URL of the page.
+type Client struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	pageURL     *url.URL
+	eventMask   uint64
+	maxFileSize int64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	frames   [][]byte
+	renderer *raster.Renderer
+	// err is set when the connection is closed or a frame cannot be
+	// decoded.
+	err error
+}
+
+// Dial loads the HTML page of the canvas server at the given URL, for
+// example the URL of an httptest.Server, and connects to its draw
+// endpoint.
+func Dial(serverURL string) (*Client, error) {
+	page, err := loadPage(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(page.drawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	var hello encoder
+	hello.byte(msgHello)
+	hello.uint32(protocolVersion)
+	hello.uint32(capCompactEncoding)
+	if err := conn.WriteMessage(websocket.BinaryMessage, hello.p); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c := &Client{
+		conn:        conn,
+		pageURL:     page.url,
+		eventMask:   page.eventMask,
+		maxFileSize: page.maxFileSize,
+		renderer:    raster.NewRenderer(page.width, page.height),
+	}
+	c.cond = sync.NewCond(&c.mu)
+	go c.readMessages()
+	return c, nil
+}
+
+// page is the canvas configuration of the HTML page of a canvas server.
+type page struct {
+	url           *url.URL
+	drawURL       string
+	width, height int
+	eventMask     uint64
+	maxFileSize   int64
+}
+
+var canvasAttrPattern = regexp.MustCompile(`([a-z-]+)="([^"]*)"`)
+
+func loadPage(serverURL string) (*page, error) {
+	resp, err := http.Get(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("canvastest: loading page: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	start := bytes.Index(body, []byte("<canvas "))
+	if start < 0 {
+		return nil, errors.New("canvastest: page has no canvas")
+	}
+	end := bytes.IndexByte(body[start:], '>')
+	if end < 0 {
+		return nil, errors.New("canvastest: page has no canvas")
+	}
+	attrs := make(map[string]string)
+	for _, m := range canvasAttrPattern.FindAllSubmatch(body[start:start+end], -1) {
+		attrs[string(m[1])] = html.UnescapeString(string(m[2]))
+	}
+
+	base, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	drawURL, err := base.Parse(attrs["data-websocket-draw-url"])
+	if err != nil {
+		return nil, err
+	}
+	switch drawURL.Scheme {
+	case "http":
+		drawURL.Scheme = "ws"
+	case "https":
+		drawURL.Scheme = "wss"
+	}
+	p := &page{url: base, drawURL: drawURL.String()}
+	p.width, _ = strconv.Atoi(attrs["width"])
+	p.height, _ = strconv.Atoi(attrs["height"])
+	p.eventMask, _ = strconv.ParseUint(attrs["data-websocket-event-mask"], 10, 64)
+	p.maxFileSize, _ = strconv.ParseInt(attrs["data-websocket-max-file-size"], 10, 64)
+	return p, nil
+}
+
+// SendEvent sends the given events to the server, encoded like the
+// JavaScript client encodes them. Like a web browser, the client only
+// sends events that are enabled with canvas.Options.EnabledEvents, and it
+// omits the data of files once their total size would exceed
+// canvas.Options.MaxFileSize.
+// SendEvent returns an error if an event is not enabled. Sending a
+// canvas.CloseEvent closes the connection, see Close.
+func (c *Client) SendEvent(events ...canvas.Event) error {
+	for _, event := range events {
+		if _, ok := event.(canvas.CloseEvent); ok {
+			return c.Close()
+		}
+		p, err := encodeEvent(c.withoutLargeFiles(event))
+		if err != nil {
+			return err
+		}
+		if c.eventMask&(1<<(p[0]-1)) == 0 {
+			return fmt.Errorf("canvastest: event %T is not enabled", event)
+		}
+		if err := c.write(websocket.BinaryMessage, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) withoutLargeFiles(event canvas.Event) canvas.Event {
+	strip := func(files []canvas.File) []canvas.File {
+		stripped := make([]canvas.File, len(files))
+		budget := c.maxFileSize
+		for i, f := range files {
+			if int64(len(f.Data)) > budget {
+				f.Data = nil
+			}
+			budget -= int64(len(f.Data))
+			stripped[i] = f
+		}
+		return stripped
+	}
+	switch e := event.(type) {
+	case canvas.DropEvent:
+		e.Files = strip(e.Files)
+		return e
+	case canvas.PasteEvent:
+		e.Files = strip(e.Files)
+		return e
+	}
+	return event
+}
+
+// Close closes the connection to the server, like closing the browser
+// tab does. The server delivers a canvas.CloseEvent to the run function.
+func (c *Client) Close() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	err := c.write(websocket.CloseMessage, msg)
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Frames returns the decoded frames received so far, in the order in
+// which they were received.
+//
+// Frames panics if a frame cannot be decoded, which indicates a bug in
+// package canvas.
+func (c *Client) Frames() []Frame {
+	return decodeFrames(c.RawFrames())
+}
+
+// RawFrames returns the frames received so far in the binary draw command
+// format, in the order in which they were received.
+func (c *Client) RawFrames() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]byte(nil), c.frames...)
+}
+
+// WaitFrames waits until at least n frames have been received, and
+// returns the decoded frames received so far. It returns ErrClosed if the
+// connection is closed before.
+func (c *Client) WaitFrames(n int) ([]Frame, error) {
+	c.mu.Lock()
+	for len(c.frames) < n && c.err == nil {
+		c.cond.Wait()
+	}
+	frames := append([][]byte(nil), c.frames...)
+	var err error
+	if len(frames) < n {
+		err = c.err
+	}
+	c.mu.Unlock()
+	return decodeFrames(frames), err
+}
+
+// Reset discards the frames received so far. The rendered image is kept.
+func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.frames = nil
+}
+
+// Image returns a copy of the canvas rendered from the frames received so
+// far.
+func (c *Client) Image() *image.RGBA {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img := c.renderer.Image()
+	cp := image.NewRGBA(img.Bounds())
+	copy(cp.Pix, img.Pix)
+	return cp
+}
+
+func (c *Client) write(messageType int, p []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	err := c.conn.WriteMessage(messageType, p)
+	if errors.Is(err, websocket.ErrCloseSent) {
+		return ErrClosed
+	}
+	return err
+}
+
+func (c *Client) readMessages() {
+	for {
+		messageType, p, err := c.conn.ReadMessage()
+		if err != nil {
+			c.stop(ErrClosed)
+			return
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		if err := c.receiveFrame(p); err != nil {
+			c.stop(err)
+			_ = c.conn.Close()
+			return
+		}
+	}
+}
+
+// receiveFrame records and renders a frame, and replies to the snapshot
+// requests and encoded images in it. The images loaded by URL are passed
+// to the renderer as encoded images.
+func (c *Client) receiveFrame(p []byte) error {
+	cmds, err := command.Decode(p)
+	if err != nil {
+		return fmt.Errorf("canvastest: frame %d: %w", len(c.RawFrames()), err)
+	}
+	var replies [][]byte
+	for i, cmd := range cmds {
+		if cmd, ok := cmd.(command.LoadImage); ok {
+			var reply []byte
+			cmds[i], reply = c.loadImage(cmd)
+			replies = append(replies, reply)
+		}
+	}
+	c.mu.Lock()
+	for _, cmd := range cmds {
+		c.renderer.Render(cmd)
+		switch cmd := cmd.(type) {
+		case command.Snapshot:
+			replies = append(replies, snapshotReply(cmd.ID, c.renderer.Image()))
+		case command.CreateImageFromEncoded:
+			replies = append(replies, imageReadyEvent(cmd))
+		}
+	}
+	c.frames = append(c.frames, p)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	for _, reply := range replies {
+		if err := c.write(websocket.BinaryMessage, reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.cond.Broadcast()
+}
+
+// imageReadyEvent encodes the event with which a web browser reports that
+// it decoded an encoded image. Images in formats that are not registered
+// with the image package are reported as failed.
+func imageReadyEvent(c command.CreateImageFromEncoded) []byte {
+	ev := canvas.ImageReadyEvent{ImageID: c.ID}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(c.Data))
+	if err != nil {
+		ev.Err = err.Error()
+	} else {
+		ev.Width, ev.Height = cfg.Width, cfg.Height
+	}
+	p, _ := encodeEvent(ev)
+	return p
+}
+
+// loadImage loads the image of a LoadImage command. It returns the command
+// that creates the loaded image, or the LoadImage command itself if the
+// image could not be loaded, and the event with which a web browser
+// reports the result.
+func (c *Client) loadImage(cmd command.LoadImage) (command.Command, []byte) {
+	img, cfg, err := c.fetchImage(cmd)
+	if err != nil {
+		p, _ := encodeEvent(canvas.ImageErrorEvent{ImageID: cmd.ID, Err: err.Error()})
+		return cmd, p
+	}
+	p, _ := encodeEvent(canvas.ImageLoadedEvent{ImageID: cmd.ID, Width: cfg.Width, Height: cfg.Height})
+	return img, p
+}
+
+// fetchImage loads the image of a LoadImage command via HTTP. Images in
+// formats that are not registered with the image package are reported as
+// failed.
+func (c *Client) fetchImage(cmd command.LoadImage) (command.CreateImageFromEncoded, image.Config, error) {
+	img := command.CreateImageFromEncoded{ID: cmd.ID}
+	u, err := c.pageURL.Parse(cmd.URL)
+	if err != nil {
+		return img, image.Config{}, err
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return img, image.Config{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return img, image.Config{}, fmt.Errorf("loading %s: %s", u, resp.Status)
+	}
+	img.MimeType = resp.Header.Get("Content-Type")
+	img.Data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return img, image.Config{}, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	return img, cfg, err
+}
+
+// snapshotReply encodes the reply to a snapshot request with the image
+// encoded as PNG, like a web browser that does not support the requested
+// format.
+func snapshotReply(id uint32, img image.Image) []byte {
+	var buf bytes.Buffer
+	var e encoder
+	e.byte(msgSnapshot)
+	e.uint32(id)
+	if err := png.Encode(&buf, img); err != nil {
+		e.bool(false)
+		e.string(err.Error())
+		return e.p
+	}
+	e.bool(true)
+	e.bytes(buf.Bytes())
+	return e.p
+}
@@ -0,0 +1,318 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvastest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/google/go-cmp/cmp"
+)
+
+// allEvents are events of every type that a client can send.
+var allEvents = []canvas.Event{
+	canvas.MouseMoveEvent{MouseEvent: canvas.MouseEvent{Buttons: canvas.ButtonPrimary, X: 1, Y: 2, Mod: 1}},
+	canvas.MouseDownEvent{MouseEvent: canvas.MouseEvent{X: 3, Y: 4}},
+	canvas.MouseUpEvent{MouseEvent: canvas.MouseEvent{X: 5, Y: 6}},
+	canvas.KeyDownEvent{KeyboardEvent: canvas.KeyboardEvent{Key: "a", Code: "KeyA", Location: 1, Repeat: true, Mod: 2}},
+	canvas.KeyUpEvent{KeyboardEvent: canvas.KeyboardEvent{Key: "Enter", Code: "Enter", IsComposing: true}},
+	canvas.ClickEvent{MouseEvent: canvas.MouseEvent{X: 7, Y: 8}},
+	canvas.DblClickEvent{MouseEvent: canvas.MouseEvent{X: 9, Y: 10}},
+	canvas.AuxClickEvent{MouseEvent: canvas.MouseEvent{Buttons: canvas.ButtonAuxiliary}},
+	canvas.WheelEvent{MouseEvent: canvas.MouseEvent{X: 11, Y: 12}, DeltaX: 1.5, DeltaY: -2, DeltaZ: 0, DeltaMode: 1},
+	canvas.TouchStartEvent{TouchEvent: canvas.TouchEvent{
+		Touches:        canvas.TouchList{{Identifier: 1, X: 13, Y: 14}, {Identifier: 2, X: 15, Y: 16}},
+		ChangedTouches: canvas.TouchList{{Identifier: 2, X: 15, Y: 16}},
+		TargetTouches:  canvas.TouchList{},
+		Mod:            4,
+	}},
+	canvas.TouchMoveEvent{TouchEvent: canvas.TouchEvent{Touches: canvas.TouchList{}, ChangedTouches: canvas.TouchList{}, TargetTouches: canvas.TouchList{}}},
+	canvas.TouchEndEvent{TouchEvent: canvas.TouchEvent{Touches: canvas.TouchList{}, ChangedTouches: canvas.TouchList{}, TargetTouches: canvas.TouchList{}}},
+	canvas.TouchCancelEvent{TouchEvent: canvas.TouchEvent{Touches: canvas.TouchList{}, ChangedTouches: canvas.TouchList{}, TargetTouches: canvas.TouchList{}}},
+	canvas.TextInputEvent{Data: "héllo"},
+	canvas.CompositionStartEvent{},
+	canvas.CompositionUpdateEvent{CompositionEvent: canvas.CompositionEvent{Data: "に"}},
+	canvas.CompositionEndEvent{CompositionEvent: canvas.CompositionEvent{Data: "日本"}},
+	canvas.FocusEvent{},
+	canvas.BlurEvent{},
+	canvas.VisibilityChangeEvent{Hidden: true},
+	canvas.MouseEnterEvent{MouseEvent: canvas.MouseEvent{X: 17, Y: 18}},
+	canvas.MouseLeaveEvent{MouseEvent: canvas.MouseEvent{X: 19, Y: 20}},
+	canvas.GamepadConnectedEvent{GamepadEvent: canvas.GamepadEvent{
+		Index:   1,
+		ID:      "Xbox 360 Controller",
+		Mapping: "standard",
+		Buttons: []canvas.GamepadButton{{Pressed: true, Value: 1}, {Value: 0.25}},
+		Axes:    []float64{-0.5, 0.75},
+	}},
+	canvas.GamepadDisconnectedEvent{GamepadEvent: canvas.GamepadEvent{Buttons: []canvas.GamepadButton{}, Axes: []float64{}}},
+	canvas.GamepadStateEvent{GamepadEvent: canvas.GamepadEvent{Buttons: []canvas.GamepadButton{}, Axes: []float64{0}}},
+	canvas.DropEvent{
+		MouseEvent: canvas.MouseEvent{X: 21, Y: 22},
+		Files:      []canvas.File{{Name: "a.txt", Type: "text/plain", Size: 3, Data: []byte("abc")}},
+	},
+	canvas.PasteEvent{Text: "pasted", Files: []canvas.File{}},
+}
+
+// echoServer starts a server whose run function passes the received
+// events to the returned channel, and draws a rectangle for each received
+// MouseDownEvent.
+func echoServer(t *testing.T, opts *canvas.Options) (*httptest.Server, <-chan canvas.Event) {
+	t.Helper()
+	received := make(chan canvas.Event, len(allEvents)+1)
+	srv := httptest.NewServer(canvas.NewServeMux(func(ctx *canvas.Context) {
+		ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+		ctx.Flush()
+		for event := range ctx.Events() {
+			received <- event
+			switch e := event.(type) {
+			case canvas.MouseDownEvent:
+				ctx.FillRect(float64(e.X), float64(e.Y), 2, 2)
+				ctx.Flush()
+			case canvas.CloseEvent:
+				return
+			}
+		}
+	}, opts))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func TestClientEvents(t *testing.T) {
+	opts := &canvas.Options{EnabledEvents: allEvents}
+	srv, received := echoServer(t, opts)
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SendEvent(allEvents...); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []canvas.Event
+	for event := range received {
+		got = append(got, event)
+		if _, ok := event.(canvas.CloseEvent); ok {
+			break
+		}
+	}
+	want := append(append([]canvas.Event(nil), allEvents...), canvas.CloseEvent{})
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestClientFrames(t *testing.T) {
+	opts := &canvas.Options{
+		Width:         10,
+		Height:        10,
+		EnabledEvents: []canvas.Event{canvas.MouseDownEvent{}},
+		Encoding:      canvas.EncodingCompact,
+	}
+	srv, _ := echoServer(t, opts)
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.WaitFrames(1); err != nil {
+		t.Fatal(err)
+	}
+	err = client.SendEvent(canvas.MouseDownEvent{MouseEvent: canvas.MouseEvent{X: 4, Y: 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := client.WaitFrames(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Frame{
+		{command.SetFillStyle{Color: color.RGBA{R: 0xff, A: 0xff}}},
+		{command.FillRect{X: 4, Y: 6, Width: 2, Height: 2}},
+	}
+	if diff := cmp.Diff(want, frames); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+
+	img := client.Image()
+	if img.Bounds().Dx() != 10 || img.Bounds().Dy() != 10 {
+		t.Errorf("got image size %v, want 10x10", img.Bounds().Size())
+	}
+	if got := img.RGBAAt(5, 7); got != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("got pixel color %v, want red", got)
+	}
+	if got := img.RGBAAt(1, 1); got != (color.RGBA{}) {
+		t.Errorf("got pixel color %v, want transparent", got)
+	}
+
+	client.Reset()
+	if got := client.Frames(); len(got) != 0 {
+		t.Errorf("got %d frames after Reset, want 0", len(got))
+	}
+}
+
+func TestClientEventNotEnabled(t *testing.T) {
+	srv, _ := echoServer(t, &canvas.Options{EnabledEvents: []canvas.Event{canvas.MouseDownEvent{}}})
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.SendEvent(canvas.MouseUpEvent{}); err == nil {
+		t.Errorf("expected error for event that is not enabled")
+	}
+}
+
+func TestClientLargeFiles(t *testing.T) {
+	opts := &canvas.Options{
+		EnabledEvents: []canvas.Event{canvas.PasteEvent{}},
+		MaxFileSize:   2,
+	}
+	srv, received := echoServer(t, opts)
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	err = client.SendEvent(canvas.PasteEvent{Files: []canvas.File{
+		{Name: "small", Size: 2, Data: []byte("ab")},
+		{Name: "large", Size: 3, Data: []byte("abc")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := canvas.PasteEvent{Files: []canvas.File{
+		{Name: "small", Size: 2, Data: []byte("ab")},
+		{Name: "large", Size: 3},
+	}}
+	if diff := cmp.Diff(want, <-received); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestClientSnapshot(t *testing.T) {
+	snapshots := make(chan []byte, 1)
+	srv := httptest.NewServer(canvas.NewServeMux(func(ctx *canvas.Context) {
+		ctx.SetFillStyle(color.RGBA{B: 0xff, A: 0xff})
+		ctx.FillRect(0, 0, 4, 4)
+		data, err := ctx.Snapshot("image/webp", 0.9)
+		if err != nil {
+			t.Error(err)
+		}
+		snapshots <- data
+	}, &canvas.Options{Width: 4, Height: 4}))
+	defer srv.Close()
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	img, err := png.Decode(bytes.NewReader(<-snapshots))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.RGBAModel.Convert(img.At(2, 2)); got != (color.RGBA{B: 0xff, A: 0xff}) {
+		t.Errorf("got pixel color %v, want blue", got)
+	}
+}
+
+func TestClientImageReady(t *testing.T) {
+	var buf bytes.Buffer
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(1, 1, color.RGBA{G: 0xff, A: 0xff})
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan canvas.Event, 2)
+	srv := httptest.NewServer(canvas.NewServeMux(func(ctx *canvas.Context) {
+		img := ctx.CreateImageFromEncoded(buf.Bytes(), "image/png")
+		ctx.CreateImageFromEncoded([]byte("not an image"), "image/webp")
+		ctx.DrawImage(img, 0, 0)
+		ctx.Flush()
+		ready <- <-ctx.Events()
+		ready <- <-ctx.Events()
+	}, &canvas.Options{Width: 4, Height: 4}))
+	defer srv.Close()
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	got := []canvas.Event{<-ready, <-ready}
+	want := []canvas.Event{
+		canvas.ImageReadyEvent{ImageID: 0, Width: 2, Height: 2},
+		canvas.ImageReadyEvent{ImageID: 1, Err: image.ErrFormat.Error()},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("events mismatch (-want, +got)\n%s", diff)
+	}
+	if got := client.Image().RGBAAt(1, 1); got != (color.RGBA{G: 0xff, A: 0xff}) {
+		t.Errorf("got pixel color %v, want green", got)
+	}
+}
+
+func TestClientLoadImage(t *testing.T) {
+	var buf bytes.Buffer
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(1, 1, color.RGBA{R: 0xff, A: 0xff})
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	static := fstest.MapFS{"sprites.png": {Data: buf.Bytes()}}
+	loaded := make(chan canvas.Event, 2)
+	srv := httptest.NewServer(canvas.NewServeMux(func(ctx *canvas.Context) {
+		img := ctx.LoadImage("static/sprites.png")
+		ctx.LoadImage("static/missing.png")
+		ctx.DrawImage(img, 0, 0)
+		ctx.Flush()
+		loaded <- <-ctx.Events()
+		loaded <- <-ctx.Events()
+	}, &canvas.Options{Width: 4, Height: 4, StaticFiles: static}))
+	defer srv.Close()
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	got := []canvas.Event{<-loaded, <-loaded}
+	want := []canvas.Event{
+		canvas.ImageLoadedEvent{ImageID: 0, Width: 3, Height: 2},
+		canvas.ImageErrorEvent{ImageID: 1, Err: "loading " + srv.URL + "/static/missing.png: 404 Not Found"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("events mismatch (-want, +got)\n%s", diff)
+	}
+	if got := client.Image().RGBAAt(1, 1); got != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("got pixel color %v, want red", got)
+	}
+}
+
+func TestClientWaitFramesClosed(t *testing.T) {
+	srv, _ := echoServer(t, nil)
+	client, err := Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.WaitFrames(100); err != ErrClosed {
+		t.Errorf("got error %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvastest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/internal/protocol"
+)
+
+// Event types of the binary event format, see package protocol. The event
+// mask of a page has the bit 1<<(type-1) set for each enabled event type.
+const (
+	evMouseMove           = protocol.EventMouseMove
+	evMouseDown           = protocol.EventMouseDown
+	evMouseUp             = protocol.EventMouseUp
+	evKeyDown             = protocol.EventKeyDown
+	evKeyUp               = protocol.EventKeyUp
+	evClick               = protocol.EventClick
+	evDblClick            = protocol.EventDblClick
+	evAuxClick            = protocol.EventAuxClick
+	evWheel               = protocol.EventWheel
+	evTouchStart          = protocol.EventTouchStart
+	evTouchMove           = protocol.EventTouchMove
+	evTouchEnd            = protocol.EventTouchEnd
+	evTouchCancel         = protocol.EventTouchCancel
+	evTextInput           = protocol.EventTextInput
+	evCompositionStart    = protocol.EventCompositionStart
+	evCompositionUpdate   = protocol.EventCompositionUpdate
+	evCompositionEnd      = protocol.EventCompositionEnd
+	evFocus               = protocol.EventFocus
+	evBlur                = protocol.EventBlur
+	evVisibilityChange    = protocol.EventVisibilityChange
+	evMouseEnter          = protocol.EventMouseEnter
+	evMouseLeave          = protocol.EventMouseLeave
+	evGamepadConnected    = protocol.EventGamepadConnected
+	evGamepadDisconnected = protocol.EventGamepadDisconnected
+	evGamepadState        = protocol.EventGamepadState
+	evDrop                = protocol.EventDrop
+	evPaste               = protocol.EventPaste
+	evImageReady          = protocol.EventImageReady
+	evImageLoaded         = protocol.EventImageLoaded
+	evImageError          = protocol.EventImageError
+)
+
+const (
+	keyFlagRepeat byte = 1 << iota
+	keyFlagIsComposing
+)
+
+// encodeEvent encodes an event in the binary format in which the
+// JavaScript client sends it to the server.
+func encodeEvent(event canvas.Event) ([]byte, error) {
+	var e encoder
+	switch ev := event.(type) {
+	case canvas.MouseMoveEvent:
+		e.mouse(evMouseMove, ev.MouseEvent)
+	case canvas.MouseDownEvent:
+		e.mouse(evMouseDown, ev.MouseEvent)
+	case canvas.MouseUpEvent:
+		e.mouse(evMouseUp, ev.MouseEvent)
+	case canvas.ClickEvent:
+		e.mouse(evClick, ev.MouseEvent)
+	case canvas.DblClickEvent:
+		e.mouse(evDblClick, ev.MouseEvent)
+	case canvas.AuxClickEvent:
+		e.mouse(evAuxClick, ev.MouseEvent)
+	case canvas.MouseEnterEvent:
+		e.mouse(evMouseEnter, ev.MouseEvent)
+	case canvas.MouseLeaveEvent:
+		e.mouse(evMouseLeave, ev.MouseEvent)
+	case canvas.WheelEvent:
+		e.mouse(evWheel, ev.MouseEvent)
+		e.float64(ev.DeltaX)
+		e.float64(ev.DeltaY)
+		e.float64(ev.DeltaZ)
+		e.byte(byte(ev.DeltaMode))
+	case canvas.KeyDownEvent:
+		e.keyboard(evKeyDown, ev.KeyboardEvent)
+	case canvas.KeyUpEvent:
+		e.keyboard(evKeyUp, ev.KeyboardEvent)
+	case canvas.TouchStartEvent:
+		e.touch(evTouchStart, ev.TouchEvent)
+	case canvas.TouchMoveEvent:
+		e.touch(evTouchMove, ev.TouchEvent)
+	case canvas.TouchEndEvent:
+		e.touch(evTouchEnd, ev.TouchEvent)
+	case canvas.TouchCancelEvent:
+		e.touch(evTouchCancel, ev.TouchEvent)
+	case canvas.TextInputEvent:
+		e.byte(evTextInput)
+		e.string(ev.Data)
+	case canvas.CompositionStartEvent:
+		e.byte(evCompositionStart)
+		e.string(ev.Data)
+	case canvas.CompositionUpdateEvent:
+		e.byte(evCompositionUpdate)
+		e.string(ev.Data)
+	case canvas.CompositionEndEvent:
+		e.byte(evCompositionEnd)
+		e.string(ev.Data)
+	case canvas.FocusEvent:
+		e.byte(evFocus)
+	case canvas.BlurEvent:
+		e.byte(evBlur)
+	case canvas.VisibilityChangeEvent:
+		e.byte(evVisibilityChange)
+		e.bool(ev.Hidden)
+	case canvas.GamepadConnectedEvent:
+		e.gamepad(evGamepadConnected, ev.GamepadEvent)
+	case canvas.GamepadDisconnectedEvent:
+		e.gamepad(evGamepadDisconnected, ev.GamepadEvent)
+	case canvas.GamepadStateEvent:
+		e.gamepad(evGamepadState, ev.GamepadEvent)
+	case canvas.DropEvent:
+		e.mouse(evDrop, ev.MouseEvent)
+		e.files(ev.Files)
+	case canvas.PasteEvent:
+		e.byte(evPaste)
+		e.string(ev.Text)
+		e.files(ev.Files)
+	case canvas.ImageReadyEvent:
+		e.byte(evImageReady)
+		e.uint32(ev.ImageID)
+		e.uint32(uint32(ev.Width))
+		e.uint32(uint32(ev.Height))
+		e.string(ev.Err)
+	case canvas.ImageLoadedEvent:
+		e.byte(evImageLoaded)
+		e.uint32(ev.ImageID)
+		e.uint32(uint32(ev.Width))
+		e.uint32(uint32(ev.Height))
+	case canvas.ImageErrorEvent:
+		e.byte(evImageError)
+		e.uint32(ev.ImageID)
+		e.string(ev.Err)
+	default:
+		return nil, fmt.Errorf("canvastest: event %T cannot be sent by a client", event)
+	}
+	return e.p, nil
+}
+
+type encoder struct {
+	p []byte
+}
+
+func (e *encoder) byte(b byte) {
+	e.p = append(e.p, b)
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+}
+
+func (e *encoder) uint32(i uint32) {
+	e.p = binary.BigEndian.AppendUint32(e.p, i)
+}
+
+func (e *encoder) float64(f float64) {
+	e.p = binary.BigEndian.AppendUint64(e.p, math.Float64bits(f))
+}
+
+func (e *encoder) bytes(p []byte) {
+	e.uint32(uint32(len(p)))
+	e.p = append(e.p, p...)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) mouse(eventType byte, m canvas.MouseEvent) {
+	e.byte(eventType)
+	e.byte(byte(m.Buttons))
+	e.uint32(uint32(m.X))
+	e.uint32(uint32(m.Y))
+	e.byte(byte(m.Mod))
+}
+
+func (e *encoder) keyboard(eventType byte, k canvas.KeyboardEvent) {
+	e.byte(eventType)
+	e.byte(byte(k.Mod))
+	e.string(k.Key)
+	e.string(k.Code)
+	e.byte(byte(k.Location))
+	var flags byte
+	if k.Repeat {
+		flags |= keyFlagRepeat
+	}
+	if k.IsComposing {
+		flags |= keyFlagIsComposing
+	}
+	e.byte(flags)
+}
+
+func (e *encoder) touch(eventType byte, t canvas.TouchEvent) {
+	e.byte(eventType)
+	e.touchList(t.Touches)
+	e.touchList(t.ChangedTouches)
+	e.touchList(t.TargetTouches)
+	e.byte(byte(t.Mod))
+}
+
+func (e *encoder) touchList(list canvas.TouchList) {
+	e.byte(byte(len(list)))
+	for _, t := range list {
+		e.uint32(t.Identifier)
+		e.uint32(uint32(t.X))
+		e.uint32(uint32(t.Y))
+	}
+}
+
+func (e *encoder) gamepad(eventType byte, g canvas.GamepadEvent) {
+	e.byte(eventType)
+	e.byte(byte(g.Index))
+	e.string(g.ID)
+	e.string(g.Mapping)
+	e.byte(byte(len(g.Buttons)))
+	for _, b := range g.Buttons {
+		e.bool(b.Pressed)
+		e.float64(b.Value)
+	}
+	e.byte(byte(len(g.Axes)))
+	for _, a := range g.Axes {
+		e.float64(a)
+	}
+}
+
+func (e *encoder) files(files []canvas.File) {
+	e.byte(byte(len(files)))
+	for _, f := range files {
+		e.string(f.Name)
+		e.string(f.Type)
+		e.p = binary.BigEndian.AppendUint64(e.p, uint64(f.Size))
+		e.bool(f.Data != nil)
+		e.bytes(f.Data)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvastest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fzipp/canvas/raster"
+)
+
+// update is a flag with a package prefix, so that it does not conflict
+// with an -update flag of the test that imports this package.
+var update = flag.Bool("canvastest.update", false, "update the golden images of canvastest.AssertGolden")
+
+// Tolerance specifies how much a rendered image may differ from a golden
+// image.
+type Tolerance struct {
+	// Color is the maximum perceptual color difference of two pixels
+	// that are considered equal, from 0 (exactly equal) to 1 (black and
+	// white are equal). The difference is measured in the YIQ color
+	// space, which approximates the perception of the human eye.
+	Color float64
+	// Pixels is the maximum fraction of pixels, from 0 to 1, that may
+	// differ by more than Color, for example because of a different
+	// anti-aliasing of edges.
+	Pixels float64
+}
+
+// GoldenTolerance is the tolerance of AssertGolden and AssertGoldenImage.
+var GoldenTolerance = Tolerance{Color: 0.1, Pixels: 0.001}
+
+// AssertGolden renders the given frames, for example the frames recorded
+// by a Recorder, on a transparent canvas of the given size with the
+// software renderer of package raster, and compares the result with the
+// golden image testdata/<name>.png, see AssertGoldenImage:
+//
+//	rec := canvastest.NewRecorder(&canvas.Options{Width: 300, Height: 150})
+//	drawChart(rec.Context())
+//	rec.Context().Flush()
+//	canvastest.AssertGolden(t, "chart", 300, 150, rec.Frames()...)
+func AssertGolden(t testing.TB, name string, width, height int, frames ...Frame) {
+	t.Helper()
+	r := raster.NewRenderer(width, height)
+	for _, frame := range frames {
+		r.Render(frame...)
+	}
+	AssertGoldenImage(t, name, r.Image())
+}
+
+// AssertGoldenImage compares the given image with the golden image
+// testdata/<name>.png, relative to the directory of the test, within
+// GoldenTolerance. If the images differ, it reports an error and writes
+// the image as testdata/<name>.got.png and an image that highlights the
+// differing pixels in red as testdata/<name>.diff.png.
+//
+// If the test is run with the -canvastest.update flag, the golden image
+// is written instead:
+//
+//	go test -canvastest.update
+func AssertGoldenImage(t testing.TB, name string, img image.Image) {
+	t.Helper()
+	assertGolden(t, filepath.Join("testdata", name), img, *update)
+}
+
+func assertGolden(t testing.TB, path string, img image.Image, update bool) {
+	t.Helper()
+	goldenPath := path + ".png"
+	gotPath := path + ".got.png"
+	diffPath := path + ".diff.png"
+	if update {
+		if err := writePNG(goldenPath, img); err != nil {
+			t.Fatalf("canvastest: updating golden image: %v", err)
+		}
+		return
+	}
+	golden, err := readPNG(goldenPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("canvastest: golden image %s does not exist, run the test with -canvastest.update to create it", goldenPath)
+	}
+	if err != nil {
+		t.Fatalf("canvastest: reading golden image: %v", err)
+	}
+	if golden.Bounds().Size() != img.Bounds().Size() {
+		t.Errorf("canvastest: image size %v differs from size %v of golden image %s",
+			img.Bounds().Size(), golden.Bounds().Size(), goldenPath)
+		if err := writePNG(gotPath, img); err != nil {
+			t.Error(err)
+		}
+		return
+	}
+	diff, n := compareImages(golden, img, GoldenTolerance.Color)
+	total := img.Bounds().Dx() * img.Bounds().Dy()
+	if float64(n) <= GoldenTolerance.Pixels*float64(total) {
+		// Remove the output of an earlier failed run.
+		_ = os.Remove(gotPath)
+		_ = os.Remove(diffPath)
+		return
+	}
+	t.Errorf("canvastest: %d of %d pixels differ from golden image %s, see %s and %s",
+		n, total, goldenPath, gotPath, diffPath)
+	if err := writePNG(gotPath, img); err != nil {
+		t.Error(err)
+	}
+	if err := writePNG(diffPath, diff); err != nil {
+		t.Error(err)
+	}
+}
+
+// maxYIQDelta is the squared YIQ difference of black and white.
+const maxYIQDelta = 0.5053 * 255 * 255
+
+// compareImages compares two images of the same size pixel by pixel. It
+// returns the number of pixels whose perceptual color difference exceeds
+// the given threshold, and a diff image that shows these pixels in red
+// over a faded grayscale version of the image b.
+func compareImages(a, b image.Image, threshold float64) (diff *image.RGBA, n int) {
+	ra := toRGBA(a)
+	rb := toRGBA(b)
+	w, h := ra.Bounds().Dx(), ra.Bounds().Dy()
+	diff = image.NewRGBA(image.Rect(0, 0, w, h))
+	maxDelta := maxYIQDelta * threshold * threshold
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ca := ra.RGBAAt(x, y)
+			cb := rb.RGBAAt(x, y)
+			if yiqDelta(ca, cb) > maxDelta {
+				n++
+				diff.SetRGBA(x, y, color.RGBA{R: 0xff, A: 0xff})
+				continue
+			}
+			// Blend the brightness of the pixel with white.
+			yb, _, _ := yiq(blendWhite(cb))
+			v := uint8(255 - (255-yb)*0.1)
+			diff.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 0xff})
+		}
+	}
+	return diff, n
+}
+
+// yiqDelta returns the squared perceptual difference of two colors,
+// after blending them with a white background, as described in
+// "Measuring perceived color difference using YIQ NTSC transmission color
+// space in mobile applications" by Y. Kotsarenko and F. Ramos.
+func yiqDelta(a, b color.RGBA) float64 {
+	if a == b {
+		return 0
+	}
+	y1, i1, q1 := yiq(blendWhite(a))
+	y2, i2, q2 := yiq(blendWhite(b))
+	dy, di, dq := y1-y2, i1-i2, q1-q2
+	return 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+}
+
+// blendWhite blends a premultiplied color with a white background.
+func blendWhite(c color.RGBA) (r, g, b float64) {
+	t := 255 - float64(c.A)
+	return float64(c.R) + t, float64(c.G) + t, float64(c.B) + t
+}
+
+func yiq(r, g, b float64) (y, i, q float64) {
+	y = 0.29889531*r + 0.58662247*g + 0.11448223*b
+	i = 0.59597799*r - 0.27417610*g - 0.32180189*b
+	q = 0.21147017*r - 0.52261711*g + 0.31114694*b
+	return y, i, q
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
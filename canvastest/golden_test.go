@@ -0,0 +1,135 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvastest
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// fakeT records the failures of a test instead of failing it.
+type fakeT struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Error(args ...any) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	t.fatal = true
+	runtime.Goexit()
+}
+
+// runFake runs f with a fakeT in its own goroutine, so that Fatalf can
+// stop it.
+func runFake(f func(t *fakeT)) *fakeT {
+	t := &fakeT{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(t)
+	}()
+	<-done
+	return t
+}
+
+func filledImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestAssertGolden(t *testing.T) {
+	gray := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+	nearGray := color.RGBA{R: 0x82, G: 0x80, B: 0x7f, A: 0xff}
+	red := color.RGBA{R: 0xff, A: 0xff}
+
+	path := filepath.Join(t.TempDir(), "golden")
+	runFake(func(ft *fakeT) {
+		assertGolden(ft, path, filledImage(10, 10, gray), true)
+	})
+	if !exists(path + ".png") {
+		t.Fatalf("golden image was not written")
+	}
+
+	similar := filledImage(10, 10, nearGray)
+	if ft := runFake(func(ft *fakeT) { assertGolden(ft, path, similar, false) }); len(ft.errors) > 0 {
+		t.Errorf("similar image: unexpected errors: %v", ft.errors)
+	}
+
+	oneOff := filledImage(10, 10, gray)
+	oneOff.SetRGBA(3, 4, red)
+	if ft := runFake(func(ft *fakeT) { assertGolden(ft, path, oneOff, false) }); len(ft.errors) != 1 {
+		t.Errorf("different image: got errors %v, want one error", ft.errors)
+	}
+	diff, err := readPNG(path + ".diff.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := color.RGBAModel.Convert(diff.At(3, 4)); got != red {
+		t.Errorf("got diff color %v for differing pixel, want %v", got, red)
+	}
+	if got := color.RGBAModel.Convert(diff.At(0, 0)); got == red {
+		t.Errorf("got diff color %v for equal pixel, want gray", got)
+	}
+	if !exists(path + ".got.png") {
+		t.Errorf("got image was not written")
+	}
+
+	// A passing comparison removes the output of the failed one.
+	runFake(func(ft *fakeT) { assertGolden(ft, path, similar, false) })
+	if exists(path+".got.png") || exists(path+".diff.png") {
+		t.Errorf("output of failed comparison was not removed")
+	}
+
+	if ft := runFake(func(ft *fakeT) { assertGolden(ft, path, filledImage(5, 10, gray), false) }); len(ft.errors) != 1 {
+		t.Errorf("different size: got errors %v, want one error", ft.errors)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	if ft := runFake(func(ft *fakeT) { assertGolden(ft, missing, similar, false) }); !ft.fatal {
+		t.Errorf("missing golden image: expected fatal error")
+	}
+}
+
+func TestYIQDelta(t *testing.T) {
+	tests := []struct {
+		a, b color.RGBA
+		want float64
+	}{
+		{color.RGBA{A: 0xff}, color.RGBA{A: 0xff}, 0},
+		{color.RGBA{A: 0xff}, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, maxYIQDelta},
+		// Transparent is blended with white.
+		{color.RGBA{}, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, 0},
+	}
+	for _, tt := range tests {
+		got := yiqDelta(tt.a, tt.b)
+		if diff := got - tt.want; diff < -1 || diff > 1 {
+			t.Errorf("yiqDelta(%v, %v) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
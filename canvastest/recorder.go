@@ -0,0 +1,198 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package canvastest provides utilities for testing code that draws on a
+// canvas.Context, without a client connection or a web browser.
+//
+// A Recorder provides a canvas.Context whose flushed frames are recorded in
+// memory and decoded into typed draw commands of package command:
+//
+//	rec := canvastest.NewRecorder(&canvas.Options{Width: 100, Height: 100})
+//	drawChart(rec.Context())
+//	rec.Context().Flush()
+//
+//	want := []canvastest.Frame{{
+//		command.SetFillStyle{Color: color.RGBA{R: 0xff, A: 0xff}},
+//		command.FillRect{X: 10, Y: 10, Width: 50, Height: 20},
+//	}}
+//	if diff := cmp.Diff(want, rec.Frames()); diff != "" {
+//		t.Errorf("mismatch (-want, +got)\n%s", diff)
+//	}
+//
+// A Client tests the whole connection instead: it connects to a canvas
+// server, for example an httptest.Server with the handler of
+// canvas.NewServeMux, like the JavaScript client in a web browser does,
+// sends events and records the frames it receives.
+//
+// AssertGolden renders recorded frames and compares them with a golden
+// image in the testdata directory. Run the tests with the
+// -canvastest.update flag to create or update the golden images.
+//
+// AssertGolden takes the size of the canvas and any number of frames
+// instead of a single frame, because a frame carries neither the canvas
+// size nor the drawing of earlier frames, which the image of a canvas
+// depends on. The update flag has the prefix "canvastest." because flags
+// are global: a plain -update flag would panic with "flag redefined" in
+// every test binary that defines its own -update flag, which is common for
+// golden tests.
+package canvastest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+)
+
+// Frame is the sequence of draw commands sent by one call of
+// canvas.Context.Flush.
+type Frame []command.Command
+
+// Recorder records the frames flushed by a canvas.Context and delivers
+// injected events to it. It must be created with NewRecorder.
+type Recorder struct {
+	ctx     *canvas.Context
+	draws   chan []byte
+	synced  chan chan struct{}
+	stopped chan struct{}
+	events  chan canvas.Event
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	frames [][]byte
+	queue  []canvas.Event
+	closed bool
+}
+
+// NewRecorder creates a Recorder with a canvas.Context that is configured
+// by the given options. The options may be nil, in which case the
+// defaults of canvas.Options apply.
+func NewRecorder(opts *canvas.Options) *Recorder {
+	r := &Recorder{
+		draws:   make(chan []byte),
+		synced:  make(chan chan struct{}),
+		stopped: make(chan struct{}),
+		events:  make(chan canvas.Event),
+	}
+	r.cond = sync.NewCond(&r.mu)
+	r.ctx = canvas.NewContext(r.draws, r.events, opts)
+	go r.record()
+	go r.deliverEvents()
+	return r
+}
+
+// Context returns the canvas.Context whose frames are recorded.
+func (r *Recorder) Context() *canvas.Context {
+	return r.ctx
+}
+
+// Frames returns the decoded frames flushed so far, in flush order.
+// Draw commands that were not flushed yet are not included.
+//
+// Frames panics if a frame cannot be decoded, which indicates a bug in
+// package canvas.
+func (r *Recorder) Frames() []Frame {
+	return decodeFrames(r.RawFrames())
+}
+
+func decodeFrames(rawFrames [][]byte) []Frame {
+	frames := make([]Frame, len(rawFrames))
+	for i, p := range rawFrames {
+		cmds, err := command.Decode(p)
+		if err != nil {
+			panic(fmt.Sprintf("canvastest: frame %d: %v", i, err))
+		}
+		frames[i] = cmds
+	}
+	return frames
+}
+
+// RawFrames returns the frames flushed so far in the binary draw command
+// format, in flush order.
+func (r *Recorder) RawFrames() [][]byte {
+	r.sync()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.frames...)
+}
+
+// Reset discards the frames recorded so far.
+func (r *Recorder) Reset() {
+	r.sync()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = nil
+}
+
+// SendEvent queues the given events for delivery on the channel returned
+// by the Events method of the Context. SendEvent does not wait for the
+// events to be received.
+func (r *Recorder) SendEvent(events ...canvas.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		panic("canvastest: SendEvent after Close")
+	}
+	r.queue = append(r.queue, events...)
+	r.cond.Signal()
+}
+
+// Close queues a canvas.CloseEvent, like a closed client connection does,
+// and closes the events channel of the Context after all queued events
+// have been received. The Context must not be flushed after Close.
+func (r *Recorder) Close() {
+	r.SendEvent(canvas.CloseEvent{})
+	r.mu.Lock()
+	r.closed = true
+	r.cond.Signal()
+	r.mu.Unlock()
+	close(r.draws)
+}
+
+// sync waits until the frames sent by all completed Flush calls have been
+// recorded.
+func (r *Recorder) sync() {
+	done := make(chan struct{})
+	select {
+	case r.synced <- done:
+		<-done
+	case <-r.stopped:
+	}
+}
+
+func (r *Recorder) record() {
+	defer close(r.stopped)
+	for {
+		select {
+		case p, ok := <-r.draws:
+			if !ok {
+				return
+			}
+			r.mu.Lock()
+			r.frames = append(r.frames, p)
+			r.mu.Unlock()
+		case done := <-r.synced:
+			close(done)
+		}
+	}
+}
+
+func (r *Recorder) deliverEvents() {
+	for {
+		r.mu.Lock()
+		for len(r.queue) == 0 && !r.closed {
+			r.cond.Wait()
+		}
+		if len(r.queue) == 0 {
+			r.mu.Unlock()
+			close(r.events)
+			return
+		}
+		event := r.queue[0]
+		r.queue = r.queue[1:]
+		r.mu.Unlock()
+		r.events <- event
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvastest
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecorderFrames(t *testing.T) {
+	rec := NewRecorder(nil)
+	ctx := rec.Context()
+	ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+	ctx.FillRect(10, 10, 50, 20)
+	ctx.Flush()
+
+	want := []Frame{{
+		command.SetFillStyle{Color: color.RGBA{R: 0xff, A: 0xff}},
+		command.FillRect{X: 10, Y: 10, Width: 50, Height: 20},
+	}}
+	if diff := cmp.Diff(want, rec.Frames()); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestRecorderMultipleFrames(t *testing.T) {
+	rec := NewRecorder(nil)
+	ctx := rec.Context()
+	ctx.FillRect(1, 2, 3, 4)
+	ctx.Flush()
+	ctx.Flush()
+	ctx.Stroke()
+	ctx.Flush()
+	ctx.Fill()
+
+	want := []Frame{
+		{command.FillRect{X: 1, Y: 2, Width: 3, Height: 4}},
+		nil,
+		{command.Stroke{}},
+	}
+	if diff := cmp.Diff(want, rec.Frames()); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+
+	rec.Reset()
+	ctx.Flush()
+	want = []Frame{{command.Fill{}}}
+	if diff := cmp.Diff(want, rec.Frames()); diff != "" {
+		t.Errorf("after Reset: mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestRecorderEvents(t *testing.T) {
+	rec := NewRecorder(nil)
+	want := []canvas.Event{
+		canvas.MouseDownEvent{MouseEvent: canvas.MouseEvent{X: 10, Y: 20}},
+		canvas.KeyDownEvent{KeyboardEvent: canvas.KeyboardEvent{Key: "a", Code: "KeyA"}},
+		canvas.CloseEvent{},
+	}
+	rec.SendEvent(want[0], want[1])
+	rec.Close()
+	var got []canvas.Event
+	for event := range rec.Context().Events() {
+		got = append(got, event)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestRecorderCanvasSize(t *testing.T) {
+	rec := NewRecorder(&canvas.Options{Width: 640, Height: 480})
+	ctx := rec.Context()
+	if ctx.CanvasWidth() != 640 || ctx.CanvasHeight() != 480 {
+		t.Errorf("got: W %d H %d, want: W 640 H 480", ctx.CanvasWidth(), ctx.CanvasHeight())
+	}
+
+	rec = NewRecorder(nil)
+	ctx = rec.Context()
+	if ctx.CanvasWidth() != 300 || ctx.CanvasHeight() != 150 {
+		t.Errorf("default size: got: W %d H %d, want: W 300 H 150", ctx.CanvasWidth(), ctx.CanvasHeight())
+	}
+}
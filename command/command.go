@@ -0,0 +1,1428 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package command
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strconv"
+
+	"github.com/fzipp/canvas"
+)
+
+// Arc corresponds to canvas.Context.Arc.
+type Arc struct {
+	X             float64
+	Y             float64
+	Radius        float64
+	StartAngle    float64
+	EndAngle      float64
+	Anticlockwise bool
+}
+
+func (c Arc) String() string {
+	return call("Arc", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.Radius), fmtFloat(c.StartAngle), fmtFloat(c.EndAngle), strconv.FormatBool(c.Anticlockwise))
+}
+
+func (c Arc) appendTo(p []byte) []byte {
+	p = append(p, bArc)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.Radius)
+	p = appendFloat64(p, c.StartAngle)
+	p = appendFloat64(p, c.EndAngle)
+	p = appendBool(p, c.Anticlockwise)
+	return p
+}
+
+// ArcTo corresponds to canvas.Context.ArcTo.
+type ArcTo struct {
+	X1     float64
+	Y1     float64
+	X2     float64
+	Y2     float64
+	Radius float64
+}
+
+func (c ArcTo) String() string {
+	return call("ArcTo", fmtFloat(c.X1), fmtFloat(c.Y1), fmtFloat(c.X2), fmtFloat(c.Y2), fmtFloat(c.Radius))
+}
+
+func (c ArcTo) appendTo(p []byte) []byte {
+	p = append(p, bArcTo)
+	p = appendFloat64(p, c.X1)
+	p = appendFloat64(p, c.Y1)
+	p = appendFloat64(p, c.X2)
+	p = appendFloat64(p, c.Y2)
+	p = appendFloat64(p, c.Radius)
+	return p
+}
+
+// BeginPath corresponds to canvas.Context.BeginPath.
+type BeginPath struct{}
+
+func (c BeginPath) String() string {
+	return call("BeginPath")
+}
+
+func (c BeginPath) appendTo(p []byte) []byte {
+	return append(p, bBeginPath)
+}
+
+// BezierCurveTo corresponds to canvas.Context.BezierCurveTo.
+type BezierCurveTo struct {
+	CP1X float64
+	CP1Y float64
+	CP2X float64
+	CP2Y float64
+	X    float64
+	Y    float64
+}
+
+func (c BezierCurveTo) String() string {
+	return call("BezierCurveTo", fmtFloat(c.CP1X), fmtFloat(c.CP1Y), fmtFloat(c.CP2X), fmtFloat(c.CP2Y), fmtFloat(c.X), fmtFloat(c.Y))
+}
+
+func (c BezierCurveTo) appendTo(p []byte) []byte {
+	p = append(p, bBezierCurveTo)
+	p = appendFloat64(p, c.CP1X)
+	p = appendFloat64(p, c.CP1Y)
+	p = appendFloat64(p, c.CP2X)
+	p = appendFloat64(p, c.CP2Y)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	return p
+}
+
+// ClearRect corresponds to canvas.Context.ClearRect.
+type ClearRect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (c ClearRect) String() string {
+	return call("ClearRect", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.Width), fmtFloat(c.Height))
+}
+
+func (c ClearRect) appendTo(p []byte) []byte {
+	p = append(p, bClearRect)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.Width)
+	p = appendFloat64(p, c.Height)
+	return p
+}
+
+// Clip corresponds to canvas.Context.Clip.
+type Clip struct{}
+
+func (c Clip) String() string {
+	return call("Clip")
+}
+
+func (c Clip) appendTo(p []byte) []byte {
+	return append(p, bClip)
+}
+
+// ClosePath corresponds to canvas.Context.ClosePath.
+type ClosePath struct{}
+
+func (c ClosePath) String() string {
+	return call("ClosePath")
+}
+
+func (c ClosePath) appendTo(p []byte) []byte {
+	return append(p, bClosePath)
+}
+
+// CreateImageData corresponds to canvas.Context.CreateImageData.
+type CreateImageData struct {
+	ID     uint32
+	Width  int
+	Height int
+	Pix    []byte
+}
+
+func (c CreateImageData) String() string {
+	return call("CreateImageData", fmtID("image", c.ID), strconv.Itoa(c.Width), strconv.Itoa(c.Height), fmtBytes(c.Pix))
+}
+
+func (c CreateImageData) appendTo(p []byte) []byte {
+	p = append(p, bCreateImageData)
+	p = appendUint32(p, c.ID)
+	p = appendUint32(p, uint32(c.Width))
+	p = appendUint32(p, uint32(c.Height))
+	p = append(p, c.Pix...)
+	return p
+}
+
+// CreateLinearGradient corresponds to canvas.Context.CreateLinearGradient.
+type CreateLinearGradient struct {
+	ID uint32
+	X0 float64
+	Y0 float64
+	X1 float64
+	Y1 float64
+}
+
+func (c CreateLinearGradient) String() string {
+	return call("CreateLinearGradient", fmtID("gradient", c.ID), fmtFloat(c.X0), fmtFloat(c.Y0), fmtFloat(c.X1), fmtFloat(c.Y1))
+}
+
+func (c CreateLinearGradient) appendTo(p []byte) []byte {
+	p = append(p, bCreateLinearGradient)
+	p = appendUint32(p, c.ID)
+	p = appendFloat64(p, c.X0)
+	p = appendFloat64(p, c.Y0)
+	p = appendFloat64(p, c.X1)
+	p = appendFloat64(p, c.Y1)
+	return p
+}
+
+// CreatePattern corresponds to canvas.Context.CreatePattern.
+type CreatePattern struct {
+	ID         uint32
+	ImageID    uint32
+	Repetition canvas.PatternRepetition
+}
+
+func (c CreatePattern) String() string {
+	return call("CreatePattern", fmtID("pattern", c.ID), fmtID("image", c.ImageID), fmtPatternRepetition(c.Repetition))
+}
+
+func (c CreatePattern) appendTo(p []byte) []byte {
+	p = append(p, bCreatePattern)
+	p = appendUint32(p, c.ID)
+	p = appendUint32(p, c.ImageID)
+	p = append(p, byte(c.Repetition))
+	return p
+}
+
+// CreateRadialGradient corresponds to canvas.Context.CreateRadialGradient.
+type CreateRadialGradient struct {
+	ID uint32
+	X0 float64
+	Y0 float64
+	R0 float64
+	X1 float64
+	Y1 float64
+	R1 float64
+}
+
+func (c CreateRadialGradient) String() string {
+	return call("CreateRadialGradient", fmtID("gradient", c.ID), fmtFloat(c.X0), fmtFloat(c.Y0), fmtFloat(c.R0), fmtFloat(c.X1), fmtFloat(c.Y1), fmtFloat(c.R1))
+}
+
+func (c CreateRadialGradient) appendTo(p []byte) []byte {
+	p = append(p, bCreateRadialGradient)
+	p = appendUint32(p, c.ID)
+	p = appendFloat64(p, c.X0)
+	p = appendFloat64(p, c.Y0)
+	p = appendFloat64(p, c.R0)
+	p = appendFloat64(p, c.X1)
+	p = appendFloat64(p, c.Y1)
+	p = appendFloat64(p, c.R1)
+	return p
+}
+
+// DrawImage corresponds to canvas.Context.DrawImage.
+type DrawImage struct {
+	ImageID uint32
+	DX      float64
+	DY      float64
+}
+
+func (c DrawImage) String() string {
+	return call("DrawImage", fmtID("image", c.ImageID), fmtFloat(c.DX), fmtFloat(c.DY))
+}
+
+func (c DrawImage) appendTo(p []byte) []byte {
+	p = append(p, bDrawImage)
+	p = appendUint32(p, c.ImageID)
+	p = appendFloat64(p, c.DX)
+	p = appendFloat64(p, c.DY)
+	return p
+}
+
+// Ellipse corresponds to canvas.Context.Ellipse.
+type Ellipse struct {
+	X             float64
+	Y             float64
+	RadiusX       float64
+	RadiusY       float64
+	Rotation      float64
+	StartAngle    float64
+	EndAngle      float64
+	Anticlockwise bool
+}
+
+func (c Ellipse) String() string {
+	return call("Ellipse", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.RadiusX), fmtFloat(c.RadiusY), fmtFloat(c.Rotation), fmtFloat(c.StartAngle), fmtFloat(c.EndAngle), strconv.FormatBool(c.Anticlockwise))
+}
+
+func (c Ellipse) appendTo(p []byte) []byte {
+	p = append(p, bEllipse)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.RadiusX)
+	p = appendFloat64(p, c.RadiusY)
+	p = appendFloat64(p, c.Rotation)
+	p = appendFloat64(p, c.StartAngle)
+	p = appendFloat64(p, c.EndAngle)
+	p = appendBool(p, c.Anticlockwise)
+	return p
+}
+
+// Fill corresponds to canvas.Context.Fill.
+type Fill struct{}
+
+func (c Fill) String() string {
+	return call("Fill")
+}
+
+func (c Fill) appendTo(p []byte) []byte {
+	return append(p, bFill)
+}
+
+// FillRect corresponds to canvas.Context.FillRect.
+type FillRect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (c FillRect) String() string {
+	return call("FillRect", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.Width), fmtFloat(c.Height))
+}
+
+func (c FillRect) appendTo(p []byte) []byte {
+	p = append(p, bFillRect)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.Width)
+	p = appendFloat64(p, c.Height)
+	return p
+}
+
+// SetFillStyle corresponds to canvas.Context.SetFillStyle.
+type SetFillStyle struct {
+	Color color.RGBA
+}
+
+func (c SetFillStyle) String() string {
+	return call("SetFillStyle", fmtColor(c.Color))
+}
+
+func (c SetFillStyle) appendTo(p []byte) []byte {
+	p = append(p, bFillStyle)
+	p = appendColor(p, c.Color)
+	return p
+}
+
+// FillText corresponds to canvas.Context.FillText.
+type FillText struct {
+	X    float64
+	Y    float64
+	Text string
+}
+
+func (c FillText) String() string {
+	return call("FillText", fmtFloat(c.X), fmtFloat(c.Y), strconv.Quote(c.Text))
+}
+
+func (c FillText) appendTo(p []byte) []byte {
+	p = append(p, bFillText)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendString(p, c.Text)
+	return p
+}
+
+// SetFont corresponds to canvas.Context.SetFont.
+type SetFont struct {
+	Font string
+}
+
+func (c SetFont) String() string {
+	return call("SetFont", strconv.Quote(c.Font))
+}
+
+func (c SetFont) appendTo(p []byte) []byte {
+	p = append(p, bFont)
+	p = appendString(p, c.Font)
+	return p
+}
+
+// GradientAddColorStop corresponds to canvas.Gradient.AddColorStop.
+type GradientAddColorStop struct {
+	GradientID uint32
+	Offset     float64
+	Color      color.RGBA
+}
+
+func (c GradientAddColorStop) String() string {
+	return call("GradientAddColorStop", fmtID("gradient", c.GradientID), fmtFloat(c.Offset), fmtColor(c.Color))
+}
+
+func (c GradientAddColorStop) appendTo(p []byte) []byte {
+	p = append(p, bGradientAddColorStop)
+	p = appendUint32(p, c.GradientID)
+	p = appendFloat64(p, c.Offset)
+	p = appendColor(p, c.Color)
+	return p
+}
+
+// GradientAddColorStopString corresponds to canvas.Gradient.AddColorStopString.
+type GradientAddColorStopString struct {
+	GradientID uint32
+	Offset     float64
+	Color      string
+}
+
+func (c GradientAddColorStopString) String() string {
+	return call("GradientAddColorStopString", fmtID("gradient", c.GradientID), fmtFloat(c.Offset), strconv.Quote(c.Color))
+}
+
+func (c GradientAddColorStopString) appendTo(p []byte) []byte {
+	p = append(p, bGradientAddColorStopString)
+	p = appendUint32(p, c.GradientID)
+	p = appendFloat64(p, c.Offset)
+	p = appendString(p, c.Color)
+	return p
+}
+
+// SetFillStyleGradient corresponds to canvas.Context.SetFillStyleGradient.
+type SetFillStyleGradient struct {
+	GradientID uint32
+}
+
+func (c SetFillStyleGradient) String() string {
+	return call("SetFillStyleGradient", fmtID("gradient", c.GradientID))
+}
+
+func (c SetFillStyleGradient) appendTo(p []byte) []byte {
+	p = append(p, bFillStyleGradient)
+	p = appendUint32(p, c.GradientID)
+	return p
+}
+
+// SetGlobalAlpha corresponds to canvas.Context.SetGlobalAlpha.
+type SetGlobalAlpha struct {
+	Alpha float64
+}
+
+func (c SetGlobalAlpha) String() string {
+	return call("SetGlobalAlpha", fmtFloat(c.Alpha))
+}
+
+func (c SetGlobalAlpha) appendTo(p []byte) []byte {
+	p = append(p, bGlobalAlpha)
+	p = appendFloat64(p, c.Alpha)
+	return p
+}
+
+// SetGlobalCompositeOperation corresponds to canvas.Context.SetGlobalCompositeOperation.
+type SetGlobalCompositeOperation struct {
+	Mode canvas.CompositeOperation
+}
+
+func (c SetGlobalCompositeOperation) String() string {
+	return call("SetGlobalCompositeOperation", fmtCompositeOperation(c.Mode))
+}
+
+func (c SetGlobalCompositeOperation) appendTo(p []byte) []byte {
+	p = append(p, bGlobalCompositeOperation)
+	p = append(p, byte(c.Mode))
+	return p
+}
+
+// SetImageSmoothingEnabled corresponds to canvas.Context.SetImageSmoothingEnabled.
+type SetImageSmoothingEnabled struct {
+	Enabled bool
+}
+
+func (c SetImageSmoothingEnabled) String() string {
+	return call("SetImageSmoothingEnabled", strconv.FormatBool(c.Enabled))
+}
+
+func (c SetImageSmoothingEnabled) appendTo(p []byte) []byte {
+	p = append(p, bImageSmoothingEnabled)
+	p = appendBool(p, c.Enabled)
+	return p
+}
+
+// SetStrokeStyleGradient corresponds to canvas.Context.SetStrokeStyleGradient.
+type SetStrokeStyleGradient struct {
+	GradientID uint32
+}
+
+func (c SetStrokeStyleGradient) String() string {
+	return call("SetStrokeStyleGradient", fmtID("gradient", c.GradientID))
+}
+
+func (c SetStrokeStyleGradient) appendTo(p []byte) []byte {
+	p = append(p, bStrokeStyleGradient)
+	p = appendUint32(p, c.GradientID)
+	return p
+}
+
+// ReleasePattern corresponds to canvas.Pattern.Release.
+type ReleasePattern struct {
+	PatternID uint32
+}
+
+func (c ReleasePattern) String() string {
+	return call("ReleasePattern", fmtID("pattern", c.PatternID))
+}
+
+func (c ReleasePattern) appendTo(p []byte) []byte {
+	p = append(p, bReleasePattern)
+	p = appendUint32(p, c.PatternID)
+	return p
+}
+
+// SetLineCap corresponds to canvas.Context.SetLineCap.
+type SetLineCap struct {
+	Cap canvas.LineCap
+}
+
+func (c SetLineCap) String() string {
+	return call("SetLineCap", fmtLineCap(c.Cap))
+}
+
+func (c SetLineCap) appendTo(p []byte) []byte {
+	p = append(p, bLineCap)
+	p = append(p, byte(c.Cap))
+	return p
+}
+
+// SetLineDashOffset corresponds to canvas.Context.SetLineDashOffset.
+type SetLineDashOffset struct {
+	Offset float64
+}
+
+func (c SetLineDashOffset) String() string {
+	return call("SetLineDashOffset", fmtFloat(c.Offset))
+}
+
+func (c SetLineDashOffset) appendTo(p []byte) []byte {
+	p = append(p, bLineDashOffset)
+	p = appendFloat64(p, c.Offset)
+	return p
+}
+
+// SetLineJoin corresponds to canvas.Context.SetLineJoin.
+type SetLineJoin struct {
+	Join canvas.LineJoin
+}
+
+func (c SetLineJoin) String() string {
+	return call("SetLineJoin", fmtLineJoin(c.Join))
+}
+
+func (c SetLineJoin) appendTo(p []byte) []byte {
+	p = append(p, bLineJoin)
+	p = append(p, byte(c.Join))
+	return p
+}
+
+// LineTo corresponds to canvas.Context.LineTo.
+type LineTo struct {
+	X float64
+	Y float64
+}
+
+func (c LineTo) String() string {
+	return call("LineTo", fmtFloat(c.X), fmtFloat(c.Y))
+}
+
+func (c LineTo) appendTo(p []byte) []byte {
+	p = append(p, bLineTo)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	return p
+}
+
+// SetLineWidth corresponds to canvas.Context.SetLineWidth.
+type SetLineWidth struct {
+	Width float64
+}
+
+func (c SetLineWidth) String() string {
+	return call("SetLineWidth", fmtFloat(c.Width))
+}
+
+func (c SetLineWidth) appendTo(p []byte) []byte {
+	p = append(p, bLineWidth)
+	p = appendFloat64(p, c.Width)
+	return p
+}
+
+// ReleaseGradient corresponds to canvas.Gradient.Release.
+type ReleaseGradient struct {
+	GradientID uint32
+}
+
+func (c ReleaseGradient) String() string {
+	return call("ReleaseGradient", fmtID("gradient", c.GradientID))
+}
+
+func (c ReleaseGradient) appendTo(p []byte) []byte {
+	p = append(p, bReleaseGradient)
+	p = appendUint32(p, c.GradientID)
+	return p
+}
+
+// SetMiterLimit corresponds to canvas.Context.SetMiterLimit.
+type SetMiterLimit struct {
+	Value float64
+}
+
+func (c SetMiterLimit) String() string {
+	return call("SetMiterLimit", fmtFloat(c.Value))
+}
+
+func (c SetMiterLimit) appendTo(p []byte) []byte {
+	p = append(p, bMiterLimit)
+	p = appendFloat64(p, c.Value)
+	return p
+}
+
+// MoveTo corresponds to canvas.Context.MoveTo.
+type MoveTo struct {
+	X float64
+	Y float64
+}
+
+func (c MoveTo) String() string {
+	return call("MoveTo", fmtFloat(c.X), fmtFloat(c.Y))
+}
+
+func (c MoveTo) appendTo(p []byte) []byte {
+	p = append(p, bMoveTo)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	return p
+}
+
+// PutImageData corresponds to canvas.Context.PutImageData.
+type PutImageData struct {
+	ImageID uint32
+	DX      float64
+	DY      float64
+}
+
+func (c PutImageData) String() string {
+	return call("PutImageData", fmtID("image", c.ImageID), fmtFloat(c.DX), fmtFloat(c.DY))
+}
+
+func (c PutImageData) appendTo(p []byte) []byte {
+	p = append(p, bPutImageData)
+	p = appendUint32(p, c.ImageID)
+	p = appendFloat64(p, c.DX)
+	p = appendFloat64(p, c.DY)
+	return p
+}
+
+// QuadraticCurveTo corresponds to canvas.Context.QuadraticCurveTo.
+type QuadraticCurveTo struct {
+	CPX float64
+	CPY float64
+	X   float64
+	Y   float64
+}
+
+func (c QuadraticCurveTo) String() string {
+	return call("QuadraticCurveTo", fmtFloat(c.CPX), fmtFloat(c.CPY), fmtFloat(c.X), fmtFloat(c.Y))
+}
+
+func (c QuadraticCurveTo) appendTo(p []byte) []byte {
+	p = append(p, bQuadraticCurveTo)
+	p = appendFloat64(p, c.CPX)
+	p = appendFloat64(p, c.CPY)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	return p
+}
+
+// Rect corresponds to canvas.Context.Rect.
+type Rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (c Rect) String() string {
+	return call("Rect", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.Width), fmtFloat(c.Height))
+}
+
+func (c Rect) appendTo(p []byte) []byte {
+	p = append(p, bRect)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.Width)
+	p = appendFloat64(p, c.Height)
+	return p
+}
+
+// Restore corresponds to canvas.Context.Restore.
+type Restore struct{}
+
+func (c Restore) String() string {
+	return call("Restore")
+}
+
+func (c Restore) appendTo(p []byte) []byte {
+	return append(p, bRestore)
+}
+
+// Rotate corresponds to canvas.Context.Rotate.
+type Rotate struct {
+	Angle float64
+}
+
+func (c Rotate) String() string {
+	return call("Rotate", fmtFloat(c.Angle))
+}
+
+func (c Rotate) appendTo(p []byte) []byte {
+	p = append(p, bRotate)
+	p = appendFloat64(p, c.Angle)
+	return p
+}
+
+// Save corresponds to canvas.Context.Save.
+type Save struct{}
+
+func (c Save) String() string {
+	return call("Save")
+}
+
+func (c Save) appendTo(p []byte) []byte {
+	return append(p, bSave)
+}
+
+// Scale corresponds to canvas.Context.Scale.
+type Scale struct {
+	X float64
+	Y float64
+}
+
+func (c Scale) String() string {
+	return call("Scale", fmtFloat(c.X), fmtFloat(c.Y))
+}
+
+func (c Scale) appendTo(p []byte) []byte {
+	p = append(p, bScale)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	return p
+}
+
+// SetLineDash corresponds to canvas.Context.SetLineDash.
+type SetLineDash struct {
+	Segments []float64
+}
+
+func (c SetLineDash) String() string {
+	return call("SetLineDash", fmtFloats(c.Segments))
+}
+
+func (c SetLineDash) appendTo(p []byte) []byte {
+	p = append(p, bSetLineDash)
+	p = appendFloat64s(p, c.Segments)
+	return p
+}
+
+// SetTransform corresponds to canvas.Context.SetTransform.
+type SetTransform struct {
+	A float64
+	B float64
+	C float64
+	D float64
+	E float64
+	F float64
+}
+
+func (c SetTransform) String() string {
+	return call("SetTransform", fmtFloat(c.A), fmtFloat(c.B), fmtFloat(c.C), fmtFloat(c.D), fmtFloat(c.E), fmtFloat(c.F))
+}
+
+func (c SetTransform) appendTo(p []byte) []byte {
+	p = append(p, bSetTransform)
+	p = appendFloat64(p, c.A)
+	p = appendFloat64(p, c.B)
+	p = appendFloat64(p, c.C)
+	p = appendFloat64(p, c.D)
+	p = appendFloat64(p, c.E)
+	p = appendFloat64(p, c.F)
+	return p
+}
+
+// SetShadowBlur corresponds to canvas.Context.SetShadowBlur.
+type SetShadowBlur struct {
+	Level float64
+}
+
+func (c SetShadowBlur) String() string {
+	return call("SetShadowBlur", fmtFloat(c.Level))
+}
+
+func (c SetShadowBlur) appendTo(p []byte) []byte {
+	p = append(p, bShadowBlur)
+	p = appendFloat64(p, c.Level)
+	return p
+}
+
+// SetShadowColor corresponds to canvas.Context.SetShadowColor.
+type SetShadowColor struct {
+	Color color.RGBA
+}
+
+func (c SetShadowColor) String() string {
+	return call("SetShadowColor", fmtColor(c.Color))
+}
+
+func (c SetShadowColor) appendTo(p []byte) []byte {
+	p = append(p, bShadowColor)
+	p = appendColor(p, c.Color)
+	return p
+}
+
+// SetShadowOffsetX corresponds to canvas.Context.SetShadowOffsetX.
+type SetShadowOffsetX struct {
+	Offset float64
+}
+
+func (c SetShadowOffsetX) String() string {
+	return call("SetShadowOffsetX", fmtFloat(c.Offset))
+}
+
+func (c SetShadowOffsetX) appendTo(p []byte) []byte {
+	p = append(p, bShadowOffsetX)
+	p = appendFloat64(p, c.Offset)
+	return p
+}
+
+// SetShadowOffsetY corresponds to canvas.Context.SetShadowOffsetY.
+type SetShadowOffsetY struct {
+	Offset float64
+}
+
+func (c SetShadowOffsetY) String() string {
+	return call("SetShadowOffsetY", fmtFloat(c.Offset))
+}
+
+func (c SetShadowOffsetY) appendTo(p []byte) []byte {
+	p = append(p, bShadowOffsetY)
+	p = appendFloat64(p, c.Offset)
+	return p
+}
+
+// Stroke corresponds to canvas.Context.Stroke.
+type Stroke struct{}
+
+func (c Stroke) String() string {
+	return call("Stroke")
+}
+
+func (c Stroke) appendTo(p []byte) []byte {
+	return append(p, bStroke)
+}
+
+// StrokeRect corresponds to canvas.Context.StrokeRect.
+type StrokeRect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (c StrokeRect) String() string {
+	return call("StrokeRect", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.Width), fmtFloat(c.Height))
+}
+
+func (c StrokeRect) appendTo(p []byte) []byte {
+	p = append(p, bStrokeRect)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.Width)
+	p = appendFloat64(p, c.Height)
+	return p
+}
+
+// SetStrokeStyle corresponds to canvas.Context.SetStrokeStyle.
+type SetStrokeStyle struct {
+	Color color.RGBA
+}
+
+func (c SetStrokeStyle) String() string {
+	return call("SetStrokeStyle", fmtColor(c.Color))
+}
+
+func (c SetStrokeStyle) appendTo(p []byte) []byte {
+	p = append(p, bStrokeStyle)
+	p = appendColor(p, c.Color)
+	return p
+}
+
+// StrokeText corresponds to canvas.Context.StrokeText.
+type StrokeText struct {
+	X    float64
+	Y    float64
+	Text string
+}
+
+func (c StrokeText) String() string {
+	return call("StrokeText", fmtFloat(c.X), fmtFloat(c.Y), strconv.Quote(c.Text))
+}
+
+func (c StrokeText) appendTo(p []byte) []byte {
+	p = append(p, bStrokeText)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendString(p, c.Text)
+	return p
+}
+
+// SetTextAlign corresponds to canvas.Context.SetTextAlign.
+type SetTextAlign struct {
+	Align canvas.TextAlign
+}
+
+func (c SetTextAlign) String() string {
+	return call("SetTextAlign", fmtTextAlign(c.Align))
+}
+
+func (c SetTextAlign) appendTo(p []byte) []byte {
+	p = append(p, bTextAlign)
+	p = append(p, byte(c.Align))
+	return p
+}
+
+// SetTextBaseline corresponds to canvas.Context.SetTextBaseline.
+type SetTextBaseline struct {
+	Baseline canvas.TextBaseline
+}
+
+func (c SetTextBaseline) String() string {
+	return call("SetTextBaseline", fmtTextBaseline(c.Baseline))
+}
+
+func (c SetTextBaseline) appendTo(p []byte) []byte {
+	p = append(p, bTextBaseline)
+	p = append(p, byte(c.Baseline))
+	return p
+}
+
+// Transform corresponds to canvas.Context.Transform.
+type Transform struct {
+	A float64
+	B float64
+	C float64
+	D float64
+	E float64
+	F float64
+}
+
+func (c Transform) String() string {
+	return call("Transform", fmtFloat(c.A), fmtFloat(c.B), fmtFloat(c.C), fmtFloat(c.D), fmtFloat(c.E), fmtFloat(c.F))
+}
+
+func (c Transform) appendTo(p []byte) []byte {
+	p = append(p, bTransform)
+	p = appendFloat64(p, c.A)
+	p = appendFloat64(p, c.B)
+	p = appendFloat64(p, c.C)
+	p = appendFloat64(p, c.D)
+	p = appendFloat64(p, c.E)
+	p = appendFloat64(p, c.F)
+	return p
+}
+
+// Translate corresponds to canvas.Context.Translate.
+type Translate struct {
+	X float64
+	Y float64
+}
+
+func (c Translate) String() string {
+	return call("Translate", fmtFloat(c.X), fmtFloat(c.Y))
+}
+
+func (c Translate) appendTo(p []byte) []byte {
+	p = append(p, bTranslate)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	return p
+}
+
+// FillTextMaxWidth corresponds to canvas.Context.FillTextMaxWidth.
+type FillTextMaxWidth struct {
+	X        float64
+	Y        float64
+	MaxWidth float64
+	Text     string
+}
+
+func (c FillTextMaxWidth) String() string {
+	return call("FillTextMaxWidth", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.MaxWidth), strconv.Quote(c.Text))
+}
+
+func (c FillTextMaxWidth) appendTo(p []byte) []byte {
+	p = append(p, bFillTextMaxWidth)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.MaxWidth)
+	p = appendString(p, c.Text)
+	return p
+}
+
+// StrokeTextMaxWidth corresponds to canvas.Context.StrokeTextMaxWidth.
+type StrokeTextMaxWidth struct {
+	X        float64
+	Y        float64
+	MaxWidth float64
+	Text     string
+}
+
+func (c StrokeTextMaxWidth) String() string {
+	return call("StrokeTextMaxWidth", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.MaxWidth), strconv.Quote(c.Text))
+}
+
+func (c StrokeTextMaxWidth) appendTo(p []byte) []byte {
+	p = append(p, bStrokeTextMaxWidth)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.MaxWidth)
+	p = appendString(p, c.Text)
+	return p
+}
+
+// SetFillStyleString corresponds to canvas.Context.SetFillStyleString.
+type SetFillStyleString struct {
+	Color string
+}
+
+func (c SetFillStyleString) String() string {
+	return call("SetFillStyleString", strconv.Quote(c.Color))
+}
+
+func (c SetFillStyleString) appendTo(p []byte) []byte {
+	p = append(p, bFillStyleString)
+	p = appendString(p, c.Color)
+	return p
+}
+
+// SetStrokeStyleString corresponds to canvas.Context.SetStrokeStyleString.
+type SetStrokeStyleString struct {
+	Color string
+}
+
+func (c SetStrokeStyleString) String() string {
+	return call("SetStrokeStyleString", strconv.Quote(c.Color))
+}
+
+func (c SetStrokeStyleString) appendTo(p []byte) []byte {
+	p = append(p, bStrokeStyleString)
+	p = appendString(p, c.Color)
+	return p
+}
+
+// SetShadowColorString corresponds to canvas.Context.SetShadowColorString.
+type SetShadowColorString struct {
+	Color string
+}
+
+func (c SetShadowColorString) String() string {
+	return call("SetShadowColorString", strconv.Quote(c.Color))
+}
+
+func (c SetShadowColorString) appendTo(p []byte) []byte {
+	p = append(p, bShadowColorString)
+	p = appendString(p, c.Color)
+	return p
+}
+
+// PutImageDataDirty corresponds to canvas.Context.PutImageDataDirty.
+type PutImageDataDirty struct {
+	ImageID     uint32
+	DX          float64
+	DY          float64
+	DirtyX      float64
+	DirtyY      float64
+	DirtyWidth  float64
+	DirtyHeight float64
+}
+
+func (c PutImageDataDirty) String() string {
+	return call("PutImageDataDirty", fmtID("image", c.ImageID), fmtFloat(c.DX), fmtFloat(c.DY), fmtFloat(c.DirtyX), fmtFloat(c.DirtyY), fmtFloat(c.DirtyWidth), fmtFloat(c.DirtyHeight))
+}
+
+func (c PutImageDataDirty) appendTo(p []byte) []byte {
+	p = append(p, bPutImageDataDirty)
+	p = appendUint32(p, c.ImageID)
+	p = appendFloat64(p, c.DX)
+	p = appendFloat64(p, c.DY)
+	p = appendFloat64(p, c.DirtyX)
+	p = appendFloat64(p, c.DirtyY)
+	p = appendFloat64(p, c.DirtyWidth)
+	p = appendFloat64(p, c.DirtyHeight)
+	return p
+}
+
+// DrawImageScaled corresponds to canvas.Context.DrawImageScaled.
+type DrawImageScaled struct {
+	ImageID uint32
+	DX      float64
+	DY      float64
+	DWidth  float64
+	DHeight float64
+}
+
+func (c DrawImageScaled) String() string {
+	return call("DrawImageScaled", fmtID("image", c.ImageID), fmtFloat(c.DX), fmtFloat(c.DY), fmtFloat(c.DWidth), fmtFloat(c.DHeight))
+}
+
+func (c DrawImageScaled) appendTo(p []byte) []byte {
+	p = append(p, bDrawImageScaled)
+	p = appendUint32(p, c.ImageID)
+	p = appendFloat64(p, c.DX)
+	p = appendFloat64(p, c.DY)
+	p = appendFloat64(p, c.DWidth)
+	p = appendFloat64(p, c.DHeight)
+	return p
+}
+
+// DrawImageSubRectangle corresponds to canvas.Context.DrawImageSubRectangle.
+type DrawImageSubRectangle struct {
+	ImageID uint32
+	SX      float64
+	SY      float64
+	SWidth  float64
+	SHeight float64
+	DX      float64
+	DY      float64
+	DWidth  float64
+	DHeight float64
+}
+
+func (c DrawImageSubRectangle) String() string {
+	return call("DrawImageSubRectangle", fmtID("image", c.ImageID), fmtFloat(c.SX), fmtFloat(c.SY), fmtFloat(c.SWidth), fmtFloat(c.SHeight), fmtFloat(c.DX), fmtFloat(c.DY), fmtFloat(c.DWidth), fmtFloat(c.DHeight))
+}
+
+func (c DrawImageSubRectangle) appendTo(p []byte) []byte {
+	p = append(p, bDrawImageSubRectangle)
+	p = appendUint32(p, c.ImageID)
+	p = appendFloat64(p, c.SX)
+	p = appendFloat64(p, c.SY)
+	p = appendFloat64(p, c.SWidth)
+	p = appendFloat64(p, c.SHeight)
+	p = appendFloat64(p, c.DX)
+	p = appendFloat64(p, c.DY)
+	p = appendFloat64(p, c.DWidth)
+	p = appendFloat64(p, c.DHeight)
+	return p
+}
+
+// ReleaseImageData corresponds to canvas.ImageData.Release.
+type ReleaseImageData struct {
+	ImageID uint32
+}
+
+func (c ReleaseImageData) String() string {
+	return call("ReleaseImageData", fmtID("image", c.ImageID))
+}
+
+func (c ReleaseImageData) appendTo(p []byte) []byte {
+	p = append(p, bReleaseImageData)
+	p = appendUint32(p, c.ImageID)
+	return p
+}
+
+// SetFillStylePattern corresponds to canvas.Context.SetFillStylePattern.
+type SetFillStylePattern struct {
+	PatternID uint32
+}
+
+func (c SetFillStylePattern) String() string {
+	return call("SetFillStylePattern", fmtID("pattern", c.PatternID))
+}
+
+func (c SetFillStylePattern) appendTo(p []byte) []byte {
+	p = append(p, bFillStylePattern)
+	p = appendUint32(p, c.PatternID)
+	return p
+}
+
+// SetStrokeStylePattern corresponds to canvas.Context.SetStrokeStylePattern.
+type SetStrokeStylePattern struct {
+	PatternID uint32
+}
+
+func (c SetStrokeStylePattern) String() string {
+	return call("SetStrokeStylePattern", fmtID("pattern", c.PatternID))
+}
+
+func (c SetStrokeStylePattern) appendTo(p []byte) []byte {
+	p = append(p, bStrokeStylePattern)
+	p = appendUint32(p, c.PatternID)
+	return p
+}
+
+// GetImageData corresponds to canvas.Context.GetImageData.
+type GetImageData struct {
+	ID uint32
+	SX float64
+	SY float64
+	SW float64
+	SH float64
+}
+
+func (c GetImageData) String() string {
+	return call("GetImageData", fmtID("image", c.ID), fmtFloat(c.SX), fmtFloat(c.SY), fmtFloat(c.SW), fmtFloat(c.SH))
+}
+
+func (c GetImageData) appendTo(p []byte) []byte {
+	p = append(p, bGetImageData)
+	p = appendUint32(p, c.ID)
+	p = appendFloat64(p, c.SX)
+	p = appendFloat64(p, c.SY)
+	p = appendFloat64(p, c.SW)
+	p = appendFloat64(p, c.SH)
+	return p
+}
+
+// SetTextInputRect corresponds to canvas.Context.SetTextInputRect.
+type SetTextInputRect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+func (c SetTextInputRect) String() string {
+	return call("SetTextInputRect", fmtFloat(c.X), fmtFloat(c.Y), fmtFloat(c.Width), fmtFloat(c.Height))
+}
+
+func (c SetTextInputRect) appendTo(p []byte) []byte {
+	p = append(p, bSetTextInputRect)
+	p = appendFloat64(p, c.X)
+	p = appendFloat64(p, c.Y)
+	p = appendFloat64(p, c.Width)
+	p = appendFloat64(p, c.Height)
+	return p
+}
+
+// Snapshot corresponds to canvas.Context.Snapshot. It asks the client to
+// reply with an image of its canvas, which is identified by the ID.
+type Snapshot struct {
+	ID      uint32
+	Format  string
+	Quality float64
+}
+
+func (c Snapshot) String() string {
+	return call("Snapshot", fmtID("snapshot", c.ID), strconv.Quote(c.Format), fmtFloat(c.Quality))
+}
+
+func (c Snapshot) appendTo(p []byte) []byte {
+	p = append(p, bSnapshot)
+	p = appendUint32(p, c.ID)
+	p = appendString(p, c.Format)
+	p = appendFloat64(p, c.Quality)
+	return p
+}
+
+// Polyline corresponds to canvas.Context.Polyline. The points are
+// alternating x and y coordinates.
+type Polyline struct {
+	Points []float64
+}
+
+func (c Polyline) String() string {
+	return call("Polyline", fmtFloats(c.Points))
+}
+
+func (c Polyline) appendTo(p []byte) []byte {
+	p = append(p, bPolyline)
+	p = appendFloat64s(p, c.Points)
+	return p
+}
+
+// Polygon corresponds to canvas.Context.Polygon. The points are
+// alternating x and y coordinates.
+type Polygon struct {
+	Points []float64
+}
+
+func (c Polygon) String() string {
+	return call("Polygon", fmtFloats(c.Points))
+}
+
+func (c Polygon) appendTo(p []byte) []byte {
+	p = append(p, bPolygon)
+	p = appendFloat64s(p, c.Points)
+	return p
+}
+
+// Points corresponds to canvas.Context.Points. The points are alternating
+// x and y coordinates.
+type Points struct {
+	Points []float64
+	Radius float64
+}
+
+func (c Points) String() string {
+	return call("Points", fmtFloats(c.Points), fmtFloat(c.Radius))
+}
+
+func (c Points) appendTo(p []byte) []byte {
+	p = append(p, bPoints)
+	p = appendFloat64(p, c.Radius)
+	p = appendFloat64s(p, c.Points)
+	return p
+}
+
+// DrawImageInstances corresponds to canvas.Context.DrawImageInstances.
+type DrawImageInstances struct {
+	ImageID   uint32
+	Instances []canvas.SpriteInstance
+}
+
+func (c DrawImageInstances) String() string {
+	return call("DrawImageInstances", fmtID("image", c.ImageID), "["+strconv.Itoa(len(c.Instances))+" instances]")
+}
+
+func (c DrawImageInstances) appendTo(p []byte) []byte {
+	p = append(p, bDrawImageInstances)
+	p = appendUint32(p, c.ImageID)
+	p = appendUint32(p, uint32(len(c.Instances)))
+	for _, in := range c.Instances {
+		p = appendFloat64(p, in.X)
+		p = appendFloat64(p, in.Y)
+		p = appendFloat64(p, in.Scale)
+		p = appendFloat64(p, in.Rotation)
+		p = appendFloat64(p, in.Alpha)
+		p = appendFloat64(p, in.SX)
+		p = appendFloat64(p, in.SY)
+		p = appendFloat64(p, in.SWidth)
+		p = appendFloat64(p, in.SHeight)
+	}
+	return p
+}
+
+// CreateImageFromEncoded corresponds to
+// canvas.Context.CreateImageFromEncoded. Data is the encoded image in the
+// format given by the MIME type.
+type CreateImageFromEncoded struct {
+	ID       uint32
+	MimeType string
+	Data     []byte
+}
+
+func (c CreateImageFromEncoded) String() string {
+	return call("CreateImageFromEncoded", fmtID("image", c.ID), strconv.Quote(c.MimeType), fmtBytes(c.Data))
+}
+
+// Image decodes the encoded image with the image formats that are
+// registered with the image package, see image.RegisterFormat.
+func (c CreateImageFromEncoded) Image() (*image.NRGBA, error) {
+	img, _, err := image.Decode(bytes.NewReader(c.Data))
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Rect, img, b.Min, draw.Src)
+	return nrgba, nil
+}
+
+func (c CreateImageFromEncoded) appendTo(p []byte) []byte {
+	p = append(p, bCreateImageFromEncoded)
+	p = appendUint32(p, c.ID)
+	p = appendString(p, c.MimeType)
+	p = appendUint32(p, uint32(len(c.Data)))
+	p = append(p, c.Data...)
+	return p
+}
+
+// LoadImage corresponds to canvas.Context.LoadImage.
+type LoadImage struct {
+	ID  uint32
+	URL string
+}
+
+func (c LoadImage) String() string {
+	return call("LoadImage", fmtID("image", c.ID), strconv.Quote(c.URL))
+}
+
+func (c LoadImage) appendTo(p []byte) []byte {
+	p = append(p, bLoadImage)
+	p = appendUint32(p, c.ID)
+	p = appendString(p, c.URL)
+	return p
+}
+
+// UpdateImageData corresponds to canvas.ImageData.Update. Tiles are the
+// changed rectangles of the image data.
+type UpdateImageData struct {
+	ImageID uint32
+	Tiles   []ImageTile
+}
+
+// ImageTile is a changed rectangle of pixels of an UpdateImageData command,
+// relative to the top-left corner of the image data.
+type ImageTile struct {
+	X, Y          int
+	Width, Height int
+	// Pix holds the pixels of the tile in R, G, B, A order, without padding
+	// between rows.
+	Pix []byte
+}
+
+func (c UpdateImageData) String() string {
+	return call("UpdateImageData", fmtID("image", c.ImageID), "["+strconv.Itoa(len(c.Tiles))+" tiles]")
+}
+
+// Patch returns a copy of the image with the pixels of the tiles copied
+// into it. The parts of tiles outside the image are ignored.
+func (c UpdateImageData) Patch(img *image.NRGBA) *image.NRGBA {
+	patched := &image.NRGBA{
+		Pix:    slices.Clone(img.Pix),
+		Stride: img.Stride,
+		Rect:   img.Rect,
+	}
+	for _, t := range c.Tiles {
+		origin := img.Rect.Min.Add(image.Pt(t.X, t.Y))
+		r := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(t.Width, t.Height))}.Intersect(img.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i := 4 * ((y-origin.Y)*t.Width + r.Min.X - origin.X)
+			copy(patched.Pix[patched.PixOffset(r.Min.X, y):], t.Pix[i:i+4*r.Dx()])
+		}
+	}
+	return patched
+}
+
+func (c UpdateImageData) appendTo(p []byte) []byte {
+	p = append(p, bUpdateImageData)
+	p = appendUint32(p, c.ImageID)
+	p = appendUint32(p, uint32(len(c.Tiles)))
+	for _, t := range c.Tiles {
+		p = appendUint32(p, uint32(t.X))
+		p = appendUint32(p, uint32(t.Y))
+		p = appendUint32(p, uint32(t.Width))
+		p = appendUint32(p, uint32(t.Height))
+		p = append(p, t.Pix...)
+	}
+	return p
+}
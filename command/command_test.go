@@ -0,0 +1,364 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package command
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/fzipp/canvas"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		draw func(*canvas.Context)
+		want []Command
+	}{
+		{
+			"path",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.MoveTo(10, 20)
+				ctx.LineTo(30, 40)
+				ctx.Arc(50, 50, 10, 0, 3.5, true)
+				ctx.ArcTo(1, 2, 3, 4, 5)
+				ctx.BezierCurveTo(1, 2, 3, 4, 5, 6)
+				ctx.QuadraticCurveTo(1, 2, 3, 4)
+				ctx.Ellipse(1, 2, 3, 4, 5, 6, 7, false)
+				ctx.Rect(1, 2, 3, 4)
+				ctx.Polyline([]float64{1, 2, 3, 4, 5})
+				ctx.Polygon([]float64{1, 2, 3, 4, 5, 6})
+				ctx.Points([]float64{7, 8, 9, 10}, 1.5)
+				ctx.Polyline(nil)
+				ctx.ClosePath()
+				ctx.Fill()
+				ctx.Stroke()
+				ctx.Clip()
+			},
+			[]Command{
+				BeginPath{},
+				MoveTo{X: 10, Y: 20},
+				LineTo{X: 30, Y: 40},
+				Arc{X: 50, Y: 50, Radius: 10, StartAngle: 0, EndAngle: 3.5, Anticlockwise: true},
+				ArcTo{X1: 1, Y1: 2, X2: 3, Y2: 4, Radius: 5},
+				BezierCurveTo{CP1X: 1, CP1Y: 2, CP2X: 3, CP2Y: 4, X: 5, Y: 6},
+				QuadraticCurveTo{CPX: 1, CPY: 2, X: 3, Y: 4},
+				Ellipse{X: 1, Y: 2, RadiusX: 3, RadiusY: 4, Rotation: 5, StartAngle: 6, EndAngle: 7},
+				Rect{X: 1, Y: 2, Width: 3, Height: 4},
+				Polyline{Points: []float64{1, 2, 3, 4}},
+				Polygon{Points: []float64{1, 2, 3, 4, 5, 6}},
+				Points{Points: []float64{7, 8, 9, 10}, Radius: 1.5},
+				ClosePath{},
+				Fill{},
+				Stroke{},
+				Clip{},
+			},
+		},
+		{
+			"rectangles and text",
+			func(ctx *canvas.Context) {
+				ctx.ClearRect(0, 0, 300, 150)
+				ctx.FillRect(10, 10, 50, 20)
+				ctx.StrokeRect(5, 6, 7, 8)
+				ctx.FillText("hello", 1, 2)
+				ctx.StrokeText("world", 3, 4)
+				ctx.FillTextMaxWidth("hello", 1, 2, 30)
+				ctx.StrokeTextMaxWidth("world", 3, 4, 40)
+				ctx.SetTextInputRect(10, 20, 100, 16)
+			},
+			[]Command{
+				ClearRect{X: 0, Y: 0, Width: 300, Height: 150},
+				FillRect{X: 10, Y: 10, Width: 50, Height: 20},
+				StrokeRect{X: 5, Y: 6, Width: 7, Height: 8},
+				FillText{X: 1, Y: 2, Text: "hello"},
+				StrokeText{X: 3, Y: 4, Text: "world"},
+				FillTextMaxWidth{X: 1, Y: 2, MaxWidth: 30, Text: "hello"},
+				StrokeTextMaxWidth{X: 3, Y: 4, MaxWidth: 40, Text: "world"},
+				SetTextInputRect{X: 10, Y: 20, Width: 100, Height: 16},
+			},
+		},
+		{
+			"state",
+			func(ctx *canvas.Context) {
+				ctx.Save()
+				ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+				ctx.SetFillStyleString("green")
+				ctx.SetStrokeStyle(color.RGBA{B: 0x80, A: 0x80})
+				ctx.SetStrokeStyleString("#abc")
+				ctx.SetShadowColor(color.Black)
+				ctx.SetShadowColorString("red")
+				ctx.SetShadowBlur(2)
+				ctx.SetShadowOffsetX(3)
+				ctx.SetShadowOffsetY(4)
+				ctx.SetFont("bold 48px serif")
+				ctx.SetGlobalAlpha(0.5)
+				ctx.SetGlobalCompositeOperation(canvas.OpXOR)
+				ctx.SetImageSmoothingEnabled(true)
+				ctx.SetLineCap(canvas.CapRound)
+				ctx.SetLineJoin(canvas.JoinBevel)
+				ctx.SetLineWidth(3)
+				ctx.SetLineDash([]float64{5, 10})
+				ctx.SetLineDashOffset(2)
+				ctx.SetMiterLimit(4)
+				ctx.SetTextAlign(canvas.AlignCenter)
+				ctx.SetTextBaseline(canvas.BaselineTop)
+				ctx.Restore()
+			},
+			[]Command{
+				Save{},
+				SetFillStyle{Color: color.RGBA{R: 0xff, A: 0xff}},
+				SetFillStyleString{Color: "green"},
+				SetStrokeStyle{Color: color.RGBA{B: 0x80, A: 0x80}},
+				SetStrokeStyleString{Color: "#abc"},
+				SetShadowColor{Color: color.RGBA{A: 0xff}},
+				SetShadowColorString{Color: "red"},
+				SetShadowBlur{Level: 2},
+				SetShadowOffsetX{Offset: 3},
+				SetShadowOffsetY{Offset: 4},
+				SetFont{Font: "bold 48px serif"},
+				SetGlobalAlpha{Alpha: 0.5},
+				SetGlobalCompositeOperation{Mode: canvas.OpXOR},
+				SetImageSmoothingEnabled{Enabled: true},
+				SetLineCap{Cap: canvas.CapRound},
+				SetLineJoin{Join: canvas.JoinBevel},
+				SetLineWidth{Width: 3},
+				SetLineDash{Segments: []float64{5, 10}},
+				SetLineDashOffset{Offset: 2},
+				SetMiterLimit{Value: 4},
+				SetTextAlign{Align: canvas.AlignCenter},
+				SetTextBaseline{Baseline: canvas.BaselineTop},
+				Restore{},
+			},
+		},
+		{
+			"transformations",
+			func(ctx *canvas.Context) {
+				ctx.Translate(1, 2)
+				ctx.Rotate(0.5)
+				ctx.Scale(2, 3)
+				ctx.Transform(1, 2, 3, 4, 5, 6)
+				ctx.SetTransform(6, 5, 4, 3, 2, 1)
+			},
+			[]Command{
+				Translate{X: 1, Y: 2},
+				Rotate{Angle: 0.5},
+				Scale{X: 2, Y: 3},
+				Transform{A: 1, B: 2, C: 3, D: 4, E: 5, F: 6},
+				SetTransform{A: 6, B: 5, C: 4, D: 3, E: 2, F: 1},
+			},
+		},
+		{
+			"images, gradients and patterns",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(image.NewRGBA(image.Rect(0, 0, 1, 1)))
+				ctx.DrawImage(img, 1, 2)
+				ctx.DrawImageScaled(img, 1, 2, 3, 4)
+				ctx.DrawImageSubRectangle(img, 1, 2, 3, 4, 5, 6, 7, 8)
+				ctx.DrawImageInstances(img, []canvas.SpriteInstance{
+					{X: 1, Y: 2, Scale: 3, Rotation: 4, Alpha: 0.5},
+					{X: 5, Y: 6, Scale: 1, Alpha: 1, SX: 1, SY: 2, SWidth: 3, SHeight: 4},
+				})
+				ctx.PutImageData(img, 1, 2)
+				ctx.PutImageDataDirty(img, 1, 2, 3, 4, 5, 6)
+				p := ctx.CreatePattern(img, canvas.PatternRepeatY)
+				ctx.SetFillStylePattern(p)
+				ctx.SetStrokeStylePattern(p)
+				p.Release()
+				lg := ctx.CreateLinearGradient(1, 2, 3, 4)
+				lg.AddColorStop(0, color.White)
+				lg.AddColorStopString(1, "blue")
+				rg := ctx.CreateRadialGradient(1, 2, 3, 4, 5, 6)
+				ctx.SetFillStyleGradient(lg)
+				ctx.SetStrokeStyleGradient(rg)
+				lg.Release()
+				rg.Release()
+				img.Release()
+				ctx.GetImageData(0, 0, 10, 20)
+				ctx.CreateImageFromEncoded([]byte{1, 2, 3}, "image/webp")
+				ctx.LoadImage("static/a.png")
+				tiled := image.NewRGBA(image.Rect(0, 0, 17, 1))
+				u := ctx.CreateImageData(tiled)
+				u.Update(tiled)
+				tiled.Pix[16*4] = 0xff
+				u.Update(tiled)
+			},
+			[]Command{
+				CreateImageData{ID: 0, Width: 1, Height: 1, Pix: []byte{0, 0, 0, 0}},
+				DrawImage{ImageID: 0, DX: 1, DY: 2},
+				DrawImageScaled{ImageID: 0, DX: 1, DY: 2, DWidth: 3, DHeight: 4},
+				DrawImageSubRectangle{ImageID: 0, SX: 1, SY: 2, SWidth: 3, SHeight: 4, DX: 5, DY: 6, DWidth: 7, DHeight: 8},
+				DrawImageInstances{ImageID: 0, Instances: []canvas.SpriteInstance{
+					{X: 1, Y: 2, Scale: 3, Rotation: 4, Alpha: 0.5},
+					{X: 5, Y: 6, Scale: 1, Alpha: 1, SX: 1, SY: 2, SWidth: 3, SHeight: 4},
+				}},
+				PutImageData{ImageID: 0, DX: 1, DY: 2},
+				PutImageDataDirty{ImageID: 0, DX: 1, DY: 2, DirtyX: 3, DirtyY: 4, DirtyWidth: 5, DirtyHeight: 6},
+				CreatePattern{ID: 0, ImageID: 0, Repetition: canvas.PatternRepeatY},
+				SetFillStylePattern{PatternID: 0},
+				SetStrokeStylePattern{PatternID: 0},
+				ReleasePattern{PatternID: 0},
+				CreateLinearGradient{ID: 0, X0: 1, Y0: 2, X1: 3, Y1: 4},
+				GradientAddColorStop{GradientID: 0, Offset: 0, Color: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+				GradientAddColorStopString{GradientID: 0, Offset: 1, Color: "blue"},
+				CreateRadialGradient{ID: 1, X0: 1, Y0: 2, R0: 3, X1: 4, Y1: 5, R1: 6},
+				SetFillStyleGradient{GradientID: 0},
+				SetStrokeStyleGradient{GradientID: 1},
+				ReleaseGradient{GradientID: 0},
+				ReleaseGradient{GradientID: 1},
+				ReleaseImageData{ImageID: 0},
+				GetImageData{ID: 1, SX: 0, SY: 0, SW: 10, SH: 20},
+				CreateImageFromEncoded{ID: 2, MimeType: "image/webp", Data: []byte{1, 2, 3}},
+				LoadImage{ID: 3, URL: "static/a.png"},
+				CreateImageData{ID: 4, Width: 17, Height: 1, Pix: make([]byte, 17*4)},
+				CreateImageData{ID: 4, Width: 17, Height: 1, Pix: make([]byte, 17*4)},
+				UpdateImageData{ImageID: 4, Tiles: []ImageTile{
+					{X: 16, Y: 0, Width: 1, Height: 1, Pix: []byte{0xff, 0, 0, 0}},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := flushed(tt.draw)
+			got, err := Decode(frame)
+			if err != nil {
+				t.Fatalf("did not expect error, but got error: %s", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got)\n%s", diff)
+			}
+			reencoded := Encode(got)
+			if diff := cmp.Diff(frame, reencoded); diff != "" {
+				t.Errorf("Encode(Decode(frame)) mismatch (-want, +got)\n%s", diff)
+			}
+
+			compact := flushedWith(tt.draw, &canvas.Options{Encoding: canvas.EncodingCompact})
+			if len(compact) >= len(frame) {
+				t.Errorf("compact frame has %d bytes, want less than %d", len(compact), len(frame))
+			}
+			got, err = Decode(compact)
+			if err != nil {
+				t.Fatalf("compact encoding: did not expect error, but got error: %s", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("compact encoding: mismatch (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		frame      []byte
+		want       []Command
+		wantOffset int
+	}{
+		{"unknown opcode", []byte{0x00}, nil, 0},
+		{"unused opcode", []byte{0x03, 0x0c}, []Command{BeginPath{}}, 1},
+		{"data too short", []byte{0x1f, 0x40, 0x24}, nil, 0},
+		{"string too short", []byte{0x0f, 0x13, 0x00, 0x00, 0x00, 0x05, 0x61}, []Command{Fill{}}, 1},
+		{"compact data too short", []byte{0x47, 0x1f, 0x41, 0x20, 0x00, 0x00, 0x40}, nil, 1},
+		{"compact varint too short", []byte{0x47, 0x0f, 0x16, 0x80}, []Command{Fill{}}, 2},
+		{"compact varint too large", []byte{0x47, 0x16, 0xff, 0xff, 0xff, 0xff, 0x7f}, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.frame)
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("expected %T error, but got: %#v", decodeErr, err)
+			}
+			if decodeErr.Offset != tt.wantOffset {
+				t.Errorf("got error offset %d, want: %d", decodeErr.Offset, tt.wantOffset)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		cmd  Command
+		want string
+	}{
+		{BeginPath{}, "BeginPath()"},
+		{FillRect{X: 10, Y: 10, Width: 50.5, Height: 20}, "FillRect(10, 10, 50.5, 20)"},
+		{Arc{X: 1, Y: 2, Radius: 3, StartAngle: 0, EndAngle: 6.25, Anticlockwise: true}, "Arc(1, 2, 3, 0, 6.25, true)"},
+		{SetFillStyle{Color: color.RGBA{R: 0xff, G: 0x80, A: 0x7f}}, "SetFillStyle(rgba(255, 128, 0, 0.5))"},
+		{FillText{X: 1, Y: 2, Text: "hello"}, `FillText(1, 2, "hello")`},
+		{SetLineCap{Cap: canvas.CapSquare}, "SetLineCap(CapSquare)"},
+		{SetGlobalCompositeOperation{Mode: canvas.OpXOR}, "SetGlobalCompositeOperation(OpXOR)"},
+		{SetTextBaseline{Baseline: 42}, "SetTextBaseline(42)"},
+		{SetLineDash{Segments: []float64{5, 10.5}}, "SetLineDash([5 10.5])"},
+		{Polygon{Points: []float64{1, 2, 3.5, 4}}, "Polygon([1 2 3.5 4])"},
+		{Points{Points: []float64{1, 2}, Radius: 0.5}, "Points([1 2], 0.5)"},
+		{CreateImageData{ID: 3, Width: 2, Height: 1, Pix: make([]byte, 8)}, "CreateImageData(image#3, 2, 1, [8 bytes])"},
+		{CreatePattern{ID: 1, ImageID: 3, Repetition: canvas.PatternNoRepeat}, "CreatePattern(pattern#1, image#3, PatternNoRepeat)"},
+		{SetStrokeStyleGradient{GradientID: 2}, "SetStrokeStyleGradient(gradient#2)"},
+		{DrawImageInstances{ImageID: 4, Instances: make([]canvas.SpriteInstance, 3)}, "DrawImageInstances(image#4, [3 instances])"},
+		{Snapshot{ID: 1, Format: "image/jpeg", Quality: 0.8}, `Snapshot(snapshot#1, "image/jpeg", 0.8)`},
+		{CreateImageFromEncoded{ID: 2, MimeType: "image/png", Data: make([]byte, 5)}, `CreateImageFromEncoded(image#2, "image/png", [5 bytes])`},
+		{LoadImage{ID: 3, URL: "static/a.png"}, `LoadImage(image#3, "static/a.png")`},
+		{UpdateImageData{ImageID: 4, Tiles: make([]ImageTile, 2)}, `UpdateImageData(image#4, [2 tiles])`},
+	}
+	for _, tt := range tests {
+		got := tt.cmd.String()
+		if got != tt.want {
+			t.Errorf("got: %s, want: %s", got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	cmds := []Command{
+		SetFillStyle{Color: color.RGBA{R: 0xff, A: 0xff}},
+		FillRect{X: 10, Y: 20, Width: 120, Height: 80},
+	}
+	want := flushed(func(ctx *canvas.Context) {
+		ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+		ctx.FillRect(10, 20, 120, 80)
+	})
+	got := Encode(cmds)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestEncodeDecodeSnapshot(t *testing.T) {
+	// Snapshot commands can only be created by a Context that is connected
+	// to a client, so they are tested by encoding and decoding.
+	cmds := []Command{
+		Snapshot{ID: 3, Format: "image/webp", Quality: 0.75},
+		FillRect{X: 1, Y: 2, Width: 3, Height: 4},
+	}
+	got, err := Decode(Encode(cmds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(cmds, got); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func flushed(draw func(*canvas.Context)) []byte {
+	return flushedWith(draw, nil)
+}
+
+func flushedWith(draw func(*canvas.Context), opts *canvas.Options) []byte {
+	draws := make(chan []byte)
+	ctx := canvas.NewContext(draws, nil, opts)
+	go func() {
+		draw(ctx)
+		ctx.Flush()
+	}()
+	return <-draws
+}
@@ -0,0 +1,630 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package command
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/fzipp/canvas"
+)
+
+// Decode decodes a frame of draw commands, as sent by canvas.Context.Flush,
+// into a sequence of commands. Frames in the standard encoding and in the
+// compact encoding (see canvas.EncodingCompact) are decoded.
+//
+// If the frame is malformed, Decode returns the commands decoded up to the
+// malformed command and an error.
+func Decode(frame []byte) ([]Command, error) {
+	r := &reader{bytes: frame}
+	var cmds []Command
+	for len(r.bytes) > 0 {
+		if r.bytes[0] == bCompact {
+			r.compact = true
+			r.bytes = r.bytes[1:]
+			continue
+		}
+		offset := len(frame) - len(r.bytes)
+		cmd := decodeCommand(r)
+		if r.err != nil {
+			return cmds, &DecodeError{Offset: offset, Err: r.err}
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+// DecodeError describes a malformed command in a frame.
+type DecodeError struct {
+	// Offset is the byte offset of the malformed command in the frame.
+	Offset int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("command: decoding command at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrDataTooShort is returned (wrapped in a DecodeError) by Decode if a
+// frame ends in the middle of a command.
+var ErrDataTooShort = errors.New("data too short")
+
+var errInvalidVarint = errors.New("invalid variable-length integer")
+
+func decodeCommand(r *reader) Command {
+	opcode := r.readByte()
+	switch opcode {
+	case bArc:
+		return Arc{
+			X:             r.readFloat64(),
+			Y:             r.readFloat64(),
+			Radius:        r.readFloat64(),
+			StartAngle:    r.readFloat64(),
+			EndAngle:      r.readFloat64(),
+			Anticlockwise: r.readBool(),
+		}
+	case bArcTo:
+		return ArcTo{
+			X1:     r.readFloat64(),
+			Y1:     r.readFloat64(),
+			X2:     r.readFloat64(),
+			Y2:     r.readFloat64(),
+			Radius: r.readFloat64(),
+		}
+	case bBeginPath:
+		return BeginPath{}
+	case bBezierCurveTo:
+		return BezierCurveTo{
+			CP1X: r.readFloat64(),
+			CP1Y: r.readFloat64(),
+			CP2X: r.readFloat64(),
+			CP2Y: r.readFloat64(),
+			X:    r.readFloat64(),
+			Y:    r.readFloat64(),
+		}
+	case bClearRect:
+		return ClearRect{
+			X:      r.readFloat64(),
+			Y:      r.readFloat64(),
+			Width:  r.readFloat64(),
+			Height: r.readFloat64(),
+		}
+	case bClip:
+		return Clip{}
+	case bClosePath:
+		return ClosePath{}
+	case bCreateImageData:
+		c := CreateImageData{
+			ID:     r.readUint32(),
+			Width:  int(r.readUint32()),
+			Height: int(r.readUint32()),
+		}
+		c.Pix = r.readBytes(c.Width * c.Height * 4)
+		return c
+	case bCreateLinearGradient:
+		return CreateLinearGradient{
+			ID: r.readUint32(),
+			X0: r.readFloat64(),
+			Y0: r.readFloat64(),
+			X1: r.readFloat64(),
+			Y1: r.readFloat64(),
+		}
+	case bCreatePattern:
+		return CreatePattern{
+			ID:         r.readUint32(),
+			ImageID:    r.readUint32(),
+			Repetition: canvas.PatternRepetition(r.readByte()),
+		}
+	case bCreateRadialGradient:
+		return CreateRadialGradient{
+			ID: r.readUint32(),
+			X0: r.readFloat64(),
+			Y0: r.readFloat64(),
+			R0: r.readFloat64(),
+			X1: r.readFloat64(),
+			Y1: r.readFloat64(),
+			R1: r.readFloat64(),
+		}
+	case bDrawImage:
+		return DrawImage{
+			ImageID: r.readUint32(),
+			DX:      r.readFloat64(),
+			DY:      r.readFloat64(),
+		}
+	case bEllipse:
+		return Ellipse{
+			X:             r.readFloat64(),
+			Y:             r.readFloat64(),
+			RadiusX:       r.readFloat64(),
+			RadiusY:       r.readFloat64(),
+			Rotation:      r.readFloat64(),
+			StartAngle:    r.readFloat64(),
+			EndAngle:      r.readFloat64(),
+			Anticlockwise: r.readBool(),
+		}
+	case bFill:
+		return Fill{}
+	case bFillRect:
+		return FillRect{
+			X:      r.readFloat64(),
+			Y:      r.readFloat64(),
+			Width:  r.readFloat64(),
+			Height: r.readFloat64(),
+		}
+	case bFillStyle:
+		return SetFillStyle{
+			Color: r.readColor(),
+		}
+	case bFillText:
+		return FillText{
+			X:    r.readFloat64(),
+			Y:    r.readFloat64(),
+			Text: r.readString(),
+		}
+	case bFont:
+		return SetFont{
+			Font: r.readString(),
+		}
+	case bGradientAddColorStop:
+		return GradientAddColorStop{
+			GradientID: r.readUint32(),
+			Offset:     r.readFloat64(),
+			Color:      r.readColor(),
+		}
+	case bGradientAddColorStopString:
+		return GradientAddColorStopString{
+			GradientID: r.readUint32(),
+			Offset:     r.readFloat64(),
+			Color:      r.readString(),
+		}
+	case bFillStyleGradient:
+		return SetFillStyleGradient{
+			GradientID: r.readUint32(),
+		}
+	case bGlobalAlpha:
+		return SetGlobalAlpha{
+			Alpha: r.readFloat64(),
+		}
+	case bGlobalCompositeOperation:
+		return SetGlobalCompositeOperation{
+			Mode: canvas.CompositeOperation(r.readByte()),
+		}
+	case bImageSmoothingEnabled:
+		return SetImageSmoothingEnabled{
+			Enabled: r.readBool(),
+		}
+	case bStrokeStyleGradient:
+		return SetStrokeStyleGradient{
+			GradientID: r.readUint32(),
+		}
+	case bReleasePattern:
+		return ReleasePattern{
+			PatternID: r.readUint32(),
+		}
+	case bLineCap:
+		return SetLineCap{
+			Cap: canvas.LineCap(r.readByte()),
+		}
+	case bLineDashOffset:
+		return SetLineDashOffset{
+			Offset: r.readFloat64(),
+		}
+	case bLineJoin:
+		return SetLineJoin{
+			Join: canvas.LineJoin(r.readByte()),
+		}
+	case bLineTo:
+		return LineTo{
+			X: r.readFloat64(),
+			Y: r.readFloat64(),
+		}
+	case bLineWidth:
+		return SetLineWidth{
+			Width: r.readFloat64(),
+		}
+	case bReleaseGradient:
+		return ReleaseGradient{
+			GradientID: r.readUint32(),
+		}
+	case bMiterLimit:
+		return SetMiterLimit{
+			Value: r.readFloat64(),
+		}
+	case bMoveTo:
+		return MoveTo{
+			X: r.readFloat64(),
+			Y: r.readFloat64(),
+		}
+	case bPutImageData:
+		return PutImageData{
+			ImageID: r.readUint32(),
+			DX:      r.readFloat64(),
+			DY:      r.readFloat64(),
+		}
+	case bQuadraticCurveTo:
+		return QuadraticCurveTo{
+			CPX: r.readFloat64(),
+			CPY: r.readFloat64(),
+			X:   r.readFloat64(),
+			Y:   r.readFloat64(),
+		}
+	case bRect:
+		return Rect{
+			X:      r.readFloat64(),
+			Y:      r.readFloat64(),
+			Width:  r.readFloat64(),
+			Height: r.readFloat64(),
+		}
+	case bRestore:
+		return Restore{}
+	case bRotate:
+		return Rotate{
+			Angle: r.readFloat64(),
+		}
+	case bSave:
+		return Save{}
+	case bScale:
+		return Scale{
+			X: r.readFloat64(),
+			Y: r.readFloat64(),
+		}
+	case bSetLineDash:
+		return SetLineDash{
+			Segments: r.readFloat64s(),
+		}
+	case bSetTransform:
+		return SetTransform{
+			A: r.readFloat64(),
+			B: r.readFloat64(),
+			C: r.readFloat64(),
+			D: r.readFloat64(),
+			E: r.readFloat64(),
+			F: r.readFloat64(),
+		}
+	case bShadowBlur:
+		return SetShadowBlur{
+			Level: r.readFloat64(),
+		}
+	case bShadowColor:
+		return SetShadowColor{
+			Color: r.readColor(),
+		}
+	case bShadowOffsetX:
+		return SetShadowOffsetX{
+			Offset: r.readFloat64(),
+		}
+	case bShadowOffsetY:
+		return SetShadowOffsetY{
+			Offset: r.readFloat64(),
+		}
+	case bStroke:
+		return Stroke{}
+	case bStrokeRect:
+		return StrokeRect{
+			X:      r.readFloat64(),
+			Y:      r.readFloat64(),
+			Width:  r.readFloat64(),
+			Height: r.readFloat64(),
+		}
+	case bStrokeStyle:
+		return SetStrokeStyle{
+			Color: r.readColor(),
+		}
+	case bStrokeText:
+		return StrokeText{
+			X:    r.readFloat64(),
+			Y:    r.readFloat64(),
+			Text: r.readString(),
+		}
+	case bTextAlign:
+		return SetTextAlign{
+			Align: canvas.TextAlign(r.readByte()),
+		}
+	case bTextBaseline:
+		return SetTextBaseline{
+			Baseline: canvas.TextBaseline(r.readByte()),
+		}
+	case bTransform:
+		return Transform{
+			A: r.readFloat64(),
+			B: r.readFloat64(),
+			C: r.readFloat64(),
+			D: r.readFloat64(),
+			E: r.readFloat64(),
+			F: r.readFloat64(),
+		}
+	case bTranslate:
+		return Translate{
+			X: r.readFloat64(),
+			Y: r.readFloat64(),
+		}
+	case bFillTextMaxWidth:
+		return FillTextMaxWidth{
+			X:        r.readFloat64(),
+			Y:        r.readFloat64(),
+			MaxWidth: r.readFloat64(),
+			Text:     r.readString(),
+		}
+	case bStrokeTextMaxWidth:
+		return StrokeTextMaxWidth{
+			X:        r.readFloat64(),
+			Y:        r.readFloat64(),
+			MaxWidth: r.readFloat64(),
+			Text:     r.readString(),
+		}
+	case bFillStyleString:
+		return SetFillStyleString{
+			Color: r.readString(),
+		}
+	case bStrokeStyleString:
+		return SetStrokeStyleString{
+			Color: r.readString(),
+		}
+	case bShadowColorString:
+		return SetShadowColorString{
+			Color: r.readString(),
+		}
+	case bPutImageDataDirty:
+		return PutImageDataDirty{
+			ImageID:     r.readUint32(),
+			DX:          r.readFloat64(),
+			DY:          r.readFloat64(),
+			DirtyX:      r.readFloat64(),
+			DirtyY:      r.readFloat64(),
+			DirtyWidth:  r.readFloat64(),
+			DirtyHeight: r.readFloat64(),
+		}
+	case bDrawImageScaled:
+		return DrawImageScaled{
+			ImageID: r.readUint32(),
+			DX:      r.readFloat64(),
+			DY:      r.readFloat64(),
+			DWidth:  r.readFloat64(),
+			DHeight: r.readFloat64(),
+		}
+	case bDrawImageSubRectangle:
+		return DrawImageSubRectangle{
+			ImageID: r.readUint32(),
+			SX:      r.readFloat64(),
+			SY:      r.readFloat64(),
+			SWidth:  r.readFloat64(),
+			SHeight: r.readFloat64(),
+			DX:      r.readFloat64(),
+			DY:      r.readFloat64(),
+			DWidth:  r.readFloat64(),
+			DHeight: r.readFloat64(),
+		}
+	case bReleaseImageData:
+		return ReleaseImageData{
+			ImageID: r.readUint32(),
+		}
+	case bFillStylePattern:
+		return SetFillStylePattern{
+			PatternID: r.readUint32(),
+		}
+	case bStrokeStylePattern:
+		return SetStrokeStylePattern{
+			PatternID: r.readUint32(),
+		}
+	case bGetImageData:
+		return GetImageData{
+			ID: r.readUint32(),
+			SX: r.readFloat64(),
+			SY: r.readFloat64(),
+			SW: r.readFloat64(),
+			SH: r.readFloat64(),
+		}
+	case bSetTextInputRect:
+		return SetTextInputRect{
+			X:      r.readFloat64(),
+			Y:      r.readFloat64(),
+			Width:  r.readFloat64(),
+			Height: r.readFloat64(),
+		}
+	case bSnapshot:
+		return Snapshot{
+			ID:      r.readUint32(),
+			Format:  r.readString(),
+			Quality: r.readFloat64(),
+		}
+	case bPolyline:
+		return Polyline{
+			Points: r.readFloat64s(),
+		}
+	case bPolygon:
+		return Polygon{
+			Points: r.readFloat64s(),
+		}
+	case bPoints:
+		p := Points{Radius: r.readFloat64()}
+		p.Points = r.readFloat64s()
+		return p
+	case bDrawImageInstances:
+		return DrawImageInstances{
+			ImageID:   r.readUint32(),
+			Instances: r.readSpriteInstances(),
+		}
+	case bCreateImageFromEncoded:
+		c := CreateImageFromEncoded{
+			ID:       r.readUint32(),
+			MimeType: r.readString(),
+		}
+		c.Data = r.readBytes(int(r.readUint32()))
+		return c
+	case bLoadImage:
+		return LoadImage{
+			ID:  r.readUint32(),
+			URL: r.readString(),
+		}
+	case bUpdateImageData:
+		return UpdateImageData{
+			ImageID: r.readUint32(),
+			Tiles:   r.readImageTiles(),
+		}
+	}
+	r.fail(fmt.Errorf("unknown opcode: %#x", opcode))
+	return nil
+}
+
+type reader struct {
+	bytes []byte
+	err   error
+	// compact is set after the marker of the compact encoding.
+	compact bool
+}
+
+func (r *reader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.bytes = nil
+}
+
+func (r *reader) readBytes(n int) []byte {
+	if n < 0 || len(r.bytes) < n {
+		r.fail(ErrDataTooShort)
+		return nil
+	}
+	p := r.bytes[:n:n]
+	r.bytes = r.bytes[n:]
+	return p
+}
+
+func (r *reader) readByte() byte {
+	p := r.readBytes(1)
+	if p == nil {
+		return 0
+	}
+	return p[0]
+}
+
+func (r *reader) readBool() bool {
+	return r.readByte() != 0
+}
+
+// readUint32 reads an ID or a length.
+func (r *reader) readUint32() uint32 {
+	if r.compact {
+		i, n := binary.Uvarint(r.bytes)
+		if n == 0 {
+			r.fail(ErrDataTooShort)
+			return 0
+		}
+		if n < 0 || i > math.MaxUint32 {
+			r.fail(errInvalidVarint)
+			return 0
+		}
+		r.bytes = r.bytes[n:]
+		return uint32(i)
+	}
+	p := r.readBytes(4)
+	if p == nil {
+		return 0
+	}
+	return byteOrder.Uint32(p)
+}
+
+func (r *reader) readFloat64() float64 {
+	if r.compact {
+		p := r.readBytes(4)
+		if p == nil {
+			return 0
+		}
+		return float64(math.Float32frombits(byteOrder.Uint32(p)))
+	}
+	p := r.readBytes(8)
+	if p == nil {
+		return 0
+	}
+	return math.Float64frombits(byteOrder.Uint64(p))
+}
+
+func (r *reader) readImageTiles() []ImageTile {
+	n := int(r.readUint32())
+	// A tile is encoded with at least 4 bytes for its rectangle.
+	if len(r.bytes) < n*4 {
+		r.fail(ErrDataTooShort)
+		return nil
+	}
+	tiles := make([]ImageTile, n)
+	for i := range tiles {
+		t := ImageTile{
+			X:      int(r.readUint32()),
+			Y:      int(r.readUint32()),
+			Width:  int(r.readUint32()),
+			Height: int(r.readUint32()),
+		}
+		t.Pix = r.readBytes(t.Width * t.Height * 4)
+		tiles[i] = t
+	}
+	return tiles
+}
+
+func (r *reader) readSpriteInstances() []canvas.SpriteInstance {
+	n := int(r.readUint32())
+	size := 8
+	if r.compact {
+		size = 4
+	}
+	// A SpriteInstance is encoded as 9 floats.
+	if len(r.bytes) < n*9*size {
+		r.fail(ErrDataTooShort)
+		return nil
+	}
+	instances := make([]canvas.SpriteInstance, n)
+	for i := range instances {
+		instances[i] = canvas.SpriteInstance{
+			X:        r.readFloat64(),
+			Y:        r.readFloat64(),
+			Scale:    r.readFloat64(),
+			Rotation: r.readFloat64(),
+			Alpha:    r.readFloat64(),
+			SX:       r.readFloat64(),
+			SY:       r.readFloat64(),
+			SWidth:   r.readFloat64(),
+			SHeight:  r.readFloat64(),
+		}
+	}
+	return instances
+}
+
+func (r *reader) readFloat64s() []float64 {
+	n := int(r.readUint32())
+	size := 8
+	if r.compact {
+		size = 4
+	}
+	if len(r.bytes) < n*size {
+		r.fail(ErrDataTooShort)
+		return nil
+	}
+	fs := make([]float64, n)
+	for i := range fs {
+		fs[i] = r.readFloat64()
+	}
+	return fs
+}
+
+func (r *reader) readString() string {
+	return string(r.readBytes(int(r.readUint32())))
+}
+
+func (r *reader) readColor() color.RGBA {
+	p := r.readBytes(4)
+	if p == nil {
+		return color.RGBA{}
+	}
+	return color.RGBA{R: p[0], G: p[1], B: p[2], A: p[3]}
+}
+
+var byteOrder = binary.BigEndian
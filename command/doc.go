@@ -0,0 +1,119 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package command decodes and encodes the binary draw command format that is
+// sent from a canvas.Context to the client when the Context is flushed.
+//
+// Each command type corresponds to a drawing method of canvas.Context (or of
+// canvas.Gradient, canvas.ImageData or canvas.Pattern) and has the method's
+// arguments as fields. Images, gradients and patterns are referred to by
+// IDs, which the Context assigns in creation order, starting with 0 for
+// each kind of object.
+//
+// Decode turns a flushed frame into a sequence of commands, Encode turns a
+// sequence of commands back into a frame. Together they allow logging,
+// diffing, filtering and transforming command streams:
+//
+//	cmds, err := command.Decode(frame)
+//	if err != nil {
+//		return err
+//	}
+//	for _, cmd := range cmds {
+//		fmt.Println(cmd)
+//	}
+package command
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Command is a single draw command. The String method returns a
+// human-readable form of the command in Go method call syntax,
+// for example "FillRect(10, 10, 50, 20)".
+type Command interface {
+	String() string
+	appendTo(p []byte) []byte
+}
+
+// Encode encodes the given commands into a frame in the binary draw command
+// format.
+func Encode(cmds []Command) []byte {
+	return Append(nil, cmds...)
+}
+
+// Append appends the binary encoding of the given commands to frame and
+// returns the extended frame.
+func Append(frame []byte, cmds ...Command) []byte {
+	for _, cmd := range cmds {
+		frame = cmd.appendTo(frame)
+	}
+	return frame
+}
+
+func appendFloat64(p []byte, f float64) []byte {
+	return byteOrder.AppendUint64(p, math.Float64bits(f))
+}
+
+func appendFloat64s(p []byte, fs []float64) []byte {
+	p = appendUint32(p, uint32(len(fs)))
+	for _, f := range fs {
+		p = appendFloat64(p, f)
+	}
+	return p
+}
+
+func appendUint32(p []byte, i uint32) []byte {
+	return byteOrder.AppendUint32(p, i)
+}
+
+func appendBool(p []byte, b bool) []byte {
+	if b {
+		return append(p, 1)
+	}
+	return append(p, 0)
+}
+
+func appendString(p []byte, s string) []byte {
+	p = appendUint32(p, uint32(len(s)))
+	return append(p, s...)
+}
+
+func appendColor(p []byte, c color.RGBA) []byte {
+	return append(p, c.R, c.G, c.B, c.A)
+}
+
+func call(name string, args ...string) string {
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func fmtFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func fmtFloats(fs []float64) string {
+	s := make([]string, len(fs))
+	for i, f := range fs {
+		s[i] = fmtFloat(f)
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+func fmtID(kind string, id uint32) string {
+	return kind + "#" + strconv.FormatUint(uint64(id), 10)
+}
+
+func fmtColor(c color.RGBA) string {
+	return "rgba(" +
+		strconv.Itoa(int(c.R)) + ", " +
+		strconv.Itoa(int(c.G)) + ", " +
+		strconv.Itoa(int(c.B)) + ", " +
+		fmtFloat(math.Round(float64(c.A)/255*100)/100) + ")"
+}
+
+func fmtBytes(p []byte) string {
+	return "[" + strconv.Itoa(len(p)) + " bytes]"
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package command
+
+import (
+	"strconv"
+
+	"github.com/fzipp/canvas"
+)
+
+var patternRepetitionNames = []string{
+	"PatternRepeat", "PatternRepeatX", "PatternRepeatY", "PatternNoRepeat",
+}
+
+var compositeOperationNames = []string{
+	"OpSourceOver", "OpSourceIn", "OpSourceOut", "OpSourceAtop",
+	"OpDestinationOver", "OpDestinationIn", "OpDestinationOut",
+	"OpDestinationAtop", "OpLighter", "OpCopy", "OpXOR", "OpMultiply",
+	"OpScreen", "OpOverlay", "OpDarken", "OpLighten", "OpColorDodge",
+	"OpColorBurn", "OpHardLight", "OpSoftLight", "OpDifference",
+	"OpExclusion", "OpHue", "OpSaturation", "OpColor", "OpLuminosity",
+}
+
+var lineCapNames = []string{"CapButt", "CapRound", "CapSquare"}
+
+var lineJoinNames = []string{"JoinMiter", "JoinRound", "JoinBevel"}
+
+var textAlignNames = []string{
+	"AlignStart", "AlignEnd", "AlignLeft", "AlignRight", "AlignCenter",
+}
+
+var textBaselineNames = []string{
+	"BaselineAlphabetic", "BaselineIdeographic", "BaselineTop",
+	"BaselineBottom", "BaselineHanging", "BaselineMiddle",
+}
+
+func fmtPatternRepetition(r canvas.PatternRepetition) string {
+	return enumName(patternRepetitionNames, byte(r))
+}
+
+func fmtCompositeOperation(op canvas.CompositeOperation) string {
+	return enumName(compositeOperationNames, byte(op))
+}
+
+func fmtLineCap(c canvas.LineCap) string {
+	return enumName(lineCapNames, byte(c))
+}
+
+func fmtLineJoin(j canvas.LineJoin) string {
+	return enumName(lineJoinNames, byte(j))
+}
+
+func fmtTextAlign(a canvas.TextAlign) string {
+	return enumName(textAlignNames, byte(a))
+}
+
+func fmtTextBaseline(b canvas.TextBaseline) string {
+	return enumName(textBaselineNames, byte(b))
+}
+
+func enumName(names []string, v byte) string {
+	if int(v) < len(names) {
+		return names[v]
+	}
+	return strconv.Itoa(int(v))
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package command
+
+// Opcodes of the draw command format. They must match the opcodes in
+// enums.go of package canvas and in canvas-websocket.js.
+const (
+	bArc byte = 1 + iota
+	bArcTo
+	bBeginPath
+	bBezierCurveTo
+	bClearRect
+	bClip
+	bClosePath
+	bCreateImageData
+	bCreateLinearGradient
+	bCreatePattern
+	bCreateRadialGradient
+	_
+	bDrawImage
+	bEllipse
+	bFill
+	bFillRect
+	bFillStyle
+	bFillText
+	bFont
+	bGradientAddColorStop
+	bGradientAddColorStopString
+	bFillStyleGradient
+	bGlobalAlpha
+	bGlobalCompositeOperation
+	bImageSmoothingEnabled
+	bStrokeStyleGradient
+	bReleasePattern
+	bLineCap
+	bLineDashOffset
+	bLineJoin
+	bLineTo
+	bLineWidth
+	bReleaseGradient
+	bMiterLimit
+	bMoveTo
+	bPutImageData
+	bQuadraticCurveTo
+	bRect
+	bRestore
+	bRotate
+	bSave
+	bScale
+	bSetLineDash
+	bSetTransform
+	bShadowBlur
+	bShadowColor
+	bShadowOffsetX
+	bShadowOffsetY
+	bStroke
+	bStrokeRect
+	bStrokeStyle
+	bStrokeText
+	bTextAlign
+	bTextBaseline
+	bTransform
+	bTranslate
+	bFillTextMaxWidth
+	bStrokeTextMaxWidth
+	bFillStyleString
+	bStrokeStyleString
+	bShadowColorString
+	bPutImageDataDirty
+	bDrawImageScaled
+	bDrawImageSubRectangle
+	bReleaseImageData
+	bFillStylePattern
+	bStrokeStylePattern
+	bGetImageData
+	bSetTextInputRect
+	bSnapshot
+	// bCompact marks the rest of a frame as encoded in the compact
+	// encoding, see canvas.EncodingCompact.
+	bCompact
+	bPolyline
+	bPolygon
+	bPoints
+	bDrawImageInstances
+	bCreateImageFromEncoded
+	bLoadImage
+	bUpdateImageData
+)
@@ -0,0 +1,201 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"bufio"
+	"compress/flate"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// Compression configures the compression of the draw frames that are sent
+// to the client with the WebSocket permessage-deflate extension. The
+// extension is negotiated with the web browser when the client connects;
+// if the browser does not support it, the frames are sent uncompressed.
+//
+// Compression pays off for large frames, for example frames with images
+// created with CreateImageData or dense plots, especially on slow network
+// connections. It costs CPU time on the server and the client.
+type Compression struct {
+	// Level sets the compression level, from flate.HuffmanOnly (-2) and
+	// flate.BestSpeed (1) to flate.BestCompression (9).
+	// If Level is not set (i.e. 0) a default value of flate.BestSpeed
+	// will be used.
+	Level int
+	// Threshold sets the minimum size in bytes of a frame to be
+	// compressed. Smaller frames are sent uncompressed, because the
+	// compression of small frames saves few bytes, if any.
+	// If Threshold is not set (i.e. 0) a default value of 512 bytes
+	// will be used.
+	Threshold int
+}
+
+func (c *Compression) level() int {
+	if c.Level == 0 {
+		return flate.BestSpeed
+	}
+	return c.Level
+}
+
+func (c *Compression) threshold() int {
+	if c.Threshold == 0 {
+		return 512
+	}
+	return c.Threshold
+}
+
+// ConnectionStats are statistics of the frames sent to a client.
+type ConnectionStats struct {
+	// Frames is the number of frames sent to the client.
+	Frames int64
+	// CompressedFrames is the number of frames that were sent with
+	// compression enabled.
+	CompressedFrames int64
+	// Bytes is the total size of the frames in the binary draw command
+	// format, before compression.
+	Bytes int64
+	// WireBytes is the total number of bytes written to the network
+	// connection after the WebSocket handshake, including the WebSocket
+	// framing and the control messages.
+	WireBytes int64
+}
+
+// CompressionRatio returns the ratio of Bytes to WireBytes, or 0 if no
+// bytes were written. A ratio greater than 1 means that the compression
+// reduced the transmitted data.
+func (s ConnectionStats) CompressionRatio() float64 {
+	if s.WireBytes == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.WireBytes)
+}
+
+// connStats collects the statistics of a connection.
+type connStats struct {
+	frames           atomic.Int64
+	compressedFrames atomic.Int64
+	bytes            atomic.Int64
+	wire             *countingConn
+	// handshake is the number of bytes written for the WebSocket
+	// handshake.
+	handshake int64
+}
+
+func (s *connStats) addFrame(size int, compressed bool) {
+	s.frames.Add(1)
+	if compressed {
+		s.compressedFrames.Add(1)
+	}
+	s.bytes.Add(int64(size))
+}
+
+func (s *connStats) snapshot() ConnectionStats {
+	if s == nil {
+		return ConnectionStats{}
+	}
+	var wireBytes int64
+	if s.wire != nil {
+		wireBytes = s.wire.written.Load() - s.handshake
+	}
+	return ConnectionStats{
+		Frames:           s.frames.Load(),
+		CompressedFrames: s.compressedFrames.Load(),
+		Bytes:            s.bytes.Load(),
+		WireBytes:        wireBytes,
+	}
+}
+
+// Stats returns the statistics of the frames sent to the client so far,
+// for example to monitor the effect of Options.Compression. The
+// statistics are zero if the Context is not connected to a client.
+func (ctx *Context) Stats() ConnectionStats {
+	return ctx.stats.snapshot()
+}
+
+// countingConn is a net.Conn that counts the bytes written to it.
+type countingConn struct {
+	net.Conn
+	written atomic.Int64
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+// countingResponseWriter is an http.ResponseWriter whose hijacked
+// connection counts the bytes written to it, so that the WebSocket
+// connection created by the upgrader can be measured.
+type countingResponseWriter struct {
+	http.ResponseWriter
+	conn *countingConn
+}
+
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("canvas: response does not implement http.Hijacker")
+	}
+	conn, brw, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.conn = &countingConn{Conn: conn}
+	return w.conn, brw, nil
+}
+
+// upgrade upgrades the HTTP connection to a WebSocket connection, with
+// compression negotiated according to the options, and returns the
+// connection and its statistics.
+func upgrade(w http.ResponseWriter, r *http.Request, opts *Options) (*websocket.Conn, *connStats, error) {
+	u := upgrader
+	u.EnableCompression = opts.Compression != nil
+	cw := &countingResponseWriter{ResponseWriter: w}
+	conn, err := u.Upgrade(cw, r, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats := &connStats{wire: cw.conn}
+	if cw.conn != nil {
+		stats.handshake = cw.conn.written.Load()
+	}
+	if opts.Compression != nil {
+		if err := conn.SetCompressionLevel(opts.Compression.level()); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+	}
+	return conn, stats, nil
+}
+
+// compressionThreshold returns the minimum size of a frame to be
+// compressed on the connection of the given request, or -1 if frames are
+// not compressed because compression is disabled or the client does not
+// offer the permessage-deflate extension.
+func compressionThreshold(r *http.Request, opts *Options) int {
+	if opts.Compression == nil || !offersDeflate(r) {
+		return -1
+	}
+	return opts.Compression.threshold()
+}
+
+func offersDeflate(r *http.Request) bool {
+	for _, header := range r.Header.Values("Sec-WebSocket-Extensions") {
+		for _, ext := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(ext, ";")
+			if strings.EqualFold(strings.TrimSpace(name), "permessage-deflate") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"image"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCompression(t *testing.T) {
+	tests := []struct {
+		name           string
+		compression    *Compression
+		clientCompress bool
+		wantCompressed bool
+	}{
+		{"disabled", nil, true, false},
+		{"not offered by client", &Compression{}, false, false},
+		{"enabled", &Compression{Level: 9}, true, true},
+		{"below threshold", &Compression{Threshold: 8192}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := make(chan ConnectionStats, 1)
+			srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+				ctx.CreateImageData(image.NewRGBA(image.Rect(0, 0, 32, 32)))
+				ctx.Flush()
+				// The client sends an event after it received the frame.
+				<-ctx.Events()
+				stats <- ctx.Stats()
+			}, &Options{
+				EnabledEvents: []Event{MouseDownEvent{}},
+				Compression:   tt.compression,
+			}))
+			defer srv.Close()
+
+			dialer := websocket.Dialer{EnableCompression: tt.clientCompress}
+			url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/draw"
+			conn, _, err := dialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			sendHello(t, conn, 0)
+			frame := readMessage(t, conn, websocket.BinaryMessage)
+			mouseDown := []byte{evMouseDown, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+			if err := conn.WriteMessage(websocket.BinaryMessage, mouseDown); err != nil {
+				t.Fatal(err)
+			}
+
+			got := <-stats
+			if got.Frames != 1 {
+				t.Errorf("got %d frames, want 1", got.Frames)
+			}
+			if got.Bytes != int64(len(frame)) {
+				t.Errorf("got %d bytes, want %d", got.Bytes, len(frame))
+			}
+			wantCompressedFrames := int64(0)
+			if tt.wantCompressed {
+				wantCompressedFrames = 1
+			}
+			if got.CompressedFrames != wantCompressedFrames {
+				t.Errorf("got %d compressed frames, want %d", got.CompressedFrames, wantCompressedFrames)
+			}
+			if compressed := got.CompressionRatio() > 10; compressed != tt.wantCompressed {
+				t.Errorf("got compression ratio %g (%d of %d bytes), want compressed: %v",
+					got.CompressionRatio(), got.WireBytes, got.Bytes, tt.wantCompressed)
+			}
+		})
+	}
+}
+
+func TestStatsNotConnected(t *testing.T) {
+	ctx := NewContext(make(chan []byte, 1), nil, nil)
+	ctx.FillRect(0, 0, 1, 1)
+	ctx.Flush()
+	if got := ctx.Stats(); got != (ConnectionStats{}) {
+		t.Errorf("got stats %+v, want zero", got)
+	}
+}
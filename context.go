@@ -11,8 +11,10 @@
 package canvas
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"log"
 )
 
 // Context is the server-side drawing context for a client-side canvas. It
@@ -31,6 +33,43 @@ type Context struct {
 	imageDataIDs idGenerator
 	gradientIDs  idGenerator
 	patternIDs   idGenerator
+
+	// snapshots, stats and frames are nil if the Context is not connected
+	// to a client.
+	snapshots *snapshotRequests
+	stats     *connStats
+	frames    *framePool
+
+	// state is the known drawing state of the client canvas, stateStack
+	// the states saved with Save. skippedBytes counts the bytes of the
+	// setters that were skipped because they did not change the state.
+	state        drawingState
+	stateStack   []drawingState
+	skippedBytes int
+}
+
+// NewContext creates a Context that is not connected to a client canvas.
+//
+// Each call of the Flush method sends the buffered drawing operations as one
+// frame in the binary draw command format to the draws channel. The receiver
+// of a frame takes ownership of it. The Events method of the Context returns
+// the given events channel.
+//
+// Most programs obtain their Context from the run function passed to
+// ListenAndServe. NewContext is useful for testing drawing code and for
+// processing draw commands on the server, see for example the canvastest
+// package.
+func NewContext(draws chan<- []byte, events <-chan Event, opts *Options) *Context {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.applyDefaults()
+	ctx := newContext(draws, events, &o)
+	if o.Encoding == EncodingCompact {
+		ctx.useCompactEncoding()
+	}
+	return ctx
 }
 
 func newContext(draws chan<- []byte, events <-chan Event, opts *Options) *Context {
@@ -41,8 +80,20 @@ func newContext(draws chan<- []byte, events <-chan Event, opts *Options) *Contex
 	}
 }
 
+// useCompactEncoding switches the Context to the compact encoding, see
+// EncodingCompact. It must be called before the first draw command.
+func (ctx *Context) useCompactEncoding() {
+	ctx.buf.compact = true
+	ctx.buf.addByte(bCompact)
+}
+
 // Events returns a channel of events sent by the client.
 //
+// The events are not buffered: the server reads the next message from the
+// client only after the previous event was received from the channel, so
+// the run function should receive events regularly. Only while Snapshot
+// waits for its reply, events are queued. No events are dropped.
+//
 // A type switch on the received Event values can differentiate between the
 // concrete event types such as MouseDownEvent or KeyUpEvent.
 func (ctx *Context) Events() <-chan Event {
@@ -62,8 +113,10 @@ func (ctx *Context) CanvasHeight() int {
 // SetFillStyle sets the color to use inside shapes.
 // The default color is black.
 func (ctx *Context) SetFillStyle(c color.Color) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bFillStyle)
 	ctx.buf.addColor(c)
+	ctx.skipUnchanged(start, ctx.state.fillStyle.set(colorStyle(c)))
 }
 
 // SetFillStyleString sets the color to use inside shapes.
@@ -71,8 +124,10 @@ func (ctx *Context) SetFillStyle(c color.Color) {
 // "darkgreen", "rgba(0.5, 0.2, 0.7, 1.0)", etc.
 // The default color is "#000" (black).
 func (ctx *Context) SetFillStyleString(color string) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bFillStyleString)
 	ctx.buf.addString(color)
+	ctx.skipUnchanged(start, ctx.state.fillStyle.set(cssStyle(color)))
 }
 
 // SetFillStyleGradient sets the gradient (a linear or radial gradient) to
@@ -81,6 +136,7 @@ func (ctx *Context) SetFillStyleGradient(g *Gradient) {
 	g.checkUseAfterRelease()
 	ctx.buf.addByte(bFillStyleGradient)
 	ctx.buf.addUint32(g.id)
+	ctx.state.fillStyle.forget()
 }
 
 // SetFillStylePattern sets the pattern (a repeating image) to use inside
@@ -89,14 +145,17 @@ func (ctx *Context) SetFillStylePattern(p *Pattern) {
 	p.checkUseAfterRelease()
 	ctx.buf.addByte(bFillStylePattern)
 	ctx.buf.addUint32(p.id)
+	ctx.state.fillStyle.forget()
 }
 
 // SetFont sets the current text style to use when drawing text. This string
 // uses the same syntax as the CSS font specifier. The default font is
 // "10px sans-serif".
 func (ctx *Context) SetFont(font string) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bFont)
 	ctx.buf.addString(font)
+	ctx.skipUnchanged(start, ctx.state.font.set(font))
 }
 
 // SetGlobalAlpha sets the alpha (transparency) value that is applied to
@@ -105,8 +164,10 @@ func (ctx *Context) SetFont(font string) {
 // (fully opaque), inclusive. The default value is 1.0.
 // Values outside that range, including ±Inf and NaN, will not be set.
 func (ctx *Context) SetGlobalAlpha(alpha float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bGlobalAlpha)
 	ctx.buf.addFloat64(alpha)
+	ctx.skipUnchanged(start, ctx.state.globalAlpha.set(alpha))
 }
 
 // SetGlobalCompositeOperation sets the type of compositing operation to
@@ -114,8 +175,10 @@ func (ctx *Context) SetGlobalAlpha(alpha float64) {
 //
 // The default mode is OpSourceOver.
 func (ctx *Context) SetGlobalCompositeOperation(mode CompositeOperation) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bGlobalCompositeOperation)
 	ctx.buf.addByte(byte(mode))
+	ctx.skipUnchanged(start, ctx.state.globalCompositeOperation.set(mode))
 }
 
 // SetImageSmoothingEnabled determines whether scaled images are smoothed
@@ -125,8 +188,10 @@ func (ctx *Context) SetGlobalCompositeOperation(mode CompositeOperation) {
 // enlarging images, the default resizing algorithm will blur the pixels. Set
 // this property to false to retain the pixels' sharpness.
 func (ctx *Context) SetImageSmoothingEnabled(enabled bool) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bImageSmoothingEnabled)
 	ctx.buf.addBool(enabled)
+	ctx.skipUnchanged(start, ctx.state.imageSmoothingEnabled.set(enabled))
 }
 
 // SetLineCap sets the shape used to draw the end points of lines.
@@ -135,8 +200,10 @@ func (ctx *Context) SetImageSmoothingEnabled(enabled bool) {
 // Note: Lines can be drawn with the Stroke, StrokeRect, and StrokeText
 // methods.
 func (ctx *Context) SetLineCap(cap LineCap) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bLineCap)
 	ctx.buf.addByte(byte(cap))
+	ctx.skipUnchanged(start, ctx.state.lineCap.set(cap))
 }
 
 // SetLineDashOffset sets the line dash offset, or "phase."
@@ -144,8 +211,10 @@ func (ctx *Context) SetLineCap(cap LineCap) {
 //
 // Note: Lines are drawn by calling the Stroke method.
 func (ctx *Context) SetLineDashOffset(offset float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bLineDashOffset)
 	ctx.buf.addFloat64(offset)
+	ctx.skipUnchanged(start, ctx.state.lineDashOffset.set(offset))
 }
 
 // SetLineJoin sets the shape used to join two line segments where they meet.
@@ -159,8 +228,10 @@ func (ctx *Context) SetLineDashOffset(offset float64) {
 // Note: Lines can be drawn with the Stroke, StrokeRect, and StrokeText
 // methods.
 func (ctx *Context) SetLineJoin(join LineJoin) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bLineJoin)
 	ctx.buf.addByte(byte(join))
+	ctx.skipUnchanged(start, ctx.state.lineJoin.set(join))
 }
 
 // SetLineWidth sets the thickness of lines.
@@ -171,8 +242,10 @@ func (ctx *Context) SetLineJoin(join LineJoin) {
 // Note: Lines can be drawn with the Stroke, StrokeRect, and StrokeText
 // methods.
 func (ctx *Context) SetLineWidth(width float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bLineWidth)
 	ctx.buf.addFloat64(width)
+	ctx.skipUnchanged(start, ctx.state.lineWidth.set(width))
 }
 
 // SetMiterLimit sets the miter limit ratio.
@@ -180,8 +253,10 @@ func (ctx *Context) SetLineWidth(width float64) {
 // Zero, negative, ±Inf, and NaN values are ignored.
 // The default value is 10.0.
 func (ctx *Context) SetMiterLimit(value float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bMiterLimit)
 	ctx.buf.addFloat64(value)
+	ctx.skipUnchanged(start, ctx.state.miterLimit.set(value))
 }
 
 // SetShadowBlur sets the amount of blur applied to shadows.
@@ -197,8 +272,10 @@ func (ctx *Context) SetMiterLimit(value float64) {
 // property is set to a non-transparent value. One of the SetShadowBlur,
 // SetShadowOffsetX, or SetShadowOffsetY properties must be non-zero, as well.
 func (ctx *Context) SetShadowBlur(level float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bShadowBlur)
 	ctx.buf.addFloat64(level)
+	ctx.skipUnchanged(start, ctx.state.shadowBlur.set(level))
 }
 
 // SetShadowColor sets the color of shadows.
@@ -212,8 +289,10 @@ func (ctx *Context) SetShadowBlur(level float64) {
 // property is set to a non-transparent value. One of the SetShadowBlur,
 // SetShadowOffsetX, or SetShadowOffsetY properties must be non-zero, as well.
 func (ctx *Context) SetShadowColor(c color.Color) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bShadowColor)
 	ctx.buf.addColor(c)
+	ctx.skipUnchanged(start, ctx.state.shadowColor.set(colorStyle(c)))
 }
 
 // SetShadowColorString sets the color of shadows.
@@ -230,8 +309,10 @@ func (ctx *Context) SetShadowColor(c color.Color) {
 // property is set to a non-transparent value. One of the SetShadowBlur,
 // SetShadowOffsetX, or SetShadowOffsetY properties must be non-zero, as well.
 func (ctx *Context) SetShadowColorString(color string) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bShadowColorString)
 	ctx.buf.addString(color)
+	ctx.skipUnchanged(start, ctx.state.shadowColor.set(cssStyle(color)))
 }
 
 // SetShadowOffsetX sets the distance that shadows will be offset horizontally.
@@ -245,8 +326,10 @@ func (ctx *Context) SetShadowColorString(color string) {
 // property is set to a non-transparent value. One of the SetShadowBlur,
 // SetShadowOffsetX, or SetShadowOffsetY properties must be non-zero, as well.
 func (ctx *Context) SetShadowOffsetX(offset float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bShadowOffsetX)
 	ctx.buf.addFloat64(offset)
+	ctx.skipUnchanged(start, ctx.state.shadowOffsetX.set(offset))
 }
 
 // SetShadowOffsetY sets the distance that shadows will be offset vertically.
@@ -260,8 +343,10 @@ func (ctx *Context) SetShadowOffsetX(offset float64) {
 // property is set to a non-transparent value. One of the SetShadowBlur,
 // SetShadowOffsetX, or SetShadowOffsetY properties must be non-zero, as well.
 func (ctx *Context) SetShadowOffsetY(offset float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bShadowOffsetY)
 	ctx.buf.addFloat64(offset)
+	ctx.skipUnchanged(start, ctx.state.shadowOffsetY.set(offset))
 }
 
 // SetStrokeStyle sets the color to use for the strokes (outlines) around
@@ -270,15 +355,19 @@ func (ctx *Context) SetShadowOffsetY(offset float64) {
 // The color is parsed as a CSS color value like "#a100cb", "#ccc",
 // "darkgreen", "rgba(0.5, 0.2, 0.7, 1.0)", etc.
 func (ctx *Context) SetStrokeStyle(c color.Color) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bStrokeStyle)
 	ctx.buf.addColor(c)
+	ctx.skipUnchanged(start, ctx.state.strokeStyle.set(colorStyle(c)))
 }
 
 // SetStrokeStyleString sets the color to use for the strokes (outlines) around
 // shapes. The default color is black.
 func (ctx *Context) SetStrokeStyleString(color string) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bStrokeStyleString)
 	ctx.buf.addString(color)
+	ctx.skipUnchanged(start, ctx.state.strokeStyle.set(cssStyle(color)))
 }
 
 // SetStrokeStyleGradient sets the gradient (a linear or radial gradient) to
@@ -287,6 +376,7 @@ func (ctx *Context) SetStrokeStyleGradient(g *Gradient) {
 	g.checkUseAfterRelease()
 	ctx.buf.addByte(bStrokeStyleGradient)
 	ctx.buf.addUint32(g.id)
+	ctx.state.strokeStyle.forget()
 }
 
 // SetStrokeStylePattern sets the pattern (a repeating image) to use for the
@@ -295,6 +385,7 @@ func (ctx *Context) SetStrokeStylePattern(p *Pattern) {
 	p.checkUseAfterRelease()
 	ctx.buf.addByte(bStrokeStylePattern)
 	ctx.buf.addUint32(p.id)
+	ctx.state.strokeStyle.forget()
 }
 
 // SetTextAlign sets the current text alignment used when drawing text.
@@ -305,16 +396,20 @@ func (ctx *Context) SetStrokeStylePattern(p *Pattern) {
 //
 // The default value is AlignStart.
 func (ctx *Context) SetTextAlign(align TextAlign) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bTextAlign)
 	ctx.buf.addByte(byte(align))
+	ctx.skipUnchanged(start, ctx.state.textAlign.set(align))
 }
 
 // SetTextBaseline sets the current text baseline used when drawing text.
 //
 // The default value is BaselineAlphabetic.
 func (ctx *Context) SetTextBaseline(baseline TextBaseline) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bTextBaseline)
 	ctx.buf.addByte(byte(baseline))
+	ctx.skipUnchanged(start, ctx.state.textBaseline.set(baseline))
 }
 
 // Arc adds a circular arc to the current sub-path.
@@ -559,6 +654,63 @@ func (ctx *Context) Rect(x, y, width, height float64) {
 	ctx.buf.addFloat64(height)
 }
 
+// Polyline adds a new sub-path of connected straight lines to the current
+// path. It is equivalent to a MoveTo to the first point followed by a
+// LineTo to each of the other points, but the points are sent to the
+// client in a single compact command.
+//
+// The points are given as a flat slice of alternating x and y coordinates:
+// {x0, y0, x1, y1, ...}. A trailing single coordinate is ignored. If the
+// slice does not contain at least one point, this method does nothing.
+func (ctx *Context) Polyline(points []float64) {
+	if len(points) < 2 {
+		return
+	}
+	ctx.buf.addByte(bPolyline)
+	ctx.addPoints(points)
+}
+
+// Polygon adds a new closed sub-path of connected straight lines to the
+// current path. It is equivalent to Polyline followed by ClosePath.
+//
+// The points are given as a flat slice of alternating x and y coordinates:
+// {x0, y0, x1, y1, ...}. A trailing single coordinate is ignored. If the
+// slice does not contain at least one point, this method does nothing.
+func (ctx *Context) Polygon(points []float64) {
+	if len(points) < 2 {
+		return
+	}
+	ctx.buf.addByte(bPolygon)
+	ctx.addPoints(points)
+}
+
+// Points adds a circle with the given radius around each of the points to
+// the current path, each as its own sub-path. This is useful for drawing
+// many markers at once, for example the dots of a scatter plot, with a
+// single call to Fill or Stroke. The radius must be non-negative.
+//
+// The points are given as a flat slice of alternating x and y coordinates:
+// {x0, y0, x1, y1, ...}. A trailing single coordinate is ignored. If the
+// slice does not contain at least one point, this method does nothing.
+func (ctx *Context) Points(points []float64, radius float64) {
+	if len(points) < 2 {
+		return
+	}
+	ctx.buf.addByte(bPoints)
+	ctx.buf.addFloat64(radius)
+	ctx.addPoints(points)
+}
+
+// addPoints adds the coordinates of the points, prefixed with the number
+// of coordinates. A trailing single coordinate is dropped.
+func (ctx *Context) addPoints(points []float64) {
+	points = points[:len(points)&^1]
+	ctx.buf.addUint32(uint32(len(points)))
+	for _, f := range points {
+		ctx.buf.addFloat64(f)
+	}
+}
+
 // Restore restores the most recently saved canvas state by popping the top
 // entry in the drawing state stack. If there is no saved state, this method
 // does nothing.
@@ -566,6 +718,7 @@ func (ctx *Context) Rect(x, y, width, height float64) {
 // For more information about the drawing state, see Save.
 func (ctx *Context) Restore() {
 	ctx.buf.addByte(bRestore)
+	ctx.restoreState()
 }
 
 // Rotate adds a rotation to the transformation matrix.
@@ -595,6 +748,7 @@ func (ctx *Context) Rotate(angle float64) {
 //     SetImageSmoothingEnabled.
 func (ctx *Context) Save() {
 	ctx.buf.addByte(bSave)
+	ctx.saveState()
 }
 
 // Scale adds a scaling transformation to the canvas units horizontally
@@ -773,17 +927,20 @@ func (ctx *Context) SetTransform(a, b, c, d, e, f float64) {
 // is empty, the line dash list is cleared and line strokes return to being
 // solid.
 func (ctx *Context) SetLineDash(segments []float64) {
+	start := len(ctx.buf.bytes)
 	ctx.buf.addByte(bSetLineDash)
 	ctx.buf.addUint32(uint32(len(segments)))
 	for _, seg := range segments {
 		ctx.buf.addFloat64(seg)
 	}
+	ctx.skipUnchanged(start, ctx.state.lineDash.set(segments))
 }
 
 // CreateImageData creates a new, blank ImageData object on the client with the
 // specified dimensions. All of the pixels in the new object are transparent
 // black. The ImageData object should be released with the ImageData.Release
-// method when it is no longer needed.
+// method when it is no longer needed. Its pixels can be changed with the
+// ImageData.Update method.
 func (ctx *Context) CreateImageData(m image.Image) *ImageData {
 	rgba := ensureRGBA(m)
 	bounds := m.Bounds()
@@ -796,6 +953,62 @@ func (ctx *Context) CreateImageData(m image.Image) *ImageData {
 	return &ImageData{id: id, ctx: ctx, width: bounds.Dx(), height: bounds.Dy()}
 }
 
+// CreateImageFromEncoded creates a new ImageData object on the client from
+// an image in an encoded format like PNG, JPEG or WebP, which is given by
+// its MIME type, for example "image/png". The encoded image is sent to the
+// client as is and decoded by the web browser, which is usually much
+// smaller than the pixels sent by CreateImageData. The ImageData object
+// should be released with the ImageData.Release method when it is no
+// longer needed.
+//
+// The browser decodes the image asynchronously and fires an
+// ImageReadyEvent when it is done. Until then, drawing the image with
+// DrawImage and the related methods draws nothing; patterns should be
+// created with CreatePattern only after the image is ready.
+//
+// The Width and Height of the returned ImageData are only known if the
+// image format is registered with the image package on the server, for
+// example by importing image/png, see image.RegisterFormat. Otherwise they
+// are 0, and the size is reported by the ImageReadyEvent.
+func (ctx *Context) CreateImageFromEncoded(data []byte, mimeType string) *ImageData {
+	id := ctx.imageDataIDs.generateID()
+	ctx.buf.addByte(bCreateImageFromEncoded)
+	ctx.buf.addUint32(id)
+	ctx.buf.addString(mimeType)
+	ctx.buf.addUint32(uint32(len(data)))
+	ctx.buf.addBytes(data)
+	img := &ImageData{id: id, ctx: ctx}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img.width, img.height = cfg.Width, cfg.Height
+	}
+	return img
+}
+
+// LoadImage creates a new ImageData object on the client from an image
+// that the web browser loads from the given URL. Relative URLs are
+// resolved against the URL of the canvas page, for example "static/a.png"
+// refers to a file served from Options.StaticFiles. Images from other
+// origins must be served with CORS headers. The browser caches the image
+// like any other resource of the page, so that it is not transmitted
+// again on every connection. The ImageData object should be released with
+// the ImageData.Release method when it is no longer needed.
+//
+// The browser loads the image asynchronously and fires an
+// ImageLoadedEvent when it is done, or an ImageErrorEvent if the image
+// could not be loaded. Until then, drawing the image with DrawImage and
+// the related methods draws nothing; patterns should be created with
+// CreatePattern only after the image is loaded.
+//
+// The Width and Height of the returned ImageData are 0, the size is
+// reported by the ImageLoadedEvent.
+func (ctx *Context) LoadImage(url string) *ImageData {
+	id := ctx.imageDataIDs.generateID()
+	ctx.buf.addByte(bLoadImage)
+	ctx.buf.addUint32(id)
+	ctx.buf.addString(url)
+	return &ImageData{id: id, ctx: ctx}
+}
+
 // PutImageData paints data from the given ImageData object onto the
 // canvas. If a dirty rectangle is provided, only the pixels from that
 // rectangle are painted. This method is not affected by the canvas
@@ -977,14 +1190,38 @@ func (ctx *Context) GetImageData(sx, sy, sw, sh float64) *ImageData {
 	return &ImageData{id: id, ctx: ctx, width: int(sw), height: int(sh)}
 }
 
+// SetTextInputRect sets the position and size of the area where text is
+// entered, for example the caret of a text editor drawn on the canvas.
+// The client moves its hidden text input element to this area, so that
+// the candidate window of an input method editor (IME) appears next to the
+// caret.
+//
+// The rectangle is given in canvas coordinates and is not affected by the
+// transformation matrix. It only has an effect if TextInputEvent or one of
+// the composition events is enabled via Options.EnabledEvents.
+func (ctx *Context) SetTextInputRect(x, y, width, height float64) {
+	ctx.buf.addByte(bSetTextInputRect)
+	ctx.buf.addFloat64(x)
+	ctx.buf.addFloat64(y)
+	ctx.buf.addFloat64(width)
+	ctx.buf.addFloat64(height)
+}
+
 // Flush sends the buffered drawing operations of the context from the server
 // to the client.
 //
 // Nothing is displayed on the client canvas until Flush is called.
 // An animation loop usually has one flush per animation frame.
 func (ctx *Context) Flush() {
+	if ctx.opts != nil && ctx.opts.DebugRedundantState {
+		log.Printf("canvas: flush: %d bytes sent, %d bytes of redundant state setters skipped", len(ctx.buf.bytes), ctx.skippedBytes)
+	}
+	ctx.skippedBytes = 0
 	ctx.draws <- ctx.buf.bytes
-	ctx.buf.reset()
+	ctx.buf.bytes = ctx.frames.get(cap(ctx.buf.bytes))
+	if ctx.buf.compact {
+		ctx.buf.addByte(bCompact)
+	}
 }
 
 type idGenerator struct {
@@ -500,6 +500,44 @@ func TestContextDrawing(t *testing.T) {
 				0x40, 0x5e, 0x33, 0x33, 0x33, 0x33, 0x33, 0x33,
 			},
 		},
+		{
+			"Polyline",
+			func(ctx *Context) { ctx.Polyline([]float64{1, 2, 3, 4, 5}) },
+			[]byte{
+				0x48,
+				0x00, 0x00, 0x00, 0x04,
+				0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			"Polyline without points",
+			func(ctx *Context) { ctx.Polyline([]float64{1}) },
+			nil,
+		},
+		{
+			"Polygon",
+			func(ctx *Context) { ctx.Polygon([]float64{1, 2}) },
+			[]byte{
+				0x49,
+				0x00, 0x00, 0x00, 0x02,
+				0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			"Points",
+			func(ctx *Context) { ctx.Points([]float64{1, 2}, 3) },
+			[]byte{
+				0x4a,
+				0x40, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x00, 0x00, 0x00, 0x02,
+				0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
 		{
 			"Restore",
 			func(ctx *Context) { ctx.Restore() },
@@ -694,6 +732,56 @@ func TestContextDrawing(t *testing.T) {
 				0x40, 0x41, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // dHeight
 			},
 		},
+		{
+			"DrawImageInstances",
+			func(ctx *Context) {
+				img := &ImageData{id: 7, ctx: ctx}
+				ctx.DrawImageInstances(img, []SpriteInstance{
+					{X: 10, Y: 15, Scale: 1, Alpha: 0.5, SWidth: 8, SHeight: 8},
+				})
+				ctx.DrawImageInstances(img, nil)
+			},
+			[]byte{
+				0x4b,                   // DrawImageInstances
+				0x00, 0x00, 0x00, 0x07, // ID
+				0x00, 0x00, 0x00, 0x01, // number of instances
+				0x40, 0x24, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // x
+				0x40, 0x2e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // y
+				0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // scale
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // rotation
+				0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // alpha
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // sx
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // sy
+				0x40, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // sWidth
+				0x40, 0x20, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // sHeight
+			},
+		},
+		{
+			"CreateImageFromEncoded",
+			func(ctx *Context) {
+				ctx.CreateImageFromEncoded([]byte{1, 2, 3}, "image/png")
+			},
+			[]byte{
+				0x4c,                   // CreateImageFromEncoded
+				0x00, 0x00, 0x00, 0x00, // ID
+				0x00, 0x00, 0x00, 0x09, // len(mimeType)
+				0x69, 0x6d, 0x61, 0x67, 0x65, 0x2f, 0x70, 0x6e, 0x67, // mimeType
+				0x00, 0x00, 0x00, 0x03, // len(data)
+				0x01, 0x02, 0x03, // data
+			},
+		},
+		{
+			"LoadImage",
+			func(ctx *Context) {
+				ctx.LoadImage("static/a.png")
+			},
+			[]byte{
+				0x4d,                   // LoadImage
+				0x00, 0x00, 0x00, 0x00, // ID
+				0x00, 0x00, 0x00, 0x0c, // len(url)
+				0x73, 0x74, 0x61, 0x74, 0x69, 0x63, 0x2f, 0x61, 0x2e, 0x70, 0x6e, 0x67, // url
+			},
+		},
 		{
 			"ImageData.Release",
 			func(ctx *Context) {
@@ -885,6 +973,17 @@ func TestContextDrawing(t *testing.T) {
 				0x40, 0x69, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // sh
 			},
 		},
+		{
+			"SetTextInputRect",
+			func(ctx *Context) { ctx.SetTextInputRect(10, 20, 120, 16) },
+			[]byte{
+				0x45,
+				0x40, 0x24, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x34, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x5e, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x40, 0x30, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -945,6 +1044,24 @@ func TestCanvasSize(t *testing.T) {
 	}
 }
 
+func TestNewContext(t *testing.T) {
+	opts := &Options{Width: 640}
+	ctx := NewContext(nil, nil, opts)
+	if ctx.CanvasWidth() != 640 || ctx.CanvasHeight() != 150 {
+		t.Errorf("got: W %d H %d, want: W 640 H 150",
+			ctx.CanvasWidth(), ctx.CanvasHeight())
+	}
+	if opts.Height != 0 {
+		t.Errorf("expected options to be unmodified, but Height was set to %d", opts.Height)
+	}
+
+	ctx = NewContext(nil, nil, nil)
+	if ctx.CanvasWidth() != 300 || ctx.CanvasHeight() != 150 {
+		t.Errorf("nil options: got: W %d H %d, want: W 300 H 150",
+			ctx.CanvasWidth(), ctx.CanvasHeight())
+	}
+}
+
 func TestUseAfterRelease(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1033,3 +1150,16 @@ func TestEvents(t *testing.T) {
 		})
 	}
 }
+
+func TestFlushAllocations(t *testing.T) {
+	ctx, written := newPooledContext()
+	defer close(ctx.draws)
+	allocs := testing.AllocsPerRun(100, func() {
+		drawAnimationFrame(ctx)
+		ctx.Flush()
+		<-written
+	})
+	if allocs != 0 {
+		t.Errorf("got %v allocations per frame, want 0", allocs)
+	}
+}
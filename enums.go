@@ -255,4 +255,16 @@ const (
 	bFillStylePattern
 	bStrokeStylePattern
 	bGetImageData
+	bSetTextInputRect
+	bSnapshot
+	// bCompact marks the rest of a frame as encoded in the compact
+	// encoding, see EncodingCompact.
+	bCompact
+	bPolyline
+	bPolygon
+	bPoints
+	bDrawImageInstances
+	bCreateImageFromEncoded
+	bLoadImage
+	bUpdateImageData
 )
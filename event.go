@@ -10,7 +10,11 @@
 
 package canvas
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/fzipp/canvas/internal/protocol"
+)
 
 // Event is an interface implemented by all event subtypes. Events can be
 // received from the channel returned by Context.Events. Use a type switch
@@ -93,6 +97,18 @@ type AuxClickEvent struct{ MouseEvent }
 
 func (e AuxClickEvent) mask() eventMask { return maskAuxClick }
 
+// The MouseEnterEvent is fired when a pointing device (usually a mouse) is
+// moved into the canvas.
+type MouseEnterEvent struct{ MouseEvent }
+
+func (e MouseEnterEvent) mask() eventMask { return maskMouseEnter }
+
+// The MouseLeaveEvent is fired when a pointing device (usually a mouse) is
+// moved out of the canvas.
+type MouseLeaveEvent struct{ MouseEvent }
+
+func (e MouseLeaveEvent) mask() eventMask { return maskMouseLeave }
+
 // The WheelEvent is fired due to the user moving a mouse wheel or similar
 // input device.
 type WheelEvent struct {
@@ -129,6 +145,20 @@ const (
 type KeyboardEvent struct {
 	// Key represents the key value of the key represented by the event.
 	Key string
+	// Code represents a physical key on the keyboard (as opposed to the
+	// character generated by pressing the key), for example "KeyW" for the
+	// W key on a QWERTY layout, regardless of the active keyboard layout.
+	Code string
+	// Location represents the location of the key on the keyboard or other
+	// input device.
+	Location KeyLocation
+	// Repeat is true if the given key is being held down such that it is
+	// automatically repeating.
+	Repeat bool
+	// IsComposing is true if the event is fired between a composition
+	// start and composition end, for example during input with an
+	// input method editor (IME).
+	IsComposing bool
 	// Mod describes the modifier keys pressed during the event.
 	Mod ModifierKeys
 }
@@ -137,6 +167,29 @@ func (e KeyboardEvent) mask() eventMask {
 	return maskKeyDown | maskKeyUp
 }
 
+// KeyLocation represents the location of a key on the keyboard or other
+// input device.
+type KeyLocation byte
+
+const (
+	// KeyLocationStandard means the key has only one version, or can't be
+	// distinguished between the left and right versions of the key, and was
+	// not pressed on the numeric keypad or a key that is considered to be
+	// part of the keypad.
+	KeyLocationStandard KeyLocation = iota
+	// KeyLocationLeft means the key was the left-hand version of the key;
+	// for example, the left-hand Control key was pressed on a standard
+	// 101 key US keyboard.
+	KeyLocationLeft
+	// KeyLocationRight means the key was the right-hand version of the key;
+	// for example, the right-hand Control key was pressed on a standard
+	// 101 key US keyboard.
+	KeyLocationRight
+	// KeyLocationNumpad means the key was on the numeric keypad, or has a
+	// virtual key code that corresponds to the numeric keypad.
+	KeyLocationNumpad
+)
+
 // The KeyDownEvent is fired when a key is pressed.
 type KeyDownEvent struct{ KeyboardEvent }
 
@@ -147,6 +200,81 @@ type KeyUpEvent struct{ KeyboardEvent }
 
 func (e KeyUpEvent) mask() eventMask { return maskKeyUp }
 
+// The TextInputEvent is fired when text is entered, for example by typing
+// on a physical or virtual keyboard, by committing the composition of an
+// input method editor (IME), by a dead key sequence, or by pasting.
+//
+// Unlike KeyDownEvent, TextInputEvent carries the composed text rather than
+// the individual key presses. Use Context.SetTextInputRect to tell the
+// client where text is entered so that the IME candidate window appears at
+// the caret.
+type TextInputEvent struct {
+	// Data is the inserted text.
+	Data string
+}
+
+func (e TextInputEvent) mask() eventMask { return maskTextInput }
+
+// CompositionEvent represents events that occur due to the user indirectly
+// entering text, for example with an input method editor (IME).
+type CompositionEvent struct {
+	// Data is the text being composed. It is empty for a
+	// CompositionStartEvent, the current composition text for a
+	// CompositionUpdateEvent, and the committed text for a
+	// CompositionEndEvent.
+	Data string
+}
+
+func (e CompositionEvent) mask() eventMask {
+	return maskCompositionStart | maskCompositionUpdate | maskCompositionEnd
+}
+
+// The CompositionStartEvent is fired when a text composition system such as
+// an input method editor starts a new composition session.
+type CompositionStartEvent struct{ CompositionEvent }
+
+func (e CompositionStartEvent) mask() eventMask { return maskCompositionStart }
+
+// The CompositionUpdateEvent is fired when a new character is received in
+// the context of a text composition session controlled by a text
+// composition system such as an input method editor.
+type CompositionUpdateEvent struct{ CompositionEvent }
+
+func (e CompositionUpdateEvent) mask() eventMask { return maskCompositionUpdate }
+
+// The CompositionEndEvent is fired when a text composition system such as
+// an input method editor completes or cancels the current composition
+// session. The committed text is also sent as a TextInputEvent, if enabled.
+type CompositionEndEvent struct{ CompositionEvent }
+
+func (e CompositionEndEvent) mask() eventMask { return maskCompositionEnd }
+
+// The FocusEvent is fired when the canvas has received focus, for example
+// when the user clicks on it or switches back to the browser window.
+type FocusEvent struct{}
+
+func (e FocusEvent) mask() eventMask { return maskFocus }
+
+// The BlurEvent is fired when the canvas has lost focus, for example when
+// the user switches to another window or browser tab.
+//
+// Applications that keep track of pressed keys should treat all keys as
+// released on a BlurEvent, since the corresponding KeyUpEvents are not
+// delivered while the canvas doesn't have focus.
+type BlurEvent struct{}
+
+func (e BlurEvent) mask() eventMask { return maskBlur }
+
+// The VisibilityChangeEvent is fired when the content of the browser tab
+// with the canvas has become visible or has been hidden, for example when
+// the user switches to another tab or minimizes the browser window.
+type VisibilityChangeEvent struct {
+	// Hidden is true if the page is no longer visible.
+	Hidden bool
+}
+
+func (e VisibilityChangeEvent) mask() eventMask { return maskVisibilityChange }
+
 // The TouchEvent is fired when the state of contacts with a touch-sensitive
 // surface changes. This surface can be a touch screen or trackpad, for
 // example. The event can describe one or more points of contact with the
@@ -223,6 +351,150 @@ type TouchCancelEvent struct{ TouchEvent }
 
 func (e TouchCancelEvent) mask() eventMask { return maskTouchCancel }
 
+// GamepadEvent represents events that occur due to the user interacting
+// with a gamepad or other game controller connected to the client.
+type GamepadEvent struct {
+	// Index is an integer that is auto-incremented to be unique for each
+	// device currently connected to the system. It can be used to
+	// distinguish multiple controllers.
+	Index int
+	// ID is a string containing some information about the controller,
+	// such as its vendor and product name.
+	ID string
+	// Mapping describes the button and axis layout of the controller.
+	// It is "standard" if the controller has been remapped to the
+	// [standard gamepad layout], otherwise it is empty.
+	//
+	// [standard gamepad layout]: https://w3c.github.io/gamepad/#remapping
+	Mapping string
+	// Buttons describes the state of the buttons present on the device.
+	Buttons []GamepadButton
+	// Axes describes the positions of the axes present on the device, for
+	// example thumb sticks. Each value is a number in the range -1.0 to
+	// 1.0, from the lowest (left or up) to the highest (right or down)
+	// position.
+	Axes []float64
+}
+
+func (e GamepadEvent) mask() eventMask {
+	return maskGamepadConnected | maskGamepadDisconnected | maskGamepadState
+}
+
+// GamepadButton represents the state of a button on a gamepad.
+type GamepadButton struct {
+	// Pressed indicates whether the button is currently pressed.
+	Pressed bool
+	// Value is the amount of pressure applied to the button, in the range
+	// 0.0 (not pressed) to 1.0 (fully pressed). Buttons without pressure
+	// sensitivity report either 0.0 or 1.0.
+	Value float64
+}
+
+// The GamepadConnectedEvent is fired when a gamepad is connected to the
+// client, or when a gamepad that was connected before is used for the first
+// time.
+type GamepadConnectedEvent struct{ GamepadEvent }
+
+func (e GamepadConnectedEvent) mask() eventMask { return maskGamepadConnected }
+
+// The GamepadDisconnectedEvent is fired when a gamepad has been
+// disconnected from the client.
+type GamepadDisconnectedEvent struct{ GamepadEvent }
+
+func (e GamepadDisconnectedEvent) mask() eventMask { return maskGamepadDisconnected }
+
+// The GamepadStateEvent is fired when the state of the buttons or axes of a
+// connected gamepad has changed. The client polls the gamepads once per
+// animation frame of the browser and sends a GamepadStateEvent only if
+// there was a change since the previous poll.
+type GamepadStateEvent struct{ GamepadEvent }
+
+func (e GamepadStateEvent) mask() eventMask { return maskGamepadState }
+
+// The DropEvent is fired when files are dragged from outside the browser,
+// for example from the file manager of the operating system, and dropped
+// onto the canvas.
+type DropEvent struct {
+	MouseEvent
+	// Files contains the dropped files.
+	Files []File
+}
+
+func (e DropEvent) mask() eventMask { return maskDrop }
+
+// The PasteEvent is fired when the user has initiated a "paste" action
+// through the browser's user interface, for example with the Ctrl+V
+// keyboard shortcut.
+type PasteEvent struct {
+	// Text is the pasted plain text, if any.
+	Text string
+	// Files contains the pasted files, for example an image copied to the
+	// clipboard.
+	Files []File
+}
+
+func (e PasteEvent) mask() eventMask { return maskPaste }
+
+// The ImageReadyEvent is fired when the client has decoded an image that
+// was created with Context.CreateImageFromEncoded, or failed to decode it.
+// It is not necessary to enable the ImageReadyEvent with
+// Options.EnabledEvents, it is always enabled.
+type ImageReadyEvent struct {
+	// ImageID is the ID of the image, see ImageData.ID.
+	ImageID uint32
+	// Width and Height are the size of the decoded image in pixels.
+	Width  int
+	Height int
+	// Err is the reason why the client could not decode the image, for
+	// example an unsupported format. It is empty if the image was decoded.
+	Err string
+}
+
+func (e ImageReadyEvent) mask() eventMask { return 0 }
+
+// The ImageLoadedEvent is fired when the client has loaded an image from
+// the URL given to Context.LoadImage.
+// It is not necessary to enable the ImageLoadedEvent with
+// Options.EnabledEvents, it is always enabled.
+type ImageLoadedEvent struct {
+	// ImageID is the ID of the image, see ImageData.ID.
+	ImageID uint32
+	// Width and Height are the size of the loaded image in pixels.
+	Width  int
+	Height int
+}
+
+func (e ImageLoadedEvent) mask() eventMask { return 0 }
+
+// The ImageErrorEvent is fired when the client could not load an image
+// from the URL given to Context.LoadImage.
+// It is not necessary to enable the ImageErrorEvent with
+// Options.EnabledEvents, it is always enabled.
+type ImageErrorEvent struct {
+	// ImageID is the ID of the image, see ImageData.ID.
+	ImageID uint32
+	// Err is the reason why the client could not load the image, for
+	// example an HTTP error status or an unsupported format.
+	Err string
+}
+
+func (e ImageErrorEvent) mask() eventMask { return 0 }
+
+// File represents a file that was dropped onto or pasted into the canvas.
+type File struct {
+	// Name is the name of the file, without path information.
+	Name string
+	// Type is the MIME type of the file, for example "image/png".
+	// It is empty if the type could not be determined by the client.
+	Type string
+	// Size is the size of the file in bytes.
+	Size int64
+	// Data is the content of the file. It is nil if the contents of the
+	// files of the event up to and including this one are larger than
+	// Options.MaxFileSize.
+	Data []byte
+}
+
 // ModifierKeys describes the modifier keys (Alt, Shift, Ctrl, Meta) pressed
 // during an event.
 type ModifierKeys byte
@@ -279,6 +551,20 @@ const (
 	maskTouchMove
 	maskTouchEnd
 	maskTouchCancel
+	maskTextInput
+	maskCompositionStart
+	maskCompositionUpdate
+	maskCompositionEnd
+	maskFocus
+	maskBlur
+	maskVisibilityChange
+	maskMouseEnter
+	maskMouseLeave
+	maskGamepadConnected
+	maskGamepadDisconnected
+	maskGamepadState
+	maskDrop
+	maskPaste
 )
 
 // MouseButtons is a number representing one or more buttons. For more than
@@ -302,32 +588,53 @@ const (
 	ButtonNone MouseButtons = 0
 )
 
+// Event types of the binary event format, see package protocol.
 const (
-	evMouseMove byte = 1 + iota
-	evMouseDown
-	evMouseUp
-	evKeyDown
-	evKeyUp
-	evClick
-	evDblClick
-	evAuxClick
-	evWheel
-	evTouchStart
-	evTouchMove
-	evTouchEnd
-	evTouchCancel
+	evMouseMove           = protocol.EventMouseMove
+	evMouseDown           = protocol.EventMouseDown
+	evMouseUp             = protocol.EventMouseUp
+	evKeyDown             = protocol.EventKeyDown
+	evKeyUp               = protocol.EventKeyUp
+	evClick               = protocol.EventClick
+	evDblClick            = protocol.EventDblClick
+	evAuxClick            = protocol.EventAuxClick
+	evWheel               = protocol.EventWheel
+	evTouchStart          = protocol.EventTouchStart
+	evTouchMove           = protocol.EventTouchMove
+	evTouchEnd            = protocol.EventTouchEnd
+	evTouchCancel         = protocol.EventTouchCancel
+	evTextInput           = protocol.EventTextInput
+	evCompositionStart    = protocol.EventCompositionStart
+	evCompositionUpdate   = protocol.EventCompositionUpdate
+	evCompositionEnd      = protocol.EventCompositionEnd
+	evFocus               = protocol.EventFocus
+	evBlur                = protocol.EventBlur
+	evVisibilityChange    = protocol.EventVisibilityChange
+	evMouseEnter          = protocol.EventMouseEnter
+	evMouseLeave          = protocol.EventMouseLeave
+	evGamepadConnected    = protocol.EventGamepadConnected
+	evGamepadDisconnected = protocol.EventGamepadDisconnected
+	evGamepadState        = protocol.EventGamepadState
+	evDrop                = protocol.EventDrop
+	evPaste               = protocol.EventPaste
+	evImageReady          = protocol.EventImageReady
+	evImageLoaded         = protocol.EventImageLoaded
+	evImageError          = protocol.EventImageError
 )
 
-func decodeEvent(p []byte) (Event, error) {
+// decodeEvent decodes an event from the client. The contents of the files
+// of a DropEvent or PasteEvent are limited to maxFileSize in total,
+// regardless of what the client sent.
+func decodeEvent(p []byte, maxFileSize int64) (Event, error) {
 	buf := &buffer{bytes: p}
-	event, err := decodeEventBuf(buf)
+	event, err := decodeEventBuf(buf, maxFileSize)
 	if buf.error != nil {
 		return nil, buf.error
 	}
 	return event, err
 }
 
-func decodeEventBuf(buf *buffer) (Event, error) {
+func decodeEventBuf(buf *buffer, maxFileSize int64) (Event, error) {
 	eventType := buf.readByte()
 	switch eventType {
 	case evMouseMove:
@@ -356,6 +663,52 @@ func decodeEventBuf(buf *buffer) (Event, error) {
 		return TouchEndEvent{decodeTouchEvent(buf)}, nil
 	case evTouchCancel:
 		return TouchCancelEvent{decodeTouchEvent(buf)}, nil
+	case evTextInput:
+		return TextInputEvent{Data: buf.readString()}, nil
+	case evCompositionStart:
+		return CompositionStartEvent{decodeCompositionEvent(buf)}, nil
+	case evCompositionUpdate:
+		return CompositionUpdateEvent{decodeCompositionEvent(buf)}, nil
+	case evCompositionEnd:
+		return CompositionEndEvent{decodeCompositionEvent(buf)}, nil
+	case evFocus:
+		return FocusEvent{}, nil
+	case evBlur:
+		return BlurEvent{}, nil
+	case evVisibilityChange:
+		return VisibilityChangeEvent{Hidden: buf.readByte() != 0}, nil
+	case evMouseEnter:
+		return MouseEnterEvent{decodeMouseEvent(buf)}, nil
+	case evMouseLeave:
+		return MouseLeaveEvent{decodeMouseEvent(buf)}, nil
+	case evGamepadConnected:
+		return GamepadConnectedEvent{decodeGamepadEvent(buf)}, nil
+	case evGamepadDisconnected:
+		return GamepadDisconnectedEvent{decodeGamepadEvent(buf)}, nil
+	case evGamepadState:
+		return GamepadStateEvent{decodeGamepadEvent(buf)}, nil
+	case evDrop:
+		return decodeDropEvent(buf, maxFileSize), nil
+	case evPaste:
+		return decodePasteEvent(buf, maxFileSize), nil
+	case evImageReady:
+		return ImageReadyEvent{
+			ImageID: buf.readUint32(),
+			Width:   int(buf.readUint32()),
+			Height:  int(buf.readUint32()),
+			Err:     buf.readString(),
+		}, nil
+	case evImageLoaded:
+		return ImageLoadedEvent{
+			ImageID: buf.readUint32(),
+			Width:   int(buf.readUint32()),
+			Height:  int(buf.readUint32()),
+		}, nil
+	case evImageError:
+		return ImageErrorEvent{
+			ImageID: buf.readUint32(),
+			Err:     buf.readString(),
+		}, nil
 	}
 	return nil, errUnknownEventType{unknownType: eventType}
 }
@@ -370,12 +723,23 @@ func decodeMouseEvent(buf *buffer) MouseEvent {
 }
 
 func decodeKeyboardEvent(buf *buffer) KeyboardEvent {
-	return KeyboardEvent{
-		Mod: ModifierKeys(buf.readByte()),
-		Key: buf.readString(),
+	e := KeyboardEvent{
+		Mod:      ModifierKeys(buf.readByte()),
+		Key:      buf.readString(),
+		Code:     buf.readString(),
+		Location: KeyLocation(buf.readByte()),
 	}
+	flags := buf.readByte()
+	e.Repeat = flags&keyFlagRepeat != 0
+	e.IsComposing = flags&keyFlagIsComposing != 0
+	return e
 }
 
+const (
+	keyFlagRepeat byte = 1 << iota
+	keyFlagIsComposing
+)
+
 func decodeWheelEvent(buf *buffer) WheelEvent {
 	return WheelEvent{
 		MouseEvent: decodeMouseEvent(buf),
@@ -412,6 +776,74 @@ func decodeTouch(buf *buffer) Touch {
 	}
 }
 
+func decodeCompositionEvent(buf *buffer) CompositionEvent {
+	return CompositionEvent{
+		Data: buf.readString(),
+	}
+}
+
+func decodeGamepadEvent(buf *buffer) GamepadEvent {
+	e := GamepadEvent{
+		Index:   int(buf.readByte()),
+		ID:      buf.readString(),
+		Mapping: buf.readString(),
+	}
+	e.Buttons = make([]GamepadButton, buf.readByte())
+	for i := range e.Buttons {
+		e.Buttons[i] = GamepadButton{
+			Pressed: buf.readByte() != 0,
+			Value:   buf.readFloat64(),
+		}
+	}
+	e.Axes = make([]float64, buf.readByte())
+	for i := range e.Axes {
+		e.Axes[i] = buf.readFloat64()
+	}
+	return e
+}
+
+func decodeDropEvent(buf *buffer, maxFileSize int64) DropEvent {
+	return DropEvent{
+		MouseEvent: decodeMouseEvent(buf),
+		Files:      decodeFiles(buf, maxFileSize),
+	}
+}
+
+func decodePasteEvent(buf *buffer, maxFileSize int64) PasteEvent {
+	return PasteEvent{
+		Text:  buf.readString(),
+		Files: decodeFiles(buf, maxFileSize),
+	}
+}
+
+// decodeFiles decodes the files of a DropEvent or PasteEvent, keeping their
+// contents only as long as they add up to at most maxFileSize.
+func decodeFiles(buf *buffer, maxFileSize int64) []File {
+	files := make([]File, buf.readByte())
+	for i := range files {
+		files[i] = decodeFile(buf, maxFileSize)
+		maxFileSize -= int64(len(files[i].Data))
+	}
+	return files
+}
+
+func decodeFile(buf *buffer, maxFileSize int64) File {
+	f := File{
+		Name: buf.readString(),
+		Type: buf.readString(),
+		Size: int64(buf.readUint64()),
+	}
+	hasData := buf.readByte() != 0
+	length := int(buf.readUint32())
+	if hasData {
+		data := buf.readBytes(length)
+		if int64(len(data)) <= maxFileSize {
+			f.Data = data
+		}
+	}
+	return f
+}
+
 type errUnknownEventType struct {
 	unknownType byte
 }
@@ -136,11 +136,39 @@ func TestDecodeEvent(t *testing.T) {
 				0b00001010,             // Modifier keys
 				0x00, 0x00, 0x00, 0x09, // len(Key)
 				0x41, 0x72, 0x72, 0x6f, 0x77, 0x4c, 0x65, 0x66, 0x74, // Key
+				0x00, 0x00, 0x00, 0x09, // len(Code)
+				0x41, 0x72, 0x72, 0x6f, 0x77, 0x4c, 0x65, 0x66, 0x74, // Code
+				0x00,       // Location
+				0b00000001, // Flags
 			},
 			KeyDownEvent{
 				KeyboardEvent{
-					Key: "ArrowLeft",
-					Mod: modKeyShift | modKeyMeta,
+					Key:      "ArrowLeft",
+					Code:     "ArrowLeft",
+					Location: KeyLocationStandard,
+					Repeat:   true,
+					Mod:      modKeyShift | modKeyMeta,
+				},
+			},
+		},
+		{
+			"KeyDownEvent: layout-independent code",
+			[]byte{
+				0x04,                   // Event type
+				0b00000000,             // Modifier keys
+				0x00, 0x00, 0x00, 0x01, // len(Key)
+				0x7a,                   // Key
+				0x00, 0x00, 0x00, 0x04, // len(Code)
+				0x4b, 0x65, 0x79, 0x59, // Code
+				0x00,       // Location
+				0b00000010, // Flags
+			},
+			KeyDownEvent{
+				KeyboardEvent{
+					Key:         "z",
+					Code:        "KeyY",
+					Location:    KeyLocationStandard,
+					IsComposing: true,
 				},
 			},
 		},
@@ -149,13 +177,19 @@ func TestDecodeEvent(t *testing.T) {
 			[]byte{
 				0x05,                   // Event type
 				0b00000011,             // Modifier keys
-				0x00, 0x00, 0x00, 0x0a, // len(Key)
-				0x41, 0x72, 0x72, 0x6f, 0x77, 0x52, 0x69, 0x67, 0x68, 0x74, // Key
+				0x00, 0x00, 0x00, 0x05, // len(Key)
+				0x53, 0x68, 0x69, 0x66, 0x74, // Key
+				0x00, 0x00, 0x00, 0x0a, // len(Code)
+				0x53, 0x68, 0x69, 0x66, 0x74, 0x52, 0x69, 0x67, 0x68, 0x74, // Code
+				0x02,       // Location
+				0b00000000, // Flags
 			},
 			KeyUpEvent{
 				KeyboardEvent{
-					Key: "ArrowRight",
-					Mod: modKeyAlt | modKeyShift,
+					Key:      "Shift",
+					Code:     "ShiftRight",
+					Location: KeyLocationRight,
+					Mod:      modKeyAlt | modKeyShift,
 				},
 			},
 		},
@@ -346,10 +380,264 @@ func TestDecodeEvent(t *testing.T) {
 				},
 			},
 		},
+		{
+			"TextInputEvent",
+			[]byte{
+				0x0e,                   // Event type
+				0x00, 0x00, 0x00, 0x06, // len(Data)
+				0xe6, 0x97, 0xa5, 0xe6, 0x9c, 0xac, // Data
+			},
+			TextInputEvent{Data: "日本"},
+		},
+		{
+			"CompositionStartEvent",
+			[]byte{
+				0x0f,                   // Event type
+				0x00, 0x00, 0x00, 0x00, // len(Data)
+			},
+			CompositionStartEvent{CompositionEvent{Data: ""}},
+		},
+		{
+			"CompositionUpdateEvent",
+			[]byte{
+				0x10,                   // Event type
+				0x00, 0x00, 0x00, 0x03, // len(Data)
+				0xe3, 0x81, 0xab, // Data
+			},
+			CompositionUpdateEvent{CompositionEvent{Data: "に"}},
+		},
+		{
+			"CompositionEndEvent",
+			[]byte{
+				0x11,                   // Event type
+				0x00, 0x00, 0x00, 0x03, // len(Data)
+				0xe4, 0xba, 0x8c, // Data
+			},
+			CompositionEndEvent{CompositionEvent{Data: "二"}},
+		},
+		{
+			"FocusEvent",
+			[]byte{
+				0x12, // Event type
+			},
+			FocusEvent{},
+		},
+		{
+			"BlurEvent",
+			[]byte{
+				0x13, // Event type
+			},
+			BlurEvent{},
+		},
+		{
+			"VisibilityChangeEvent",
+			[]byte{
+				0x14, // Event type
+				0x01, // Hidden
+			},
+			VisibilityChangeEvent{Hidden: true},
+		},
+		{
+			"MouseEnterEvent",
+			[]byte{
+				0x15,                   // Event type
+				0b00000000,             // Buttons
+				0x00, 0x00, 0x00, 0x00, // X
+				0x00, 0x00, 0x00, 0x2a, // Y
+				0b00000000, // Modifier keys
+			},
+			MouseEnterEvent{
+				MouseEvent{
+					Buttons: ButtonNone,
+					X:       0,
+					Y:       42,
+				},
+			},
+		},
+		{
+			"MouseLeaveEvent",
+			[]byte{
+				0x16,                   // Event type
+				0b00000001,             // Buttons
+				0x00, 0x00, 0x01, 0x2c, // X
+				0x00, 0x00, 0x00, 0x96, // Y
+				0b00000010, // Modifier keys
+			},
+			MouseLeaveEvent{
+				MouseEvent{
+					Buttons: ButtonPrimary,
+					X:       300,
+					Y:       150,
+					Mod:     modKeyShift,
+				},
+			},
+		},
+		{
+			"GamepadConnectedEvent",
+			[]byte{
+				0x17,                   // Event type
+				0x01,                   // Index
+				0x00, 0x00, 0x00, 0x03, // len(ID)
+				0x50, 0x61, 0x64, // ID
+				0x00, 0x00, 0x00, 0x08, // len(Mapping)
+				0x73, 0x74, 0x61, 0x6e, 0x64, 0x61, 0x72, 0x64, // Mapping
+				0x00, // len(Buttons)
+				0x00, // len(Axes)
+			},
+			GamepadConnectedEvent{
+				GamepadEvent{
+					Index:   1,
+					ID:      "Pad",
+					Mapping: "standard",
+					Buttons: []GamepadButton{},
+					Axes:    []float64{},
+				},
+			},
+		},
+		{
+			"GamepadDisconnectedEvent",
+			[]byte{
+				0x18,                   // Event type
+				0x00,                   // Index
+				0x00, 0x00, 0x00, 0x00, // len(ID)
+				0x00, 0x00, 0x00, 0x00, // len(Mapping)
+				0x00, // len(Buttons)
+				0x00, // len(Axes)
+			},
+			GamepadDisconnectedEvent{
+				GamepadEvent{
+					Buttons: []GamepadButton{},
+					Axes:    []float64{},
+				},
+			},
+		},
+		{
+			"GamepadStateEvent",
+			[]byte{
+				0x19,                   // Event type
+				0x02,                   // Index
+				0x00, 0x00, 0x00, 0x00, // len(ID)
+				0x00, 0x00, 0x00, 0x00, // len(Mapping)
+
+				0x02,                                           // len(Buttons)
+				0x01,                                           // Buttons[0].Pressed
+				0x3f, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Buttons[0].Value
+				0x00,                                           // Buttons[1].Pressed
+				0x3f, 0xd0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Buttons[1].Value
+
+				0x02,                                           // len(Axes)
+				0xbf, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Axes[0]
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Axes[1]
+			},
+			GamepadStateEvent{
+				GamepadEvent{
+					Index: 2,
+					Buttons: []GamepadButton{
+						{Pressed: true, Value: 1},
+						{Pressed: false, Value: 0.25},
+					},
+					Axes: []float64{-0.5, 0},
+				},
+			},
+		},
+		{
+			"DropEvent",
+			[]byte{
+				0x1a,                   // Event type
+				0b00000000,             // Buttons
+				0x00, 0x00, 0x00, 0x64, // X
+				0x00, 0x00, 0x00, 0x32, // Y
+				0b00000000, // Modifier keys
+
+				0x02, // len(Files)
+
+				0x00, 0x00, 0x00, 0x05, // len(Files[0].Name)
+				0x61, 0x2e, 0x74, 0x78, 0x74, // Files[0].Name
+				0x00, 0x00, 0x00, 0x0a, // len(Files[0].Type)
+				0x74, 0x65, 0x78, 0x74, 0x2f, 0x70, 0x6c, 0x61, 0x69, 0x6e, // Files[0].Type
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // Files[0].Size
+				0x01,                   // Files[0] has data
+				0x00, 0x00, 0x00, 0x02, // len(Files[0].Data)
+				0x68, 0x69, // Files[0].Data
+
+				0x00, 0x00, 0x00, 0x05, // len(Files[1].Name)
+				0x62, 0x2e, 0x62, 0x69, 0x6e, // Files[1].Name
+				0x00, 0x00, 0x00, 0x00, // len(Files[1].Type)
+				0x00, 0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, // Files[1].Size
+				0x00,                   // Files[1] has data
+				0x00, 0x00, 0x00, 0x00, // len(Files[1].Data)
+			},
+			DropEvent{
+				MouseEvent: MouseEvent{
+					Buttons: ButtonNone,
+					X:       100,
+					Y:       50,
+				},
+				Files: []File{
+					{Name: "a.txt", Type: "text/plain", Size: 2, Data: []byte("hi")},
+					{Name: "b.bin", Size: 1 << 31},
+				},
+			},
+		},
+		{
+			"PasteEvent",
+			[]byte{
+				0x1b,                   // Event type
+				0x00, 0x00, 0x00, 0x05, // len(Text)
+				0x68, 0x65, 0x6c, 0x6c, 0x6f, // Text
+				0x00, // len(Files)
+			},
+			PasteEvent{
+				Text:  "hello",
+				Files: []File{},
+			},
+		},
+		{
+			"ImageReadyEvent",
+			[]byte{
+				0x1c,                   // Event type
+				0x00, 0x00, 0x00, 0x03, // ImageID
+				0x00, 0x00, 0x01, 0x40, // Width
+				0x00, 0x00, 0x00, 0xc8, // Height
+				0x00, 0x00, 0x00, 0x00, // len(Err)
+			},
+			ImageReadyEvent{
+				ImageID: 3,
+				Width:   320,
+				Height:  200,
+			},
+		},
+		{
+			"ImageLoadedEvent",
+			[]byte{
+				0x1d,                   // Event type
+				0x00, 0x00, 0x00, 0x04, // ImageID
+				0x00, 0x00, 0x00, 0x40, // Width
+				0x00, 0x00, 0x00, 0x20, // Height
+			},
+			ImageLoadedEvent{
+				ImageID: 4,
+				Width:   64,
+				Height:  32,
+			},
+		},
+		{
+			"ImageErrorEvent",
+			[]byte{
+				0x1e,                   // Event type
+				0x00, 0x00, 0x00, 0x05, // ImageID
+				0x00, 0x00, 0x00, 0x03, // len(Err)
+				0x34, 0x30, 0x34, // Err
+			},
+			ImageErrorEvent{
+				ImageID: 5,
+				Err:     "404",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := decodeEvent(tt.p)
+			got, err := decodeEvent(tt.p, 1<<20)
 			if err != nil {
 				t.Errorf("did not expect error, but got error: %s", err)
 				return
@@ -361,6 +649,48 @@ func TestDecodeEvent(t *testing.T) {
 	}
 }
 
+func TestDecodeEventMaxFileSize(t *testing.T) {
+	p := []byte{
+		0x1b,                   // Event type
+		0x00, 0x00, 0x00, 0x00, // len(Text)
+		0x03, // len(Files)
+
+		0x00, 0x00, 0x00, 0x00, // len(Files[0].Name)
+		0x00, 0x00, 0x00, 0x00, // len(Files[0].Type)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, // Files[0].Size
+		0x01,                   // Files[0] has data
+		0x00, 0x00, 0x00, 0x03, // len(Files[0].Data)
+		0x61, 0x62, 0x63, // Files[0].Data
+
+		0x00, 0x00, 0x00, 0x00, // len(Files[1].Name)
+		0x00, 0x00, 0x00, 0x00, // len(Files[1].Type)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // Files[1].Size
+		0x01,                   // Files[1] has data
+		0x00, 0x00, 0x00, 0x02, // len(Files[1].Data)
+		0x64, 0x65, // Files[1].Data
+
+		0x00, 0x00, 0x00, 0x00, // len(Files[2].Name)
+		0x00, 0x00, 0x00, 0x00, // len(Files[2].Type)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, // Files[2].Size
+		0x01,                   // Files[2] has data
+		0x00, 0x00, 0x00, 0x01, // len(Files[2].Data)
+		0x66, // Files[2].Data
+	}
+	got, err := decodeEvent(p, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second file exceeds the remaining limit, the third one fits.
+	want := PasteEvent{Files: []File{
+		{Size: 3, Data: []byte("abc")},
+		{Size: 2},
+		{Size: 1, Data: []byte("f")},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
 func TestUnsupportedEventType(t *testing.T) {
 	tests := []byte{
 		0x00,
@@ -370,7 +700,7 @@ func TestUnsupportedEventType(t *testing.T) {
 	}
 	wantErrorMessage := "unknown event type: "
 	for _, tt := range tests {
-		got, err := decodeEvent([]byte{tt})
+		got, err := decodeEvent([]byte{tt}, 1<<20)
 		if err == nil {
 			t.Errorf("expected error, but got none")
 			return
@@ -396,7 +726,7 @@ func TestEventDataTooShort(t *testing.T) {
 		{[]byte{0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
 	}
 	for _, tt := range tests {
-		got, err := decodeEvent(tt.p)
+		got, err := decodeEvent(tt.p, 1<<20)
 		if err == nil {
 			t.Errorf("expected error, but got none")
 			return
@@ -432,6 +762,22 @@ func TestEventMask(t *testing.T) {
 		{TouchMoveEvent{}, 0b0010000000000},
 		{TouchEndEvent{}, 0b0100000000000},
 		{TouchCancelEvent{}, 0b1000000000000},
+		{TextInputEvent{}, 0b10000000000000},
+		{CompositionEvent{}, 0b11100000000000000},
+		{CompositionStartEvent{}, 0b00100000000000000},
+		{CompositionUpdateEvent{}, 0b01000000000000000},
+		{CompositionEndEvent{}, 0b10000000000000000},
+		{FocusEvent{}, 0b100000000000000000},
+		{BlurEvent{}, 0b1000000000000000000},
+		{VisibilityChangeEvent{}, 0b10000000000000000000},
+		{MouseEnterEvent{}, 0b100000000000000000000},
+		{MouseLeaveEvent{}, 0b1000000000000000000000},
+		{GamepadEvent{}, 0b1110000000000000000000000},
+		{GamepadConnectedEvent{}, 0b0010000000000000000000000},
+		{GamepadDisconnectedEvent{}, 0b0100000000000000000000000},
+		{GamepadStateEvent{}, 0b1000000000000000000000000},
+		{DropEvent{}, 0b10000000000000000000000000},
+		{PasteEvent{}, 0b100000000000000000000000000},
 	}
 	for _, tt := range tests {
 		got := tt.event.mask()
@@ -0,0 +1,18 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/canvastest"
+)
+
+func TestRun(t *testing.T) {
+	rec := canvastest.NewRecorder(&canvas.Options{Width: 500, Height: 500})
+	run(rec.Context())
+	canvastest.AssertGolden(t, "hilbert", 500, 500, rec.Frames()...)
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/fzipp/canvas/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// The protocol version and the handshake message are defined in package
+// protocol, which is shared with the headless client of package
+// canvastest.
+const (
+	protocolVersion = protocol.Version
+	msgHello        = protocol.MsgHello
+	// capCompactEncoding indicates that the client decodes the compact
+	// encoding, see EncodingCompact.
+	capCompactEncoding = protocol.CapCompactEncoding
+)
+
+// closeVersionMismatch is the status code with which the server closes
+// the connection to a client with an incompatible protocol version.
+const closeVersionMismatch = 4000
+
+// handshakeTimeout is the time the server waits for the handshake message
+// of a client.
+const handshakeTimeout = 10 * time.Second
+
+// A VersionMismatchEvent is fired if the JavaScript client in the web
+// browser is not compatible with the server, for example because the
+// browser runs an outdated version of the script after the server was
+// upgraded. The server closes the connection with an explanatory reason,
+// instead of sending draw commands that the client would misinterpret,
+// and the client reloads the page if Options.ReconnectInterval is set.
+//
+// The VersionMismatchEvent is the first and only event received by the
+// run function, followed by a CloseEvent. The draw commands of the run
+// function are discarded. It is not necessary to enable the
+// VersionMismatchEvent with Options.EnabledEvents, it is always enabled.
+type VersionMismatchEvent struct {
+	// ClientVersion is the protocol version of the client, or 0 if the
+	// client did not send its version.
+	ClientVersion int
+	// ServerVersion is the protocol version of the server.
+	ServerVersion int
+}
+
+func (e VersionMismatchEvent) mask() eventMask { return 0 }
+
+// hello is the content of a handshake message.
+type hello struct {
+	version      uint32
+	capabilities uint32
+}
+
+// readHello reads the handshake message of the client. A client that
+// does not send a handshake message within handshakeTimeout, or whose
+// first message is not a handshake message, is assumed to be an old
+// client without handshake, and version 0 is returned. An error is
+// returned if the connection was closed before.
+func readHello(conn *websocket.Conn) (hello, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	for {
+		messageType, p, err := conn.ReadMessage()
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return hello{}, nil
+		}
+		if err != nil {
+			return hello{}, err
+		}
+		if messageType != websocket.BinaryMessage {
+			continue
+		}
+		buf := &buffer{bytes: p}
+		if len(p) != 9 || buf.readByte() != msgHello {
+			return hello{}, nil
+		}
+		return hello{
+			version:      buf.readUint32(),
+			capabilities: buf.readUint32(),
+		}, nil
+	}
+}
+
+// rejectClient closes the connection to a client with an incompatible
+// protocol version.
+func rejectClient(conn *websocket.Conn, clientVersion uint32) {
+	reason := fmt.Sprintf("canvas: protocol version %d of the client does not match version %d of the server", clientVersion, protocolVersion)
+	msg := websocket.FormatCloseMessage(closeVersionMismatch, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// runRejected calls the run function for a rejected client, with a
+// VersionMismatchEvent followed by a CloseEvent and without sending the
+// draw commands to the client.
+func runRejected(run func(*Context), opts *Options, clientVersion uint32) {
+	draws := make(chan []byte)
+	go func() {
+		for range draws {
+		}
+	}()
+	defer close(draws)
+	events := make(chan Event, 2)
+	events <- VersionMismatchEvent{
+		ClientVersion: int(clientVersion),
+		ServerVersion: protocolVersion,
+	}
+	events <- CloseEvent{}
+	close(events)
+	run(newContext(draws, events, opts))
+}
+
+// javaScriptVersion identifies the content of the JavaScript file. The
+// HTML page references the file with it as a query parameter, so that web
+// browsers do not use an outdated cached version of the file.
+var javaScriptVersion = contentHash(javaScriptCode)
+
+func contentHash(p []byte) string {
+	sum := sha256.Sum256(p)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"errors"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+)
+
+func sendHello(t *testing.T, conn *websocket.Conn, capabilities uint32) {
+	t.Helper()
+	sendHelloVersion(t, conn, protocolVersion, capabilities)
+}
+
+func sendHelloVersion(t *testing.T, conn *websocket.Conn, version, capabilities uint32) {
+	t.Helper()
+	buf := &buffer{}
+	buf.addByte(msgHello)
+	buf.addUint32(version)
+	buf.addUint32(capabilities)
+	if err := conn.WriteMessage(websocket.BinaryMessage, buf.bytes); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		firstMessage []byte
+		want         VersionMismatchEvent
+	}{
+		{
+			"other version",
+			[]byte{msgHello, 0, 0, 0, 99, 0, 0, 0, 1},
+			VersionMismatchEvent{ClientVersion: 99, ServerVersion: protocolVersion},
+		},
+		{
+			"client without handshake",
+			[]byte{evMouseDown, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0},
+			VersionMismatchEvent{ClientVersion: 0, ServerVersion: protocolVersion},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan []Event, 1)
+			srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+				ctx.FillRect(1, 2, 3, 4)
+				ctx.Flush()
+				var events []Event
+				for event := range ctx.Events() {
+					events = append(events, event)
+				}
+				received <- events
+			}, &Options{EnabledEvents: []Event{MouseDownEvent{}}}))
+			defer srv.Close()
+
+			url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/draw"
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if err := conn.WriteMessage(websocket.BinaryMessage, tt.firstMessage); err != nil {
+				t.Fatal(err)
+			}
+
+			_, p, err := conn.ReadMessage()
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				t.Fatalf("got message %v and error %v, want close error", p, err)
+			}
+			if closeErr.Code != closeVersionMismatch {
+				t.Errorf("got close code %d, want %d", closeErr.Code, closeVersionMismatch)
+			}
+			if !strings.Contains(closeErr.Text, "protocol version") {
+				t.Errorf("got close reason %q, want explanation of protocol version mismatch", closeErr.Text)
+			}
+
+			want := []Event{tt.want, CloseEvent{}}
+			if diff := cmp.Diff(want, <-received); diff != "" {
+				t.Errorf("events mismatch (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJavaScriptCaching(t *testing.T) {
+	opts := &Options{}
+	opts.applyDefaults()
+	rec := httptest.NewRecorder()
+	(&htmlHandler{opts: opts}).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	wantSrc := `src="canvas-websocket.js?v=` + javaScriptVersion + `"`
+	if !strings.Contains(rec.Body.String(), wantSrc) {
+		t.Errorf("missing %s in:\n%s", wantSrc, rec.Body)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/canvas-websocket.js?v=" + javaScriptVersion, "public, max-age=31536000, immutable"},
+		{"/canvas-websocket.js?v=0123456789abcdef", "no-cache"},
+		{"/canvas-websocket.js", "no-cache"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		javaScriptHandler(rec, httptest.NewRequest("GET", tt.url, nil))
+		if got := rec.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: got Cache-Control %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+// TestJavaScriptProtocol checks that the JavaScript client implements the
+// protocol version of the server.
+func TestJavaScriptProtocol(t *testing.T) {
+	code := string(javaScriptCode)
+	constants := map[string]int{
+		"protocolVersion":      protocolVersion,
+		"capCompactEncoding":   int(capCompactEncoding),
+		"closeVersionMismatch": closeVersionMismatch,
+	}
+	for name, want := range constants {
+		m := regexp.MustCompile(`const ` + name + ` = (\d+);`).FindStringSubmatch(code)
+		if m == nil {
+			t.Errorf("constant %s not found in JavaScript code", name)
+			continue
+		}
+		if got, _ := strconv.Atoi(m[1]); got != want {
+			t.Errorf("JavaScript constant %s = %d, want %d", name, got, want)
+		}
+	}
+
+	start := strings.Index(code, "function draw(")
+	end := strings.Index(code, "function sendSnapshot(")
+	if start < 0 || end < start {
+		t.Fatal("draw function not found in JavaScript code")
+	}
+	handled := make(map[byte]bool)
+	for _, m := range regexp.MustCompile(`case (\d+):`).FindAllStringSubmatch(code[start:end], -1) {
+		opcode, _ := strconv.Atoi(m[1])
+		handled[byte(opcode)] = true
+	}
+	for opcode := bArc; opcode <= bUpdateImageData; opcode++ {
+		if opcode == 12 {
+			// Unused opcode.
+			continue
+		}
+		if !handled[opcode] {
+			t.Errorf("opcode %d is not handled by the JavaScript draw function", opcode)
+		}
+		delete(handled, opcode)
+	}
+	for opcode := range handled {
+		t.Errorf("JavaScript draw function handles unknown opcode %d", opcode)
+	}
+}
@@ -11,12 +11,18 @@
 package canvas
 
 import (
+	"bytes"
 	"image"
 	"image/draw"
 )
 
+// imageTileSize is the width and height of the tiles in which
+// ImageData.Update compares the pixels of an image.
+const imageTileSize = 16
+
 // ImageData represents the underlying pixel data of an image. It is
-// created using the Context.CreateImageData and Context.GetImageData methods.
+// created using the Context.CreateImageData, Context.CreateImageFromEncoded
+// and Context.GetImageData methods.
 // It can also be used to set a part of the canvas by using
 // Context.PutImageData, Context.PutImageDataDirty, Context.DrawImage and
 // Context.DrawImageScaled.
@@ -24,10 +30,13 @@ import (
 // The image data should be released with the Release method when it is no
 // longer needed.
 type ImageData struct {
-	id       uint32
-	ctx      *Context
-	width    int
-	height   int
+	id     uint32
+	ctx    *Context
+	width  int
+	height int
+	// pix are the pixels that were last sent to the client, without
+	// padding between rows, or nil if they are not known.
+	pix      []byte
 	released bool
 }
 
@@ -41,6 +50,14 @@ func (m *ImageData) Height() int {
 	return m.height
 }
 
+// ID returns the ID of the image data, which identifies it in an
+// ImageReadyEvent, ImageLoadedEvent and ImageErrorEvent, and in the draw
+// commands of package command. The IDs are assigned in creation order,
+// starting with 0.
+func (m *ImageData) ID() uint32 {
+	return m.id
+}
+
 // Release releases the image data on the client side.
 func (m *ImageData) Release() {
 	if m.released {
@@ -48,9 +65,137 @@ func (m *ImageData) Release() {
 	}
 	m.ctx.buf.addByte(bReleaseImageData)
 	m.ctx.buf.addUint32(m.id)
+	m.pix = nil
 	m.released = true
 }
 
+// Update replaces the pixels of the image data on the client with the
+// pixels of the given image. Only the tiles of the image that changed since
+// the pixels were last sent to the client are transmitted, which makes
+// Update much cheaper than creating a new ImageData for images of which
+// only small parts change from frame to frame, like heat maps or cellular
+// automata. Like all frames, the transmitted pixels are compressed if
+// Options.Compression is set.
+//
+// To find the changed tiles, the image data keeps a copy of the pixels
+// that were sent to the client by Update. The first call of Update
+// transmits the whole image like Context.CreateImageData, so that image
+// data that is never updated does not keep a copy. The whole image is also
+// transmitted if the size of the given image differs from the size of the
+// image data, or if all tiles changed.
+func (m *ImageData) Update(img image.Image) {
+	m.checkUseAfterRelease()
+	rgba := ensureRGBA(img)
+	bounds := rgba.Bounds()
+	if m.pix == nil || bounds.Dx() != m.width || bounds.Dy() != m.height {
+		m.upload(rgba)
+		return
+	}
+	tiles := m.changedTiles(rgba)
+	if len(tiles) == 0 {
+		return
+	}
+	size := 0
+	for _, t := range tiles {
+		size += 4 * t.Dx() * t.Dy()
+	}
+	if size == len(m.pix) {
+		m.upload(rgba)
+		return
+	}
+	buf := &m.ctx.buf
+	buf.addByte(bUpdateImageData)
+	buf.addUint32(m.id)
+	buf.addUint32(uint32(len(tiles)))
+	for _, t := range tiles {
+		buf.addUint32(uint32(t.Min.X))
+		buf.addUint32(uint32(t.Min.Y))
+		buf.addUint32(uint32(t.Dx()))
+		buf.addUint32(uint32(t.Dy()))
+		for y := t.Min.Y; y < t.Max.Y; y++ {
+			row := m.pixRow(y)[4*t.Min.X : 4*t.Max.X]
+			copy(row, rgbaRow(rgba, y)[4*t.Min.X:4*t.Max.X])
+			buf.addBytes(row)
+		}
+	}
+}
+
+// upload sends all pixels of the image to the client, which creates the
+// image data anew with the same ID.
+func (m *ImageData) upload(img *image.RGBA) {
+	bounds := img.Bounds()
+	m.width, m.height = bounds.Dx(), bounds.Dy()
+	m.pix = packPixels(img)
+	buf := &m.ctx.buf
+	buf.addByte(bCreateImageData)
+	buf.addUint32(m.id)
+	buf.addUint32(uint32(m.width))
+	buf.addUint32(uint32(m.height))
+	buf.addBytes(m.pix)
+}
+
+// changedTiles returns the tiles in which the pixels of the image differ
+// from the pixels that were last sent to the client. Adjacent changed tiles
+// within a row of tiles are merged into one rectangle.
+func (m *ImageData) changedTiles(img *image.RGBA) []image.Rectangle {
+	var tiles []image.Rectangle
+	for y0 := 0; y0 < m.height; y0 += imageTileSize {
+		y1 := min(y0+imageTileSize, m.height)
+		var run image.Rectangle
+		for x0 := 0; x0 < m.width; x0 += imageTileSize {
+			tile := image.Rect(x0, y0, min(x0+imageTileSize, m.width), y1)
+			if !m.tileChanged(img, tile) {
+				continue
+			}
+			if !run.Empty() && run.Max.X == tile.Min.X {
+				run.Max.X = tile.Max.X
+				continue
+			}
+			if !run.Empty() {
+				tiles = append(tiles, run)
+			}
+			run = tile
+		}
+		if !run.Empty() {
+			tiles = append(tiles, run)
+		}
+	}
+	return tiles
+}
+
+func (m *ImageData) tileChanged(img *image.RGBA, tile image.Rectangle) bool {
+	for y := tile.Min.Y; y < tile.Max.Y; y++ {
+		known := m.pixRow(y)[4*tile.Min.X : 4*tile.Max.X]
+		if !bytes.Equal(known, rgbaRow(img, y)[4*tile.Min.X:4*tile.Max.X]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pixRow returns the known pixels of row y.
+func (m *ImageData) pixRow(y int) []byte {
+	return m.pix[4*m.width*y : 4*m.width*(y+1)]
+}
+
+// rgbaRow returns the pixels of row y of the image, counted from the top
+// of its bounds.
+func rgbaRow(img *image.RGBA, y int) []byte {
+	bounds := img.Bounds()
+	i := img.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+	return img.Pix[i : i+4*bounds.Dx()]
+}
+
+// packPixels returns the pixels of the image without padding between rows.
+func packPixels(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	pix := make([]byte, 0, 4*bounds.Dx()*bounds.Dy())
+	for y := range bounds.Dy() {
+		pix = append(pix, rgbaRow(img, y)...)
+	}
+	return pix
+}
+
 func (m *ImageData) checkUseAfterRelease() {
 	if m.released {
 		panic("ImageData: use after release")
@@ -5,8 +5,10 @@
 package canvas
 
 import (
+	"bytes"
 	"image"
 	"image/color"
+	"image/png"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -76,3 +78,174 @@ func TestEnsureRGBA(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateImageFromEncodedSize(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+	}{
+		{"registered format", buf.Bytes(), 3, 2},
+		{"unknown format", []byte("RIFF....WEBP"), 0, 0},
+	}
+	ctx := newContext(nil, nil, nil)
+	for _, tt := range tests {
+		img := ctx.CreateImageFromEncoded(tt.data, "image/png")
+		if img.Width() != tt.width || img.Height() != tt.height {
+			t.Errorf("%s: got size %dx%d, want %dx%d", tt.name, img.Width(), img.Height(), tt.width, tt.height)
+		}
+	}
+}
+
+func TestImageDataChangedTiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed []image.Point
+		want    []image.Rectangle
+	}{
+		{"unchanged", nil, nil},
+		{"one pixel", []image.Point{{1, 1}}, []image.Rectangle{image.Rect(0, 0, 16, 16)}},
+		{"adjacent tiles", []image.Point{{1, 1}, {20, 5}}, []image.Rectangle{image.Rect(0, 0, 32, 16)}},
+		{"separate tiles", []image.Point{{1, 1}, {35, 1}}, []image.Rectangle{
+			image.Rect(0, 0, 16, 16), image.Rect(32, 0, 40, 16),
+		}},
+		{"clipped tile", []image.Point{{39, 39}}, []image.Rectangle{image.Rect(32, 32, 40, 40)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newContext(nil, nil, nil)
+			img := ctx.CreateImageData(image.NewRGBA(image.Rect(0, 0, 40, 40)))
+			img.Update(image.NewRGBA(image.Rect(0, 0, 40, 40)))
+			changed := image.NewRGBA(image.Rect(0, 0, 40, 40))
+			for _, p := range tt.changed {
+				changed.SetRGBA(p.X, p.Y, color.RGBA{R: 0xff, A: 0xff})
+			}
+			got := img.changedTiles(changed)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImageDataUpdate(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	tests := []struct {
+		name   string
+		update func() image.Image
+		want   []byte
+	}{
+		{
+			"unchanged",
+			func() image.Image {
+				return image.NewRGBA(image.Rect(0, 0, 17, 1))
+			},
+			[]byte{},
+		},
+		{
+			"changed tile",
+			func() image.Image {
+				img := image.NewRGBA(image.Rect(0, 0, 17, 1))
+				img.SetRGBA(16, 0, red)
+				return img
+			},
+			[]byte{
+				0x4e,                   // UpdateImageData
+				0x00, 0x00, 0x00, 0x00, // ID
+				0x00, 0x00, 0x00, 0x01, // len(tiles)
+				0x00, 0x00, 0x00, 0x10, // X
+				0x00, 0x00, 0x00, 0x00, // Y
+				0x00, 0x00, 0x00, 0x01, // Width
+				0x00, 0x00, 0x00, 0x01, // Height
+				0xff, 0x00, 0x00, 0xff, // Pix
+			},
+		},
+		{
+			"sub-image",
+			func() image.Image {
+				img := image.NewRGBA(image.Rect(0, 0, 20, 2))
+				img.SetRGBA(18, 1, red)
+				return img.SubImage(image.Rect(2, 1, 19, 2))
+			},
+			[]byte{
+				0x4e,                   // UpdateImageData
+				0x00, 0x00, 0x00, 0x00, // ID
+				0x00, 0x00, 0x00, 0x01, // len(tiles)
+				0x00, 0x00, 0x00, 0x10, // X
+				0x00, 0x00, 0x00, 0x00, // Y
+				0x00, 0x00, 0x00, 0x01, // Width
+				0x00, 0x00, 0x00, 0x01, // Height
+				0xff, 0x00, 0x00, 0xff, // Pix
+			},
+		},
+		{
+			"all tiles changed",
+			func() image.Image {
+				img := image.NewRGBA(image.Rect(0, 0, 17, 1))
+				img.SetRGBA(0, 0, red)
+				img.SetRGBA(16, 0, red)
+				return img
+			},
+			append([]byte{
+				0x08,                   // CreateImageData
+				0x00, 0x00, 0x00, 0x00, // ID
+				0x00, 0x00, 0x00, 0x11, // Width
+				0x00, 0x00, 0x00, 0x01, // Height
+				0xff, 0x00, 0x00, 0xff, // Pix
+			}, append(make([]byte, 15*4), 0xff, 0x00, 0x00, 0xff)...),
+		},
+		{
+			"changed size",
+			func() image.Image {
+				return image.NewRGBA(image.Rect(0, 0, 1, 1))
+			},
+			[]byte{
+				0x08,                   // CreateImageData
+				0x00, 0x00, 0x00, 0x00, // ID
+				0x00, 0x00, 0x00, 0x01, // Width
+				0x00, 0x00, 0x00, 0x01, // Height
+				0x00, 0x00, 0x00, 0x00, // Pix
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := newContext(nil, nil, nil)
+			img := ctx.CreateImageData(image.NewRGBA(image.Rect(0, 0, 17, 1)))
+			img.Update(image.NewRGBA(image.Rect(0, 0, 17, 1)))
+			ctx.buf.reset()
+			img.Update(tt.update())
+			if diff := cmp.Diff(tt.want, ctx.buf.bytes); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImageDataFirstUpdate(t *testing.T) {
+	ctx := newContext(nil, nil, nil)
+	img := ctx.CreateImageData(image.NewRGBA(image.Rect(0, 0, 2, 1)))
+	if img.pix != nil {
+		t.Errorf("CreateImageData kept a copy of the pixels")
+	}
+	ctx.buf.reset()
+	img.Update(image.NewRGBA(image.Rect(0, 0, 2, 1)))
+	want := []byte{
+		0x08,                   // CreateImageData
+		0x00, 0x00, 0x00, 0x00, // ID
+		0x00, 0x00, 0x00, 0x02, // Width
+		0x00, 0x00, 0x00, 0x01, // Height
+		0x00, 0x00, 0x00, 0x00, // Pix
+		0x00, 0x00, 0x00, 0x00,
+	}
+	if diff := cmp.Diff(want, ctx.buf.bytes); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	if img.pix == nil {
+		t.Errorf("Update did not keep a copy of the pixels")
+	}
+}
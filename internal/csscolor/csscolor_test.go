@@ -0,0 +1,61 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package csscolor
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.RGBA
+	}{
+		{"red", color.RGBA{R: 0xff, A: 0xff}},
+		{"  RebeccaPurple ", color.RGBA{R: 0x66, G: 0x33, B: 0x99, A: 0xff}},
+		{"transparent", color.RGBA{}},
+		{"#f80", color.RGBA{R: 0xff, G: 0x88, A: 0xff}},
+		{"#f808", color.RGBA{R: 0xff, G: 0x88, A: 0x88}},
+		{"#12ab34", color.RGBA{R: 0x12, G: 0xab, B: 0x34, A: 0xff}},
+		{"#12ab3480", color.RGBA{R: 0x12, G: 0xab, B: 0x34, A: 0x80}},
+		{"rgb(255, 128, 0)", color.RGBA{R: 0xff, G: 0x80, A: 0xff}},
+		{"rgba(255, 128, 0, 0.5)", color.RGBA{R: 0xff, G: 0x80, A: 0x80}},
+		{"rgb(100% 50% 0% / 50%)", color.RGBA{R: 0xff, G: 0x80, A: 0x80}},
+		{"rgb(300, -5, 0)", color.RGBA{R: 0xff, A: 0xff}},
+		{"hsl(120, 100%, 50%)", color.RGBA{G: 0xff, A: 0xff}},
+		{"hsla(240deg 100% 25% / 0.5)", color.RGBA{B: 0x80, A: 0x80}},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.s)
+		if !ok {
+			t.Errorf("Parse(%q): expected success, but failed", tt.s)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q): got: %v, want: %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"notacolor",
+		"#12",
+		"#12345",
+		"#ggg",
+		"rgb(1, 2)",
+		"rgb(1, 2, 3",
+		"rgb(a, b, c)",
+		"hsl(120, 100, 50)",
+		"cmyk(0, 0, 0, 0)",
+	}
+	for _, s := range tests {
+		if got, ok := Parse(s); ok {
+			t.Errorf("Parse(%q): expected failure, but got: %v", s, got)
+		}
+	}
+}
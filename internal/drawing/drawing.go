@@ -0,0 +1,42 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package drawing records what a function draws on a canvas.Context
+// without a client connection. It is shared by the Draw functions of the
+// renderer packages raster, svg and pdf.
+package drawing
+
+import (
+	"fmt"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+)
+
+// Frames creates a canvas.Context with a canvas of the given size, calls
+// the draw function with it, and returns the decoded frames in flush
+// order. The draw commands that the function did not flush are returned
+// as the last frame, unless there are none. Frames panics if a frame
+// cannot be decoded, which indicates a bug in package canvas.
+func Frames(width, height int, draw func(ctx *canvas.Context)) [][]command.Command {
+	draws := make(chan []byte)
+	ctx := canvas.NewContext(draws, nil, &canvas.Options{Width: width, Height: height})
+	go func() {
+		defer close(draws)
+		draw(ctx)
+		ctx.Flush()
+	}()
+	var frames [][]command.Command
+	for p := range draws {
+		if len(p) == 0 {
+			continue
+		}
+		cmds, err := command.Decode(p)
+		if err != nil {
+			panic(fmt.Sprintf("drawing: frame %d: %v", len(frames), err))
+		}
+		frames = append(frames, cmds)
+	}
+	return frames
+}
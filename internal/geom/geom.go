@@ -0,0 +1,382 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package geom provides the points, affine transformations and paths
+// shared by the renderers (raster, SVG, PDF).
+package geom
+
+import (
+	"math"
+
+	"github.com/fzipp/canvas"
+)
+
+type Point struct {
+	X, Y float64
+}
+
+// Pt is shorthand for Point{X: x, Y: y}.
+func Pt(x, y float64) Point {
+	return Point{X: x, Y: y}
+}
+
+func (p Point) Add(q Point) Point     { return Point{p.X + q.X, p.Y + q.Y} }
+func (p Point) Sub(q Point) Point     { return Point{p.X - q.X, p.Y - q.Y} }
+func (p Point) Mul(s float64) Point   { return Point{p.X * s, p.Y * s} }
+func (p Point) Dot(q Point) float64   { return p.X*q.X + p.Y*q.Y }
+func (p Point) Cross(q Point) float64 { return p.X*q.Y - p.Y*q.X }
+func (p Point) Length() float64       { return math.Hypot(p.X, p.Y) }
+func (p Point) Perp() Point           { return Point{-p.Y, p.X} }
+func (p Point) Lerp(q Point, t float64) Point {
+	return Point{p.X + (q.X-p.X)*t, p.Y + (q.Y-p.Y)*t}
+}
+
+func (p Point) Normalize() Point {
+	l := p.Length()
+	if l == 0 {
+		return Point{}
+	}
+	return Point{p.X / l, p.Y / l}
+}
+
+// Matrix is an affine transformation matrix
+//
+//	A C E
+//	B D F
+//	0 0 1
+//
+// as used by canvas.Context.SetTransform, by the SVG matrix transform
+// function and by the PDF cm operator.
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+var Identity = Matrix{A: 1, D: 1}
+
+// Multiply returns the matrix m×n, which applies n first, then m.
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.C*n.B,
+		B: m.B*n.A + m.D*n.B,
+		C: m.A*n.C + m.C*n.D,
+		D: m.B*n.C + m.D*n.D,
+		E: m.A*n.E + m.C*n.F + m.E,
+		F: m.B*n.E + m.D*n.F + m.F,
+	}
+}
+
+func (m Matrix) Apply(p Point) Point {
+	return Point{
+		X: m.A*p.X + m.C*p.Y + m.E,
+		Y: m.B*p.X + m.D*p.Y + m.F,
+	}
+}
+
+// Scale returns the largest factor by which the matrix stretches lengths.
+func (m Matrix) Scale() float64 {
+	return math.Max(math.Hypot(m.A, m.B), math.Hypot(m.C, m.D))
+}
+
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.D - m.B*m.C
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.D / det,
+		B: -m.B / det,
+		C: -m.C / det,
+		D: m.A / det,
+		E: (m.C*m.F - m.D*m.E) / det,
+		F: (m.B*m.E - m.A*m.F) / det,
+	}, true
+}
+
+// A Segment is a path command in device space: 'M' (move to),
+// 'L' (line to), 'Q' (quadratic curve to), 'C' (cubic curve to), or
+// 'Z' (close path). The last point is the end point of the segment,
+// the points before it are control points.
+type Segment struct {
+	Op     byte
+	Points []Point
+}
+
+// A Path is a canvas path in device space. The points are transformed
+// when they are added to the path, with the transformation that is
+// current at that time, like a canvas path is. Since affine
+// transformations map lines and Bézier curves to lines and Bézier curves,
+// the path can be exactly transformed back into any user space when it
+// is written.
+type Path struct {
+	Segments []Segment
+	start    Point
+	current  Point
+	// open is true if the path has a current subpath.
+	open bool
+}
+
+func (p *Path) Reset() {
+	*p = Path{}
+}
+
+func (p *Path) add(op byte, points ...Point) {
+	p.Segments = append(p.Segments, Segment{Op: op, Points: points})
+	if len(points) > 0 {
+		p.current = points[len(points)-1]
+	}
+}
+
+func (p *Path) MoveTo(q Point) {
+	p.add('M', q)
+	p.start = q
+	p.open = true
+}
+
+func (p *Path) LineTo(q Point) {
+	if !p.open {
+		p.MoveTo(q)
+		return
+	}
+	p.add('L', q)
+}
+
+// ensureSubpath starts a new subpath at q if there is no current subpath.
+func (p *Path) ensureSubpath(q Point) {
+	if !p.open {
+		p.MoveTo(q)
+	}
+}
+
+func (p *Path) ClosePath() {
+	if !p.open {
+		return
+	}
+	p.add('Z')
+	p.current = p.start
+}
+
+func (p *Path) QuadraticCurveTo(c, q Point) {
+	p.ensureSubpath(c)
+	p.add('Q', c, q)
+}
+
+func (p *Path) BezierCurveTo(c1, c2, q Point) {
+	p.ensureSubpath(c1)
+	p.add('C', c1, c2, q)
+}
+
+// Ellipse adds an elliptical arc, given in user space, to the path as
+// a sequence of cubic Bézier curves.
+func (p *Path) Ellipse(m Matrix, center Point, rx, ry, rotation, start, end float64, anticlockwise bool) {
+	sweep := arcSweep(start, end, anticlockwise)
+	sin, cos := math.Sincos(rotation)
+	toUser := func(q Point) Point {
+		x, y := rx*q.X, ry*q.Y
+		return Point{
+			X: center.X + x*cos - y*sin,
+			Y: center.Y + x*sin + y*cos,
+		}
+	}
+	s, c := math.Sincos(start)
+	p.LineTo(m.Apply(toUser(Point{c, s})))
+	n := max(int(math.Ceil(math.Abs(sweep)/(math.Pi/2)-1e-9)), 1)
+	step := sweep / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	for i := range n {
+		a0 := start + step*float64(i)
+		a1 := a0 + step
+		s0, c0 := math.Sincos(a0)
+		s1, c1 := math.Sincos(a1)
+		p0 := Point{c0, s0}
+		p3 := Point{c1, s1}
+		p1 := p0.Add(Point{-s0, c0}.Mul(k))
+		p2 := p3.Sub(Point{-s1, c1}.Mul(k))
+		p.add('C', m.Apply(toUser(p1)), m.Apply(toUser(p2)), m.Apply(toUser(p3)))
+	}
+}
+
+// arcSweep returns the signed angle swept by an arc from start to end,
+// following the rules of the canvas arc and ellipse methods.
+func arcSweep(start, end float64, anticlockwise bool) float64 {
+	const fullCircle = 2 * math.Pi
+	switch {
+	case !anticlockwise && end-start >= fullCircle:
+		return fullCircle
+	case anticlockwise && start-end >= fullCircle:
+		return -fullCircle
+	case !anticlockwise:
+		sweep := math.Mod(end-start, fullCircle)
+		if sweep < 0 {
+			sweep += fullCircle
+		}
+		return sweep
+	default:
+		sweep := math.Mod(start-end, fullCircle)
+		if sweep < 0 {
+			sweep += fullCircle
+		}
+		return -sweep
+	}
+}
+
+// ArcTo adds an arc that is tangent to the lines from the current point
+// to p1 and from p1 to p2, given in user space, to the path.
+func (p *Path) ArcTo(m Matrix, p1, p2 Point, radius float64) {
+	p.ensureSubpath(m.Apply(p1))
+	inv, ok := m.Invert()
+	if !ok {
+		return
+	}
+	p0 := inv.Apply(p.current)
+	d0 := p0.Sub(p1)
+	d2 := p2.Sub(p1)
+	cross := d0.Cross(d2)
+	if p0 == p1 || p1 == p2 || radius == 0 || math.Abs(cross) < 1e-12*d0.Length()*d2.Length() {
+		p.LineTo(m.Apply(p1))
+		return
+	}
+	u0 := d0.Normalize()
+	u2 := d2.Normalize()
+	halfAngle := math.Acos(math.Max(-1, math.Min(1, u0.Dot(u2)))) / 2
+	dist := radius / math.Tan(halfAngle)
+	t0 := p1.Add(u0.Mul(dist))
+	t2 := p1.Add(u2.Mul(dist))
+	center := p1.Add(u0.Add(u2).Normalize().Mul(radius / math.Sin(halfAngle)))
+	start := math.Atan2(t0.Y-center.Y, t0.X-center.X)
+	end := math.Atan2(t2.Y-center.Y, t2.X-center.X)
+	anticlockwise := p1.Sub(p0).Cross(p2.Sub(p1)) < 0
+	p.Ellipse(m, center, radius, radius, 0, start, end, anticlockwise)
+}
+
+func (p *Path) Rect(m Matrix, x, y, w, h float64) {
+	p.MoveTo(m.Apply(Point{x, y}))
+	p.LineTo(m.Apply(Point{x + w, y}))
+	p.LineTo(m.Apply(Point{x + w, y + h}))
+	p.LineTo(m.Apply(Point{x, y + h}))
+	p.ClosePath()
+}
+
+// Polyline adds a sub-path through the points, given as alternating x and
+// y coordinates, and closes it if closed is set.
+func (p *Path) Polyline(m Matrix, points []float64, closed bool) {
+	if len(points) < 2 {
+		return
+	}
+	p.MoveTo(m.Apply(Point{points[0], points[1]}))
+	for i := 2; i+1 < len(points); i += 2 {
+		p.LineTo(m.Apply(Point{points[i], points[i+1]}))
+	}
+	if closed {
+		p.ClosePath()
+	}
+}
+
+// Circles adds a circle sub-path around each of the points, given as
+// alternating x and y coordinates.
+func (p *Path) Circles(m Matrix, points []float64, radius float64) {
+	for i := 0; i+1 < len(points); i += 2 {
+		x, y := points[i], points[i+1]
+		p.MoveTo(m.Apply(Point{x + radius, y}))
+		p.Ellipse(m, Point{x, y}, radius, radius, 0, 0, 2*math.Pi, false)
+	}
+}
+
+// A Polyline is a subpath of a Path with its curves flattened into line
+// segments.
+type Polyline struct {
+	Points []Point
+	Closed bool
+}
+
+// Flatten returns the subpaths of the path as polylines, with the curves
+// approximated by line segments that deviate at most tolerance from them.
+// Like in a canvas path, a closed subpath is followed by a new subpath at
+// its start point.
+func (p *Path) Flatten(tolerance float64) []Polyline {
+	var polylines []Polyline
+	var start Point
+	for _, seg := range p.Segments {
+		if seg.Op == 'M' {
+			start = seg.Points[0]
+			polylines = append(polylines, Polyline{Points: []Point{start}})
+			continue
+		}
+		pl := &polylines[len(polylines)-1]
+		if pl.Closed {
+			polylines = append(polylines, Polyline{Points: []Point{start}})
+			pl = &polylines[len(polylines)-1]
+		}
+		p0 := pl.Points[len(pl.Points)-1]
+		switch seg.Op {
+		case 'L':
+			pl.Points = append(pl.Points, seg.Points[0])
+		case 'Q':
+			c, q := seg.Points[0], seg.Points[1]
+			n := curveSegments(p0.Sub(c.Mul(2)).Add(q).Length(), tolerance)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				pl.Points = append(pl.Points, p0.Lerp(c, t).Lerp(c.Lerp(q, t), t))
+			}
+		case 'C':
+			c1, c2, q := seg.Points[0], seg.Points[1], seg.Points[2]
+			dd := math.Max(
+				p0.Sub(c1.Mul(2)).Add(c2).Length(),
+				c1.Sub(c2.Mul(2)).Add(q).Length(),
+			)
+			n := curveSegments(dd*1.5, tolerance)
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				a := p0.Lerp(c1, t)
+				b := c1.Lerp(c2, t)
+				c := c2.Lerp(q, t)
+				pl.Points = append(pl.Points, a.Lerp(b, t).Lerp(b.Lerp(c, t), t))
+			}
+		case 'Z':
+			pl.Closed = true
+		}
+	}
+	return polylines
+}
+
+// curveSegments returns the number of line segments for a curve whose
+// second differences have the given magnitude, so that the flattening
+// error stays below the tolerance.
+func curveSegments(dd, tolerance float64) int {
+	n := int(math.Ceil(math.Sqrt(dd / (8 * tolerance))))
+	return min(max(n, 1), 1000)
+}
+
+// Sprite is a canvas.SpriteInstance resolved for drawing an image of
+// a given size: the source rectangle SX, SY, SW, SH of the image is drawn
+// into the destination rectangle of the same size centered at the origin
+// of Transform, with the global alpha multiplied by Alpha.
+type Sprite struct {
+	Transform      Matrix
+	Alpha          float64
+	SX, SY, SW, SH float64
+}
+
+// ResolveSprite resolves a sprite instance for an image of the given size.
+func ResolveSprite(in canvas.SpriteInstance, width, height int) Sprite {
+	sp := Sprite{SX: in.SX, SY: in.SY, SW: in.SWidth, SH: in.SHeight}
+	if sp.SW == 0 || sp.SH == 0 {
+		sp.SX, sp.SY, sp.SW, sp.SH = 0, 0, float64(width), float64(height)
+	}
+	sin, cos := math.Sincos(in.Rotation)
+	sp.Transform = Matrix{
+		A: cos * in.Scale, B: sin * in.Scale,
+		C: -sin * in.Scale, D: cos * in.Scale,
+		E: in.X, F: in.Y,
+	}
+	sp.Alpha = math.Min(math.Max(in.Alpha, 0), 1)
+	return sp
+}
+
+// RectPath returns a path consisting of a single rectangle, given in
+// user space.
+func RectPath(m Matrix, x, y, w, h float64) *Path {
+	p := &Path{}
+	p.Rect(m, x, y, w, h)
+	return p
+}
@@ -0,0 +1,69 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package protocol defines the message types and version of the protocol
+// between the canvas server and its clients, shared by package canvas and
+// the headless client of package canvastest, so that both always speak the
+// same version.
+package protocol
+
+// Version is the version of the protocol between the server and the
+// JavaScript client, which comprises the draw command format, the event
+// format and the other messages. It must be incremented with every
+// incompatible change, for example a new opcode, and it must match
+// protocolVersion in canvas-websocket.js.
+const Version = 6
+
+// MsgHello is the type of the handshake message that the client sends
+// first after it connected. The message consists of the type, the
+// protocol version of the client (uint32) and its capability flags
+// (uint32).
+const MsgHello byte = 0x81
+
+// Capability flags of the handshake message.
+const (
+	// CapCompactEncoding indicates that the client decodes the compact
+	// encoding of draw frames.
+	CapCompactEncoding uint32 = 1 << iota
+)
+
+// MsgSnapshot is the type of the message with which the client replies to
+// a snapshot request. Unlike the event types it is not delivered as an
+// event.
+const MsgSnapshot byte = 0x80
+
+// Event types of the binary event format. The event mask of a page has
+// the bit 1<<(type-1) set for each enabled event type.
+const (
+	EventMouseMove byte = 1 + iota
+	EventMouseDown
+	EventMouseUp
+	EventKeyDown
+	EventKeyUp
+	EventClick
+	EventDblClick
+	EventAuxClick
+	EventWheel
+	EventTouchStart
+	EventTouchMove
+	EventTouchEnd
+	EventTouchCancel
+	EventTextInput
+	EventCompositionStart
+	EventCompositionUpdate
+	EventCompositionEnd
+	EventFocus
+	EventBlur
+	EventVisibilityChange
+	EventMouseEnter
+	EventMouseLeave
+	EventGamepadConnected
+	EventGamepadDisconnected
+	EventGamepadState
+	EventDrop
+	EventPaste
+	EventImageReady
+	EventImageLoaded
+	EventImageError
+)
@@ -6,6 +6,9 @@ package canvas
 
 import (
 	"image/color"
+	"io"
+	"io/fs"
+	"net/http"
 	"time"
 )
 
@@ -36,6 +39,12 @@ type Options struct {
 	MouseCursorHidden bool
 	// ContextMenuDisabled disables the context menu on the canvas.
 	ContextMenuDisabled bool
+	// BrowserShortcutsEnabled keeps the default browser actions of key
+	// events, so that shortcuts like F5 (reload) or Ctrl+L (focus address
+	// bar) still work while keyboard events are enabled.
+	// By default, the default action of every key event sent to the server
+	// is prevented.
+	BrowserShortcutsEnabled bool
 	// ScaleToPageWidth scales the canvas to the full horizontal extent
 	// of the page in the browser window.
 	// This scaling does not change the width within the coordinate system
@@ -52,8 +61,78 @@ type Options struct {
 	// If ReconnectInterval is not set (i.e. 0) the canvas will not try
 	// to reconnect if the connection was lost.
 	ReconnectInterval time.Duration
+	// MaxFileSize limits the total size in bytes of the file contents
+	// that are transmitted from the client to the server with a DropEvent
+	// or PasteEvent. The client transmits the contents of the files in
+	// order as long as they fit within the limit, and only the names,
+	// types, and sizes of the remaining files. The server discards file
+	// contents beyond the limit, and it closes the connection if a client
+	// sends a message that is larger than this limit, or than a snapshot
+	// of the canvas, plus 1 MiB for the other parts of the message like
+	// file names or pasted text.
+	// If MaxFileSize is not set (i.e. 0) a default value of 10 MiB
+	// will be used.
+	MaxFileSize int64
+	// Record enables the recording of sessions, for example to reproduce
+	// a problem exactly as a user experienced it. If Record is set, it is
+	// called when a client connects, with the request of the WebSocket
+	// connection, and the frames sent to the client and the events
+	// received from the client are written to the returned writer in the
+	// format of the recording package. The writer is closed when the
+	// session ends. If Record returns an error, the session is not
+	// recorded. See RecordToDir for recording each session to a file,
+	// and NewReplayServeMux for replaying a recording in the browser.
+	Record func(r *http.Request) (io.WriteCloser, error)
+	// Compression enables the compression of the draw frames sent to the
+	// client, see Compression. The statistics returned by Context.Stats
+	// show the achieved compression ratio.
+	// If Compression is not set (i.e. nil) the frames are sent
+	// uncompressed.
+	Compression *Compression
+	// Encoding selects the encoding of the numbers in the draw frames
+	// sent to the client. EncodingCompact roughly halves the size of
+	// frames with many coordinates, like polylines, at the cost of
+	// precision.
+	// If Encoding is not set (i.e. 0) EncodingStandard will be used.
+	Encoding Encoding
+	// StaticFiles is served under the path /static/, next to the canvas
+	// page, for example the images that are loaded by the client with
+	// Context.LoadImage. Since the browser caches these files, they are
+	// not transmitted again on every connection, in contrast to images
+	// created with Context.CreateImageData.
+	// If StaticFiles is not set (i.e. nil) no static files are served.
+	StaticFiles fs.FS
+	// DebugRedundantState enables a debug mode, in which each flush of a
+	// Context logs the number of bytes that were saved by skipping
+	// redundant state setters. The Context skips setters like
+	// SetFillStyle or SetLineWidth that would set a value that is already
+	// current, taking Save and Restore into account.
+	DebugRedundantState bool
 }
 
+// Encoding is the encoding of the numbers in draw frames.
+type Encoding int
+
+const (
+	// EncodingStandard encodes floating-point numbers like coordinates
+	// as 64-bit floats (8 bytes), and IDs and lengths as 32-bit unsigned
+	// integers (4 bytes).
+	EncodingStandard Encoding = iota
+	// EncodingCompact encodes floating-point numbers as 32-bit floats
+	// (4 bytes), and IDs and lengths as variable-length integers (1 byte
+	// for values up to 127). For example, a LineTo command takes 9 bytes
+	// instead of 17 bytes. 32-bit floats represent integers exactly up
+	// to 16777216 and have a precision of about 7 significant decimal
+	// digits, which is sufficient for canvas coordinates in most cases.
+	//
+	// The compact encoding is negotiated with the client when it
+	// connects: the standard encoding is used with clients that do not
+	// support it. Each compact frame starts with a marker, so that the
+	// decoders of packages command, raster, svg and pdf detect the
+	// encoding of a frame automatically.
+	EncodingCompact
+)
+
 func (o *Options) applyDefaults() {
 	if o.Width == 0 {
 		o.Width = 300
@@ -64,6 +143,9 @@ func (o *Options) applyDefaults() {
 	if o.PageBackground == nil {
 		o.PageBackground = color.White
 	}
+	if o.MaxFileSize == 0 {
+		o.MaxFileSize = 10 << 20
+	}
 }
 
 func (o *Options) eventMask() (mask eventMask) {
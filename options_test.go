@@ -24,6 +24,7 @@ func TestOptionsApplyDefaults(t *testing.T) {
 				Width:          300,
 				Height:         150,
 				PageBackground: color.White,
+				MaxFileSize:    10 << 20,
 			},
 		},
 		{
@@ -36,6 +37,7 @@ func TestOptionsApplyDefaults(t *testing.T) {
 				Width:          800,
 				Height:         600,
 				PageBackground: color.White,
+				MaxFileSize:    10 << 20,
 			},
 		},
 		{
@@ -47,6 +49,19 @@ func TestOptionsApplyDefaults(t *testing.T) {
 				Width:          300,
 				Height:         150,
 				PageBackground: color.Black,
+				MaxFileSize:    10 << 20,
+			},
+		},
+		{
+			"max file size given",
+			&Options{
+				MaxFileSize: 1024,
+			},
+			&Options{
+				Width:          300,
+				Height:         150,
+				PageBackground: color.White,
+				MaxFileSize:    1024,
 			},
 		},
 	}
@@ -0,0 +1,115 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// objects is the list of indirect objects of a PDF document. The object
+// with number n is objects[n-1].
+type objects [][]byte
+
+// add adds an object and returns its object number.
+func (o *objects) add(obj string) int {
+	*o = append(*o, []byte(obj))
+	return len(*o)
+}
+
+// addStream adds a stream object with the given dictionary entries and
+// the data compressed with the Flate filter, and returns its object
+// number.
+func (o *objects) addStream(dict string, data []byte) int {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	if dict != "" {
+		dict += " "
+	}
+	var obj bytes.Buffer
+	fmt.Fprintf(&obj, "<< %s/Filter /FlateDecode /Length %d >>\nstream\n", dict, buf.Len())
+	obj.Write(buf.Bytes())
+	obj.WriteString("\nendstream")
+	*o = append(*o, obj.Bytes())
+	return len(*o)
+}
+
+// writeTo writes a PDF file with the objects and a cross-reference table
+// to w. root is the object number of the document catalog.
+func (o objects) writeTo(w io.Writer, root int) (int64, error) {
+	var buf bytes.Buffer
+	// The comment with bytes above 127 marks the file as binary.
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(o))
+	for i, obj := range o {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", i+1)
+		buf.Write(obj)
+		buf.WriteString("\nendobj\n")
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n", len(o)+1)
+	buf.WriteString("0000000000 65535 f \n")
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(o)+1, root, xref)
+	return buf.WriteTo(w)
+}
+
+func ref(n int) string {
+	return strconv.Itoa(n) + " 0 R"
+}
+
+// fmtNumber formats a number as a PDF real, which must not be in
+// exponential notation.
+func fmtNumber(v float64) string {
+	v = math.Round(v*10000) / 10000
+	if v == 0 || math.IsNaN(v) {
+		// Avoid "-0"
+		v = 0
+	}
+	if math.IsInf(v, 0) {
+		// Real numbers are limited to about ±3.4e38 in PDF.
+		v = math.Copysign(math.MaxFloat32, v)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func fmtNumbers(vs ...float64) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = fmtNumber(v)
+	}
+	return strings.Join(s, " ")
+}
+
+// fmtString formats a byte string as a PDF literal string.
+func fmtString(s []byte) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, b := range s {
+		switch b {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\n':
+			sb.WriteString(`\n`)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
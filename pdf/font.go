@@ -0,0 +1,264 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A font is a standard PDF font at a size in canvas pixels.
+type font struct {
+	family family
+	bold   bool
+	italic bool
+	size   float64
+}
+
+var defaultFont = font{family: helvetica, size: 10}
+
+// family is one of the font families of the standard 14 fonts that every
+// PDF reader provides, except for Symbol and ZapfDingbats.
+type family int
+
+const (
+	helvetica family = iota
+	times
+	courier
+)
+
+// baseFont returns the PostScript name of the font.
+func (f font) baseFont() string {
+	style := 0
+	if f.bold {
+		style |= 1
+	}
+	if f.italic {
+		style |= 2
+	}
+	return baseFonts[f.family][style]
+}
+
+var baseFonts = [...][4]string{
+	helvetica: {"Helvetica", "Helvetica-Bold", "Helvetica-Oblique", "Helvetica-BoldOblique"},
+	times:     {"Times-Roman", "Times-Bold", "Times-Italic", "Times-BoldItalic"},
+	courier:   {"Courier", "Courier-Bold", "Courier-Oblique", "Courier-BoldOblique"},
+}
+
+// families maps CSS font family names to the standard font family that
+// substitutes them.
+var families = map[string]family{
+	"sans-serif":      helvetica,
+	"system-ui":       helvetica,
+	"helvetica":       helvetica,
+	"helvetica neue":  helvetica,
+	"arial":           helvetica,
+	"verdana":         helvetica,
+	"tahoma":          helvetica,
+	"segoe ui":        helvetica,
+	"serif":           times,
+	"times":           times,
+	"times new roman": times,
+	"georgia":         times,
+	"monospace":       courier,
+	"courier":         courier,
+	"courier new":     courier,
+	"consolas":        courier,
+	"menlo":           courier,
+	"monaco":          courier,
+}
+
+// parseFont parses the value of the CSS font shorthand property, for
+// example "italic bold 12px/30px Georgia, serif". The first family that
+// has a substitute among the standard fonts determines the family,
+// Helvetica is used if there is none. Font variants and stretches are
+// ignored.
+func parseFont(s string) (font, bool) {
+	f := font{family: helvetica}
+	rest := strings.TrimSpace(s)
+	for {
+		word, after, _ := strings.Cut(rest, " ")
+		if word == "" {
+			return font{}, false
+		}
+		if size, ok := parseFontSize(word); ok {
+			f.size = size
+			rest = after
+			break
+		}
+		switch strings.ToLower(word) {
+		case "italic", "oblique":
+			f.italic = true
+		case "bold", "bolder", "600", "700", "800", "900":
+			f.bold = true
+		}
+		rest = strings.TrimSpace(after)
+	}
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "/") {
+		// Line height
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	if strings.TrimSpace(rest) == "" {
+		return font{}, false
+	}
+	for _, name := range strings.Split(rest, ",") {
+		name = strings.ToLower(strings.Trim(strings.TrimSpace(name), `"'`))
+		if fam, ok := families[name]; ok {
+			f.family = fam
+			break
+		}
+	}
+	return f, true
+}
+
+// parseFontSize parses a CSS font size with an absolute or font-relative
+// length unit, optionally followed by a line height, and returns it in
+// canvas pixels.
+func parseFontSize(s string) (float64, bool) {
+	s, _, _ = strings.Cut(s, "/")
+	units := []struct {
+		suffix string
+		px     float64
+	}{
+		{"px", 1},
+		{"pt", 4.0 / 3},
+		{"rem", 16},
+		{"em", 16},
+		{"%", 16.0 / 100},
+	}
+	for _, u := range units {
+		if v, ok := strings.CutSuffix(s, u.suffix); ok {
+			size, err := strconv.ParseFloat(v, 64)
+			if err != nil || size < 0 {
+				return 0, false
+			}
+			return size * u.px, true
+		}
+	}
+	return 0, false
+}
+
+// encode converts a UTF-8 string to the WinAnsiEncoding of the standard
+// fonts. Characters that cannot be encoded are replaced by '?'.
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		case r == '\t':
+			b = append(b, ' ')
+		default:
+			c, ok := winAnsi[r]
+			if !ok {
+				c = '?'
+			}
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// winAnsi maps the characters of WinAnsiEncoding in the range 0x80–0x9f,
+// where it differs from Latin-1.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86,
+	'‡': 0x87, 'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c,
+	'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95,
+	'–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// width returns the width of the encoded text in canvas pixels.
+func (f font) width(text []byte) float64 {
+	w := 0
+	for _, c := range text {
+		w += f.charWidth(c)
+	}
+	return float64(w) * f.size / 1000
+}
+
+// charWidth returns the width of a character in thousandths of the font
+// size. The widths of the printable ASCII characters are taken from the
+// font metrics of the standard fonts. Times-BoldItalic is measured like
+// Times-Bold, and the oblique Helvetica fonts like the upright ones. The
+// widths of other characters are estimated.
+func (f font) charWidth(c byte) int {
+	if f.family == courier {
+		return 600
+	}
+	var widths *[95]int
+	switch {
+	case f.family == times && f.bold:
+		widths = &timesBoldWidths
+	case f.family == times && f.italic:
+		widths = &timesItalicWidths
+	case f.family == times:
+		widths = &timesRomanWidths
+	case f.bold:
+		widths = &helveticaBoldWidths
+	default:
+		widths = &helveticaWidths
+	}
+	if c < 0x20 || c >= 0x7f {
+		return widths['n'-0x20]
+	}
+	return widths[c-0x20]
+}
+
+// ascent and descent return the distances of the top and the bottom of
+// the em box from the baseline, in thousandths of the font size.
+func (f font) ascent() float64 {
+	return [...]float64{helvetica: 718, times: 683, courier: 629}[f.family]
+}
+
+func (f font) descent() float64 {
+	return [...]float64{helvetica: 207, times: 217, courier: 157}[f.family]
+}
+
+// Character widths for the characters 0x20 to 0x7e.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+	timesRomanWidths = [95]int{
+		250, 333, 408, 500, 500, 833, 778, 180, 333, 333, 500, 564, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444,
+		921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722,
+		556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500,
+		333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500,
+		500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541,
+	}
+	timesBoldWidths = [95]int{
+		250, 333, 555, 500, 500, 1000, 833, 278, 333, 333, 500, 570, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 570, 570, 570, 500,
+		930, 722, 667, 722, 722, 667, 611, 778, 778, 389, 500, 778, 667, 944, 722, 778,
+		611, 778, 722, 556, 667, 722, 722, 1000, 722, 722, 667, 333, 278, 333, 581, 500,
+		333, 500, 556, 444, 556, 444, 333, 500, 556, 278, 333, 556, 278, 833, 556, 500,
+		556, 556, 444, 389, 333, 556, 500, 722, 500, 500, 444, 394, 220, 394, 520,
+	}
+	timesItalicWidths = [95]int{
+		250, 333, 420, 500, 500, 833, 778, 214, 333, 333, 500, 675, 250, 333, 250, 278,
+		500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 333, 333, 675, 675, 675, 500,
+		920, 611, 611, 667, 722, 611, 611, 722, 722, 333, 444, 667, 556, 833, 667, 722,
+		611, 722, 611, 500, 556, 722, 611, 833, 611, 556, 556, 389, 278, 389, 422, 500,
+		333, 500, 500, 444, 500, 444, 278, 500, 500, 278, 278, 444, 278, 722, 500, 500,
+		500, 500, 389, 389, 278, 500, 444, 667, 444, 444, 389, 400, 275, 400, 541,
+	}
+)
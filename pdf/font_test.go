@@ -0,0 +1,73 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import "testing"
+
+func TestParseFont(t *testing.T) {
+	tests := []struct {
+		s    string
+		want font
+	}{
+		{"10px sans-serif", font{family: helvetica, size: 10}},
+		{"bold 12pt serif", font{family: times, bold: true, size: 16}},
+		{"italic 700 2em 'Courier New', monospace", font{family: courier, bold: true, italic: true, size: 32}},
+		{"small-caps 14px/20px Fantasy, Georgia", font{family: times, size: 14}},
+		{"  16px   \"Unknown Font\"  ", font{family: helvetica, size: 16}},
+		{"oblique 50% Arial", font{family: helvetica, italic: true, size: 8}},
+	}
+	for _, tt := range tests {
+		got, ok := parseFont(tt.s)
+		if !ok {
+			t.Errorf("parseFont(%q): expected success, but failed", tt.s)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseFont(%q): got: %+v, want: %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParseFontInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"sans-serif",
+		"bold serif",
+		"12px",
+		"-1px serif",
+		"12ab serif",
+	}
+	for _, s := range tests {
+		if got, ok := parseFont(s); ok {
+			t.Errorf("parseFont(%q): expected failure, but got: %+v", s, got)
+		}
+	}
+}
+
+func TestFontBaseFont(t *testing.T) {
+	tests := []struct {
+		f    font
+		want string
+	}{
+		{font{family: helvetica}, "Helvetica"},
+		{font{family: helvetica, bold: true, italic: true}, "Helvetica-BoldOblique"},
+		{font{family: times}, "Times-Roman"},
+		{font{family: times, italic: true}, "Times-Italic"},
+		{font{family: courier, bold: true}, "Courier-Bold"},
+	}
+	for _, tt := range tests {
+		if got := tt.f.baseFont(); got != tt.want {
+			t.Errorf("%+v.baseFont(): got: %q, want: %q", tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	got := string(encode("Grüße\t€ – ✓"))
+	want := "Gr\xfc\xdfe \x80 \x96 ?"
+	if got != want {
+		t.Errorf("got: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"strings"
+
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// pathData returns the path construction operators for the path, with the
+// points transformed by m. Quadratic curves are written as the equivalent
+// cubic curves, since PDF only has cubic curves.
+func pathData(p *geom.Path, m geom.Matrix) string {
+	var sb strings.Builder
+	var start, current geom.Point
+	closed := false
+	for _, seg := range p.Segments {
+		points := make([]geom.Point, len(seg.Points))
+		for i, q := range seg.Points {
+			points[i] = m.Apply(q)
+		}
+		if closed && seg.Op != 'M' {
+			// A canvas subpath that continues after closePath starts at
+			// the first point of the closed subpath.
+			writePoints(&sb, "m", start)
+		}
+		closed = false
+		switch seg.Op {
+		case 'M':
+			writePoints(&sb, "m", points...)
+			start = points[0]
+		case 'L':
+			writePoints(&sb, "l", points...)
+		case 'Q':
+			c, q := points[0], points[1]
+			c1 := current.Add(c.Sub(current).Mul(2.0 / 3))
+			c2 := q.Add(c.Sub(q).Mul(2.0 / 3))
+			writePoints(&sb, "c", c1, c2, q)
+		case 'C':
+			writePoints(&sb, "c", points...)
+		case 'Z':
+			sb.WriteString("h\n")
+			closed = true
+			current = start
+			continue
+		}
+		current = points[len(points)-1]
+	}
+	return sb.String()
+}
+
+func writePoints(sb *strings.Builder, op string, points ...geom.Point) {
+	for _, q := range points {
+		sb.WriteString(fmtNumbers(q.X, q.Y))
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(op)
+	sb.WriteByte('\n')
+}
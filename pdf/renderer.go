@@ -0,0 +1,986 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pdf converts the draw commands of a canvas.Context into a PDF
+// document, for example to export a report with several pages:
+//
+//	doc := pdf.Draw(595, 842, func(ctx *canvas.Context) {
+//		ctx.SetFont("bold 24px serif")
+//		ctx.FillText("Summary", 50, 80)
+//		ctx.Flush() // ends the first page
+//		ctx.FillRect(50, 50, 200, 100)
+//	})
+//	err := os.WriteFile("report.pdf", doc, 0o644)
+//
+// The document is a PDF 1.4 file with pages of the size of the canvas,
+// where a canvas pixel is one PDF point (1/72 inch). Each flushed frame
+// becomes a page, and a Renderer can also start a new page explicitly
+// with NewPage. The compressed content stream of a page flips the
+// coordinate system, so that the origin is at the top left like on the
+// canvas, and draws paths with the PDF path operators. Gradients become
+// shading patterns, canvas patterns become tiling patterns, and images
+// become image XObjects with their alpha channel as soft mask. Global
+// alpha and the blend modes of canvas.CompositeOperation are set with
+// graphics state parameter dictionaries.
+//
+// Text is set in the standard fonts that every PDF reader provides: the
+// font families of the canvas font are substituted by Helvetica, Times or
+// Courier, and characters that are not in the Windows-1252 character set
+// are replaced by question marks.
+//
+// The PDF imaging model has no equivalent for some canvas features.
+// Shading functions have no alpha, so the opacity of gradient color stops
+// is ignored. Porter-Duff composite operations other than source-over are
+// drawn as source-over, PutImageData draws over the page instead of
+// replacing its content, and ClearRect only has an effect if it covers
+// the whole canvas, in which case it discards the content of the current
+// page. Image data retrieved with GetImageData is transparent. Image
+// smoothing is left to the PDF reader. Shadows, the maximum width of text
+// and images loaded from URLs with Context.LoadImage are ignored.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	// The image formats are registered for decoding the images of
+	// CreateImageFromEncoded commands.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/fzipp/canvas/internal/csscolor"
+	"github.com/fzipp/canvas/internal/drawing"
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// Draw calls the draw function with a canvas.Context of the given size and
+// returns a PDF document with one page per non-empty frame that it
+// flushed. Commands that were not flushed are put on the last page. The
+// document has at least one page.
+func Draw(width, height int, draw func(ctx *canvas.Context)) []byte {
+	r := NewRenderer(width, height)
+	for _, cmds := range drawing.Frames(width, height, draw) {
+		r.Render(cmds...)
+		r.NewPage()
+	}
+	var buf bytes.Buffer
+	_, _ = r.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// Renderer converts draw commands into the pages of a PDF document.
+// It keeps the drawing state, the current path and the created images,
+// gradients and patterns across calls and pages, like a canvas in a web
+// browser keeps them across flushed frames. A Renderer must be created
+// with NewRenderer.
+type Renderer struct {
+	width  int
+	height int
+
+	// objects holds the fonts, images, patterns and graphics state
+	// parameters. The page objects are only added when the document is
+	// written.
+	objects objects
+	// pages holds the content streams of the completed pages, content
+	// the content stream of the current page.
+	pages   [][]byte
+	content bytes.Buffer
+
+	// The resources shared by all pages, as entries of the resource
+	// dictionaries.
+	fonts      []string
+	extGStates []string
+	patterns   []string
+	xObjects   []string
+	fontNames  map[string]string
+	stateNames map[extGState]string
+
+	state state
+	stack []state
+	path  geom.Path
+
+	images         map[uint32]*imageObj
+	gradients      map[uint32]*gradient
+	canvasPatterns map[uint32]*pattern
+}
+
+type state struct {
+	transform   geom.Matrix
+	fillStyle   style
+	strokeStyle style
+	lineWidth   float64
+	lineCap     canvas.LineCap
+	lineJoin    canvas.LineJoin
+	miterLimit  float64
+	lineDash    []float64
+	dashOffset  float64
+	globalAlpha float64
+	compositeOp canvas.CompositeOperation
+	font        font
+	textAlign   canvas.TextAlign
+	baseline    canvas.TextBaseline
+	// clip holds the clipping paths in device space. The clipping region
+	// is their intersection.
+	clip []*geom.Path
+}
+
+func defaultState() state {
+	black := style{color: color.RGBA{A: 0xff}}
+	return state{
+		transform:   geom.Identity,
+		fillStyle:   black,
+		strokeStyle: black,
+		lineWidth:   1,
+		miterLimit:  10,
+		globalAlpha: 1,
+		font:        defaultFont,
+	}
+}
+
+// A style is a fill or stroke style: a color, a gradient or a pattern.
+type style struct {
+	color    color.RGBA
+	gradient *gradient
+	pattern  *pattern
+}
+
+type colorStop struct {
+	offset float64
+	color  color.RGBA
+}
+
+type gradient struct {
+	radial bool
+	p0, p1 geom.Point
+	r0, r1 float64
+	stops  []colorStop
+}
+
+type pattern struct {
+	image      *imageObj
+	repetition canvas.PatternRepetition
+}
+
+// An imageObj is an image XObject. An image without pixels has no
+// XObject and an empty name.
+type imageObj struct {
+	name          string
+	obj           int
+	width, height int
+	// img is kept for patching the image with UpdateImageData commands.
+	img *image.NRGBA
+}
+
+// extGState is a set of graphics state parameters.
+type extGState struct {
+	alpha float64
+	blend string
+}
+
+// NewRenderer creates a Renderer for a PDF document with pages of the
+// given size.
+func NewRenderer(width, height int) *Renderer {
+	return &Renderer{
+		width:          max(width, 0),
+		height:         max(height, 0),
+		fontNames:      make(map[string]string),
+		stateNames:     make(map[extGState]string),
+		state:          defaultState(),
+		images:         make(map[uint32]*imageObj),
+		gradients:      make(map[uint32]*gradient),
+		canvasPatterns: make(map[uint32]*pattern),
+	}
+}
+
+// NewPage ends the current page and starts a new, empty page. The drawing
+// state, the current path, and the created images, gradients and patterns
+// are kept.
+func (r *Renderer) NewPage() {
+	r.pages = append(r.pages, bytes.Clone(r.content.Bytes()))
+	r.content.Reset()
+}
+
+// WriteTo writes the PDF document with the pages created so far to w.
+// The current page is included if anything was drawn on it, or if it is
+// the only page.
+func (r *Renderer) WriteTo(w io.Writer) (int64, error) {
+	pages := r.pages
+	if r.content.Len() > 0 || len(pages) == 0 {
+		pages = append(pages[:len(pages):len(pages)], r.content.Bytes())
+	}
+	objs := slices.Clone(r.objects)
+	res := objs.add(r.resourceDict())
+	tree := objs.add("")
+	// The content stream of each page starts with a transformation from
+	// the canvas coordinate system with the origin at the top left corner
+	// into the PDF coordinate system with the origin at the bottom left
+	// corner.
+	flip := "1 0 0 -1 0 " + strconv.Itoa(r.height) + " cm\n"
+	kids := make([]string, len(pages))
+	for i, content := range pages {
+		contents := objs.addStream("", append([]byte(flip), content...))
+		page := objs.add(fmt.Sprintf("<< /Type /Page /Parent %s /MediaBox [0 0 %d %d] /Resources %s /Contents %s >>",
+			ref(tree), r.width, r.height, ref(res), ref(contents)))
+		kids[i] = ref(page)
+	}
+	objs[tree-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	root := objs.add("<< /Type /Catalog /Pages " + ref(tree) + " >>")
+	return objs.writeTo(w, root)
+}
+
+func (r *Renderer) resourceDict() string {
+	var sb strings.Builder
+	sb.WriteString("<< /ProcSet [/PDF /Text /ImageB /ImageC]")
+	for _, res := range []struct {
+		name    string
+		entries []string
+	}{
+		{"Font", r.fonts},
+		{"ExtGState", r.extGStates},
+		{"Pattern", r.patterns},
+		{"XObject", r.xObjects},
+	} {
+		if len(res.entries) > 0 {
+			sb.WriteString(" /" + res.name + " << " + strings.Join(res.entries, " ") + " >>")
+		}
+	}
+	sb.WriteString(" >>")
+	return sb.String()
+}
+
+// addResource adds an object to a resource dictionary under a new name
+// with the given prefix, and returns the name.
+func addResource(entries *[]string, prefix string, obj int) string {
+	name := prefix + strconv.Itoa(len(*entries)+1)
+	*entries = append(*entries, "/"+name+" "+ref(obj))
+	return name
+}
+
+// RenderFrame decodes a frame in the binary draw command format, as sent
+// by canvas.Context.Flush, and renders its commands onto the current page.
+// If the frame cannot be decoded completely, the commands before the
+// error are rendered, and the error is returned.
+func (r *Renderer) RenderFrame(frame []byte) error {
+	cmds, err := command.Decode(frame)
+	r.Render(cmds...)
+	return err
+}
+
+// Render renders the given commands onto the current page.
+func (r *Renderer) Render(cmds ...command.Command) {
+	for _, cmd := range cmds {
+		r.render(cmd)
+	}
+}
+
+func (r *Renderer) render(cmd command.Command) {
+	s := &r.state
+	m := s.transform
+	switch c := cmd.(type) {
+	// Paths
+	case command.BeginPath:
+		r.path.Reset()
+	case command.ClosePath:
+		r.path.ClosePath()
+	case command.MoveTo:
+		r.path.MoveTo(m.Apply(geom.Pt(c.X, c.Y)))
+	case command.LineTo:
+		r.path.LineTo(m.Apply(geom.Pt(c.X, c.Y)))
+	case command.QuadraticCurveTo:
+		r.path.QuadraticCurveTo(m.Apply(geom.Pt(c.CPX, c.CPY)), m.Apply(geom.Pt(c.X, c.Y)))
+	case command.BezierCurveTo:
+		r.path.BezierCurveTo(m.Apply(geom.Pt(c.CP1X, c.CP1Y)), m.Apply(geom.Pt(c.CP2X, c.CP2Y)), m.Apply(geom.Pt(c.X, c.Y)))
+	case command.Arc:
+		if c.Radius >= 0 {
+			r.path.Ellipse(m, geom.Pt(c.X, c.Y), c.Radius, c.Radius, 0, c.StartAngle, c.EndAngle, c.Anticlockwise)
+		}
+	case command.ArcTo:
+		if c.Radius >= 0 {
+			r.path.ArcTo(m, geom.Pt(c.X1, c.Y1), geom.Pt(c.X2, c.Y2), c.Radius)
+		}
+	case command.Ellipse:
+		if c.RadiusX >= 0 && c.RadiusY >= 0 {
+			r.path.Ellipse(m, geom.Pt(c.X, c.Y), c.RadiusX, c.RadiusY, c.Rotation, c.StartAngle, c.EndAngle, c.Anticlockwise)
+		}
+	case command.Rect:
+		r.path.Rect(m, c.X, c.Y, c.Width, c.Height)
+	case command.Polyline:
+		r.path.Polyline(m, c.Points, false)
+	case command.Polygon:
+		r.path.Polyline(m, c.Points, true)
+	case command.Points:
+		if c.Radius >= 0 {
+			r.path.Circles(m, c.Points, c.Radius)
+		}
+
+	// Drawing
+	case command.Fill:
+		r.fill(&r.path)
+	case command.Stroke:
+		r.stroke(&r.path)
+	case command.Clip:
+		r.clip()
+	case command.FillRect:
+		r.fill(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+	case command.StrokeRect:
+		r.stroke(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+	case command.ClearRect:
+		r.clearRect(c.X, c.Y, c.Width, c.Height)
+	case command.FillText:
+		r.text(c.X, c.Y, c.Text, false)
+	case command.FillTextMaxWidth:
+		r.text(c.X, c.Y, c.Text, false)
+	case command.StrokeText:
+		r.text(c.X, c.Y, c.Text, true)
+	case command.StrokeTextMaxWidth:
+		r.text(c.X, c.Y, c.Text, true)
+
+	// Styles
+	case command.SetFillStyle:
+		s.fillStyle = style{color: c.Color}
+	case command.SetStrokeStyle:
+		s.strokeStyle = style{color: c.Color}
+	case command.SetFillStyleString:
+		if clr, ok := csscolor.Parse(c.Color); ok {
+			s.fillStyle = style{color: clr}
+		}
+	case command.SetStrokeStyleString:
+		if clr, ok := csscolor.Parse(c.Color); ok {
+			s.strokeStyle = style{color: clr}
+		}
+	case command.SetFillStyleGradient:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			s.fillStyle = style{gradient: g}
+		}
+	case command.SetStrokeStyleGradient:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			s.strokeStyle = style{gradient: g}
+		}
+	case command.SetFillStylePattern:
+		if p, ok := r.canvasPatterns[c.PatternID]; ok {
+			s.fillStyle = style{pattern: p}
+		}
+	case command.SetStrokeStylePattern:
+		if p, ok := r.canvasPatterns[c.PatternID]; ok {
+			s.strokeStyle = style{pattern: p}
+		}
+	case command.SetLineWidth:
+		if validPositive(c.Width) {
+			s.lineWidth = c.Width
+		}
+	case command.SetLineCap:
+		s.lineCap = c.Cap
+	case command.SetLineJoin:
+		s.lineJoin = c.Join
+	case command.SetMiterLimit:
+		if validPositive(c.Value) {
+			s.miterLimit = c.Value
+		}
+	case command.SetLineDash:
+		if dash, ok := normalizeDash(c.Segments); ok {
+			s.lineDash = dash
+		}
+	case command.SetLineDashOffset:
+		if !math.IsNaN(c.Offset) && !math.IsInf(c.Offset, 0) {
+			s.dashOffset = c.Offset
+		}
+	case command.SetGlobalAlpha:
+		if c.Alpha >= 0 && c.Alpha <= 1 {
+			s.globalAlpha = c.Alpha
+		}
+	case command.SetGlobalCompositeOperation:
+		s.compositeOp = c.Mode
+	case command.SetFont:
+		if f, ok := parseFont(c.Font); ok {
+			s.font = f
+		}
+	case command.SetTextAlign:
+		s.textAlign = c.Align
+	case command.SetTextBaseline:
+		s.baseline = c.Baseline
+
+	// State and transformations
+	case command.Save:
+		r.stack = append(r.stack, r.state)
+	case command.Restore:
+		if len(r.stack) > 0 {
+			r.state = r.stack[len(r.stack)-1]
+			r.stack = r.stack[:len(r.stack)-1]
+		}
+	case command.Scale:
+		s.transform = m.Multiply(geom.Matrix{A: c.X, D: c.Y})
+	case command.Rotate:
+		sin, cos := math.Sincos(c.Angle)
+		s.transform = m.Multiply(geom.Matrix{A: cos, B: sin, C: -sin, D: cos})
+	case command.Translate:
+		s.transform = m.Multiply(geom.Matrix{A: 1, D: 1, E: c.X, F: c.Y})
+	case command.Transform:
+		s.transform = m.Multiply(geom.Matrix{A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, F: c.F})
+	case command.SetTransform:
+		s.transform = geom.Matrix{A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, F: c.F}
+
+	// Gradients and patterns
+	case command.CreateLinearGradient:
+		r.gradients[c.ID] = &gradient{
+			p0: geom.Pt(c.X0, c.Y0),
+			p1: geom.Pt(c.X1, c.Y1),
+		}
+	case command.CreateRadialGradient:
+		if c.R0 >= 0 && c.R1 >= 0 {
+			r.gradients[c.ID] = &gradient{
+				radial: true,
+				p0:     geom.Pt(c.X0, c.Y0),
+				r0:     c.R0,
+				p1:     geom.Pt(c.X1, c.Y1),
+				r1:     c.R1,
+			}
+		}
+	case command.GradientAddColorStop:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			g.addColorStop(colorStop{offset: c.Offset, color: c.Color})
+		}
+	case command.GradientAddColorStopString:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			if clr, ok := csscolor.Parse(c.Color); ok {
+				g.addColorStop(colorStop{offset: c.Offset, color: clr})
+			}
+		}
+	case command.ReleaseGradient:
+		delete(r.gradients, c.GradientID)
+	case command.CreatePattern:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.canvasPatterns[c.ID] = &pattern{image: img, repetition: c.Repetition}
+		}
+	case command.ReleasePattern:
+		delete(r.canvasPatterns, c.PatternID)
+
+	// Images
+	case command.CreateImageData:
+		r.images[c.ID] = r.imageObject(&image.NRGBA{
+			Pix:    c.Pix,
+			Stride: 4 * c.Width,
+			Rect:   image.Rect(0, 0, c.Width, c.Height),
+		})
+	case command.CreateImageFromEncoded:
+		// Like a web browser, images in unsupported formats are not
+		// drawn.
+		if img, err := c.Image(); err == nil {
+			r.images[c.ID] = r.imageObject(img)
+		}
+	case command.GetImageData:
+		w := int(math.Ceil(math.Abs(c.SW)))
+		h := int(math.Ceil(math.Abs(c.SH)))
+		r.images[c.ID] = r.imageObject(image.NewNRGBA(image.Rect(0, 0, w, h)))
+	case command.UpdateImageData:
+		// The patched image is a new image object, the image drawn
+		// before keeps its content.
+		if obj, ok := r.images[c.ImageID]; ok {
+			r.images[c.ImageID] = r.imageObject(c.Patch(obj.img))
+		}
+	case command.ReleaseImageData:
+		delete(r.images, c.ImageID)
+	case command.PutImageData:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.putImageData(img, c.DX, c.DY, 0, 0, float64(img.width), float64(img.height))
+		}
+	case command.PutImageDataDirty:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.putImageData(img, c.DX, c.DY, c.DirtyX, c.DirtyY, c.DirtyWidth, c.DirtyHeight)
+		}
+	case command.DrawImage:
+		if img, ok := r.images[c.ImageID]; ok {
+			w, h := float64(img.width), float64(img.height)
+			r.drawImage(img, 0, 0, w, h, c.DX, c.DY, w, h)
+		}
+	case command.DrawImageScaled:
+		if img, ok := r.images[c.ImageID]; ok {
+			w, h := float64(img.width), float64(img.height)
+			r.drawImage(img, 0, 0, w, h, c.DX, c.DY, c.DWidth, c.DHeight)
+		}
+	case command.DrawImageSubRectangle:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.drawImage(img, c.SX, c.SY, c.SWidth, c.SHeight, c.DX, c.DY, c.DWidth, c.DHeight)
+		}
+	case command.DrawImageInstances:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.drawImageInstances(img, c.Instances)
+		}
+	}
+	// Image smoothing, shadows and the text input rectangle have no PDF
+	// equivalent.
+}
+
+func validPositive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 0)
+}
+
+func normalizeDash(segments []float64) ([]float64, bool) {
+	var total float64
+	for _, l := range segments {
+		if l < 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+			return nil, false
+		}
+		total += l
+	}
+	if total == 0 {
+		return nil, true
+	}
+	if len(segments)%2 == 1 {
+		return append(segments[:len(segments):len(segments)], segments...), true
+	}
+	return segments, true
+}
+
+func (g *gradient) addColorStop(stop colorStop) {
+	if stop.offset < 0 || stop.offset > 1 || math.IsNaN(stop.offset) {
+		return
+	}
+	// Stops with equal offsets keep their insertion order, like in
+	// canvas gradients.
+	i := len(g.stops)
+	for i > 0 && g.stops[i-1].offset > stop.offset {
+		i--
+	}
+	g.stops = append(g.stops, colorStop{})
+	copy(g.stops[i+1:], g.stops[i:])
+	g.stops[i] = stop
+}
+
+// paints reports whether the style paints anything. Gradients without
+// color stops and degenerate gradients are transparent, like patterns of
+// empty images.
+func (st style) paints() bool {
+	switch {
+	case st.gradient != nil:
+		g := st.gradient
+		return len(g.stops) > 0 && (g.p0 != g.p1 || g.radial && g.r0 != g.r1)
+	case st.pattern != nil:
+		return st.pattern.image.name != ""
+	}
+	return true
+}
+
+// alpha returns the opacity of a color style. The opacity of gradients and
+// patterns is not supported.
+func (st style) alpha() float64 {
+	if st.gradient != nil || st.pattern != nil {
+		return 1
+	}
+	return float64(st.color.A) / 255
+}
+
+func (r *Renderer) fill(p *geom.Path) {
+	r.paintPath(p, r.state.fillStyle, false)
+}
+
+func (r *Renderer) stroke(p *geom.Path) {
+	r.paintPath(p, r.state.strokeStyle, true)
+}
+
+// paintPath fills or strokes the path in the current user space, so that
+// line widths, gradients and patterns are in the same coordinate system as
+// on the canvas.
+func (r *Renderer) paintPath(p *geom.Path, st style, stroke bool) {
+	s := &r.state
+	if len(p.Segments) == 0 || !st.paints() {
+		return
+	}
+	inv, ok := s.transform.Invert()
+	if !ok {
+		return
+	}
+	r.begin(s.globalAlpha * st.alpha())
+	r.concat(s.transform)
+	r.setPaint(st, stroke)
+	op := "f"
+	if stroke {
+		r.lineState()
+		op = "S"
+	}
+	r.content.WriteString(pathData(p, inv))
+	r.content.WriteString(op + "\n")
+	r.end()
+}
+
+// begin starts a drawing operation with a graphics state that has the
+// clipping region, the given alpha and the blend mode of the current
+// state. It must be followed by a call of end.
+func (r *Renderer) begin(alpha float64) {
+	r.content.WriteString("q\n")
+	for _, p := range r.state.clip {
+		r.content.WriteString(pathData(p, geom.Identity))
+		r.content.WriteString("W n\n")
+	}
+	gs := extGState{alpha: alpha, blend: blendModes[r.state.compositeOp]}
+	if gs == (extGState{alpha: 1}) {
+		return
+	}
+	name, ok := r.stateNames[gs]
+	if !ok {
+		dict := "<< /Type /ExtGState /ca " + fmtNumber(gs.alpha) + " /CA " + fmtNumber(gs.alpha)
+		if gs.blend != "" {
+			dict += " /BM /" + gs.blend
+		}
+		name = addResource(&r.extGStates, "GS", r.objects.add(dict+" >>"))
+		r.stateNames[gs] = name
+	}
+	r.content.WriteString("/" + name + " gs\n")
+}
+
+func (r *Renderer) end() {
+	r.content.WriteString("Q\n")
+}
+
+var blendModes = map[canvas.CompositeOperation]string{
+	canvas.OpMultiply:   "Multiply",
+	canvas.OpScreen:     "Screen",
+	canvas.OpOverlay:    "Overlay",
+	canvas.OpDarken:     "Darken",
+	canvas.OpLighten:    "Lighten",
+	canvas.OpColorDodge: "ColorDodge",
+	canvas.OpColorBurn:  "ColorBurn",
+	canvas.OpHardLight:  "HardLight",
+	canvas.OpSoftLight:  "SoftLight",
+	canvas.OpDifference: "Difference",
+	canvas.OpExclusion:  "Exclusion",
+	canvas.OpHue:        "Hue",
+	canvas.OpSaturation: "Saturation",
+	canvas.OpColor:      "Color",
+	canvas.OpLuminosity: "Luminosity",
+}
+
+// concat transforms the user space of the content stream by m.
+func (r *Renderer) concat(m geom.Matrix) {
+	if m == geom.Identity {
+		return
+	}
+	r.content.WriteString(fmtMatrix(m) + " cm\n")
+}
+
+func fmtMatrix(m geom.Matrix) string {
+	return fmtNumbers(m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+// pageMatrix returns the transformation from the canvas coordinate system
+// into the default coordinate system of a page.
+func (r *Renderer) pageMatrix() geom.Matrix {
+	return geom.Matrix{A: 1, D: -1, F: float64(r.height)}
+}
+
+// setPaint sets the fill or stroke color for a style. Gradients and
+// patterns are written as new pattern objects on each use, since their
+// color stops and the current transformation may change between uses.
+func (r *Renderer) setPaint(st style, stroke bool) {
+	var name string
+	switch {
+	case st.gradient != nil:
+		name = r.shadingPattern(st.gradient)
+	case st.pattern != nil:
+		name = r.tilingPattern(st.pattern)
+	default:
+		op := "rg"
+		if stroke {
+			op = "RG"
+		}
+		r.content.WriteString(fmtRGB(st.color) + " " + op + "\n")
+		return
+	}
+	if stroke {
+		r.content.WriteString("/Pattern CS /" + name + " SCN\n")
+	} else {
+		r.content.WriteString("/Pattern cs /" + name + " scn\n")
+	}
+}
+
+func fmtRGB(c color.RGBA) string {
+	return fmtNumbers(float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+func (r *Renderer) shadingPattern(g *gradient) string {
+	shadingType := 2
+	coords := fmtNumbers(g.p0.X, g.p0.Y, g.p1.X, g.p1.Y)
+	if g.radial {
+		shadingType = 3
+		coords = fmtNumbers(g.p0.X, g.p0.Y, g.r0, g.p1.X, g.p1.Y, g.r1)
+	}
+	shading := fmt.Sprintf("<< /ShadingType %d /ColorSpace /DeviceRGB /Coords [%s] /Function %s /Extend [true true] >>",
+		shadingType, coords, colorFunction(g.stops))
+	m := r.pageMatrix().Multiply(r.state.transform)
+	obj := r.objects.add("<< /Type /Pattern /PatternType 2 /Shading " + shading + " /Matrix [" + fmtMatrix(m) + "] >>")
+	return addResource(&r.patterns, "P", obj)
+}
+
+// colorFunction returns a function that maps the gradient parameter in the
+// range [0, 1] to the colors of the color stops. It is a stitching
+// function of linear interpolations between each pair of adjacent stops.
+func colorFunction(stops []colorStop) string {
+	if first := stops[0]; first.offset > 0 {
+		stops = append([]colorStop{{offset: 0, color: first.color}}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.offset < 1 {
+		stops = append(stops[:len(stops):len(stops)], colorStop{offset: 1, color: last.color})
+	}
+	var funcs, bounds, encode []string
+	for i := 1; i < len(stops); i++ {
+		funcs = append(funcs, "<< /FunctionType 2 /Domain [0 1] /C0 ["+fmtRGB(stops[i-1].color)+"] /C1 ["+fmtRGB(stops[i].color)+"] /N 1 >>")
+		encode = append(encode, "0 1")
+		if i < len(stops)-1 {
+			bounds = append(bounds, fmtNumber(stops[i].offset))
+		}
+	}
+	if len(funcs) == 1 {
+		return funcs[0]
+	}
+	return "<< /FunctionType 3 /Domain [0 1] /Functions [" + strings.Join(funcs, " ") +
+		"] /Bounds [" + strings.Join(bounds, " ") + "] /Encode [" + strings.Join(encode, " ") + "] >>"
+}
+
+// tilingPattern writes a tiling pattern object that repeats the pattern
+// image. The tile is enlarged in the directions in which a canvas pattern
+// does not repeat.
+func (r *Renderer) tilingPattern(p *pattern) string {
+	const noRepeat = 1 << 20
+	img := p.image
+	w, h := float64(img.width), float64(img.height)
+	xStep, yStep := w, h
+	if p.repetition == canvas.PatternRepeatY || p.repetition == canvas.PatternNoRepeat {
+		xStep = noRepeat
+	}
+	if p.repetition == canvas.PatternRepeatX || p.repetition == canvas.PatternNoRepeat {
+		yStep = noRepeat
+	}
+	m := r.pageMatrix().Multiply(r.state.transform)
+	dict := fmt.Sprintf("/Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 %s] /XStep %s /YStep %s /Resources << /XObject << /%s %s >> >> /Matrix [%s]",
+		fmtNumbers(w, h), fmtNumber(xStep), fmtNumber(yStep), img.name, ref(img.obj), fmtMatrix(m))
+	content := "q " + fmtNumbers(w, 0, 0, -h, 0, h) + " cm /" + img.name + " Do Q"
+	return addResource(&r.patterns, "P", r.objects.addStream(dict, []byte(content)))
+}
+
+// imageObject writes an image XObject with the color components of the
+// image, and a soft mask with its alpha channel if it is not opaque.
+func (r *Renderer) imageObject(img *image.NRGBA) *imageObj {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	o := &imageObj{width: w, height: h, img: img}
+	if w == 0 || h == 0 {
+		return o
+	}
+	rgb := make([]byte, 0, 3*w*h)
+	alpha := make([]byte, 0, w*h)
+	opaque := true
+	for y := range h {
+		for x := range w {
+			i := img.PixOffset(x, y)
+			rgb = append(rgb, img.Pix[i:i+3]...)
+			alpha = append(alpha, img.Pix[i+3])
+			opaque = opaque && img.Pix[i+3] == 0xff
+		}
+	}
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /BitsPerComponent 8", w, h)
+	rgbDict := dict + " /ColorSpace /DeviceRGB"
+	if !opaque {
+		mask := r.objects.addStream(dict+" /ColorSpace /DeviceGray", alpha)
+		rgbDict += " /SMask " + ref(mask)
+	}
+	o.obj = r.objects.addStream(rgbDict, rgb)
+	o.name = addResource(&r.xObjects, "Im", o.obj)
+	return o
+}
+
+// drawImageInstances draws the image centered at the position of each
+// instance, with the transformation and global alpha of the current state
+// combined with those of the instance.
+func (r *Renderer) drawImageInstances(img *imageObj, instances []canvas.SpriteInstance) {
+	s := &r.state
+	transform, globalAlpha := s.transform, s.globalAlpha
+	defer func() {
+		s.transform, s.globalAlpha = transform, globalAlpha
+	}()
+	for _, in := range instances {
+		sp := geom.ResolveSprite(in, img.width, img.height)
+		s.transform = transform.Multiply(sp.Transform)
+		s.globalAlpha = globalAlpha * sp.Alpha
+		r.drawImage(img, sp.SX, sp.SY, sp.SW, sp.SH, -sp.SW/2, -sp.SH/2, sp.SW, sp.SH)
+	}
+}
+
+func (r *Renderer) drawImage(img *imageObj, sx, sy, sw, sh, dx, dy, dw, dh float64) {
+	s := &r.state
+	if img.name == "" || sw == 0 || sh == 0 || dw == 0 || dh == 0 {
+		return
+	}
+	if _, ok := s.transform.Invert(); !ok {
+		return
+	}
+	if sw < 0 {
+		sx, sw = sx+sw, -sw
+	}
+	if sh < 0 {
+		sy, sh = sy+sh, -sh
+	}
+	if dw < 0 {
+		dx, dw = dx+dw, -dw
+	}
+	if dh < 0 {
+		dy, dh = dy+dh, -dh
+	}
+	r.begin(s.globalAlpha)
+	r.concat(s.transform)
+	r.image(img, sx, sy, sw, sh, dx, dy, dw, dh)
+	r.end()
+}
+
+// image draws the source rectangle of the image into the destination
+// rectangle of the current user space.
+func (r *Renderer) image(img *imageObj, sx, sy, sw, sh, dx, dy, dw, dh float64) {
+	kx, ky := dw/sw, dh/sh
+	w, h := float64(img.width)*kx, float64(img.height)*ky
+	// An image is drawn into the unit square, with its first row at the
+	// top. The destination rectangle clips the parts of the image outside
+	// the source rectangle.
+	r.content.WriteString(fmtNumbers(dx, dy, dw, dh) + " re W n\n")
+	r.content.WriteString(fmtNumbers(w, 0, 0, -h, dx-sx*kx, dy-sy*ky+h) + " cm /" + img.name + " Do\n")
+}
+
+// putImageData draws the dirty rectangle of the image data at (dx, dy)
+// without transformation, global alpha, blending and clipping.
+func (r *Renderer) putImageData(img *imageObj, dx, dy, dirtyX, dirtyY, dirtyW, dirtyH float64) {
+	if img.name == "" {
+		return
+	}
+	if dirtyW < 0 {
+		dirtyX, dirtyW = dirtyX+dirtyW, -dirtyW
+	}
+	if dirtyH < 0 {
+		dirtyY, dirtyH = dirtyY+dirtyH, -dirtyH
+	}
+	x0 := math.Max(math.Floor(dirtyX), 0)
+	y0 := math.Max(math.Floor(dirtyY), 0)
+	x1 := math.Min(math.Ceil(dirtyX+dirtyW), float64(img.width))
+	y1 := math.Min(math.Ceil(dirtyY+dirtyH), float64(img.height))
+	if x1 <= x0 || y1 <= y0 {
+		return
+	}
+	dx, dy = math.Floor(dx), math.Floor(dy)
+	r.content.WriteString("q\n")
+	r.image(img, x0, y0, x1-x0, y1-y0, dx+x0, dy+y0, x1-x0, y1-y0)
+	r.end()
+}
+
+func (r *Renderer) text(x, y float64, text string, stroke bool) {
+	s := &r.state
+	st := s.fillStyle
+	if stroke {
+		st = s.strokeStyle
+	}
+	if !st.paints() {
+		return
+	}
+	if _, ok := s.transform.Invert(); !ok {
+		return
+	}
+	f := s.font
+	b := encode(text)
+	switch s.textAlign {
+	case canvas.AlignEnd, canvas.AlignRight:
+		x -= f.width(b)
+	case canvas.AlignCenter:
+		x -= f.width(b) / 2
+	}
+	switch s.baseline {
+	case canvas.BaselineTop, canvas.BaselineHanging:
+		y += f.ascent() * f.size / 1000
+	case canvas.BaselineMiddle:
+		y += (f.ascent() - f.descent()) / 2 * f.size / 1000
+	case canvas.BaselineBottom, canvas.BaselineIdeographic:
+		y -= f.descent() * f.size / 1000
+	}
+	r.begin(s.globalAlpha * st.alpha())
+	r.concat(s.transform)
+	r.setPaint(st, stroke)
+	mode := ""
+	if stroke {
+		r.lineState()
+		mode = "1 Tr "
+	}
+	r.content.WriteString("BT /" + r.fontResource(f) + " " + fmtNumber(f.size) + " Tf " + mode)
+	// The text matrix flips the glyphs, which would otherwise be upside
+	// down in the canvas coordinate system.
+	r.content.WriteString("1 0 0 -1 " + fmtNumbers(x, y) + " Tm " + fmtString(b) + " Tj ET\n")
+	r.end()
+}
+
+// fontResource returns the resource name of the standard font, and adds
+// it to the resources on first use.
+func (r *Renderer) fontResource(f font) string {
+	base := f.baseFont()
+	name, ok := r.fontNames[base]
+	if !ok {
+		obj := r.objects.add("<< /Type /Font /Subtype /Type1 /BaseFont /" + base + " /Encoding /WinAnsiEncoding >>")
+		name = addResource(&r.fonts, "F", obj)
+		r.fontNames[base] = name
+	}
+	return name
+}
+
+// lineState sets the line width, cap, join, miter limit and dash pattern.
+// The line cap and line join styles of the canvas have the same numbers
+// as in PDF.
+func (r *Renderer) lineState() {
+	s := &r.state
+	fmt.Fprintf(&r.content, "%s w %d J %d j %s M", fmtNumber(s.lineWidth), s.lineCap, s.lineJoin, fmtNumber(s.miterLimit))
+	if s.lineDash != nil {
+		fmt.Fprintf(&r.content, " [%s] %s d", fmtNumbers(s.lineDash...), fmtNumber(s.dashOffset))
+	}
+	r.content.WriteString("\n")
+}
+
+func (r *Renderer) clip() {
+	s := &r.state
+	p := r.path
+	if len(p.Segments) == 0 {
+		// Clipping to an empty path makes the clipping region empty.
+		p = *geom.RectPath(geom.Identity, 0, 0, 0, 0)
+	}
+	s.clip = append(s.clip[:len(s.clip):len(s.clip)], &p)
+}
+
+// clearRect discards the content of the current page drawn so far if the
+// rectangle covers the whole canvas. Clearing parts of the canvas cannot
+// be expressed in PDF.
+func (r *Renderer) clearRect(x, y, w, h float64) {
+	s := &r.state
+	if s.clip != nil {
+		return
+	}
+	inv, ok := s.transform.Invert()
+	if !ok {
+		return
+	}
+	corners := []geom.Point{
+		geom.Pt(0, 0),
+		geom.Pt(float64(r.width), 0),
+		geom.Pt(0, float64(r.height)),
+		geom.Pt(float64(r.width), float64(r.height)),
+	}
+	// A transformed rectangle covers the canvas if it contains
+	// all corners of the canvas.
+	x0, x1 := math.Min(x, x+w), math.Max(x, x+w)
+	y0, y1 := math.Min(y, y+h), math.Max(y, y+h)
+	for _, c := range corners {
+		p := inv.Apply(c)
+		if p.X < x0-1e-9 || p.X > x1+1e-9 || p.Y < y0-1e-9 || p.Y > y1+1e-9 {
+			return
+		}
+	}
+	r.content.Reset()
+}
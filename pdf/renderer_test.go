@@ -0,0 +1,573 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name        string
+		draw        func(ctx *canvas.Context)
+		wantContent []string
+		wantObjects []string
+	}{
+		{
+			"fill rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(color.RGBA{R: 0xff, G: 0x80, A: 0x80})
+				ctx.FillRect(10, 10, 20, 10)
+			},
+			[]string{
+				"q",
+				"/GS1 gs",
+				"1 0.502 0 rg",
+				"10 10 m", "30 10 l", "30 20 l", "10 20 l", "h",
+				"f",
+				"Q",
+			},
+			[]string{
+				"<< /Type /ExtGState /ca 0.502 /CA 0.502 >>",
+			},
+		},
+		{
+			"path",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.MoveTo(0, 0)
+				ctx.LineTo(10, 0)
+				ctx.QuadraticCurveTo(20, 0, 20, 10)
+				ctx.BezierCurveTo(20, 20, 10, 30, 0, 30)
+				ctx.ClosePath()
+				ctx.LineTo(5, 5)
+				ctx.SetFillStyleString("steelblue")
+				ctx.Fill()
+			},
+			[]string{
+				"q",
+				"0.2745 0.5098 0.7059 rg",
+				"0 0 m",
+				"10 0 l",
+				"16.6667 0 20 3.3333 20 10 c",
+				"20 20 10 30 0 30 c",
+				"h",
+				"0 0 m",
+				"5 5 l",
+				"f",
+				"Q",
+			},
+			nil,
+		},
+		{
+			"stroke with transformation",
+			func(ctx *canvas.Context) {
+				ctx.Translate(10, 20)
+				ctx.Scale(2, 2)
+				ctx.BeginPath()
+				ctx.MoveTo(0, 0)
+				ctx.LineTo(5, 5)
+				ctx.SetLineWidth(3)
+				ctx.SetLineCap(canvas.CapRound)
+				ctx.SetLineJoin(canvas.JoinBevel)
+				ctx.SetLineDash([]float64{2, 1, 3})
+				ctx.SetLineDashOffset(1)
+				ctx.SetStrokeStyle(color.RGBA{B: 0xff, A: 0xff})
+				ctx.Stroke()
+			},
+			[]string{
+				"q",
+				"2 0 0 2 10 20 cm",
+				"0 0 1 RG",
+				"3 w 1 J 2 j 10 M [2 1 3 2 1 3] 1 d",
+				"0 0 m",
+				"5 5 l",
+				"S",
+				"Q",
+			},
+			nil,
+		},
+		{
+			"clip with save and restore",
+			func(ctx *canvas.Context) {
+				ctx.Save()
+				ctx.BeginPath()
+				ctx.Rect(0, 0, 50, 50)
+				ctx.Clip()
+				ctx.FillRect(10, 10, 100, 100)
+				ctx.Restore()
+				ctx.FillRect(1, 1, 1, 1)
+			},
+			[]string{
+				"q",
+				"0 0 m", "50 0 l", "50 50 l", "0 50 l", "h",
+				"W n",
+				"0 0 0 rg",
+				"10 10 m", "110 10 l", "110 110 l", "10 110 l", "h",
+				"f",
+				"Q",
+				"q",
+				"0 0 0 rg",
+				"1 1 m", "2 1 l", "2 2 l", "1 2 l", "h",
+				"f",
+				"Q",
+			},
+			nil,
+		},
+		{
+			"linear gradient",
+			func(ctx *canvas.Context) {
+				g := ctx.CreateLinearGradient(0, 0, 100, 0)
+				g.AddColorStop(0, color.RGBA{R: 0xff, A: 0xff})
+				g.AddColorStop(1, color.RGBA{B: 0xff, A: 0xff})
+				ctx.SetFillStyleGradient(g)
+				ctx.Translate(0, 10)
+				ctx.FillRect(0, 0, 100, 10)
+			},
+			[]string{
+				"q",
+				"1 0 0 1 0 10 cm",
+				"/Pattern cs /P1 scn",
+				"0 0 m", "100 0 l", "100 10 l", "0 10 l", "h",
+				"f",
+				"Q",
+			},
+			[]string{
+				"<< /Type /Pattern /PatternType 2 /Shading << /ShadingType 2 /ColorSpace /DeviceRGB /Coords [0 0 100 0] /Function << /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [0 0 1] /N 1 >> /Extend [true true] >> /Matrix [1 0 0 -1 0 90] >>",
+			},
+		},
+		{
+			"radial gradient",
+			func(ctx *canvas.Context) {
+				g := ctx.CreateRadialGradient(50, 50, 0, 50, 50, 40)
+				g.AddColorStopString(0.5, "white")
+				g.AddColorStop(0.25, color.RGBA{R: 0xff, A: 0xff})
+				g.AddColorStop(1, color.RGBA{A: 0xff})
+				ctx.SetStrokeStyleGradient(g)
+				ctx.StrokeRect(10, 10, 80, 80)
+			},
+			[]string{
+				"q",
+				"/Pattern CS /P1 SCN",
+				"1 w 0 J 0 j 10 M",
+				"10 10 m", "90 10 l", "90 90 l", "10 90 l", "h",
+				"S",
+				"Q",
+			},
+			[]string{
+				"<< /Type /Pattern /PatternType 2 /Shading << /ShadingType 3 /ColorSpace /DeviceRGB /Coords [50 50 0 50 50 40] /Function << /FunctionType 3 /Domain [0 1] /Functions [" +
+					"<< /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [1 0 0] /N 1 >> " +
+					"<< /FunctionType 2 /Domain [0 1] /C0 [1 0 0] /C1 [1 1 1] /N 1 >> " +
+					"<< /FunctionType 2 /Domain [0 1] /C0 [1 1 1] /C1 [0 0 0] /N 1 >>" +
+					"] /Bounds [0.25 0.5] /Encode [0 1 0 1 0 1] >> /Extend [true true] >> /Matrix [1 0 0 -1 0 100] >>",
+			},
+		},
+		{
+			"gradient without color stops",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyleGradient(ctx.CreateLinearGradient(0, 0, 100, 0))
+				ctx.FillRect(0, 0, 100, 10)
+			},
+			nil,
+			nil,
+		},
+		{
+			"pattern",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(checkerboard())
+				ctx.SetFillStylePattern(ctx.CreatePattern(img, canvas.PatternRepeatX))
+				ctx.FillRect(0, 0, 10, 10)
+			},
+			[]string{
+				"q",
+				"/Pattern cs /P1 scn",
+				"0 0 m", "10 0 l", "10 10 l", "0 10 l", "h",
+				"f",
+				"Q",
+			},
+			[]string{
+				"<< /Type /XObject /Subtype /Image /Width 2 /Height 2 /BitsPerComponent 8 /ColorSpace /DeviceRGB >>\n" +
+					"\xff\x00\x00\x00\x00\xff\x00\x00\xff\xff\x00\x00",
+				"<< /Type /Pattern /PatternType 1 /PaintType 1 /TilingType 1 /BBox [0 0 2 2] /XStep 2 /YStep 1048576 /Resources << /XObject << /Im1 1 0 R >> >> /Matrix [1 0 0 -1 0 100] >>\n" +
+					"q 2 0 0 -2 0 2 cm /Im1 Do Q",
+			},
+		},
+		{
+			"draw image",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(translucent())
+				ctx.SetGlobalAlpha(0.5)
+				ctx.DrawImage(img, 5, 5)
+				ctx.DrawImageSubRectangle(img, 1, 0, 1, 1, 20, 20, 10, 10)
+			},
+			[]string{
+				"q",
+				"/GS1 gs",
+				"5 5 2 1 re W n",
+				"2 0 0 -1 5 6 cm /Im1 Do",
+				"Q",
+				"q",
+				"/GS1 gs",
+				"20 20 10 10 re W n",
+				"20 0 0 -10 10 30 cm /Im1 Do",
+				"Q",
+			},
+			[]string{
+				"<< /Type /XObject /Subtype /Image /Width 2 /Height 1 /BitsPerComponent 8 /ColorSpace /DeviceGray >>\n" +
+					"\xff\x80",
+				"<< /Type /XObject /Subtype /Image /Width 2 /Height 1 /BitsPerComponent 8 /ColorSpace /DeviceRGB /SMask 1 0 R >>\n" +
+					"\x10\x20\x30\x40\x50\x60",
+				"<< /Type /ExtGState /ca 0.5 /CA 0.5 >>",
+			},
+		},
+		{
+			"put image data",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(checkerboard())
+				ctx.Scale(5, 5)
+				ctx.PutImageDataDirty(img, 10.5, 20, 1, 0, 5, 5)
+			},
+			[]string{
+				"q",
+				"11 20 1 2 re W n",
+				"2 0 0 -2 10 22 cm /Im1 Do",
+				"Q",
+			},
+			nil,
+		},
+		{
+			"fill text",
+			func(ctx *canvas.Context) {
+				ctx.SetFont("bold 20px Georgia, serif")
+				ctx.SetTextAlign(canvas.AlignCenter)
+				ctx.SetTextBaseline(canvas.BaselineTop)
+				ctx.FillText("Hi", 50, 10)
+			},
+			[]string{
+				"q",
+				"0 0 0 rg",
+				"BT /F1 20 Tf 1 0 0 -1 39.44 23.66 Tm (Hi) Tj ET",
+				"Q",
+			},
+			[]string{
+				"<< /Type /Font /Subtype /Type1 /BaseFont /Times-Bold /Encoding /WinAnsiEncoding >>",
+			},
+		},
+		{
+			"stroke text",
+			func(ctx *canvas.Context) {
+				ctx.SetStrokeStyle(color.RGBA{R: 0xff, A: 0xff})
+				ctx.StrokeText(`(a)\€✓`, 10, 20)
+			},
+			[]string{
+				"q",
+				"1 0 0 RG",
+				"1 w 0 J 0 j 10 M",
+				"BT /F1 10 Tf 1 Tr 1 0 0 -1 10 20 Tm (\\(a\\)\\\\\x80?) Tj ET",
+				"Q",
+			},
+			[]string{
+				"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+			},
+		},
+		{
+			"blend mode",
+			func(ctx *canvas.Context) {
+				ctx.SetGlobalCompositeOperation(canvas.OpMultiply)
+				ctx.FillRect(0, 0, 1, 1)
+				ctx.SetGlobalCompositeOperation(canvas.OpDestinationOut)
+				ctx.FillRect(0, 0, 1, 1)
+			},
+			[]string{
+				"q",
+				"/GS1 gs",
+				"0 0 0 rg",
+				"0 0 m", "1 0 l", "1 1 l", "0 1 l", "h",
+				"f",
+				"Q",
+				"q",
+				"0 0 0 rg",
+				"0 0 m", "1 0 l", "1 1 l", "0 1 l", "h",
+				"f",
+				"Q",
+			},
+			[]string{
+				"<< /Type /ExtGState /ca 1 /CA 1 /BM /Multiply >>",
+			},
+		},
+		{
+			"clear whole canvas",
+			func(ctx *canvas.Context) {
+				ctx.FillRect(0, 0, 10, 10)
+				ctx.ClearRect(0, 0, 100, 100)
+				ctx.FillRect(1, 1, 1, 1)
+				ctx.ClearRect(0, 0, 50, 50)
+			},
+			[]string{
+				"q",
+				"0 0 0 rg",
+				"1 1 m", "2 1 l", "2 2 l", "1 2 l", "h",
+				"f",
+				"Q",
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, pages := parse(t, Draw(100, 100, tt.draw))
+			if len(pages) != 1 {
+				t.Fatalf("got %d pages, want: 1", len(pages))
+			}
+			if diff := cmp.Diff(tt.wantContent, pages[0]); diff != "" {
+				t.Errorf("content mismatch (-want, +got)\n%s", diff)
+			}
+			for _, want := range tt.wantObjects {
+				if !slices.Contains(objs, want) {
+					t.Errorf("missing object %q in:\n%q", want, objs)
+				}
+			}
+		})
+	}
+}
+
+func TestDrawPages(t *testing.T) {
+	tests := []struct {
+		name      string
+		draw      func(ctx *canvas.Context)
+		wantPages [][]string
+	}{
+		{
+			"empty",
+			func(ctx *canvas.Context) {},
+			[][]string{nil},
+		},
+		{
+			"one page per flush",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+				ctx.FillRect(0, 0, 1, 1)
+				ctx.Flush()
+				ctx.Flush()
+				ctx.FillRect(2, 2, 1, 1)
+				ctx.Flush()
+				ctx.FillRect(3, 3, 1, 1)
+			},
+			[][]string{
+				{"q", "1 0 0 rg", "0 0 m", "1 0 l", "1 1 l", "0 1 l", "h", "f", "Q"},
+				{"q", "1 0 0 rg", "2 2 m", "3 2 l", "3 3 l", "2 3 l", "h", "f", "Q"},
+				{"q", "1 0 0 rg", "3 3 m", "4 3 l", "4 4 l", "3 4 l", "h", "f", "Q"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, pages := parse(t, Draw(100, 100, tt.draw))
+			if diff := cmp.Diff(tt.wantPages, pages); diff != "" {
+				t.Errorf("mismatch (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRendererNewPage(t *testing.T) {
+	r := NewRenderer(100, 100)
+	r.Render(command.MoveTo{X: 1, Y: 2}, command.LineTo{X: 3, Y: 4})
+	r.NewPage()
+	r.NewPage()
+	r.Render(command.Stroke{})
+	r.NewPage()
+	var buf bytes.Buffer
+	_, _ = r.WriteTo(&buf)
+	_, pages := parse(t, buf.Bytes())
+	want := [][]string{
+		nil,
+		nil,
+		{"q", "0 0 0 RG", "1 w 0 J 0 j 10 M", "1 2 m", "3 4 l", "S", "Q"},
+	}
+	if diff := cmp.Diff(want, pages); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestRendererWriteTo(t *testing.T) {
+	r := NewRenderer(640, 480)
+	r.Render(command.FillRect{X: 1, Y: 2, Width: 3, Height: 4})
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("did not expect error, but got error: %s", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("got n: %d, want: %d", n, buf.Len())
+	}
+	objs, _ := parse(t, buf.Bytes())
+	want := []string{
+		"<< /ProcSet [/PDF /Text /ImageB /ImageC] >>",
+		"<< /Type /Pages /Kids [4 0 R] /Count 1 >>",
+		"<< >>\n1 0 0 -1 0 480 cm\nq\n0 0 0 rg\n1 2 m\n4 2 l\n4 6 l\n1 6 l\nh\nf\nQ\n",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 640 480] /Resources 1 0 R /Contents 3 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+	}
+	if diff := cmp.Diff(want, objs); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+	// Writing the document does not change the renderer.
+	var buf2 bytes.Buffer
+	_, _ = r.WriteTo(&buf2)
+	if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+		t.Errorf("second document differs from first document")
+	}
+}
+
+func TestRendererRenderFrameError(t *testing.T) {
+	r := NewRenderer(10, 10)
+	frame := command.Encode([]command.Command{command.FillRect{X: 1, Y: 2, Width: 3, Height: 4}})
+	frame = append(frame, 0x00)
+	var decodeErr *command.DecodeError
+	if err := r.RenderFrame(frame); !errors.As(err, &decodeErr) {
+		t.Errorf("expected %T error, but got: %#v", decodeErr, err)
+	}
+	var buf bytes.Buffer
+	_, _ = r.WriteTo(&buf)
+	_, pages := parse(t, buf.Bytes())
+	if !slices.Contains(pages[0], "4 6 l") {
+		t.Errorf("commands before error were not rendered:\n%q", pages[0])
+	}
+}
+
+var (
+	startXRefPattern = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+	streamPattern    = regexp.MustCompile(`^(<<.*?) ?/Filter /FlateDecode /Length (\d+) >>\nstream\n`)
+	refPattern       = regexp.MustCompile(`(\d+) 0 R`)
+)
+
+// parse checks the structure of a PDF document as written by a Renderer,
+// and returns its objects and the lines of the content streams of its
+// pages. Stream objects are returned as their dictionary without the
+// filter and length entries, followed by a newline and the decompressed
+// stream data. The transformation at the start of each content stream is
+// not included in the page lines.
+func parse(t *testing.T, doc []byte) (objs []string, pages [][]string) {
+	t.Helper()
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header:\n%q", doc)
+	}
+	m := startXRefPattern.FindSubmatch(doc)
+	if m == nil {
+		t.Fatalf("missing startxref:\n%q", doc)
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	var n int
+	if _, err := fmt.Sscanf(string(doc[xref:]), "xref\n0 %d\n", &n); err != nil {
+		t.Fatalf("invalid xref table: %v", err)
+	}
+	entries := doc[bytes.IndexByte(doc[xref+5:], '\n')+xref+6:]
+	for i := 1; i < n; i++ {
+		entry := string(entries[20*i : 20*i+20])
+		offset, _ := strconv.Atoi(entry[:10])
+		head := fmt.Sprintf("%d 0 obj\n", i)
+		if !bytes.HasPrefix(doc[offset:], []byte(head)) {
+			t.Fatalf("xref entry %d does not point to object: %q", i, entry)
+		}
+		body := doc[offset+len(head):]
+		body = body[:bytes.Index(body, []byte("\nendobj\n"))]
+		objs = append(objs, parseObject(t, body))
+	}
+	trailer := string(entries[20*n:])
+	root := refs(t, trailer, "/Root")[0]
+	tree := refs(t, objs[root-1], "/Pages")[0]
+	for _, page := range refs(t, objs[tree-1], "/Kids") {
+		contents := refs(t, objs[page-1], "/Contents")[0]
+		_, data, _ := strings.Cut(objs[contents-1], "\n")
+		lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+		if !strings.HasPrefix(lines[0], "1 0 0 -1 0 ") {
+			t.Fatalf("content stream does not start with transformation:\n%s", data)
+		}
+		var content []string
+		if len(lines) > 1 {
+			content = lines[1:]
+		}
+		pages = append(pages, content)
+	}
+	return objs, pages
+}
+
+func parseObject(t *testing.T, body []byte) string {
+	t.Helper()
+	m := streamPattern.FindSubmatch(body)
+	if m == nil {
+		return string(body)
+	}
+	length, _ := strconv.Atoi(string(m[2]))
+	data := body[len(m[0]):]
+	if want := length + len("\nendstream"); len(data) != want {
+		t.Fatalf("stream length %d does not match data:\n%q", length, body)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[:length]))
+	if err != nil {
+		t.Fatalf("invalid stream data: %v", err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("invalid stream data: %v", err)
+	}
+	return strings.TrimSpace(string(m[1])) + " >>\n" + string(decompressed)
+}
+
+// refs returns the object numbers referenced by the value of the
+// dictionary entry with the given key.
+func refs(t *testing.T, dict, key string) []int {
+	t.Helper()
+	_, value, ok := strings.Cut(dict, key+" ")
+	if !ok {
+		t.Fatalf("missing %s in %q", key, dict)
+	}
+	if strings.HasPrefix(value, "[") {
+		value = value[:strings.Index(value, "]")]
+	} else {
+		value = value[:strings.Index(value, " R")+2]
+	}
+	var nums []int
+	for _, m := range refPattern.FindAllStringSubmatch(value, -1) {
+		num, _ := strconv.Atoi(m[1])
+		nums = append(nums, num)
+	}
+	return nums
+}
+
+func checkerboard() *image.RGBA {
+	return &image.RGBA{
+		Pix: []byte{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff,
+			0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff,
+		},
+		Stride: 8,
+		Rect:   image.Rect(0, 0, 2, 2),
+	}
+}
+
+func translucent() *image.RGBA {
+	return &image.RGBA{
+		Pix:    []byte{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80},
+		Stride: 8,
+		Rect:   image.Rect(0, 0, 2, 1),
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package raster
+
+import (
+	"math"
+
+	"github.com/fzipp/canvas"
+)
+
+// composite combines a source color s with a destination color d
+// according to a composite operation, as specified by the W3C
+// Compositing and Blending Level 1 specification.
+func composite(op canvas.CompositeOperation, s, d rgba) rgba {
+	switch op {
+	case canvas.OpSourceOver:
+		return porterDuff(s, d, 1, 1-s.a)
+	case canvas.OpSourceIn:
+		return porterDuff(s, d, d.a, 0)
+	case canvas.OpSourceOut:
+		return porterDuff(s, d, 1-d.a, 0)
+	case canvas.OpSourceAtop:
+		return porterDuff(s, d, d.a, 1-s.a)
+	case canvas.OpDestinationOver:
+		return porterDuff(s, d, 1-d.a, 1)
+	case canvas.OpDestinationIn:
+		return porterDuff(s, d, 0, s.a)
+	case canvas.OpDestinationOut:
+		return porterDuff(s, d, 0, 1-s.a)
+	case canvas.OpDestinationAtop:
+		return porterDuff(s, d, 1-d.a, s.a)
+	case canvas.OpLighter:
+		return rgba{
+			r: math.Min(s.r+d.r, 1),
+			g: math.Min(s.g+d.g, 1),
+			b: math.Min(s.b+d.b, 1),
+			a: math.Min(s.a+d.a, 1),
+		}
+	case canvas.OpCopy:
+		return s
+	case canvas.OpXOR:
+		return porterDuff(s, d, 1-d.a, 1-s.a)
+	}
+	return blend(op, s, d)
+}
+
+func porterDuff(s, d rgba, fs, fd float64) rgba {
+	return rgba{
+		r: s.r*fs + d.r*fd,
+		g: s.g*fs + d.g*fd,
+		b: s.b*fs + d.b*fd,
+		a: s.a*fs + d.a*fd,
+	}
+}
+
+// bounded reports whether a composite operation leaves the destination
+// unchanged where the source is transparent. Operations that are not
+// bounded affect the whole canvas, not just the area of a shape.
+func bounded(op canvas.CompositeOperation) bool {
+	switch op {
+	case canvas.OpSourceIn, canvas.OpSourceOut, canvas.OpDestinationIn,
+		canvas.OpDestinationAtop, canvas.OpCopy:
+		return false
+	}
+	return true
+}
+
+type rgb struct {
+	r, g, b float64
+}
+
+// blend applies a blend mode followed by source-over compositing.
+func blend(op canvas.CompositeOperation, s, d rgba) rgba {
+	if s.a == 0 {
+		return d
+	}
+	if d.a == 0 {
+		return s
+	}
+	cs := rgb{s.r / s.a, s.g / s.a, s.b / s.a}
+	cb := rgb{d.r / d.a, d.g / d.a, d.b / d.a}
+	var b rgb
+	switch op {
+	case canvas.OpHue:
+		b = setLum(setSat(cs, sat(cb)), lum(cb))
+	case canvas.OpSaturation:
+		b = setLum(setSat(cb, sat(cs)), lum(cb))
+	case canvas.OpColor:
+		b = setLum(cs, lum(cb))
+	case canvas.OpLuminosity:
+		b = setLum(cb, lum(cs))
+	default:
+		f := separableBlendFunc(op)
+		b = rgb{f(cb.r, cs.r), f(cb.g, cs.g), f(cb.b, cs.b)}
+	}
+	both := s.a * d.a
+	return rgba{
+		r: s.r*(1-d.a) + d.r*(1-s.a) + both*b.r,
+		g: s.g*(1-d.a) + d.g*(1-s.a) + both*b.g,
+		b: s.b*(1-d.a) + d.b*(1-s.a) + both*b.b,
+		a: s.a + d.a*(1-s.a),
+	}
+}
+
+// separableBlendFunc returns the blend function B(cb, cs) of a separable
+// blend mode, where cb is the backdrop and cs is the source color
+// component.
+func separableBlendFunc(op canvas.CompositeOperation) func(cb, cs float64) float64 {
+	switch op {
+	case canvas.OpMultiply:
+		return multiply
+	case canvas.OpScreen:
+		return screen
+	case canvas.OpOverlay:
+		return func(cb, cs float64) float64 { return hardLight(cs, cb) }
+	case canvas.OpDarken:
+		return math.Min
+	case canvas.OpLighten:
+		return math.Max
+	case canvas.OpColorDodge:
+		return colorDodge
+	case canvas.OpColorBurn:
+		return colorBurn
+	case canvas.OpHardLight:
+		return hardLight
+	case canvas.OpSoftLight:
+		return softLight
+	case canvas.OpDifference:
+		return func(cb, cs float64) float64 { return math.Abs(cb - cs) }
+	case canvas.OpExclusion:
+		return func(cb, cs float64) float64 { return cb + cs - 2*cb*cs }
+	}
+	// Unknown operations are ignored by the canvas API, so this
+	// is never reached with a valid command stream.
+	return func(cb, cs float64) float64 { return cs }
+}
+
+func multiply(cb, cs float64) float64 {
+	return cb * cs
+}
+
+func screen(cb, cs float64) float64 {
+	return cb + cs - cb*cs
+}
+
+func hardLight(cb, cs float64) float64 {
+	if cs <= 0.5 {
+		return multiply(cb, 2*cs)
+	}
+	return screen(cb, 2*cs-1)
+}
+
+func colorDodge(cb, cs float64) float64 {
+	switch {
+	case cb == 0:
+		return 0
+	case cs >= 1:
+		return 1
+	}
+	return math.Min(1, cb/(1-cs))
+}
+
+func colorBurn(cb, cs float64) float64 {
+	switch {
+	case cb >= 1:
+		return 1
+	case cs == 0:
+		return 0
+	}
+	return 1 - math.Min(1, (1-cb)/cs)
+}
+
+func softLight(cb, cs float64) float64 {
+	if cs <= 0.5 {
+		return cb - (1-2*cs)*cb*(1-cb)
+	}
+	var d float64
+	if cb <= 0.25 {
+		d = ((16*cb-12)*cb + 4) * cb
+	} else {
+		d = math.Sqrt(cb)
+	}
+	return cb + (2*cs-1)*(d-cb)
+}
+
+func lum(c rgb) float64 {
+	return 0.3*c.r + 0.59*c.g + 0.11*c.b
+}
+
+func setLum(c rgb, l float64) rgb {
+	d := l - lum(c)
+	return clipColor(rgb{c.r + d, c.g + d, c.b + d})
+}
+
+func clipColor(c rgb) rgb {
+	l := lum(c)
+	n := math.Min(c.r, math.Min(c.g, c.b))
+	x := math.Max(c.r, math.Max(c.g, c.b))
+	if n < 0 {
+		c = rgb{
+			l + (c.r-l)*l/(l-n),
+			l + (c.g-l)*l/(l-n),
+			l + (c.b-l)*l/(l-n),
+		}
+	}
+	if x > 1 {
+		c = rgb{
+			l + (c.r-l)*(1-l)/(x-l),
+			l + (c.g-l)*(1-l)/(x-l),
+			l + (c.b-l)*(1-l)/(x-l),
+		}
+	}
+	return c
+}
+
+func sat(c rgb) float64 {
+	return math.Max(c.r, math.Max(c.g, c.b)) - math.Min(c.r, math.Min(c.g, c.b))
+}
+
+func setSat(c rgb, s float64) rgb {
+	components := [3]*float64{&c.r, &c.g, &c.b}
+	// Sort the pointers to the components by value: min, mid, max.
+	if *components[0] > *components[1] {
+		components[0], components[1] = components[1], components[0]
+	}
+	if *components[1] > *components[2] {
+		components[1], components[2] = components[2], components[1]
+	}
+	if *components[0] > *components[1] {
+		components[0], components[1] = components[1], components[0]
+	}
+	cMin, cMid, cMax := components[0], components[1], components[2]
+	if *cMax > *cMin {
+		*cMid = (*cMid - *cMin) * s / (*cMax - *cMin)
+		*cMax = s
+	} else {
+		*cMid, *cMax = 0, 0
+	}
+	*cMin = 0
+	return c
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// rgba is a color with premultiplied alpha and components between 0 and 1.
+type rgba struct {
+	r, g, b, a float64
+}
+
+// premultiplied converts a color with non-premultiplied alpha, as it is
+// sent for fill, stroke and gradient stop colors, to an rgba value.
+func premultiplied(c color.RGBA) rgba {
+	a := float64(c.A) / 255
+	return rgba{
+		r: float64(c.R) / 255 * a,
+		g: float64(c.G) / 255 * a,
+		b: float64(c.B) / 255 * a,
+		a: a,
+	}
+}
+
+func (c rgba) scale(s float64) rgba {
+	return rgba{c.r * s, c.g * s, c.b * s, c.a * s}
+}
+
+// A style is a fill or stroke style: a color, a gradient or a pattern.
+type style struct {
+	color    rgba
+	gradient *gradient
+	pattern  *pattern
+}
+
+// source returns a function that returns the color of the style at a
+// point in user space. The smoothing flag selects bilinear instead of
+// nearest neighbor sampling for patterns.
+func (s style) source(smoothing bool) func(p geom.Point) rgba {
+	switch {
+	case s.gradient != nil:
+		return s.gradient.at
+	case s.pattern != nil:
+		return func(p geom.Point) rgba { return s.pattern.at(p, smoothing) }
+	default:
+		c := s.color
+		return func(geom.Point) rgba { return c }
+	}
+}
+
+type colorStop struct {
+	offset float64
+	color  color.RGBA
+}
+
+type gradient struct {
+	radial bool
+	p0, p1 geom.Point
+	r0, r1 float64
+	stops  []colorStop
+}
+
+func (g *gradient) addColorStop(offset float64, c color.RGBA) {
+	if offset < 0 || offset > 1 || math.IsNaN(offset) {
+		return
+	}
+	g.stops = append(g.stops, colorStop{offset: offset, color: c})
+	sort.SliceStable(g.stops, func(i, j int) bool {
+		return g.stops[i].offset < g.stops[j].offset
+	})
+}
+
+func (g *gradient) at(p geom.Point) rgba {
+	if len(g.stops) == 0 {
+		return rgba{}
+	}
+	var t float64
+	if g.radial {
+		var ok bool
+		t, ok = g.radialParam(p)
+		if !ok {
+			return rgba{}
+		}
+	} else {
+		d := g.p1.Sub(g.p0)
+		l := d.Dot(d)
+		if l == 0 {
+			return rgba{}
+		}
+		t = p.Sub(g.p0).Dot(d) / l
+	}
+	return g.colorAt(t)
+}
+
+// radialParam returns the largest ω for which p lies on the circle
+// interpolated between the start and end circle with a non-negative
+// radius, as specified for canvas radial gradients.
+func (g *gradient) radialParam(p geom.Point) (float64, bool) {
+	if g.p0 == g.p1 && g.r0 == g.r1 {
+		return 0, false
+	}
+	cd := g.p1.Sub(g.p0)
+	pd := p.Sub(g.p0)
+	dr := g.r1 - g.r0
+	a := cd.Dot(cd) - dr*dr
+	b := pd.Dot(cd) + g.r0*dr
+	c := pd.Dot(pd) - g.r0*g.r0
+	valid := func(w float64) bool { return g.r0+w*dr >= 0 }
+	if math.Abs(a) < 1e-12 {
+		if b == 0 {
+			return 0, false
+		}
+		w := c / (2 * b)
+		return w, valid(w)
+	}
+	disc := b*b - a*c
+	if disc < 0 {
+		return 0, false
+	}
+	sq := math.Sqrt(disc)
+	w1 := (b + sq) / a
+	w2 := (b - sq) / a
+	if w1 < w2 {
+		w1, w2 = w2, w1
+	}
+	if valid(w1) {
+		return w1, true
+	}
+	return w2, valid(w2)
+}
+
+func (g *gradient) colorAt(t float64) rgba {
+	stops := g.stops
+	if t <= stops[0].offset {
+		return premultiplied(stops[0].color)
+	}
+	last := stops[len(stops)-1]
+	if t >= last.offset {
+		return premultiplied(last.color)
+	}
+	i := sort.Search(len(stops), func(i int) bool { return stops[i].offset > t })
+	s0, s1 := stops[i-1], stops[i]
+	f := (t - s0.offset) / (s1.offset - s0.offset)
+	lerp := func(a, b uint8) float64 {
+		return (float64(a) + (float64(b)-float64(a))*f) / 255
+	}
+	a := lerp(s0.color.A, s1.color.A)
+	return rgba{
+		r: lerp(s0.color.R, s1.color.R) * a,
+		g: lerp(s0.color.G, s1.color.G) * a,
+		b: lerp(s0.color.B, s1.color.B) * a,
+		a: a,
+	}
+}
+
+type pattern struct {
+	img        *image.NRGBA
+	repetition canvas.PatternRepetition
+}
+
+func (p *pattern) at(q geom.Point, smoothing bool) rgba {
+	w, h := float64(p.img.Rect.Dx()), float64(p.img.Rect.Dy())
+	if w == 0 || h == 0 {
+		return rgba{}
+	}
+	repeatX := p.repetition == canvas.PatternRepeat || p.repetition == canvas.PatternRepeatX
+	repeatY := p.repetition == canvas.PatternRepeat || p.repetition == canvas.PatternRepeatY
+	if (!repeatX && (q.X < 0 || q.X >= w)) || (!repeatY && (q.Y < 0 || q.Y >= h)) {
+		return rgba{}
+	}
+	return sample(p.img, image.Rect(0, 0, p.img.Rect.Dx(), p.img.Rect.Dy()), q, smoothing, repeatX, repeatY)
+}
+
+// sample returns the color of an image at the point q in image space.
+// Only the pixels within the rectangle r are used. Coordinates outside
+// of r are wrapped around if the corresponding repeat flag is set,
+// otherwise they are clamped to the edge of r.
+func sample(img *image.NRGBA, r image.Rectangle, q geom.Point, smoothing, repeatX, repeatY bool) rgba {
+	if r.Empty() {
+		return rgba{}
+	}
+	if !smoothing {
+		x := wrapOrClamp(int(math.Floor(q.X)), r.Min.X, r.Max.X, repeatX)
+		y := wrapOrClamp(int(math.Floor(q.Y)), r.Min.Y, r.Max.Y, repeatY)
+		return pixel(img, x, y)
+	}
+	fx, fy := q.X-0.5, q.Y-0.5
+	x0, y0 := math.Floor(fx), math.Floor(fy)
+	tx, ty := fx-x0, fy-y0
+	xa := wrapOrClamp(int(x0), r.Min.X, r.Max.X, repeatX)
+	xb := wrapOrClamp(int(x0)+1, r.Min.X, r.Max.X, repeatX)
+	ya := wrapOrClamp(int(y0), r.Min.Y, r.Max.Y, repeatY)
+	yb := wrapOrClamp(int(y0)+1, r.Min.Y, r.Max.Y, repeatY)
+	c00, c10 := pixel(img, xa, ya), pixel(img, xb, ya)
+	c01, c11 := pixel(img, xa, yb), pixel(img, xb, yb)
+	lerp := func(a, b rgba, t float64) rgba {
+		return rgba{
+			r: a.r + (b.r-a.r)*t,
+			g: a.g + (b.g-a.g)*t,
+			b: a.b + (b.b-a.b)*t,
+			a: a.a + (b.a-a.a)*t,
+		}
+	}
+	return lerp(lerp(c00, c10, tx), lerp(c01, c11, tx), ty)
+}
+
+func wrapOrClamp(i, lo, hi int, wrap bool) int {
+	if wrap {
+		n := hi - lo
+		i = (i - lo) % n
+		if i < 0 {
+			i += n
+		}
+		return lo + i
+	}
+	return min(max(i, lo), hi-1)
+}
+
+func pixel(img *image.NRGBA, x, y int) rgba {
+	i := img.PixOffset(x, y)
+	s := img.Pix[i : i+4 : i+4]
+	return premultiplied(color.RGBA{R: s[0], G: s[1], B: s[2], A: s[3]})
+}
+
+// toByte converts a color component in the range [0, 1] to a byte.
+func toByte(v float64) uint8 {
+	return uint8(math.Round(math.Min(math.Max(v, 0), 1) * 255))
+}
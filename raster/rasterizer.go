@@ -0,0 +1,235 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package raster
+
+import (
+	"math"
+
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// A rasterizer computes the anti-aliased coverage of polygons with the
+// nonzero winding rule, using signed area accumulation: each edge adds
+// the signed area it covers to the cells of a row, and the running sum
+// over a row gives the winding number weighted by pixel coverage.
+type rasterizer struct {
+	width, height int
+	// acc has a stride of width+1, so that edges to the right of the
+	// canvas have a cell to accumulate into.
+	acc        []float32
+	minY, maxY int
+}
+
+func newRasterizer(width, height int) *rasterizer {
+	return &rasterizer{
+		width:  width,
+		height: height,
+		acc:    make([]float32, (width+1)*height),
+		minY:   height,
+		maxY:   0,
+	}
+}
+
+// polygon adds a closed polygon in device space.
+func (z *rasterizer) polygon(poly []geom.Point) {
+	for i, p := range poly {
+		z.line(p, poly[(i+1)%len(poly)])
+	}
+}
+
+// line adds an edge. The edge is clipped to the canvas first, so that the
+// work per edge is bounded by the size of the canvas, even for huge
+// coordinates: the parts above and below the canvas do not cover any
+// pixels, and the parts to the left and right of the canvas are moved onto
+// its left and right border, where they cover the rows in the same way.
+// Edges with NaN or infinite coordinates are ignored.
+func (z *rasterizer) line(a, b geom.Point) {
+	if !isFinite(a) || !isFinite(b) {
+		return
+	}
+	dir := float32(1)
+	if a.Y > b.Y {
+		dir = -1
+		a, b = b, a
+	}
+	height := float64(z.height)
+	if b.Y <= 0 || a.Y >= height {
+		return
+	}
+	if a.Y < 0 {
+		a = a.Lerp(b, (0-a.Y)/(b.Y-a.Y))
+		a.Y = 0
+	}
+	if b.Y > height {
+		b = a.Lerp(b, (height-a.Y)/(b.Y-a.Y))
+		b.Y = height
+	}
+	// Split the edge where it crosses the left and right border.
+	width := float64(z.width)
+	ts := [4]float64{0}
+	n := 1
+	for _, x := range [2]float64{0, width} {
+		if t := (x - a.X) / (b.X - a.X); t > 0 && t < 1 {
+			ts[n] = t
+			n++
+		}
+	}
+	if n == 3 && ts[1] > ts[2] {
+		ts[1], ts[2] = ts[2], ts[1]
+	}
+	ts[n] = 1
+	p := a
+	for i := 1; i <= n; i++ {
+		q := b
+		if ts[i] < 1 {
+			q = a.Lerp(b, ts[i])
+		}
+		z.edge(
+			geom.Pt(min(max(p.X, 0), width), p.Y),
+			geom.Pt(min(max(q.X, 0), width), q.Y),
+			dir,
+		)
+		p = q
+	}
+}
+
+func isFinite(p geom.Point) bool {
+	return !math.IsNaN(p.X) && !math.IsNaN(p.Y) && !math.IsInf(p.X, 0) && !math.IsInf(p.Y, 0)
+}
+
+// edge adds an edge within the canvas from a to b, with a.y <= b.y. The
+// direction dir is -1 if the original edge pointed upward. The clipped
+// coordinates of an edge are not finite if the original coordinates are
+// too large to interpolate between them.
+func (z *rasterizer) edge(a, b geom.Point, dir float32) {
+	if !isFinite(a) || !isFinite(b) {
+		return
+	}
+	ay, by := float32(a.Y), float32(b.Y)
+	if by-ay <= 0.000001 {
+		return
+	}
+	ax, bx := float32(a.X), float32(b.X)
+	dxdy := (bx - ax) / (by - ay)
+
+	x := ax
+	y := float32(math.Floor(float64(ay)))
+	yMax := float32(math.Ceil(float64(by)))
+	yMax = min(yMax, float32(z.height))
+	width := z.width
+	stride := width + 1
+
+	if y < yMax {
+		z.minY = min(z.minY, int(y))
+		z.maxY = max(z.maxY, int(yMax))
+	}
+	for ; y < yMax; y++ {
+		dy := min(y+1, by) - max(y, ay)
+		xNext := x + dy*dxdy
+		row := z.acc[int(y)*stride : int(y+1)*stride]
+		d := dy * dir
+		x0, x1 := x, xNext
+		if x > xNext {
+			x0, x1 = x1, x0
+		}
+		x0i := int(math.Floor(float64(x0)))
+		x0Floor := float32(x0i)
+		x1i := int(math.Ceil(float64(x1)))
+		x1Floor := float32(x1i)
+
+		if x1i <= x0i+1 {
+			xmf := 0.5*(x+xNext) - x0Floor
+			row[clamp(x0i, width)] += d - d*xmf
+			row[clamp(x0i+1, width)] += d * xmf
+		} else {
+			s := 1 / (x1 - x0)
+			x0f := x0 - x0Floor
+			oneMinusX0f := 1 - x0f
+			a0 := 0.5 * s * oneMinusX0f * oneMinusX0f
+			x1f := x1 - x1Floor + 1
+			am := 0.5 * s * x1f * x1f
+
+			row[clamp(x0i, width)] += d * a0
+			if x1i == x0i+2 {
+				row[clamp(x0i+1, width)] += d * (1 - a0 - am)
+			} else {
+				a1 := s * (1.5 - x0f)
+				row[clamp(x0i+1, width)] += d * (a1 - a0)
+				dTimesS := d * s
+				for xi := x0i + 2; xi < x1i-1; xi++ {
+					row[clamp(xi, width)] += dTimesS
+				}
+				a2 := a1 + s*float32(x1i-x0i-3)
+				row[clamp(x1i-1, width)] += d * (1 - a2 - am)
+			}
+			row[clamp(x1i, width)] += d * am
+		}
+		x = xNext
+	}
+}
+
+func clamp(i, n int) int {
+	return min(max(i, 0), n)
+}
+
+// mask accumulates the coverage of the polygons added so far into a new
+// mask and resets the rasterizer.
+func (z *rasterizer) mask() *mask {
+	m := &mask{
+		width:  z.width,
+		height: z.height,
+		alpha:  make([]float32, z.width*z.height),
+		minY:   z.minY,
+		maxY:   z.maxY,
+	}
+	stride := z.width + 1
+	for y := z.minY; y < z.maxY; y++ {
+		row := z.acc[y*stride : (y+1)*stride]
+		out := m.alpha[y*z.width : (y+1)*z.width]
+		var acc float32
+		for x := range out {
+			acc += row[x]
+			a := acc
+			if a < 0 {
+				a = -a
+			}
+			out[x] = min(a, 1)
+		}
+		clear(row)
+	}
+	z.minY, z.maxY = z.height, 0
+	return m
+}
+
+// A mask holds a coverage value between 0 and 1 for each pixel. Rows
+// outside of [minY, maxY) are fully transparent.
+type mask struct {
+	width, height int
+	alpha         []float32
+	minY, maxY    int
+}
+
+func fillMask(width, height int, polys [][]geom.Point) *mask {
+	z := newRasterizer(width, height)
+	for _, poly := range polys {
+		z.polygon(poly)
+	}
+	return z.mask()
+}
+
+// intersect returns the pixelwise product of two masks.
+func (m *mask) intersect(other *mask) *mask {
+	out := &mask{
+		width:  m.width,
+		height: m.height,
+		alpha:  make([]float32, len(m.alpha)),
+		minY:   max(m.minY, other.minY),
+		maxY:   min(m.maxY, other.maxY),
+	}
+	for i := out.minY * m.width; i < out.maxY*m.width; i++ {
+		out.alpha[i] = m.alpha[i] * other.alpha[i]
+	}
+	return out
+}
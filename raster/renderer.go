@@ -0,0 +1,581 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package raster renders the draw commands of a canvas.Context into an
+// *image.RGBA, without a client connection or a web browser, for example
+// to create thumbnails on a server or to test drawing code:
+//
+//	img := raster.Draw(300, 150, func(ctx *canvas.Context) {
+//		ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+//		ctx.FillRect(10, 10, 50, 20)
+//	})
+//
+// The image has one pixel per canvas pixel and starts out transparent.
+// Like the backing store of a canvas in a web browser, it holds
+// premultiplied colors that every draw command modifies in place, so all
+// composite operations and blend modes are computed exactly, including
+// the ones that clear the canvas outside of the drawn shape. Paths are
+// flattened to polygons, filled with the nonzero winding rule, and
+// anti-aliased by the area they cover of each pixel. Colors given as
+// strings are parsed as CSS colors.
+//
+// There is no font rasterizer and no blur filter: text commands and
+// shadow settings are ignored. Images loaded from URLs with
+// Context.LoadImage are not drawn.
+package raster
+
+import (
+	"image"
+	"image/color"
+	// The image formats are registered for decoding the images of
+	// CreateImageFromEncoded commands.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/fzipp/canvas/internal/csscolor"
+	"github.com/fzipp/canvas/internal/drawing"
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// Draw calls the draw function with a canvas.Context of the given size and
+// returns an image of what it drew, whether it was flushed or not.
+func Draw(width, height int, draw func(ctx *canvas.Context)) *image.RGBA {
+	r := NewRenderer(width, height)
+	for _, cmds := range drawing.Frames(width, height, draw) {
+		r.Render(cmds...)
+	}
+	return r.Image()
+}
+
+// Renderer renders draw commands onto an image. It keeps the drawing
+// state, the current path and the created images, gradients and patterns
+// across calls, like a canvas in a web browser keeps them across flushed
+// frames. A Renderer must be created with NewRenderer.
+type Renderer struct {
+	img    *image.RGBA
+	width  int
+	height int
+
+	state state
+	stack []state
+	path  geom.Path
+
+	images    map[uint32]*image.NRGBA
+	gradients map[uint32]*gradient
+	patterns  map[uint32]*pattern
+}
+
+type state struct {
+	transform   geom.Matrix
+	fillStyle   style
+	strokeStyle style
+	lineWidth   float64
+	lineCap     canvas.LineCap
+	lineJoin    canvas.LineJoin
+	miterLimit  float64
+	lineDash    []float64
+	dashOffset  float64
+	globalAlpha float64
+	compositeOp canvas.CompositeOperation
+	smoothing   bool
+	// clip is nil if there is no clipping region. Clip masks are never
+	// modified after creation, so they can be shared between states.
+	clip *mask
+}
+
+func defaultState() state {
+	black := rgba{a: 1}
+	return state{
+		transform:   geom.Identity,
+		fillStyle:   style{color: black},
+		strokeStyle: style{color: black},
+		lineWidth:   1,
+		miterLimit:  10,
+		globalAlpha: 1,
+		smoothing:   true,
+	}
+}
+
+// NewRenderer creates a Renderer for a transparent canvas of the given
+// size.
+func NewRenderer(width, height int) *Renderer {
+	width, height = max(width, 0), max(height, 0)
+	return &Renderer{
+		img:       image.NewRGBA(image.Rect(0, 0, width, height)),
+		width:     width,
+		height:    height,
+		state:     defaultState(),
+		images:    make(map[uint32]*image.NRGBA),
+		gradients: make(map[uint32]*gradient),
+		patterns:  make(map[uint32]*pattern),
+	}
+}
+
+// Image returns the image that the Renderer renders onto. The image is
+// updated by subsequent calls of Render and RenderFrame.
+func (r *Renderer) Image() *image.RGBA {
+	return r.img
+}
+
+// RenderFrame decodes a frame in the binary draw command format, as sent
+// by canvas.Context.Flush, and renders its commands. If the frame cannot
+// be decoded completely, the commands before the error are rendered, and
+// the error is returned.
+func (r *Renderer) RenderFrame(frame []byte) error {
+	cmds, err := command.Decode(frame)
+	r.Render(cmds...)
+	return err
+}
+
+// Render renders the given commands.
+func (r *Renderer) Render(cmds ...command.Command) {
+	for _, cmd := range cmds {
+		r.render(cmd)
+	}
+}
+
+func (r *Renderer) render(cmd command.Command) {
+	s := &r.state
+	m := s.transform
+	switch c := cmd.(type) {
+	// Paths
+	case command.BeginPath:
+		r.path.Reset()
+	case command.ClosePath:
+		r.path.ClosePath()
+	case command.MoveTo:
+		r.path.MoveTo(m.Apply(geom.Pt(c.X, c.Y)))
+	case command.LineTo:
+		r.path.LineTo(m.Apply(geom.Pt(c.X, c.Y)))
+	case command.QuadraticCurveTo:
+		r.path.QuadraticCurveTo(m.Apply(geom.Pt(c.CPX, c.CPY)), m.Apply(geom.Pt(c.X, c.Y)))
+	case command.BezierCurveTo:
+		r.path.BezierCurveTo(m.Apply(geom.Pt(c.CP1X, c.CP1Y)), m.Apply(geom.Pt(c.CP2X, c.CP2Y)), m.Apply(geom.Pt(c.X, c.Y)))
+	case command.Arc:
+		if c.Radius >= 0 {
+			r.path.Ellipse(m, geom.Pt(c.X, c.Y), c.Radius, c.Radius, 0, c.StartAngle, c.EndAngle, c.Anticlockwise)
+		}
+	case command.ArcTo:
+		if c.Radius >= 0 {
+			r.path.ArcTo(m, geom.Pt(c.X1, c.Y1), geom.Pt(c.X2, c.Y2), c.Radius)
+		}
+	case command.Ellipse:
+		if c.RadiusX >= 0 && c.RadiusY >= 0 {
+			r.path.Ellipse(m, geom.Pt(c.X, c.Y), c.RadiusX, c.RadiusY, c.Rotation, c.StartAngle, c.EndAngle, c.Anticlockwise)
+		}
+	case command.Rect:
+		r.path.Rect(m, c.X, c.Y, c.Width, c.Height)
+	case command.Polyline:
+		r.path.Polyline(m, c.Points, false)
+	case command.Polygon:
+		r.path.Polyline(m, c.Points, true)
+	case command.Points:
+		if c.Radius >= 0 {
+			r.path.Circles(m, c.Points, c.Radius)
+		}
+
+	// Drawing
+	case command.Fill:
+		r.fill(&r.path)
+	case command.Stroke:
+		r.stroke(&r.path)
+	case command.Clip:
+		clip := fillMask(r.width, r.height, polygons(&r.path))
+		if s.clip != nil {
+			clip = clip.intersect(s.clip)
+		}
+		s.clip = clip
+	case command.FillRect:
+		r.fill(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+	case command.StrokeRect:
+		r.stroke(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+	case command.ClearRect:
+		r.clear(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+
+	// Styles
+	case command.SetFillStyle:
+		s.fillStyle = style{color: premultiplied(c.Color)}
+	case command.SetStrokeStyle:
+		s.strokeStyle = style{color: premultiplied(c.Color)}
+	case command.SetFillStyleString:
+		if clr, ok := csscolor.Parse(c.Color); ok {
+			s.fillStyle = style{color: premultiplied(clr)}
+		}
+	case command.SetStrokeStyleString:
+		if clr, ok := csscolor.Parse(c.Color); ok {
+			s.strokeStyle = style{color: premultiplied(clr)}
+		}
+	case command.SetFillStyleGradient:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			s.fillStyle = style{gradient: g}
+		}
+	case command.SetStrokeStyleGradient:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			s.strokeStyle = style{gradient: g}
+		}
+	case command.SetFillStylePattern:
+		if p, ok := r.patterns[c.PatternID]; ok {
+			s.fillStyle = style{pattern: p}
+		}
+	case command.SetStrokeStylePattern:
+		if p, ok := r.patterns[c.PatternID]; ok {
+			s.strokeStyle = style{pattern: p}
+		}
+	case command.SetLineWidth:
+		if validPositive(c.Width) {
+			s.lineWidth = c.Width
+		}
+	case command.SetLineCap:
+		s.lineCap = c.Cap
+	case command.SetLineJoin:
+		s.lineJoin = c.Join
+	case command.SetMiterLimit:
+		if validPositive(c.Value) {
+			s.miterLimit = c.Value
+		}
+	case command.SetLineDash:
+		if dash, ok := normalizeDash(c.Segments); ok {
+			s.lineDash = dash
+		}
+	case command.SetLineDashOffset:
+		if !math.IsNaN(c.Offset) && !math.IsInf(c.Offset, 0) {
+			s.dashOffset = c.Offset
+		}
+	case command.SetGlobalAlpha:
+		if c.Alpha >= 0 && c.Alpha <= 1 {
+			s.globalAlpha = c.Alpha
+		}
+	case command.SetGlobalCompositeOperation:
+		s.compositeOp = c.Mode
+	case command.SetImageSmoothingEnabled:
+		s.smoothing = c.Enabled
+
+	// State and transformations
+	case command.Save:
+		r.stack = append(r.stack, r.state)
+	case command.Restore:
+		if len(r.stack) > 0 {
+			r.state = r.stack[len(r.stack)-1]
+			r.stack = r.stack[:len(r.stack)-1]
+		}
+	case command.Scale:
+		s.transform = m.Multiply(geom.Matrix{A: c.X, D: c.Y})
+	case command.Rotate:
+		sin, cos := math.Sincos(c.Angle)
+		s.transform = m.Multiply(geom.Matrix{A: cos, B: sin, C: -sin, D: cos})
+	case command.Translate:
+		s.transform = m.Multiply(geom.Matrix{A: 1, D: 1, E: c.X, F: c.Y})
+	case command.Transform:
+		s.transform = m.Multiply(geom.Matrix{A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, F: c.F})
+	case command.SetTransform:
+		s.transform = geom.Matrix{A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, F: c.F}
+
+	// Gradients and patterns
+	case command.CreateLinearGradient:
+		r.gradients[c.ID] = &gradient{
+			p0: geom.Pt(c.X0, c.Y0),
+			p1: geom.Pt(c.X1, c.Y1),
+		}
+	case command.CreateRadialGradient:
+		if c.R0 >= 0 && c.R1 >= 0 {
+			r.gradients[c.ID] = &gradient{
+				radial: true,
+				p0:     geom.Pt(c.X0, c.Y0),
+				r0:     c.R0,
+				p1:     geom.Pt(c.X1, c.Y1),
+				r1:     c.R1,
+			}
+		}
+	case command.GradientAddColorStop:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			g.addColorStop(c.Offset, c.Color)
+		}
+	case command.GradientAddColorStopString:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			if clr, ok := csscolor.Parse(c.Color); ok {
+				g.addColorStop(c.Offset, clr)
+			}
+		}
+	case command.ReleaseGradient:
+		delete(r.gradients, c.GradientID)
+	case command.CreatePattern:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.patterns[c.ID] = &pattern{img: img, repetition: c.Repetition}
+		}
+	case command.ReleasePattern:
+		delete(r.patterns, c.PatternID)
+
+	// Images
+	case command.CreateImageData:
+		r.images[c.ID] = &image.NRGBA{
+			Pix:    c.Pix,
+			Stride: 4 * c.Width,
+			Rect:   image.Rect(0, 0, c.Width, c.Height),
+		}
+	case command.UpdateImageData:
+		// The image is patched as a copy, since its pixels may be shared
+		// with the frame of the command that created it.
+		if img, ok := r.images[c.ImageID]; ok {
+			r.images[c.ImageID] = c.Patch(img)
+		}
+	case command.ReleaseImageData:
+		delete(r.images, c.ImageID)
+	case command.CreateImageFromEncoded:
+		// Like a web browser, images in unsupported formats are not
+		// drawn.
+		if img, err := c.Image(); err == nil {
+			r.images[c.ID] = img
+		}
+	case command.GetImageData:
+		r.images[c.ID] = r.getImageData(c.SX, c.SY, c.SW, c.SH)
+	case command.PutImageData:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.putImageData(img, c.DX, c.DY, 0, 0, float64(img.Rect.Dx()), float64(img.Rect.Dy()))
+		}
+	case command.PutImageDataDirty:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.putImageData(img, c.DX, c.DY, c.DirtyX, c.DirtyY, c.DirtyWidth, c.DirtyHeight)
+		}
+	case command.DrawImage:
+		if img, ok := r.images[c.ImageID]; ok {
+			w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+			r.drawImage(img, 0, 0, w, h, c.DX, c.DY, w, h)
+		}
+	case command.DrawImageScaled:
+		if img, ok := r.images[c.ImageID]; ok {
+			w, h := float64(img.Rect.Dx()), float64(img.Rect.Dy())
+			r.drawImage(img, 0, 0, w, h, c.DX, c.DY, c.DWidth, c.DHeight)
+		}
+	case command.DrawImageSubRectangle:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.drawImage(img, c.SX, c.SY, c.SWidth, c.SHeight, c.DX, c.DY, c.DWidth, c.DHeight)
+		}
+	case command.DrawImageInstances:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.drawImageInstances(img, c.Instances)
+		}
+	}
+	// Text, shadows and the text input rectangle are not rendered.
+}
+
+func validPositive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 0)
+}
+
+// polygons returns the subpaths of the path, flattened into polygons for
+// filling.
+func polygons(p *geom.Path) [][]geom.Point {
+	var polys [][]geom.Point
+	for _, pl := range p.Flatten(tolerance) {
+		if len(pl.Points) > 2 {
+			polys = append(polys, pl.Points)
+		}
+	}
+	return polys
+}
+
+func (r *Renderer) fill(p *geom.Path) {
+	r.paint(fillMask(r.width, r.height, polygons(p)), r.state.fillStyle.source(r.state.smoothing))
+}
+
+func (r *Renderer) stroke(p *geom.Path) {
+	s := &r.state
+	inv, ok := s.transform.Invert()
+	if !ok {
+		return
+	}
+	st := &stroker{
+		halfWidth:  s.lineWidth / 2,
+		cap:        s.lineCap,
+		join:       s.lineJoin,
+		miterLimit: s.miterLimit,
+		scale:      s.transform.Scale(),
+	}
+	for _, pl := range p.Flatten(tolerance) {
+		points := make([]geom.Point, len(pl.Points))
+		for i, q := range pl.Points {
+			points[i] = inv.Apply(q)
+		}
+		if s.lineDash == nil || tooManyDashes(points, pl.Closed, s.lineDash) {
+			st.stroke(points, pl.Closed)
+			continue
+		}
+		for _, d := range dash(points, pl.Closed, s.lineDash, s.dashOffset) {
+			st.stroke(d, false)
+		}
+	}
+	for _, poly := range st.polys {
+		for i, q := range poly {
+			poly[i] = s.transform.Apply(q)
+		}
+	}
+	r.paint(fillMask(r.width, r.height, st.polys), s.strokeStyle.source(s.smoothing))
+}
+
+// paint composites the source, given in user space, onto the image,
+// with the coverage of the shape mask, the global alpha, the composite
+// operation and the clipping region of the current state.
+func (r *Renderer) paint(shape *mask, source func(p geom.Point) rgba) {
+	s := &r.state
+	inv, ok := s.transform.Invert()
+	if !ok {
+		return
+	}
+	minY, maxY := shape.minY, shape.maxY
+	unbounded := !bounded(s.compositeOp)
+	if unbounded {
+		minY, maxY = 0, r.height
+	}
+	for y := minY; y < maxY; y++ {
+		for x := 0; x < r.width; x++ {
+			i := y*r.width + x
+			coverage := float64(shape.alpha[i])
+			if coverage == 0 && !unbounded {
+				continue
+			}
+			clip := 1.0
+			if s.clip != nil {
+				clip = float64(s.clip.alpha[i])
+				if clip == 0 {
+					continue
+				}
+			}
+			var src rgba
+			if coverage > 0 {
+				src = source(inv.Apply(geom.Pt(float64(x)+0.5, float64(y)+0.5)))
+				src = src.scale(coverage * s.globalAlpha)
+			}
+			dst := r.at(x, y)
+			out := composite(s.compositeOp, src, dst)
+			if clip < 1 {
+				out = rgba{
+					r: dst.r + (out.r-dst.r)*clip,
+					g: dst.g + (out.g-dst.g)*clip,
+					b: dst.b + (out.b-dst.b)*clip,
+					a: dst.a + (out.a-dst.a)*clip,
+				}
+			}
+			r.set(x, y, out)
+		}
+	}
+}
+
+// clear clears the pixels covered by the shape to transparent black,
+// regardless of the global alpha and composite operation.
+func (r *Renderer) clear(p *geom.Path) {
+	shape := fillMask(r.width, r.height, polygons(p))
+	if r.state.clip != nil {
+		shape = shape.intersect(r.state.clip)
+	}
+	for y := shape.minY; y < shape.maxY; y++ {
+		for x := 0; x < r.width; x++ {
+			if coverage := float64(shape.alpha[y*r.width+x]); coverage > 0 {
+				r.set(x, y, r.at(x, y).scale(1-coverage))
+			}
+		}
+	}
+}
+
+// drawImageInstances draws the image centered at the position of each
+// instance, with the transformation and global alpha of the current state
+// combined with those of the instance.
+func (r *Renderer) drawImageInstances(img *image.NRGBA, instances []canvas.SpriteInstance) {
+	s := &r.state
+	transform, globalAlpha := s.transform, s.globalAlpha
+	defer func() {
+		s.transform, s.globalAlpha = transform, globalAlpha
+	}()
+	for _, in := range instances {
+		sp := geom.ResolveSprite(in, img.Rect.Dx(), img.Rect.Dy())
+		s.transform = transform.Multiply(sp.Transform)
+		s.globalAlpha = globalAlpha * sp.Alpha
+		r.drawImage(img, sp.SX, sp.SY, sp.SW, sp.SH, -sp.SW/2, -sp.SH/2, sp.SW, sp.SH)
+	}
+}
+
+func (r *Renderer) drawImage(img *image.NRGBA, sx, sy, sw, sh, dx, dy, dw, dh float64) {
+	if sw == 0 || sh == 0 || dw == 0 || dh == 0 {
+		return
+	}
+	src := image.Rect(
+		int(math.Floor(math.Min(sx, sx+sw))), int(math.Floor(math.Min(sy, sy+sh))),
+		int(math.Ceil(math.Max(sx, sx+sw))), int(math.Ceil(math.Max(sy, sy+sh))),
+	).Intersect(img.Rect)
+	smoothing := r.state.smoothing
+	source := func(p geom.Point) rgba {
+		q := geom.Point{
+			X: sx + (p.X-dx)*sw/dw,
+			Y: sy + (p.Y-dy)*sh/dh,
+		}
+		return sample(img, src, q, smoothing, false, false)
+	}
+	r.paint(fillMask(r.width, r.height, polygons(geom.RectPath(r.state.transform, dx, dy, dw, dh))), source)
+}
+
+func (r *Renderer) getImageData(sx, sy, sw, sh float64) *image.NRGBA {
+	x0, y0 := int(math.Floor(math.Min(sx, sx+sw))), int(math.Floor(math.Min(sy, sy+sh)))
+	x1, y1 := int(math.Ceil(math.Max(sx, sx+sw))), int(math.Ceil(math.Max(sy, sy+sh)))
+	img := image.NewNRGBA(image.Rect(0, 0, x1-x0, y1-y0))
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if !(image.Point{X: x, Y: y}).In(r.img.Rect) {
+				continue
+			}
+			img.SetNRGBA(x-x0, y-y0, color.NRGBAModel.Convert(r.img.RGBAAt(x, y)).(color.NRGBA))
+		}
+	}
+	return img
+}
+
+// putImageData copies the dirty rectangle of the image data to the canvas
+// at (dx, dy) in device space, without any transformation, compositing or
+// clipping.
+func (r *Renderer) putImageData(img *image.NRGBA, dx, dy, dirtyX, dirtyY, dirtyW, dirtyH float64) {
+	if dirtyW < 0 {
+		dirtyX, dirtyW = dirtyX+dirtyW, -dirtyW
+	}
+	if dirtyH < 0 {
+		dirtyY, dirtyH = dirtyY+dirtyH, -dirtyH
+	}
+	dirty := image.Rect(
+		int(math.Floor(dirtyX)), int(math.Floor(dirtyY)),
+		int(math.Ceil(dirtyX+dirtyW)), int(math.Ceil(dirtyY+dirtyH)),
+	).Intersect(img.Rect)
+	ox, oy := int(math.Floor(dx)), int(math.Floor(dy))
+	for y := dirty.Min.Y; y < dirty.Max.Y; y++ {
+		for x := dirty.Min.X; x < dirty.Max.X; x++ {
+			p := image.Pt(x+ox, y+oy)
+			if !p.In(r.img.Rect) {
+				continue
+			}
+			r.img.SetRGBA(p.X, p.Y, color.RGBAModel.Convert(img.NRGBAAt(x, y)).(color.RGBA))
+		}
+	}
+}
+
+func (r *Renderer) at(x, y int) rgba {
+	i := r.img.PixOffset(x, y)
+	s := r.img.Pix[i : i+4 : i+4]
+	return rgba{
+		r: float64(s[0]) / 255,
+		g: float64(s[1]) / 255,
+		b: float64(s[2]) / 255,
+		a: float64(s[3]) / 255,
+	}
+}
+
+func (r *Renderer) set(x, y int, c rgba) {
+	i := r.img.PixOffset(x, y)
+	s := r.img.Pix[i : i+4 : i+4]
+	a := toByte(c.a)
+	// Keep the color components valid for premultiplied alpha.
+	s[0] = min(toByte(c.r), a)
+	s[1] = min(toByte(c.g), a)
+	s[2] = min(toByte(c.b), a)
+	s[3] = a
+}
@@ -0,0 +1,500 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+)
+
+var (
+	red   = color.RGBA{R: 0xff, A: 0xff}
+	green = color.RGBA{G: 0xff, A: 0xff}
+	blue  = color.RGBA{B: 0xff, A: 0xff}
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	none  = color.RGBA{}
+)
+
+type pixelAt struct {
+	x, y int
+	want color.RGBA
+}
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name   string
+		draw   func(ctx *canvas.Context)
+		pixels []pixelAt
+	}{
+		{
+			"fill rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(10, 10, 20, 10)
+			},
+			[]pixelAt{{10, 10, red}, {29, 19, red}, {9, 10, none}, {30, 10, none}, {10, 20, none}},
+		},
+		{
+			"fill path with hole",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(blue)
+				ctx.BeginPath()
+				ctx.Rect(0, 0, 40, 40)
+				// Opposite winding direction
+				ctx.MoveTo(10, 10)
+				ctx.LineTo(10, 30)
+				ctx.LineTo(30, 30)
+				ctx.LineTo(30, 10)
+				ctx.ClosePath()
+				ctx.Fill()
+			},
+			[]pixelAt{{5, 5, blue}, {20, 20, none}, {35, 35, blue}},
+		},
+		{
+			"fill circle",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyleString("lime")
+				ctx.BeginPath()
+				ctx.Arc(50, 50, 20, 0, 2*math.Pi, false)
+				ctx.Fill()
+			},
+			[]pixelAt{{50, 50, green}, {50, 32, green}, {34, 34, none}, {50, 75, none}},
+		},
+		{
+			"fill polygon and points",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.BeginPath()
+				ctx.Polygon([]float64{10, 10, 40, 10, 10, 40})
+				ctx.Fill()
+				ctx.SetFillStyle(blue)
+				ctx.BeginPath()
+				ctx.Points([]float64{70, 20, 70, 60}, 5)
+				ctx.Fill()
+			},
+			[]pixelAt{
+				{12, 12, red}, {35, 35, none},
+				{70, 20, blue}, {70, 60, blue}, {70, 40, none}, {77, 20, none},
+			},
+		},
+		{
+			"stroke with butt and square caps",
+			func(ctx *canvas.Context) {
+				ctx.SetLineWidth(10)
+				ctx.SetStrokeStyle(red)
+				ctx.BeginPath()
+				ctx.MoveTo(20, 20)
+				ctx.LineTo(80, 20)
+				ctx.Stroke()
+				ctx.SetLineCap(canvas.CapSquare)
+				ctx.SetStrokeStyle(blue)
+				ctx.BeginPath()
+				ctx.MoveTo(20, 60)
+				ctx.LineTo(80, 60)
+				ctx.Stroke()
+			},
+			[]pixelAt{
+				{20, 16, red}, {79, 24, red}, {17, 20, none}, {50, 26, none},
+				{17, 60, blue}, {82, 64, blue}, {86, 60, none},
+			},
+		},
+		{
+			"stroke joins",
+			func(ctx *canvas.Context) {
+				ctx.SetLineWidth(10)
+				for i, join := range []canvas.LineJoin{canvas.JoinMiter, canvas.JoinBevel} {
+					x := float64(i * 50)
+					ctx.SetLineJoin(join)
+					ctx.BeginPath()
+					ctx.MoveTo(x+10, 40)
+					ctx.LineTo(x+10, 10)
+					ctx.LineTo(x+40, 10)
+					ctx.Stroke()
+				}
+			},
+			[]pixelAt{
+				// The outer corner is filled by the miter join, but not
+				// by the bevel join.
+				{6, 6, color.RGBA{A: 0xff}},
+				{56, 6, none},
+				{58, 10, color.RGBA{A: 0xff}},
+			},
+		},
+		{
+			"line dash",
+			func(ctx *canvas.Context) {
+				ctx.SetLineWidth(4)
+				ctx.SetLineDash([]float64{10, 5})
+				ctx.BeginPath()
+				ctx.MoveTo(0, 10)
+				ctx.LineTo(100, 10)
+				ctx.Stroke()
+			},
+			[]pixelAt{{5, 10, color.RGBA{A: 0xff}}, {12, 10, none}, {20, 10, color.RGBA{A: 0xff}}, {27, 10, none}},
+		},
+		{
+			"transformations",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.Translate(50, 50)
+				ctx.Scale(2, 2)
+				ctx.Rotate(math.Pi / 2)
+				ctx.FillRect(0, 0, 10, 5)
+			},
+			// The rectangle covers x in [40, 50] and y in [50, 70]
+			[]pixelAt{{41, 51, red}, {48, 68, red}, {38, 55, none}, {52, 55, none}, {45, 72, none}},
+		},
+		{
+			"save and restore",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.Save()
+				ctx.SetFillStyle(blue)
+				ctx.Translate(50, 0)
+				ctx.Restore()
+				ctx.FillRect(0, 0, 10, 10)
+			},
+			[]pixelAt{{5, 5, red}, {55, 5, none}},
+		},
+		{
+			"clip",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.Rect(10, 10, 20, 20)
+				ctx.Clip()
+				ctx.SetFillStyle(green)
+				ctx.FillRect(0, 0, 100, 100)
+			},
+			[]pixelAt{{15, 15, green}, {5, 5, none}, {35, 15, none}},
+		},
+		{
+			"clear rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(0, 0, 100, 100)
+				ctx.ClearRect(10, 10, 10, 10)
+			},
+			[]pixelAt{{15, 15, none}, {5, 5, red}},
+		},
+		{
+			"global alpha",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(white)
+				ctx.FillRect(0, 0, 100, 100)
+				ctx.SetGlobalAlpha(0.5)
+				ctx.SetFillStyle(color.RGBA{A: 0xff})
+				ctx.FillRect(0, 0, 10, 10)
+			},
+			[]pixelAt{{5, 5, color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}}},
+		},
+		{
+			"composite copy",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(0, 0, 100, 100)
+				ctx.SetGlobalCompositeOperation(canvas.OpCopy)
+				ctx.SetFillStyle(blue)
+				ctx.FillRect(0, 0, 10, 10)
+			},
+			[]pixelAt{{5, 5, blue}, {50, 50, none}},
+		},
+		{
+			"composite destination over",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(0, 0, 10, 10)
+				ctx.SetGlobalCompositeOperation(canvas.OpDestinationOver)
+				ctx.SetFillStyle(blue)
+				ctx.FillRect(0, 0, 20, 20)
+			},
+			[]pixelAt{{5, 5, red}, {15, 15, blue}},
+		},
+		{
+			"blend multiply",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(color.RGBA{R: 0xff, G: 0xff, A: 0xff})
+				ctx.FillRect(0, 0, 10, 10)
+				ctx.SetGlobalCompositeOperation(canvas.OpMultiply)
+				ctx.SetFillStyle(color.RGBA{G: 0xff, B: 0xff, A: 0xff})
+				ctx.FillRect(0, 0, 10, 10)
+			},
+			[]pixelAt{{5, 5, green}},
+		},
+		{
+			"linear gradient",
+			func(ctx *canvas.Context) {
+				g := ctx.CreateLinearGradient(0, 0, 100, 0)
+				g.AddColorStop(0, color.RGBA{A: 0xff})
+				g.AddColorStop(1, white)
+				ctx.SetFillStyleGradient(g)
+				ctx.FillRect(0, 0, 100, 10)
+			},
+			[]pixelAt{
+				{0, 5, color.RGBA{R: 0x01, G: 0x01, B: 0x01, A: 0xff}},
+				{49, 5, color.RGBA{R: 0x7e, G: 0x7e, B: 0x7e, A: 0xff}},
+				{99, 5, color.RGBA{R: 0xfe, G: 0xfe, B: 0xfe, A: 0xff}},
+			},
+		},
+		{
+			"radial gradient",
+			func(ctx *canvas.Context) {
+				g := ctx.CreateRadialGradient(50, 50, 0, 50, 50, 40)
+				g.AddColorStopString(0, "red")
+				g.AddColorStopString(0.5, "red")
+				g.AddColorStopString(0.5, "blue")
+				ctx.SetFillStyleGradient(g)
+				ctx.FillRect(0, 0, 100, 100)
+			},
+			[]pixelAt{{50, 50, red}, {50, 35, red}, {50, 25, blue}, {0, 0, blue}},
+		},
+		{
+			"pattern",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(checkerboard(2))
+				ctx.SetImageSmoothingEnabled(false)
+				ctx.SetFillStylePattern(ctx.CreatePattern(img, canvas.PatternRepeatX))
+				ctx.FillRect(0, 0, 100, 100)
+			},
+			[]pixelAt{{0, 0, white}, {1, 0, blue}, {2, 0, white}, {51, 1, white}, {0, 2, none}},
+		},
+		{
+			"draw image",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(checkerboard(2))
+				ctx.SetImageSmoothingEnabled(false)
+				ctx.DrawImage(img, 10, 10)
+				ctx.DrawImageScaled(img, 20, 20, 20, 20)
+				ctx.DrawImageSubRectangle(img, 1, 0, 1, 1, 50, 50, 10, 10)
+			},
+			[]pixelAt{
+				{10, 10, white}, {11, 10, blue}, {12, 10, none},
+				{25, 25, white}, {35, 25, blue}, {35, 35, white},
+				{55, 55, blue},
+			},
+		},
+		{
+			"draw image instances",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(checkerboard(2))
+				ctx.SetImageSmoothingEnabled(false)
+				ctx.DrawImageInstances(img, []canvas.SpriteInstance{
+					{X: 20, Y: 20, Scale: 10, Alpha: 1},
+					{X: 60, Y: 20, Scale: 10, Rotation: math.Pi / 2, Alpha: 1},
+					{X: 20, Y: 60, Scale: 10, Alpha: 1, SX: 1, SY: 0, SWidth: 1, SHeight: 1},
+					// The zero values of Scale and Alpha draw nothing.
+					{X: 80, Y: 20},
+					{X: 80, Y: 60, Scale: 10},
+				})
+				// The transformation is restored afterward.
+				ctx.DrawImage(img, 90, 90)
+			},
+			[]pixelAt{
+				{15, 15, white}, {25, 15, blue}, {15, 25, blue}, {31, 20, none},
+				// Rotated by 90 degrees clockwise.
+				{55, 15, blue}, {65, 15, white}, {55, 25, white},
+				{20, 60, blue}, {14, 60, none},
+				{80, 20, none}, {75, 55, none}, {85, 65, none},
+				{90, 90, white}, {91, 90, blue},
+			},
+		},
+		{
+			"update image data",
+			func(ctx *canvas.Context) {
+				board := checkerboard(20)
+				img := ctx.CreateImageData(board)
+				img.Update(board)
+				ctx.DrawImage(img, 10, 10)
+				board.SetRGBA(18, 18, blue)
+				img.Update(board)
+				ctx.DrawImage(img, 40, 40)
+			},
+			[]pixelAt{{28, 28, white}, {40, 40, white}, {57, 58, blue}, {58, 58, blue}, {59, 59, white}},
+		},
+		{
+			"put image data",
+			func(ctx *canvas.Context) {
+				ctx.SetGlobalAlpha(0.5)
+				ctx.Translate(100, 100)
+				img := ctx.CreateImageData(checkerboard(2))
+				ctx.PutImageData(img, 10, 10)
+				ctx.PutImageDataDirty(img, 20, 20, 1, 0, 1, 1)
+			},
+			[]pixelAt{{10, 10, white}, {11, 10, blue}, {20, 20, none}, {21, 20, blue}, {21, 21, none}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Draw(100, 100, tt.draw)
+			for _, p := range tt.pixels {
+				got := img.RGBAAt(p.x, p.y)
+				if got != p.want {
+					t.Errorf("pixel at (%d, %d): got: %v, want: %v", p.x, p.y, got, p.want)
+				}
+			}
+		})
+	}
+}
+
+// TestDrawHugeGeometry checks that geometry far outside of the canvas is
+// clipped before it is rasterized, instead of being walked row by row.
+func TestDrawHugeGeometry(t *testing.T) {
+	tests := []struct {
+		name   string
+		draw   func(ctx *canvas.Context)
+		pixels []pixelAt
+	}{
+		{
+			"tall rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(0, -1e8, 10, 2e8)
+			},
+			[]pixelAt{{0, 0, red}, {9, 99, red}, {10, 50, none}},
+		},
+		{
+			"wide rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(-1e300, 10, 2e300, 10)
+			},
+			[]pixelAt{{0, 10, red}, {99, 19, red}, {50, 9, none}, {50, 20, none}},
+		},
+		{
+			"infinite rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.FillRect(math.Inf(-1), 10, math.Inf(1), 10)
+			},
+			[]pixelAt{{50, 15, none}},
+		},
+		{
+			"huge arc",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.BeginPath()
+				ctx.Arc(50, 50, 1e12, 0, 2*math.Pi, false)
+				ctx.Fill()
+			},
+			[]pixelAt{{0, 0, red}, {99, 99, red}},
+		},
+		{
+			"huge line width",
+			func(ctx *canvas.Context) {
+				ctx.SetStrokeStyle(red)
+				ctx.SetLineWidth(1e9)
+				ctx.BeginPath()
+				ctx.MoveTo(10, 50)
+				ctx.LineTo(90, 50)
+				ctx.Stroke()
+			},
+			[]pixelAt{{50, 0, red}, {50, 99, red}},
+		},
+		{
+			"huge points",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(red)
+				ctx.BeginPath()
+				ctx.Points([]float64{50, 50}, 1e12)
+				ctx.Fill()
+			},
+			[]pixelAt{{0, 0, red}, {99, 99, red}},
+		},
+		{
+			"too many dashes",
+			func(ctx *canvas.Context) {
+				ctx.SetStrokeStyle(red)
+				ctx.SetLineWidth(2)
+				ctx.SetLineDash([]float64{1e-9, 1e-9})
+				ctx.BeginPath()
+				ctx.MoveTo(-5e5, 50)
+				ctx.LineTo(5e5, 50)
+				ctx.Stroke()
+			},
+			// The line is stroked without dashes.
+			[]pixelAt{{0, 50, red}, {99, 49, red}, {50, 52, none}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Draw(100, 100, tt.draw)
+			for _, p := range tt.pixels {
+				got := img.RGBAAt(p.x, p.y)
+				if got != p.want {
+					t.Errorf("pixel at (%d, %d): got: %v, want: %v", p.x, p.y, got, p.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDrawSize(t *testing.T) {
+	img := Draw(640, 480, func(ctx *canvas.Context) {
+		if ctx.CanvasWidth() != 640 || ctx.CanvasHeight() != 480 {
+			t.Errorf("got canvas size: W %d H %d, want: W 640 H 480", ctx.CanvasWidth(), ctx.CanvasHeight())
+		}
+	})
+	if want := image.Rect(0, 0, 640, 480); img.Rect != want {
+		t.Errorf("got image bounds: %v, want: %v", img.Rect, want)
+	}
+}
+
+func TestRendererAntiAliasing(t *testing.T) {
+	r := NewRenderer(10, 10)
+	r.Render(
+		command.SetFillStyle{Color: color.RGBA{A: 0xff}},
+		command.FillRect{X: 0.5, Y: 0, Width: 5, Height: 10},
+	)
+	want := []uint8{0x80, 0xff, 0xff, 0xff, 0xff, 0x80, 0x00}
+	for x, a := range want {
+		if got := r.Image().RGBAAt(x, 5).A; got != a {
+			t.Errorf("alpha at x=%d: got: %#x, want: %#x", x, got, a)
+		}
+	}
+}
+
+func TestRendererKeepsStateAcrossFrames(t *testing.T) {
+	r := NewRenderer(10, 10)
+	r.Render(command.SetFillStyle{Color: red})
+	r.Render(command.FillRect{X: 0, Y: 0, Width: 10, Height: 10})
+	if got := r.Image().RGBAAt(5, 5); got != red {
+		t.Errorf("got: %v, want: %v", got, red)
+	}
+}
+
+func TestRendererRenderFrameError(t *testing.T) {
+	r := NewRenderer(10, 10)
+	frame := command.Encode([]command.Command{
+		command.SetFillStyle{Color: red},
+		command.FillRect{X: 0, Y: 0, Width: 10, Height: 10},
+	})
+	frame = append(frame, 0x00)
+	if err := r.RenderFrame(frame); err == nil {
+		t.Errorf("expected error, but got none")
+	}
+	if got := r.Image().RGBAAt(5, 5); got != red {
+		t.Errorf("commands before error: got: %v, want: %v", got, red)
+	}
+}
+
+// checkerboard returns an image with a 1×1 checkerboard pattern of white
+// and blue pixels.
+func checkerboard(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			if (x+y)%2 == 0 {
+				img.SetRGBA(x, y, white)
+			} else {
+				img.SetRGBA(x, y, blue)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,263 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package raster
+
+import (
+	"math"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// tolerance is the maximum distance in device pixels between a curve
+// and the line segments approximating it.
+const tolerance = 0.1
+
+// arcSegments returns the number of line segments for an arc with the
+// given device space radius and sweep angle.
+func arcSegments(radius, sweep float64) int {
+	if radius <= tolerance || sweep == 0 {
+		return 1
+	}
+	step := 2 * math.Acos(1-tolerance/radius)
+	n := int(math.Ceil(sweep / step))
+	return min(max(n, 1), 10000)
+}
+
+// A stroker converts polylines in user space into polygons that cover
+// the area of their strokes. The polygons overlap at joins and caps, but
+// they all have the same orientation, so that they can be filled together
+// with the nonzero winding rule.
+type stroker struct {
+	halfWidth  float64
+	cap        canvas.LineCap
+	join       canvas.LineJoin
+	miterLimit float64
+	// scale is the scale factor of the transformation from user space
+	// to device space, used to choose the resolution of round joins
+	// and caps.
+	scale float64
+
+	polys [][]geom.Point
+}
+
+// stroke adds the stroke polygons of a polyline.
+func (s *stroker) stroke(points []geom.Point, closed bool) {
+	points = dedupe(points, closed)
+	if len(points) < 2 {
+		return
+	}
+	n := len(points)
+	segments := n - 1
+	if closed {
+		segments = n
+	}
+	for i := range segments {
+		s.segment(points[i], points[(i+1)%n])
+	}
+	for i := 1; i < n-1; i++ {
+		s.joinAt(points[i-1], points[i], points[i+1])
+	}
+	if closed {
+		s.joinAt(points[n-2], points[n-1], points[0])
+		s.joinAt(points[n-1], points[0], points[1])
+		return
+	}
+	s.capAt(points[0], points[0].Sub(points[1]).Normalize())
+	s.capAt(points[n-1], points[n-1].Sub(points[n-2]).Normalize())
+}
+
+// dedupe removes consecutive duplicate points, including a closing point
+// equal to the first point of a closed polyline.
+func dedupe(points []geom.Point, closed bool) []geom.Point {
+	out := make([]geom.Point, 0, len(points))
+	for _, p := range points {
+		if len(out) == 0 || out[len(out)-1] != p {
+			out = append(out, p)
+		}
+	}
+	if closed && len(out) > 1 && out[0] == out[len(out)-1] {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+func (s *stroker) segment(a, b geom.Point) {
+	n := b.Sub(a).Normalize().Perp().Mul(s.halfWidth)
+	s.add(a.Add(n), b.Add(n), b.Sub(n), a.Sub(n))
+}
+
+func (s *stroker) joinAt(prev, p, next geom.Point) {
+	d1 := p.Sub(prev).Normalize()
+	d2 := next.Sub(p).Normalize()
+	cross := d1.Cross(d2)
+	dot := d1.Dot(d2)
+	if math.Abs(cross) < 1e-9 && dot > 0 {
+		return
+	}
+	if s.join == canvas.JoinRound {
+		s.circle(p)
+		return
+	}
+	side := -1.0
+	if cross < 0 {
+		side = 1
+	}
+	o1 := p.Add(d1.Perp().Mul(side * s.halfWidth))
+	o2 := p.Add(d2.Perp().Mul(side * s.halfWidth))
+	if s.join == canvas.JoinMiter {
+		cosHalf := math.Sqrt((1 + dot) / 2)
+		if cosHalf > 0 && 1/cosHalf <= s.miterLimit {
+			dir := o1.Add(o2).Sub(p.Mul(2)).Normalize()
+			tip := p.Add(dir.Mul(s.halfWidth / cosHalf))
+			s.add(p, o1, tip, o2)
+			return
+		}
+	}
+	s.add(p, o1, o2)
+}
+
+// capAt adds the cap at the end point p of a polyline, where dir is the
+// outward direction of the line at p.
+func (s *stroker) capAt(p, dir geom.Point) {
+	switch s.cap {
+	case canvas.CapRound:
+		s.circle(p)
+	case canvas.CapSquare:
+		n := dir.Perp().Mul(s.halfWidth)
+		e := dir.Mul(s.halfWidth)
+		s.add(p.Add(n), p.Add(n).Add(e), p.Sub(n).Add(e), p.Sub(n))
+	}
+}
+
+func (s *stroker) circle(center geom.Point) {
+	n := arcSegments(s.halfWidth*s.scale, 2*math.Pi)
+	n = max(n, 8)
+	poly := make([]geom.Point, n)
+	for i := range poly {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		poly[i] = geom.Pt(center.X+cos*s.halfWidth, center.Y+sin*s.halfWidth)
+	}
+	s.add(poly...)
+}
+
+// add adds a polygon, reversing it if necessary to give it a positive
+// orientation.
+func (s *stroker) add(poly ...geom.Point) {
+	if signedArea(poly) < 0 {
+		for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+			poly[i], poly[j] = poly[j], poly[i]
+		}
+	}
+	s.polys = append(s.polys, poly)
+}
+
+func signedArea(poly []geom.Point) float64 {
+	var area float64
+	for i, p := range poly {
+		area += p.Cross(poly[(i+1)%len(poly)])
+	}
+	return area / 2
+}
+
+// dash splits a polyline into the dashes of a dash pattern, starting at
+// the given offset into the pattern. The dashes are open polylines.
+func dash(points []geom.Point, closed bool, pattern []float64, offset float64) [][]geom.Point {
+	if closed && len(points) > 0 {
+		points = append(points[:len(points):len(points)], points[0])
+	}
+	var total float64
+	for _, l := range pattern {
+		total += l
+	}
+	offset = math.Mod(offset, total)
+	if offset < 0 {
+		offset += total
+	}
+	i := 0
+	for offset >= pattern[i] {
+		offset -= pattern[i]
+		i = (i + 1) % len(pattern)
+	}
+	remaining := pattern[i] - offset
+	on := i%2 == 0
+
+	var dashes [][]geom.Point
+	var current []geom.Point
+	if on && len(points) > 0 {
+		current = []geom.Point{points[0]}
+	}
+	for k := 1; k < len(points); k++ {
+		a, b := points[k-1], points[k]
+		segLen := b.Sub(a).Length()
+		pos := 0.0
+		for segLen-pos > remaining {
+			pos += remaining
+			q := a.Lerp(b, pos/segLen)
+			if on {
+				current = append(current, q)
+				dashes = append(dashes, current)
+				current = nil
+			} else {
+				current = []geom.Point{q}
+			}
+			on = !on
+			i = (i + 1) % len(pattern)
+			remaining = pattern[i]
+		}
+		remaining -= segLen - pos
+		if on {
+			current = append(current, b)
+		}
+	}
+	if on && len(current) > 1 {
+		dashes = append(dashes, current)
+	}
+	return dashes
+}
+
+// maxDashes is the maximum number of dashes of a polyline. Like in web
+// browsers, which limit the number of dashes as well, a polyline that
+// would have more dashes is stroked without dashes.
+const maxDashes = 1000000
+
+// tooManyDashes reports whether the polyline would have more than
+// maxDashes dashes with the dash pattern, or an infinite length.
+func tooManyDashes(points []geom.Point, closed bool, pattern []float64) bool {
+	var length float64
+	for i := 1; i < len(points); i++ {
+		length += points[i].Sub(points[i-1]).Length()
+	}
+	if closed && len(points) > 1 {
+		length += points[0].Sub(points[len(points)-1]).Length()
+	}
+	var total float64
+	for _, l := range pattern {
+		total += l
+	}
+	dashes := length / total * float64(len(pattern)/2)
+	return !(dashes <= maxDashes)
+}
+
+// normalizeDash returns the dash pattern that is in effect for the given
+// line dash segments, or nil for solid lines. It reports false if the
+// segments contain negative or non-finite values, which are rejected by
+// the canvas API.
+func normalizeDash(segments []float64) ([]float64, bool) {
+	var total float64
+	for _, l := range segments {
+		if l < 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+			return nil, false
+		}
+		total += l
+	}
+	if total == 0 {
+		return nil, true
+	}
+	if len(segments)%2 == 1 {
+		return append(segments[:len(segments):len(segments)], segments...), true
+	}
+	return segments, true
+}
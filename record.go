@@ -0,0 +1,98 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fzipp/canvas/recording"
+)
+
+// RecordToDir returns a function for Options.Record that records each
+// session to a new file in the given directory. The file name is the
+// start time of the session in UTC with the extension ".rec", for example
+// "20260102T150405.123456789Z.rec".
+func RecordToDir(dir string) func(*http.Request) (io.WriteCloser, error) {
+	return func(*http.Request) (io.WriteCloser, error) {
+		name := time.Now().UTC().Format("20060102T150405.000000000Z") + ".rec"
+		return os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	}
+}
+
+// sessionRecorder writes the frames and events of a session to a
+// recording. The methods of a nil *sessionRecorder do nothing, so that
+// sessions that are not recorded do not need special handling.
+type sessionRecorder struct {
+	mu    sync.Mutex
+	w     *recording.Writer
+	c     io.Closer
+	start time.Time
+}
+
+// newSessionRecorder starts a recording with the Record option, or returns
+// nil if recording is not enabled or cannot be started.
+func newSessionRecorder(r *http.Request, opts *Options) *sessionRecorder {
+	if opts.Record == nil {
+		return nil
+	}
+	wc, err := opts.Record(r)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	start := time.Now()
+	w, err := recording.NewWriter(wc, recording.Header{
+		Width:    opts.Width,
+		Height:   opts.Height,
+		Start:    start,
+		Protocol: protocolVersion,
+	})
+	if err != nil {
+		log.Println(err)
+		_ = wc.Close()
+		return nil
+	}
+	return &sessionRecorder{w: w, c: wc, start: start}
+}
+
+// record writes a record with the time since the start of the session.
+// If writing fails, the recording is stopped.
+func (r *sessionRecorder) record(kind recording.Kind, data []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.w == nil {
+		return
+	}
+	err := r.w.WriteRecord(recording.Record{
+		Kind: kind,
+		Time: time.Since(r.start),
+		Data: data,
+	})
+	if err != nil {
+		log.Println(err)
+		r.w = nil
+	}
+}
+
+func (r *sessionRecorder) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w = nil
+	if err := r.c.Close(); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package recording reads and writes recordings of canvas sessions, as
+// created with the Record option of the canvas package. A recording
+// contains every frame of draw commands that the server sent to the
+// client, and every event message that the client sent to the server,
+// with the time at which it was sent.
+//
+// # Format
+//
+// A recording consists of a header followed by a sequence of records.
+// All integers are unsigned and big-endian, like in the binary draw
+// command format. The header has 30 bytes:
+//
+//	magic    [8]byte  "CANVREC\n"
+//	version  uint16   format version, currently 2
+//	width    uint32   width of the canvas in pixels
+//	height   uint32   height of the canvas in pixels
+//	start    int64    start time of the session in nanoseconds
+//	                  since the Unix epoch (signed)
+//	protocol uint32   version of the draw protocol of the session
+//
+// The header of format version 1 has 26 bytes and ends after the start
+// time; it does not record the draw protocol version.
+//
+// Each record consists of a 13 byte record header followed by the data:
+//
+//	kind     uint8    1 = frame, 2 = event
+//	time     uint64   time since the start of the session in nanoseconds
+//	length   uint32   length of the data in bytes
+//	data     [length]byte
+//
+// The data of a frame record is a frame in the binary draw command format,
+// as sent by canvas.Context.Flush, which can be decoded with the command
+// package. The data of an event record is an event message in the binary
+// format sent by the client. Records are in chronological order. Readers
+// skip records of unknown kinds, so that future versions of the format can
+// add new kinds of records.
+//
+// A recording that was not closed properly, for example because the
+// server crashed, may end with an incomplete record. Reader reports it
+// with io.ErrUnexpectedEOF after all complete records.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Version is the version of the recording format written by Writer.
+const Version = 2
+
+const (
+	magic            = "CANVREC\n"
+	headerSize       = 30
+	headerSizeV1     = 26
+	recordHeaderSize = 13
+)
+
+var (
+	// ErrInvalidHeader is returned by NewReader if the data does not start
+	// with a recording header.
+	ErrInvalidHeader = errors.New("recording: invalid header")
+	// ErrUnsupportedVersion is returned by NewReader if the recording has
+	// a newer format version than Version.
+	ErrUnsupportedVersion = errors.New("recording: unsupported format version")
+)
+
+// Header describes a recorded session.
+type Header struct {
+	// Width and Height are the size of the canvas in pixels.
+	Width, Height int
+	// Start is the time at which the session started.
+	Start time.Time
+	// Protocol is the version of the draw protocol with which the frames
+	// and events were encoded, as negotiated by the handshake of the
+	// canvas package. It is 0 for recordings of format version 1.
+	Protocol int
+}
+
+// Kind is the kind of a record.
+type Kind byte
+
+const (
+	// KindFrame is the kind of records with a frame of draw commands that
+	// was sent to the client.
+	KindFrame Kind = 1
+	// KindEvent is the kind of records with an event message that was
+	// received from the client.
+	KindEvent Kind = 2
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindFrame:
+		return "frame"
+	case KindEvent:
+		return "event"
+	}
+	return fmt.Sprintf("Kind(%d)", k)
+}
+
+// A Record is a message of the recorded session.
+type Record struct {
+	Kind Kind
+	// Time is the time since the start of the session.
+	Time time.Duration
+	Data []byte
+}
+
+// Writer writes a recording. Each record is written with a single call of
+// the Write method of the underlying writer.
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter writes the recording header to w and returns a Writer that
+// writes records to w.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	buf := make([]byte, 0, headerSize)
+	buf = append(buf, magic...)
+	buf = binary.BigEndian.AppendUint16(buf, Version)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.Width))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.Height))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(h.Start.UnixNano()))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.Protocol))
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// WriteRecord writes a record. Records must be written in chronological
+// order.
+func (w *Writer) WriteRecord(rec Record) error {
+	buf := w.buf[:0]
+	buf = append(buf, byte(rec.Kind))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(rec.Time))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(rec.Data)))
+	buf = append(buf, rec.Data...)
+	w.buf = buf
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// Reader reads a recording.
+type Reader struct {
+	r      *bufio.Reader
+	header Header
+}
+
+// NewReader reads the recording header from r and returns a Reader that
+// reads the records from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	buf := make([]byte, headerSize)
+	if err := readHeader(br, buf[:headerSizeV1]); err != nil {
+		return nil, err
+	}
+	if string(buf[:len(magic)]) != magic {
+		return nil, ErrInvalidHeader
+	}
+	version := binary.BigEndian.Uint16(buf[8:])
+	if version > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	h := Header{
+		Width:  int(binary.BigEndian.Uint32(buf[10:])),
+		Height: int(binary.BigEndian.Uint32(buf[14:])),
+		Start:  time.Unix(0, int64(binary.BigEndian.Uint64(buf[18:]))),
+	}
+	if version >= 2 {
+		if err := readHeader(br, buf[headerSizeV1:]); err != nil {
+			return nil, err
+		}
+		h.Protocol = int(binary.BigEndian.Uint32(buf[26:]))
+	}
+	return &Reader{r: br, header: h}, nil
+}
+
+// readHeader reads a part of the recording header.
+func readHeader(r io.Reader, buf []byte) error {
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrInvalidHeader
+		}
+		return err
+	}
+	return nil
+}
+
+// Header returns the header of the recording.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next reads the next record. It returns io.EOF at the end of the
+// recording, and io.ErrUnexpectedEOF if the recording ends with an
+// incomplete record. Records of unknown kinds are skipped.
+func (r *Reader) Next() (Record, error) {
+	for {
+		var head [recordHeaderSize]byte
+		if _, err := io.ReadFull(r.r, head[:]); err != nil {
+			return Record{}, err
+		}
+		rec := Record{
+			Kind: Kind(head[0]),
+			Time: time.Duration(binary.BigEndian.Uint64(head[1:])),
+		}
+		length := int64(binary.BigEndian.Uint32(head[9:]))
+		// The data is copied instead of read into a buffer of the given
+		// length, so that a corrupt length does not allocate more memory
+		// than the size of the recording.
+		var data bytes.Buffer
+		n, err := io.CopyN(&data, r.r, length)
+		if n < length {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return Record{}, err
+		}
+		if rec.Kind != KindFrame && rec.Kind != KindEvent {
+			continue
+		}
+		rec.Data = data.Bytes()
+		return rec, nil
+	}
+}
+
+// ReadAll reads a complete recording. An incomplete record at the end of
+// the recording is ignored.
+func ReadAll(r io.Reader) (Header, []Record, error) {
+	rr, err := NewReader(r)
+	if err != nil {
+		return Header{}, nil, err
+	}
+	var records []Record
+	for {
+		rec, err := rr.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return rr.Header(), records, nil
+		}
+		if err != nil {
+			return Header{}, nil, err
+		}
+		records = append(records, rec)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package recording
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteRead(t *testing.T) {
+	header := Header{
+		Width:    640,
+		Height:   480,
+		Start:    time.Unix(1700000000, 123456789),
+		Protocol: 6,
+	}
+	records := []Record{
+		{Kind: KindFrame, Time: 0, Data: []byte{1, 2, 3}},
+		{Kind: KindEvent, Time: 15 * time.Millisecond, Data: []byte{4}},
+		{Kind: KindFrame, Time: 16 * time.Millisecond, Data: []byte{}},
+	}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := w.WriteRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gotHeader, gotRecords, err := ReadAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotHeader.Start.Equal(header.Start) {
+		t.Errorf("start: got %v, want %v", gotHeader.Start, header.Start)
+	}
+	if gotHeader.Width != header.Width || gotHeader.Height != header.Height {
+		t.Errorf("size: got %dx%d, want %dx%d",
+			gotHeader.Width, gotHeader.Height, header.Width, header.Height)
+	}
+	if gotHeader.Protocol != header.Protocol {
+		t.Errorf("protocol: got %d, want %d", gotHeader.Protocol, header.Protocol)
+	}
+	if diff := cmp.Diff(records, gotRecords); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestReaderSkipsUnknownKinds(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 1, Height: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.WriteRecord(Record{Kind: 99, Time: 1, Data: []byte{1, 2}})
+	_ = w.WriteRecord(Record{Kind: KindEvent, Time: 2, Data: []byte{3}})
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Record{Kind: KindEvent, Time: 2, Data: []byte{3}}
+	if diff := cmp.Diff(want, rec); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got error %v, want %v", err, io.EOF)
+	}
+}
+
+func TestReaderIncompleteRecord(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Width: 1, Height: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.WriteRecord(Record{Kind: KindFrame, Time: 1, Data: []byte{1}})
+	_ = w.WriteRecord(Record{Kind: KindFrame, Time: 2, Data: []byte{1, 2, 3}})
+	data := buf.Bytes()[:buf.Len()-1]
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	_, records, err := ReadAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("got %d records, want 1", len(records))
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	data := []byte("CANVREC\n")
+	data = append(data, 0x00, 0x01)             // version
+	data = append(data, 0x00, 0x00, 0x00, 0x02) // width
+	data = append(data, 0x00, 0x00, 0x00, 0x03) // height
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0) // start
+	data = append(data, byte(KindFrame), 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x00, 0x00, 0x01, 0xff)
+
+	header, records, err := ReadAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Header{Width: 2, Height: 3, Start: time.Unix(0, 0)}
+	if diff := cmp.Diff(want, header); diff != "" {
+		t.Errorf("header mismatch (-want, +got)\n%s", diff)
+	}
+	if diff := cmp.Diff([]Record{{Kind: KindFrame, Data: []byte{0xff}}}, records); diff != "" {
+		t.Errorf("records mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	var valid bytes.Buffer
+	if _, err := NewWriter(&valid, Header{}); err != nil {
+		t.Fatal(err)
+	}
+	newer := bytes.Clone(valid.Bytes())
+	newer[9] = Version + 1
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrInvalidHeader},
+		{"short", valid.Bytes()[:10], ErrInvalidHeader},
+		{"short protocol", valid.Bytes()[:28], ErrInvalidHeader},
+		{"magic", append([]byte("NOTAREC\n"), valid.Bytes()[8:]...), ErrInvalidHeader},
+		{"version", newer, ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKindString(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		want string
+	}{
+		{KindFrame, "frame"},
+		{KindEvent, "event"},
+		{7, "Kind(7)"},
+	}
+	for _, tt := range tests {
+		if got := tt.kind.String(); got != tt.want {
+			t.Errorf("Kind(%d).String() = %q, want %q", byte(tt.kind), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fzipp/canvas/recording"
+	"github.com/gorilla/websocket"
+)
+
+// NewReplayServeMux creates a http.ServeMux that replays a recording of a
+// session, as created with Options.Record, to a browser. Like the ServeMux
+// created by NewServeMux it serves an HTML page with a canvas on "/", but
+// instead of running a draw function for a client, it sends the recorded
+// frames at their original speed. The page has controls to pause and
+// resume the replay and to seek to any point in time. Each client gets its
+// own replay, starting at the beginning.
+//
+// The recording is read completely from r. The canvas has the size of the
+// recorded canvas. The other options configure the page like for
+// NewServeMux, events are not transmitted.
+//
+// Recordings of sessions with a different version of the draw protocol
+// than the one of this package cannot be replayed, since the client would
+// misinterpret their frames. NewReplayServeMux returns an error for them.
+func NewReplayServeMux(r io.Reader, opts *Options) (*http.ServeMux, error) {
+	header, records, err := recording.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if header.Protocol != protocolVersion {
+		return nil, fmt.Errorf("canvas: recording has protocol version %d, want version %d", header.Protocol, protocolVersion)
+	}
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Width = header.Width
+	o.Height = header.Height
+	o.EnabledEvents = nil
+	o.applyDefaults()
+
+	h := &replayHandler{}
+	for _, rec := range records {
+		if rec.Kind == recording.KindFrame {
+			h.frames = append(h.frames, rec)
+		}
+		h.duration = max(h.duration, rec.Time)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /", &htmlHandler{
+		opts:   &o,
+		replay: true,
+	})
+	mux.HandleFunc("GET /canvas-websocket.js", javaScriptHandler)
+	mux.Handle("GET /draw", h)
+	handleStaticFiles(mux, &o)
+	return mux, nil
+}
+
+type replayHandler struct {
+	frames   []recording.Record
+	duration time.Duration
+}
+
+// replayControl is a message from the replay controls of the client.
+type replayControl struct {
+	// Type is "play", "pause" or "seek".
+	Type string `json:"type"`
+	// Time is the time to seek to in milliseconds.
+	Time float64 `json:"time"`
+}
+
+// replayStatus is a message to the replay controls of the client. A
+// message of type "reset" tells the client to reset its canvas before
+// the frames up to a seek position are sent again. A message of type
+// "status" reports the current position of the replay.
+type replayStatus struct {
+	Type string `json:"type"`
+	// Time and Duration are in milliseconds.
+	Time     float64 `json:"time,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+	Paused   bool    `json:"paused,omitempty"`
+}
+
+func (h *replayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer conn.Close()
+	controls := make(chan replayControl)
+	done := make(chan struct{})
+	defer close(done)
+	go readReplayControls(conn, controls, done)
+	p := &replayer{
+		conn:     conn,
+		frames:   h.frames,
+		duration: h.duration,
+	}
+	if err := p.run(controls); err != nil {
+		log.Println(err)
+	}
+}
+
+// readReplayControls reads the replay controls from the connection until
+// it fails or done is closed.
+func readReplayControls(conn *websocket.Conn, controls chan<- replayControl, done <-chan struct{}) {
+	defer close(controls)
+	for {
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		var c replayControl
+		if err := json.Unmarshal(p, &c); err != nil {
+			continue
+		}
+		select {
+		case controls <- c:
+		case <-done:
+			return
+		}
+	}
+}
+
+// replayer replays the frames of a recording over a connection.
+type replayer struct {
+	conn     *websocket.Conn
+	frames   []recording.Record
+	duration time.Duration
+
+	// next is the index of the next frame to send.
+	next int
+	// pos is the position of the replay at the time since, or while
+	// the replay is paused.
+	pos    time.Duration
+	since  time.Time
+	paused bool
+}
+
+// position returns the current position of the replay.
+func (p *replayer) position() time.Duration {
+	if p.paused {
+		return p.pos
+	}
+	return min(p.pos+time.Since(p.since), p.duration)
+}
+
+// run replays the frames until the connection is closed.
+func (p *replayer) run(controls <-chan replayControl) error {
+	p.since = time.Now()
+	if err := p.sendStatus(); err != nil {
+		return err
+	}
+	for {
+		var due <-chan time.Time
+		var timer *time.Timer
+		if !p.paused && p.next < len(p.frames) {
+			timer = time.NewTimer(p.frames[p.next].Time - p.position())
+			due = timer.C
+		}
+		var err error
+		select {
+		case c, ok := <-controls:
+			if !ok {
+				return nil
+			}
+			err = p.control(c)
+		case <-due:
+			err = p.sendDueFrames()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *replayer) control(c replayControl) error {
+	switch c.Type {
+	case "pause":
+		if p.paused {
+			return nil
+		}
+		p.pos = p.position()
+		p.paused = true
+	case "play":
+		if !p.paused {
+			return nil
+		}
+		if p.next == len(p.frames) && p.pos >= p.duration {
+			// Play from the beginning again after the end.
+			return p.seek(0, false)
+		}
+		p.since = time.Now()
+		p.paused = false
+	case "seek":
+		t := time.Duration(c.Time * float64(time.Millisecond))
+		return p.seek(min(max(t, 0), p.duration), p.paused)
+	default:
+		return nil
+	}
+	return p.sendStatus()
+}
+
+// seek resets the client canvas and sends all frames up to the time t at
+// once.
+func (p *replayer) seek(t time.Duration, paused bool) error {
+	if err := p.sendJSON(replayStatus{Type: "reset"}); err != nil {
+		return err
+	}
+	var batch bytes.Buffer
+	p.next = 0
+	for p.next < len(p.frames) && p.frames[p.next].Time <= t {
+		batch.Write(p.frames[p.next].Data)
+		p.next++
+	}
+	if batch.Len() > 0 {
+		if err := p.conn.WriteMessage(websocket.BinaryMessage, batch.Bytes()); err != nil {
+			return err
+		}
+	}
+	p.pos = t
+	p.since = time.Now()
+	p.paused = paused
+	return p.sendStatus()
+}
+
+// sendDueFrames sends the frames that are due. At the end of the
+// recording the replay is paused.
+func (p *replayer) sendDueFrames() error {
+	now := p.position()
+	for p.next < len(p.frames) && p.frames[p.next].Time <= now {
+		if err := p.conn.WriteMessage(websocket.BinaryMessage, p.frames[p.next].Data); err != nil {
+			return err
+		}
+		p.next++
+	}
+	if p.next == len(p.frames) {
+		p.pos = p.duration
+		p.paused = true
+		return p.sendStatus()
+	}
+	return nil
+}
+
+func (p *replayer) sendStatus() error {
+	return p.sendJSON(replayStatus{
+		Type:     "status",
+		Time:     float64(p.position()) / float64(time.Millisecond),
+		Duration: float64(p.duration) / float64(time.Millisecond),
+		Paused:   p.paused,
+	})
+}
+
+func (p *replayer) sendJSON(v any) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return p.conn.WriteMessage(websocket.TextMessage, msg)
+}
@@ -0,0 +1,224 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fzipp/canvas/recording"
+	"github.com/gorilla/websocket"
+)
+
+// recordBuffer is a recording destination that can be read while the
+// session is still running.
+type recordBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *recordBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *recordBuffer) Close() error {
+	return nil
+}
+
+func (b *recordBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	buf := &recordBuffer{}
+	opts := &Options{
+		Width:         320,
+		Height:        200,
+		EnabledEvents: []Event{MouseDownEvent{}},
+		Record: func(*http.Request) (io.WriteCloser, error) {
+			return buf, nil
+		},
+	}
+	srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+		ctx.FillRect(1, 2, 3, 4)
+		ctx.Flush()
+		<-ctx.Events()
+		ctx.Fill()
+		ctx.Flush()
+		<-ctx.Events()
+	}, opts))
+	defer srv.Close()
+
+	conn := dial(t, srv, "/draw")
+	frame1 := readMessage(t, conn, websocket.BinaryMessage)
+	mouseDown := []byte{0x02, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0}
+	if err := conn.WriteMessage(websocket.BinaryMessage, mouseDown); err != nil {
+		t.Fatal(err)
+	}
+	frame2 := readMessage(t, conn, websocket.BinaryMessage)
+	conn.Close()
+
+	header, records, err := recording.ReadAll(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Width != 320 || header.Height != 200 {
+		t.Errorf("recorded size %dx%d, want 320x200", header.Width, header.Height)
+	}
+	var kinds []recording.Kind
+	for _, rec := range records {
+		kinds = append(kinds, rec.Kind)
+	}
+	wantKinds := []recording.Kind{recording.KindFrame, recording.KindEvent, recording.KindFrame}
+	if len(kinds) != len(wantKinds) || kinds[0] != wantKinds[0] || kinds[1] != wantKinds[1] || kinds[2] != wantKinds[2] {
+		t.Fatalf("recorded kinds %v, want %v", kinds, wantKinds)
+	}
+	if !bytes.Equal(records[0].Data, frame1) || !bytes.Equal(records[2].Data, frame2) {
+		t.Errorf("recorded frames differ from sent frames")
+	}
+	if !bytes.Equal(records[1].Data, mouseDown) {
+		t.Errorf("recorded event %v, want %v", records[1].Data, mouseDown)
+	}
+
+	mux, err := NewReplayServeMux(bytes.NewReader(buf.Bytes()), &Options{Title: "Replay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaySrv := httptest.NewServer(mux)
+	defer replaySrv.Close()
+
+	resp, err := http.Get(replaySrv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{`width="320" height="200"`, `data-replay="true"`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("missing %s in:\n%s", want, page)
+		}
+	}
+
+	conn = dial(t, replaySrv, "/draw")
+	defer conn.Close()
+	status := readStatus(t, conn)
+	if status.Type != "status" || status.Paused {
+		t.Errorf("initial status %+v, want playing status", status)
+	}
+	if got := readMessage(t, conn, websocket.BinaryMessage); !bytes.Equal(got, frame1) {
+		t.Errorf("replayed frame %v, want %v", got, frame1)
+	}
+
+	// Seeking to the end resets the canvas and sends all frames at once.
+	seek, _ := json.Marshal(replayControl{Type: "seek", Time: 1e9})
+	if err := conn.WriteMessage(websocket.TextMessage, seek); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		status = readStatus(t, conn)
+		if status.Type == "reset" {
+			break
+		}
+	}
+	want := append(bytes.Clone(frame1), frame2...)
+	if got := readMessage(t, conn, websocket.BinaryMessage); !bytes.Equal(got, want) {
+		t.Errorf("frames after seek %v, want %v", got, want)
+	}
+	status = readStatus(t, conn)
+	if status.Type != "status" || status.Time != status.Duration {
+		t.Errorf("status after seek %+v, want position at the end", status)
+	}
+}
+
+func dial(t *testing.T, srv *httptest.Server, path string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + path
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendHello(t, conn, 0)
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn, wantType int) []byte {
+	t.Helper()
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType == wantType {
+			return p
+		}
+	}
+}
+
+func readStatus(t *testing.T, conn *websocket.Conn) replayStatus {
+	t.Helper()
+	var status replayStatus
+	if err := json.Unmarshal(readMessage(t, conn, websocket.TextMessage), &status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestReadReplayControlsStopsWhenDone(t *testing.T) {
+	controls := make(chan replayControl)
+	done := make(chan struct{})
+	returned := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		readReplayControls(conn, controls, done)
+		close(returned)
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pause, _ := json.Marshal(replayControl{Type: "pause"})
+	if err := conn.WriteMessage(websocket.TextMessage, pause); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody receives the control, as after the replayer has returned.
+	close(done)
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("readReplayControls did not return after done was closed")
+	}
+}
+
+func TestReplayProtocolMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := recording.NewWriter(&buf, recording.Header{Width: 1, Height: 1, Protocol: protocolVersion - 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReplayServeMux(&buf, nil); err == nil {
+		t.Error("expected error for recording with a different protocol version, got none")
+	}
+}
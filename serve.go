@@ -13,9 +13,9 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"sync"
 	"time"
 
+	"github.com/fzipp/canvas/recording"
 	"github.com/gorilla/websocket"
 )
 
@@ -71,26 +71,41 @@ func NewServeMux(run func(*Context), opts *Options) *http.ServeMux {
 		opts: opts,
 		draw: run,
 	})
+	handleStaticFiles(mux, opts)
 	return mux
 }
 
+// handleStaticFiles registers the handler for Options.StaticFiles.
+func handleStaticFiles(mux *http.ServeMux, opts *Options) {
+	if opts.StaticFiles == nil {
+		return
+	}
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(opts.StaticFiles)))
+}
+
 type htmlHandler struct {
 	opts *Options
+	// replay enables the replay controls of the page.
+	replay bool
 }
 
 func (h *htmlHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
 	model := map[string]any{
-		"DrawURL":             template.URL("draw"),
-		"Width":               h.opts.Width,
-		"Height":              h.opts.Height,
-		"Title":               h.opts.Title,
-		"PageBackground":      template.CSS(rgbaString(h.opts.PageBackground)),
-		"EventMask":           h.opts.eventMask(),
-		"MouseCursorHidden":   h.opts.MouseCursorHidden,
-		"ContextMenuDisabled": h.opts.ContextMenuDisabled,
-		"ScaleToPageWidth":    h.opts.ScaleToPageWidth,
-		"ScaleToPageHeight":   h.opts.ScaleToPageHeight,
-		"ReconnectInterval":   int64(h.opts.ReconnectInterval / time.Millisecond),
+		"DrawURL":                 template.URL("draw"),
+		"Width":                   h.opts.Width,
+		"Height":                  h.opts.Height,
+		"Title":                   h.opts.Title,
+		"PageBackground":          template.CSS(rgbaString(h.opts.PageBackground)),
+		"EventMask":               h.opts.eventMask(),
+		"MouseCursorHidden":       h.opts.MouseCursorHidden,
+		"ContextMenuDisabled":     h.opts.ContextMenuDisabled,
+		"BrowserShortcutsEnabled": h.opts.BrowserShortcutsEnabled,
+		"ScaleToPageWidth":        h.opts.ScaleToPageWidth,
+		"ScaleToPageHeight":       h.opts.ScaleToPageHeight,
+		"ReconnectInterval":       int64(h.opts.ReconnectInterval / time.Millisecond),
+		"MaxFileSize":             h.opts.MaxFileSize,
+		"Replay":                  h.replay,
+		"ScriptVersion":           javaScriptVersion,
 	}
 	err := indexHTMLTemplate.Execute(w, model)
 	if err != nil {
@@ -104,8 +119,14 @@ func rgbaString(c color.Color) string {
 	return fmt.Sprintf("rgba(%d, %d, %d, %g)", clr.R, clr.G, clr.B, math.Round((float64(clr.A)/255)*100)/100)
 }
 
-func javaScriptHandler(w http.ResponseWriter, _ *http.Request) {
+func javaScriptHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/javascript")
+	if r.URL.Query().Get("v") == javaScriptVersion {
+		// The URL changes with the content of the file.
+		w.Header().Add("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Add("Cache-Control", "no-cache")
+	}
 	_, err := w.Write(javaScriptCode)
 	if err != nil {
 		log.Println(err)
@@ -124,47 +145,110 @@ type drawHandler struct {
 }
 
 func (h *drawHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, stats, err := upgrade(w, r, h.opts)
 	if err != nil {
 		log.Println(err)
 		return
 	}
+	defer conn.Close()
+	conn.SetReadLimit(readLimit(h.opts))
+
+	client, err := readHello(conn)
+	if err != nil {
+		return
+	}
+	if client.version != protocolVersion {
+		rejectClient(conn, client.version)
+		runRejected(h.draw, h.opts, client.version)
+		return
+	}
+
+	rec := newSessionRecorder(r, h.opts)
+	defer rec.close()
 
 	events := make(chan Event)
 	defer close(events)
 	draws := make(chan []byte)
 	defer close(draws)
+	snapshots := newSnapshotRequests()
 
-	wg := sync.WaitGroup{}
-	wg.Add(2)
-	go readMessages(conn, events, &wg)
-	go writeMessages(conn, draws, &wg)
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		readMessages(conn, events, snapshots, rec, h.opts.MaxFileSize)
+	}()
+	frames := newFramePool()
+	go writeMessages(conn, draws, frames, rec, stats, compressionThreshold(r, h.opts))
 
 	ctx := newContext(draws, events, h.opts)
+	ctx.snapshots = snapshots
+	ctx.stats = stats
+	ctx.frames = frames
+	if h.opts.Encoding == EncodingCompact && client.capabilities&capCompactEncoding != 0 {
+		ctx.useCompactEncoding()
+	}
+	drawDone := make(chan struct{})
 	go func() {
-		defer wg.Done()
+		defer close(drawDone)
 		h.draw(ctx)
 	}()
 
-	wg.Wait()
-	wg.Add(1)
+	// Reading ends when the client closes the connection or the
+	// connection fails.
+	<-readDone
 	events <- CloseEvent{}
-	wg.Wait()
+	<-drawDone
 }
 
-func writeMessages(conn *websocket.Conn, messages <-chan []byte, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for {
-		message := <-messages
-		err := conn.WriteMessage(websocket.BinaryMessage, message)
-		if err != nil {
-			break
+// messageAllowance is the size allowed for the parts of a message from
+// the client other than file contents or snapshot images, like file names
+// or pasted text.
+const messageAllowance = 1 << 20
+
+// readLimit returns the maximum size of a message from the client: an
+// event with file contents of Options.MaxFileSize in total, or the reply
+// to a snapshot request, plus messageAllowance. An encoded snapshot image
+// is assumed to be at most twice as large as the raw pixels of the canvas.
+func readLimit(opts *Options) int64 {
+	snapshot := 2 * 4 * int64(opts.Width) * int64(opts.Height)
+	return max(opts.MaxFileSize, snapshot) + messageAllowance
+}
+
+// writeMessages writes the messages to the connection until the messages
+// channel is closed, and returns their buffers to the frame pool
+// afterward. Messages of at least compressThreshold bytes are compressed,
+// no messages are compressed if compressThreshold is negative. After a
+// write error the remaining messages are discarded, so that flushing a
+// Context never blocks on a broken connection.
+func writeMessages(conn *websocket.Conn, messages <-chan []byte, frames *framePool, rec *sessionRecorder, stats *connStats, compressThreshold int) {
+	var err error
+	for message := range messages {
+		if err == nil {
+			err = writeMessage(conn, message, rec, stats, compressThreshold)
 		}
+		frames.put(message)
+	}
+}
+
+func writeMessage(conn *websocket.Conn, message []byte, rec *sessionRecorder, stats *connStats, compressThreshold int) error {
+	rec.record(recording.KindFrame, message)
+	compress := compressThreshold >= 0 && len(message) >= compressThreshold
+	conn.EnableWriteCompression(compress)
+	err := conn.WriteMessage(websocket.BinaryMessage, message)
+	if err == nil {
+		stats.addFrame(len(message), compress)
 	}
+	return err
 }
 
-func readMessages(conn *websocket.Conn, events chan<- Event, wg *sync.WaitGroup) {
-	defer wg.Done()
+// readMessages reads the messages from the connection until it fails, and
+// sends the events to the events channel. Like the connection itself, it
+// waits until an event is received before it reads the next message, so
+// that a slow run function slows down the client. Only while a snapshot
+// request is waiting for its reply, which must be read from the
+// connection, the events are queued.
+func readMessages(conn *websocket.Conn, events chan<- Event, snapshots *snapshotRequests, rec *sessionRecorder, maxFileSize int64) {
+	var queue []Event
 	for {
 		messageType, p, err := conn.ReadMessage()
 		if err != nil {
@@ -173,10 +257,38 @@ func readMessages(conn *websocket.Conn, events chan<- Event, wg *sync.WaitGroup)
 		if messageType != websocket.BinaryMessage {
 			continue
 		}
-		event, err := decodeEvent(p)
+		if len(p) > 0 && p[0] == msgSnapshot {
+			reply, err := decodeSnapshotReply(p)
+			if err == nil {
+				snapshots.deliver(reply)
+			}
+			queue = sendEvents(events, queue, snapshots)
+			continue
+		}
+		rec.record(recording.KindEvent, p)
+		event, err := decodeEvent(p, maxFileSize)
 		if err != nil {
 			continue
 		}
+		queue = sendEvents(events, append(queue, event), snapshots)
+	}
+	snapshots.close()
+	for _, event := range queue {
 		events <- event
 	}
 }
+
+// sendEvents sends the queued events in order until all are sent or a
+// snapshot request is waiting for its reply, and returns the events that
+// are still queued.
+func sendEvents(events chan<- Event, queue []Event, snapshots *snapshotRequests) []Event {
+	for len(queue) > 0 {
+		select {
+		case events <- queue[0]:
+			queue = queue[1:]
+		case <-snapshots.waiting():
+			return queue
+		}
+	}
+	return queue[:0]
+}
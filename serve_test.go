@@ -6,7 +6,13 @@ package canvas
 
 import (
 	"image/color"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
 )
 
 func TestRGBAString(t *testing.T) {
@@ -33,3 +39,136 @@ func TestRGBAString(t *testing.T) {
 		})
 	}
 }
+
+func TestHTMLHandler(t *testing.T) {
+	opts := &Options{
+		Title:             "Test",
+		Width:             640,
+		Height:            480,
+		EnabledEvents:     []Event{MouseDownEvent{}},
+		ReconnectInterval: 2 * time.Second,
+		MaxFileSize:       1024,
+	}
+	opts.applyDefaults()
+	h := &htmlHandler{opts: opts}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<title>Test</title>`,
+		`width="640" height="480"`,
+		`data-websocket-event-mask="2"`,
+		`data-websocket-reconnect-interval="2000"`,
+		`data-websocket-max-file-size="1024"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s in:\n%s", want, body)
+		}
+	}
+}
+
+func TestEncodingNegotiation(t *testing.T) {
+	tests := []struct {
+		name         string
+		encoding     Encoding
+		capabilities uint32
+		want         []byte
+	}{
+		{"standard", EncodingStandard, capCompactEncoding, []byte{bLineTo, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0, 0, 0, 0, 0}},
+		{"compact", EncodingCompact, capCompactEncoding, []byte{bCompact, bLineTo, 0x3f, 0x80, 0, 0, 0x40, 0, 0, 0}},
+		{"compact not supported by client", EncodingCompact, 0, []byte{bLineTo, 0x3f, 0xf0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+				ctx.LineTo(1, 2)
+				ctx.Flush()
+				ctx.LineTo(1, 2)
+				ctx.Flush()
+			}, &Options{Encoding: tt.encoding}))
+			defer srv.Close()
+			url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/draw"
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			sendHello(t, conn, tt.capabilities)
+			// Each frame is encoded independently.
+			for i := 0; i < 2; i++ {
+				got := readMessage(t, conn, websocket.BinaryMessage)
+				if diff := cmp.Diff(tt.want, got); diff != "" {
+					t.Errorf("frame %d: mismatch (-want, +got)\n%s", i, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	closed := make(chan Event, 1)
+	srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+		closed <- <-ctx.Events()
+	}, &Options{Width: 1, Height: 1, MaxFileSize: 1}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/draw"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sendHello(t, conn, 0)
+	large := make([]byte, 1+messageAllowance+16)
+	large[0] = evPaste
+	// The server may close the connection before the message is written.
+	_ = conn.WriteMessage(websocket.BinaryMessage, large)
+	select {
+	case event := <-closed:
+		if _, ok := event.(CloseEvent); !ok {
+			t.Errorf("got event %T, want CloseEvent", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection was not closed")
+	}
+}
+
+func TestOversizedFile(t *testing.T) {
+	events := make(chan Event, 1)
+	srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+		events <- <-ctx.Events()
+	}, &Options{Width: 1, Height: 1, MaxFileSize: 4}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/draw"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sendHello(t, conn, 0)
+	// The client claims to send the data of a file that is larger than
+	// MaxFileSize.
+	paste := []byte{
+		evPaste,
+		0x00, 0x00, 0x00, 0x00, // len(Text)
+		0x01,                   // len(Files)
+		0x00, 0x00, 0x00, 0x01, // len(Files[0].Name)
+		0x61,                   // Files[0].Name
+		0x00, 0x00, 0x00, 0x00, // len(Files[0].Type)
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, // Files[0].Size
+		0x01,                   // Files[0] has data
+		0x00, 0x00, 0x00, 0x08, // len(Files[0].Data)
+		1, 2, 3, 4, 5, 6, 7, 8, // Files[0].Data
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, paste); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		want := PasteEvent{Files: []File{{Name: "a", Size: 8}}}
+		if diff := cmp.Diff(want, event); diff != "" {
+			t.Errorf("mismatch (-want, +got)\n%s", diff)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+}
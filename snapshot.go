@@ -0,0 +1,164 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/fzipp/canvas/internal/protocol"
+)
+
+// ErrNotConnected is returned by Context.Snapshot if the Context is not
+// connected to a client, or if the connection was closed before the client
+// replied.
+var ErrNotConnected = errors.New("canvas: not connected to a client")
+
+// SnapshotError is returned by Context.Snapshot if the client could not
+// encode its canvas, for example because the canvas is too large.
+type SnapshotError struct {
+	// Reason is the reason given by the client.
+	Reason string
+}
+
+func (err *SnapshotError) Error() string {
+	return "canvas: snapshot failed: " + err.Reason
+}
+
+// Snapshot asks the client to encode the current content of its canvas as
+// an image, and returns the encoded image. Snapshot flushes the buffered
+// drawing operations before, so that they are included in the snapshot,
+// and blocks until the client replies.
+//
+// The image is encoded by the browser, with its own rendering of text and
+// anti-aliasing. The format is the MIME type of the image, for example
+// "image/png", "image/jpeg" or "image/webp". If format is empty, the image
+// is encoded as PNG. If the browser does not support the requested format,
+// it encodes the image as PNG instead; http.DetectContentType can be used
+// to find out the actual format. The quality is a number between 0 and 1
+// for lossy formats like JPEG and WebP. If it is outside of this range,
+// the default quality of the browser is used.
+//
+// Events received from the client while Snapshot waits for the reply are
+// queued and can be received from the Events channel afterwards. No
+// events are dropped.
+func (ctx *Context) Snapshot(format string, quality float64) ([]byte, error) {
+	if ctx.snapshots == nil {
+		return nil, ErrNotConnected
+	}
+	id, reply, ok := ctx.snapshots.add()
+	if !ok {
+		return nil, ErrNotConnected
+	}
+	ctx.buf.addByte(bSnapshot)
+	ctx.buf.addUint32(id)
+	ctx.buf.addString(format)
+	ctx.buf.addFloat64(quality)
+	ctx.Flush()
+	r, ok := <-reply
+	if !ok {
+		return nil, ErrNotConnected
+	}
+	if !r.ok {
+		return nil, &SnapshotError{Reason: string(r.data)}
+	}
+	return r.data, nil
+}
+
+// msgSnapshot is the type of the message with which the client replies to
+// a snapshot request. Unlike the event types it is not delivered as an
+// event.
+const msgSnapshot = protocol.MsgSnapshot
+
+// snapshotReply is the reply of the client to a snapshot request. The data
+// is the encoded image if ok is true, otherwise the reason of the failure.
+type snapshotReply struct {
+	id   uint32
+	ok   bool
+	data []byte
+}
+
+func decodeSnapshotReply(p []byte) (snapshotReply, error) {
+	buf := &buffer{bytes: p}
+	buf.readByte() // message type
+	r := snapshotReply{
+		id: buf.readUint32(),
+		ok: buf.readByte() != 0,
+	}
+	r.data = buf.readBytes(int(buf.readUint32()))
+	return r, buf.error
+}
+
+// snapshotRequests keeps track of the snapshot requests of a connection
+// that are waiting for a reply.
+type snapshotRequests struct {
+	mu      sync.Mutex
+	ids     idGenerator
+	pending map[uint32]chan snapshotReply
+	// busy is closed while requests are pending.
+	busy   chan struct{}
+	closed bool
+}
+
+func newSnapshotRequests() *snapshotRequests {
+	return &snapshotRequests{
+		pending: make(map[uint32]chan snapshotReply),
+		busy:    make(chan struct{}),
+	}
+}
+
+// waiting returns a channel that is closed while requests are waiting for
+// a reply.
+func (s *snapshotRequests) waiting() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.busy
+}
+
+// add registers a new request. The returned channel receives the reply,
+// or is closed without a reply if the connection is closed. If the
+// connection is already closed, add reports false.
+func (s *snapshotRequests) add() (id uint32, reply <-chan snapshotReply, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, nil, false
+	}
+	ch := make(chan snapshotReply, 1)
+	id = s.ids.generateID()
+	if len(s.pending) == 0 {
+		close(s.busy)
+	}
+	s.pending[id] = ch
+	return id, ch, true
+}
+
+// deliver passes a reply to the waiting request. Replies to unknown
+// requests are ignored.
+func (s *snapshotRequests) deliver(r snapshotReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.pending[r.id]
+	if !ok {
+		return
+	}
+	delete(s.pending, r.id)
+	if len(s.pending) == 0 {
+		s.busy = make(chan struct{})
+	}
+	ch <- r
+}
+
+// close closes the channels of all waiting requests. Requests cannot be
+// added afterwards.
+func (s *snapshotRequests) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+	s.closed = true
+}
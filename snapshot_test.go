@@ -0,0 +1,175 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+)
+
+type snapshotResult struct {
+	data []byte
+	err  error
+}
+
+// snapshotServer starts a server whose run function fills a rectangle,
+// takes a snapshot and reports the result, and then reports the next
+// received event.
+func snapshotServer(t *testing.T) (*httptest.Server, <-chan snapshotResult, <-chan Event) {
+	t.Helper()
+	results := make(chan snapshotResult, 1)
+	events := make(chan Event, 1)
+	srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+		ctx.FillRect(1, 2, 3, 4)
+		data, err := ctx.Snapshot("image/jpeg", 0.5)
+		results <- snapshotResult{data: data, err: err}
+		events <- <-ctx.Events()
+	}, &Options{EnabledEvents: []Event{MouseDownEvent{}}}))
+	t.Cleanup(srv.Close)
+	return srv, results, events
+}
+
+// readSnapshotRequest reads the frame with the snapshot request and
+// returns the ID of the request.
+func readSnapshotRequest(t *testing.T, conn *websocket.Conn) uint32 {
+	t.Helper()
+	frame := readMessage(t, conn, websocket.BinaryMessage)
+	const fillRectLen = 1 + 4*8
+	want := []byte{bSnapshot}
+	want = binary.BigEndian.AppendUint32(want, 0)
+	want = binary.BigEndian.AppendUint32(want, uint32(len("image/jpeg")))
+	want = append(want, "image/jpeg"...)
+	want = binary.BigEndian.AppendUint64(want, 0x3fe0000000000000) // 0.5
+	if len(frame) < fillRectLen || !bytes.Equal(frame[fillRectLen:], want) {
+		t.Fatalf("got frame %v, want FillRect followed by %v", frame, want)
+	}
+	return binary.BigEndian.Uint32(frame[fillRectLen+1:])
+}
+
+func snapshotReplyMessage(id uint32, ok bool, data []byte) []byte {
+	p := []byte{msgSnapshot}
+	p = binary.BigEndian.AppendUint32(p, id)
+	if ok {
+		p = append(p, 1)
+	} else {
+		p = append(p, 0)
+	}
+	p = binary.BigEndian.AppendUint32(p, uint32(len(data)))
+	return append(p, data...)
+}
+
+func TestSnapshot(t *testing.T) {
+	srv, results, events := snapshotServer(t)
+	conn := dial(t, srv, "/draw")
+	defer conn.Close()
+
+	id := readSnapshotRequest(t, conn)
+	// An event that is sent before the reply must not block the reply.
+	mouseDown := []byte{evMouseDown, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0}
+	if err := conn.WriteMessage(websocket.BinaryMessage, mouseDown); err != nil {
+		t.Fatal(err)
+	}
+	image := []byte{0xff, 0xd8, 0xff, 0xe0}
+	if err := conn.WriteMessage(websocket.BinaryMessage, snapshotReplyMessage(id, true, image)); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-results
+	if got.err != nil {
+		t.Fatalf("unexpected error: %v", got.err)
+	}
+	if !bytes.Equal(got.data, image) {
+		t.Errorf("got snapshot %v, want %v", got.data, image)
+	}
+	want := MouseDownEvent{MouseEvent{X: 1, Y: 2}}
+	if diff := cmp.Diff(want, <-events); diff != "" {
+		t.Errorf("event mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestSnapshotQueuesEvents(t *testing.T) {
+	const n = 2000
+	results := make(chan snapshotResult, 1)
+	received := make(chan []Event, 1)
+	srv := httptest.NewServer(NewServeMux(func(ctx *Context) {
+		ctx.FillRect(1, 2, 3, 4)
+		data, err := ctx.Snapshot("image/jpeg", 0.5)
+		results <- snapshotResult{data: data, err: err}
+		events := make([]Event, n)
+		for i := range events {
+			events[i] = <-ctx.Events()
+		}
+		received <- events
+	}, &Options{EnabledEvents: []Event{MouseDownEvent{}}}))
+	defer srv.Close()
+	conn := dial(t, srv, "/draw")
+	defer conn.Close()
+
+	id := readSnapshotRequest(t, conn)
+	var want []Event
+	for i := range n {
+		mouseDown := []byte{evMouseDown, 0, 0, 0, byte(i >> 8), byte(i), 0, 0, 0, 0, 0}
+		if err := conn.WriteMessage(websocket.BinaryMessage, mouseDown); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, MouseDownEvent{MouseEvent{X: i}})
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, snapshotReplyMessage(id, true, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-results; got.err != nil {
+		t.Fatalf("unexpected error: %v", got.err)
+	}
+	if diff := cmp.Diff(want, <-received); diff != "" {
+		t.Errorf("event mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestSnapshotFailed(t *testing.T) {
+	srv, results, _ := snapshotServer(t)
+	conn := dial(t, srv, "/draw")
+	defer conn.Close()
+
+	id := readSnapshotRequest(t, conn)
+	reply := snapshotReplyMessage(id, false, []byte("canvas could not be encoded"))
+	if err := conn.WriteMessage(websocket.BinaryMessage, reply); err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-results
+	var snapshotErr *SnapshotError
+	if !errors.As(got.err, &snapshotErr) {
+		t.Fatalf("got error %v, want %T", got.err, snapshotErr)
+	}
+	if snapshotErr.Reason != "canvas could not be encoded" {
+		t.Errorf("got reason %q, want %q", snapshotErr.Reason, "canvas could not be encoded")
+	}
+}
+
+func TestSnapshotConnectionClosed(t *testing.T) {
+	srv, results, _ := snapshotServer(t)
+	conn := dial(t, srv, "/draw")
+	readSnapshotRequest(t, conn)
+	conn.Close()
+
+	got := <-results
+	if got.err != ErrNotConnected {
+		t.Errorf("got error %v, want %v", got.err, ErrNotConnected)
+	}
+}
+
+func TestSnapshotNotConnected(t *testing.T) {
+	ctx := NewContext(make(chan []byte), nil, nil)
+	if _, err := ctx.Snapshot("", 0); err != ErrNotConnected {
+		t.Errorf("got error %v, want %v", err, ErrNotConnected)
+	}
+}
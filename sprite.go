@@ -0,0 +1,63 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+// SpriteInstance describes one placement of an image that is drawn with
+// Context.DrawImageInstances.
+//
+// Unlike most zero values, the zero value of SpriteInstance is not useful:
+// its Scale of 0 draws the image at size zero, and its Alpha of 0 draws it
+// fully transparent, so it draws nothing. Scale and Alpha are usually set
+// to 1, like in SpriteInstance{X: 10, Y: 20, Scale: 1, Alpha: 1}. A zero
+// Alpha is useful to hide an instance, for example at the end of a
+// fade-out, without removing it from the slice.
+type SpriteInstance struct {
+	// X and Y are the position of the center of the drawn image.
+	X, Y float64
+	// Scale is the factor by which the source rectangle is scaled.
+	// A scale of 1 draws the image in its original size.
+	Scale float64
+	// Rotation is the clockwise rotation of the image around its center
+	// in radians.
+	Rotation float64
+	// Alpha is the transparency of the image, a number between 0.0 (fully
+	// transparent) and 1.0 (fully opaque). It is multiplied with the global
+	// alpha value of the Context.
+	Alpha float64
+	// SX, SY, SWidth and SHeight are the sub-rectangle of the source image
+	// to draw, for example a single frame of a sprite sheet. If SWidth or
+	// SHeight is zero, the whole image is drawn.
+	SX, SY, SWidth, SHeight float64
+}
+
+// DrawImageInstances draws the image once for each of the instances, with
+// their individual position, scale, rotation, transparency and source
+// rectangle. The instances are drawn in order, on top of each other.
+//
+// It is equivalent to a sequence of DrawImageSubRectangle calls with
+// according transformations and global alpha values, but the client draws
+// all instances in one loop, which is much faster for large numbers of
+// sprites like in particle systems. The current transformation and the
+// global alpha value of the Context are unchanged afterward.
+func (ctx *Context) DrawImageInstances(src *ImageData, instances []SpriteInstance) {
+	src.checkUseAfterRelease()
+	if len(instances) == 0 {
+		return
+	}
+	ctx.buf.addByte(bDrawImageInstances)
+	ctx.buf.addUint32(src.id)
+	ctx.buf.addUint32(uint32(len(instances)))
+	for _, in := range instances {
+		ctx.buf.addFloat64(in.X)
+		ctx.buf.addFloat64(in.Y)
+		ctx.buf.addFloat64(in.Scale)
+		ctx.buf.addFloat64(in.Rotation)
+		ctx.buf.addFloat64(in.Alpha)
+		ctx.buf.addFloat64(in.SX)
+		ctx.buf.addFloat64(in.SY)
+		ctx.buf.addFloat64(in.SWidth)
+		ctx.buf.addFloat64(in.SHeight)
+	}
+}
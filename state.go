@@ -0,0 +1,122 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"image/color"
+	"slices"
+)
+
+// drawingState is the drawing state of the client canvas as far as it is
+// known to the Context. It is used to skip setters that would not change
+// the state, for example when a program sets the same fill style for
+// every frame.
+//
+// A value is only known after it was set by the Context, the initial state
+// of the client canvas is not assumed. The state records the last value
+// that was sent to the client, even if the client ignores it as invalid
+// (for example a negative line width): sending the same value again would
+// be ignored as well.
+type drawingState struct {
+	fillStyle                knownValue[style]
+	strokeStyle              knownValue[style]
+	font                     knownValue[string]
+	globalAlpha              knownValue[float64]
+	globalCompositeOperation knownValue[CompositeOperation]
+	imageSmoothingEnabled    knownValue[bool]
+	lineCap                  knownValue[LineCap]
+	lineDash                 knownLineDash
+	lineDashOffset           knownValue[float64]
+	lineJoin                 knownValue[LineJoin]
+	lineWidth                knownValue[float64]
+	miterLimit               knownValue[float64]
+	shadowBlur               knownValue[float64]
+	shadowColor              knownValue[style]
+	shadowOffsetX            knownValue[float64]
+	shadowOffsetY            knownValue[float64]
+	textAlign                knownValue[TextAlign]
+	textBaseline             knownValue[TextBaseline]
+}
+
+// knownValue is a value of the drawing state.
+type knownValue[T comparable] struct {
+	value T
+	known bool
+}
+
+// set sets the value and reports whether it changed, i.e. whether the
+// setter has to be sent to the client.
+func (v *knownValue[T]) set(value T) bool {
+	if v.known && v.value == value {
+		return false
+	}
+	v.value, v.known = value, true
+	return true
+}
+
+// forget marks the value as unknown.
+func (v *knownValue[T]) forget() {
+	*v = knownValue[T]{}
+}
+
+// knownLineDash is the line dash pattern of the drawing state.
+type knownLineDash struct {
+	segments []float64
+	known    bool
+}
+
+// set sets the line dash pattern and reports whether it changed.
+func (v *knownLineDash) set(segments []float64) bool {
+	if v.known && slices.Equal(v.segments, segments) {
+		return false
+	}
+	v.segments, v.known = slices.Clone(segments), true
+	return true
+}
+
+// style is a fill, stroke or shadow color, either given as color.Color or
+// as CSS color string. The same color given in both ways is treated as
+// a change. Gradients and patterns are not tracked, because their IDs
+// are reused after they are released.
+type style struct {
+	rgba  color.RGBA
+	css   string
+	isCSS bool
+}
+
+func colorStyle(c color.Color) style {
+	return style{rgba: color.RGBAModel.Convert(c).(color.RGBA)}
+}
+
+func cssStyle(color string) style {
+	return style{css: color, isCSS: true}
+}
+
+// skipUnchanged removes the setter command that was added to the buffer at
+// start if it did not change the drawing state. The removed bytes are
+// counted for Options.DebugRedundantState.
+func (ctx *Context) skipUnchanged(start int, changed bool) {
+	if changed {
+		return
+	}
+	ctx.skippedBytes += len(ctx.buf.bytes) - start
+	ctx.buf.bytes = ctx.buf.bytes[:start]
+}
+
+// saveState pushes the current drawing state onto the state stack, like
+// the client does on Save.
+func (ctx *Context) saveState() {
+	ctx.stateStack = append(ctx.stateStack, ctx.state)
+}
+
+// restoreState pops the drawing state from the state stack, like the
+// client does on Restore. If the stack is empty, the state is unchanged.
+func (ctx *Context) restoreState() {
+	if len(ctx.stateStack) == 0 {
+		return
+	}
+	ctx.state = ctx.stateStack[len(ctx.stateStack)-1]
+	ctx.stateStack = ctx.stateStack[:len(ctx.stateStack)-1]
+}
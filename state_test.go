@@ -0,0 +1,170 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package canvas
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRedundantStateElimination(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	tests := []struct {
+		name string
+		draw func(ctx *Context)
+		// want draws the same without redundant setters.
+		want func(ctx *Context)
+	}{
+		{
+			"repeated setters",
+			func(ctx *Context) {
+				ctx.SetFillStyle(red)
+				ctx.SetLineWidth(2)
+				ctx.FillRect(1, 2, 3, 4)
+				ctx.SetFillStyle(color.NRGBA{R: 0xff, A: 0xff})
+				ctx.SetLineWidth(2)
+				ctx.SetFont("12px serif")
+				ctx.SetFont("12px serif")
+				ctx.SetLineDash([]float64{1, 2})
+				ctx.SetLineDash([]float64{1, 2})
+				ctx.SetTextAlign(AlignCenter)
+				ctx.SetTextAlign(AlignCenter)
+			},
+			func(ctx *Context) {
+				ctx.SetFillStyle(red)
+				ctx.SetLineWidth(2)
+				ctx.FillRect(1, 2, 3, 4)
+				ctx.SetFont("12px serif")
+				ctx.SetLineDash([]float64{1, 2})
+				ctx.SetTextAlign(AlignCenter)
+			},
+		},
+		{
+			"changed values",
+			func(ctx *Context) {
+				ctx.SetLineWidth(2)
+				ctx.SetLineWidth(3)
+				ctx.SetLineWidth(2)
+				ctx.SetLineDash([]float64{1, 2})
+				ctx.SetLineDash([]float64{1, 2, 3})
+			},
+			func(ctx *Context) {
+				ctx.SetLineWidth(2)
+				ctx.SetLineWidth(3)
+				ctx.SetLineWidth(2)
+				ctx.SetLineDash([]float64{1, 2})
+				ctx.SetLineDash([]float64{1, 2, 3})
+			},
+		},
+		{
+			"color and CSS color string",
+			func(ctx *Context) {
+				ctx.SetStrokeStyle(red)
+				ctx.SetStrokeStyleString("red")
+				ctx.SetStrokeStyle(red)
+				ctx.SetShadowColorString("red")
+				ctx.SetShadowColorString("red")
+			},
+			func(ctx *Context) {
+				ctx.SetStrokeStyle(red)
+				ctx.SetStrokeStyleString("red")
+				ctx.SetStrokeStyle(red)
+				ctx.SetShadowColorString("red")
+			},
+		},
+		{
+			"gradients and patterns",
+			func(ctx *Context) {
+				g := &Gradient{id: 1, ctx: ctx}
+				ctx.SetFillStyle(red)
+				ctx.SetFillStyleGradient(g)
+				ctx.SetFillStyleGradient(g)
+				ctx.SetFillStyle(red)
+			},
+			func(ctx *Context) {
+				g := &Gradient{id: 1, ctx: ctx}
+				ctx.SetFillStyle(red)
+				ctx.SetFillStyleGradient(g)
+				ctx.SetFillStyleGradient(g)
+				ctx.SetFillStyle(red)
+			},
+		},
+		{
+			"save and restore",
+			func(ctx *Context) {
+				ctx.SetGlobalAlpha(0.5)
+				ctx.Save()
+				ctx.SetGlobalAlpha(0.5)
+				ctx.SetGlobalAlpha(1)
+				ctx.Restore()
+				ctx.SetGlobalAlpha(1)
+				ctx.SetGlobalAlpha(0.5)
+				// Restore without Save does not change the state.
+				ctx.Restore()
+				ctx.SetGlobalAlpha(0.5)
+			},
+			func(ctx *Context) {
+				ctx.SetGlobalAlpha(0.5)
+				ctx.Save()
+				ctx.SetGlobalAlpha(1)
+				ctx.Restore()
+				ctx.SetGlobalAlpha(1)
+				ctx.SetGlobalAlpha(0.5)
+				ctx.Restore()
+			},
+		},
+		{
+			"state across frames",
+			func(ctx *Context) {
+				ctx.SetMiterLimit(4)
+				ctx.Flush()
+				ctx.SetMiterLimit(4)
+			},
+			func(ctx *Context) {
+				ctx.SetMiterLimit(4)
+				ctx.Flush()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := drawFrames(tt.want)
+			got := drawFrames(tt.draw)
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("mismatch (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedundantStateSkippedBytes(t *testing.T) {
+	ctx := newContext(nil, nil, nil)
+	ctx.SetLineWidth(2)
+	ctx.SetLineWidth(2)
+	ctx.SetFont("bold 12px serif")
+	ctx.SetFont("bold 12px serif")
+	want := (1 + 8) + (1 + 4 + len("bold 12px serif"))
+	if ctx.skippedBytes != want {
+		t.Errorf("got %d skipped bytes, want %d", ctx.skippedBytes, want)
+	}
+}
+
+// drawFrames returns the frames flushed by draw, including a final flush.
+func drawFrames(draw func(ctx *Context)) [][]byte {
+	draws := make(chan []byte)
+	ctx := newContext(draws, nil, nil)
+	go func() {
+		draw(ctx)
+		ctx.Flush()
+		close(draws)
+	}()
+	var frames [][]byte
+	for frame := range draws {
+		frames = append(frames, frame)
+	}
+	return frames
+}
@@ -0,0 +1,30 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"strings"
+
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// pathData returns the path data for the d attribute of an SVG path
+// element, with the points transformed by m.
+func pathData(p *geom.Path, m geom.Matrix) string {
+	var sb strings.Builder
+	for _, seg := range p.Segments {
+		sb.WriteByte(seg.Op)
+		for i, q := range seg.Points {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			q = m.Apply(q)
+			sb.WriteString(fmtNumber(q.X))
+			sb.WriteByte(' ')
+			sb.WriteString(fmtNumber(q.Y))
+		}
+	}
+	return sb.String()
+}
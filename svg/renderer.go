@@ -0,0 +1,977 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package svg converts the draw commands of a canvas.Context into an SVG
+// document, for example to export a chart as a vector graphics file:
+//
+//	doc := svg.Draw(300, 150, func(ctx *canvas.Context) {
+//		ctx.SetFillStyle(color.RGBA{R: 0xff, A: 0xff})
+//		ctx.FillRect(10, 10, 50, 20)
+//	})
+//	err := os.WriteFile("chart.svg", doc, 0o644)
+//
+// The root svg element has the size of the canvas as its width, height
+// and viewBox. Every fill and stroke appends a path element, every text
+// command a text element with the canvas font as CSS font property, so
+// the viewer lays out the text. The current transformation becomes the
+// transform attribute of the element, global alpha its opacity, and the
+// blend modes of canvas.CompositeOperation its CSS mix-blend-mode.
+// Gradients, patterns and clipping regions are defined once in the defs
+// section and referenced by the elements that use them. Images are
+// embedded in the defs section as PNG data URIs.
+//
+// SVG elements are painted over each other and cannot erase or read what
+// is below them. Therefore the Porter-Duff composite operations other
+// than source-over are drawn as source-over, PutImageData draws over the
+// existing content, and ClearRect only has an effect if it covers the
+// whole canvas, in which case the elements drawn before are discarded.
+// Image data retrieved with GetImageData is transparent. Shadows, the
+// maximum width of text and images loaded from URLs with
+// Context.LoadImage are ignored.
+package svg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	// The image formats are registered for decoding the images of
+	// CreateImageFromEncoded commands.
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/fzipp/canvas/internal/drawing"
+	"github.com/fzipp/canvas/internal/geom"
+)
+
+// Draw calls the draw function with a canvas.Context of the given size and
+// returns an SVG document with everything it drew, whether it was flushed
+// or not.
+func Draw(width, height int, draw func(ctx *canvas.Context)) []byte {
+	r := NewRenderer(width, height)
+	for _, cmds := range drawing.Frames(width, height, draw) {
+		r.Render(cmds...)
+	}
+	var buf bytes.Buffer
+	_, _ = r.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// Renderer converts draw commands into the elements of an SVG document.
+// It keeps the drawing state, the current path and the created images,
+// gradients and patterns across calls, like a canvas in a web browser
+// keeps them across flushed frames. A Renderer must be created with
+// NewRenderer.
+type Renderer struct {
+	width  int
+	height int
+
+	defs bytes.Buffer
+	body bytes.Buffer
+	// nextID is used to create unique IDs for the elements in defs.
+	nextID int
+
+	state state
+	stack []state
+	path  geom.Path
+
+	images    map[uint32]*imageDef
+	gradients map[uint32]*gradient
+	patterns  map[uint32]*pattern
+}
+
+type state struct {
+	transform   geom.Matrix
+	fillStyle   style
+	strokeStyle style
+	lineWidth   float64
+	lineCap     canvas.LineCap
+	lineJoin    canvas.LineJoin
+	miterLimit  float64
+	lineDash    []float64
+	dashOffset  float64
+	globalAlpha float64
+	compositeOp canvas.CompositeOperation
+	smoothing   bool
+	font        string
+	textAlign   canvas.TextAlign
+	baseline    canvas.TextBaseline
+	// clipID is the ID of the clipPath element of the clipping region,
+	// or empty if there is no clipping region.
+	clipID string
+}
+
+func defaultState() state {
+	black := style{color: "#000000", opacity: 1}
+	return state{
+		transform:   geom.Identity,
+		fillStyle:   black,
+		strokeStyle: black,
+		lineWidth:   1,
+		miterLimit:  10,
+		globalAlpha: 1,
+		smoothing:   true,
+		font:        "10px sans-serif",
+	}
+}
+
+// A style is a fill or stroke style: a color, a gradient or a pattern.
+type style struct {
+	color    string
+	opacity  float64
+	gradient *gradient
+	pattern  *pattern
+}
+
+type colorStop struct {
+	offset  float64
+	color   string
+	opacity float64
+}
+
+type gradient struct {
+	radial bool
+	p0, p1 geom.Point
+	r0, r1 float64
+	stops  []colorStop
+}
+
+type pattern struct {
+	image      *imageDef
+	repetition canvas.PatternRepetition
+}
+
+// An imageDef is an image element in the defs section of the document.
+type imageDef struct {
+	id            string
+	width, height int
+	// img is kept for patching the image with UpdateImageData commands.
+	img *image.NRGBA
+}
+
+// NewRenderer creates a Renderer for an SVG document with a canvas of the
+// given size.
+func NewRenderer(width, height int) *Renderer {
+	return &Renderer{
+		width:     max(width, 0),
+		height:    max(height, 0),
+		state:     defaultState(),
+		images:    make(map[uint32]*imageDef),
+		gradients: make(map[uint32]*gradient),
+		patterns:  make(map[uint32]*pattern),
+	}
+}
+
+// WriteTo writes the SVG document with the elements created so far to w.
+func (r *Renderer) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		r.width, r.height, r.width, r.height)
+	if r.defs.Len() > 0 {
+		buf.WriteString("<defs>\n")
+		buf.Write(r.defs.Bytes())
+		buf.WriteString("</defs>\n")
+	}
+	buf.Write(r.body.Bytes())
+	buf.WriteString("</svg>\n")
+	return buf.WriteTo(w)
+}
+
+// RenderFrame decodes a frame in the binary draw command format, as sent
+// by canvas.Context.Flush, and renders its commands. If the frame cannot
+// be decoded completely, the commands before the error are rendered, and
+// the error is returned.
+func (r *Renderer) RenderFrame(frame []byte) error {
+	cmds, err := command.Decode(frame)
+	r.Render(cmds...)
+	return err
+}
+
+// Render renders the given commands.
+func (r *Renderer) Render(cmds ...command.Command) {
+	for _, cmd := range cmds {
+		r.render(cmd)
+	}
+}
+
+func (r *Renderer) render(cmd command.Command) {
+	s := &r.state
+	m := s.transform
+	switch c := cmd.(type) {
+	// Paths
+	case command.BeginPath:
+		r.path.Reset()
+	case command.ClosePath:
+		r.path.ClosePath()
+	case command.MoveTo:
+		r.path.MoveTo(m.Apply(geom.Pt(c.X, c.Y)))
+	case command.LineTo:
+		r.path.LineTo(m.Apply(geom.Pt(c.X, c.Y)))
+	case command.QuadraticCurveTo:
+		r.path.QuadraticCurveTo(m.Apply(geom.Pt(c.CPX, c.CPY)), m.Apply(geom.Pt(c.X, c.Y)))
+	case command.BezierCurveTo:
+		r.path.BezierCurveTo(m.Apply(geom.Pt(c.CP1X, c.CP1Y)), m.Apply(geom.Pt(c.CP2X, c.CP2Y)), m.Apply(geom.Pt(c.X, c.Y)))
+	case command.Arc:
+		if c.Radius >= 0 {
+			r.path.Ellipse(m, geom.Pt(c.X, c.Y), c.Radius, c.Radius, 0, c.StartAngle, c.EndAngle, c.Anticlockwise)
+		}
+	case command.ArcTo:
+		if c.Radius >= 0 {
+			r.path.ArcTo(m, geom.Pt(c.X1, c.Y1), geom.Pt(c.X2, c.Y2), c.Radius)
+		}
+	case command.Ellipse:
+		if c.RadiusX >= 0 && c.RadiusY >= 0 {
+			r.path.Ellipse(m, geom.Pt(c.X, c.Y), c.RadiusX, c.RadiusY, c.Rotation, c.StartAngle, c.EndAngle, c.Anticlockwise)
+		}
+	case command.Rect:
+		r.path.Rect(m, c.X, c.Y, c.Width, c.Height)
+	case command.Polyline:
+		r.path.Polyline(m, c.Points, false)
+	case command.Polygon:
+		r.path.Polyline(m, c.Points, true)
+	case command.Points:
+		if c.Radius >= 0 {
+			r.path.Circles(m, c.Points, c.Radius)
+		}
+
+	// Drawing
+	case command.Fill:
+		r.fill(&r.path)
+	case command.Stroke:
+		r.stroke(&r.path)
+	case command.Clip:
+		r.clip()
+	case command.FillRect:
+		r.fill(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+	case command.StrokeRect:
+		r.stroke(geom.RectPath(m, c.X, c.Y, c.Width, c.Height))
+	case command.ClearRect:
+		r.clearRect(c.X, c.Y, c.Width, c.Height)
+	case command.FillText:
+		r.text(c.X, c.Y, c.Text, false)
+	case command.FillTextMaxWidth:
+		r.text(c.X, c.Y, c.Text, false)
+	case command.StrokeText:
+		r.text(c.X, c.Y, c.Text, true)
+	case command.StrokeTextMaxWidth:
+		r.text(c.X, c.Y, c.Text, true)
+
+	// Styles
+	case command.SetFillStyle:
+		s.fillStyle = colorStyle(c.Color)
+	case command.SetStrokeStyle:
+		s.strokeStyle = colorStyle(c.Color)
+	case command.SetFillStyleString:
+		s.fillStyle = style{color: c.Color, opacity: 1}
+	case command.SetStrokeStyleString:
+		s.strokeStyle = style{color: c.Color, opacity: 1}
+	case command.SetFillStyleGradient:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			s.fillStyle = style{gradient: g}
+		}
+	case command.SetStrokeStyleGradient:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			s.strokeStyle = style{gradient: g}
+		}
+	case command.SetFillStylePattern:
+		if p, ok := r.patterns[c.PatternID]; ok {
+			s.fillStyle = style{pattern: p}
+		}
+	case command.SetStrokeStylePattern:
+		if p, ok := r.patterns[c.PatternID]; ok {
+			s.strokeStyle = style{pattern: p}
+		}
+	case command.SetLineWidth:
+		if validPositive(c.Width) {
+			s.lineWidth = c.Width
+		}
+	case command.SetLineCap:
+		s.lineCap = c.Cap
+	case command.SetLineJoin:
+		s.lineJoin = c.Join
+	case command.SetMiterLimit:
+		if validPositive(c.Value) {
+			s.miterLimit = c.Value
+		}
+	case command.SetLineDash:
+		if dash, ok := normalizeDash(c.Segments); ok {
+			s.lineDash = dash
+		}
+	case command.SetLineDashOffset:
+		if !math.IsNaN(c.Offset) && !math.IsInf(c.Offset, 0) {
+			s.dashOffset = c.Offset
+		}
+	case command.SetGlobalAlpha:
+		if c.Alpha >= 0 && c.Alpha <= 1 {
+			s.globalAlpha = c.Alpha
+		}
+	case command.SetGlobalCompositeOperation:
+		s.compositeOp = c.Mode
+	case command.SetImageSmoothingEnabled:
+		s.smoothing = c.Enabled
+	case command.SetFont:
+		s.font = c.Font
+	case command.SetTextAlign:
+		s.textAlign = c.Align
+	case command.SetTextBaseline:
+		s.baseline = c.Baseline
+
+	// State and transformations
+	case command.Save:
+		r.stack = append(r.stack, r.state)
+	case command.Restore:
+		if len(r.stack) > 0 {
+			r.state = r.stack[len(r.stack)-1]
+			r.stack = r.stack[:len(r.stack)-1]
+		}
+	case command.Scale:
+		s.transform = m.Multiply(geom.Matrix{A: c.X, D: c.Y})
+	case command.Rotate:
+		sin, cos := math.Sincos(c.Angle)
+		s.transform = m.Multiply(geom.Matrix{A: cos, B: sin, C: -sin, D: cos})
+	case command.Translate:
+		s.transform = m.Multiply(geom.Matrix{A: 1, D: 1, E: c.X, F: c.Y})
+	case command.Transform:
+		s.transform = m.Multiply(geom.Matrix{A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, F: c.F})
+	case command.SetTransform:
+		s.transform = geom.Matrix{A: c.A, B: c.B, C: c.C, D: c.D, E: c.E, F: c.F}
+
+	// Gradients and patterns
+	case command.CreateLinearGradient:
+		r.gradients[c.ID] = &gradient{
+			p0: geom.Pt(c.X0, c.Y0),
+			p1: geom.Pt(c.X1, c.Y1),
+		}
+	case command.CreateRadialGradient:
+		if c.R0 >= 0 && c.R1 >= 0 {
+			r.gradients[c.ID] = &gradient{
+				radial: true,
+				p0:     geom.Pt(c.X0, c.Y0),
+				r0:     c.R0,
+				p1:     geom.Pt(c.X1, c.Y1),
+				r1:     c.R1,
+			}
+		}
+	case command.GradientAddColorStop:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			st := colorStyle(c.Color)
+			g.addColorStop(colorStop{offset: c.Offset, color: st.color, opacity: st.opacity})
+		}
+	case command.GradientAddColorStopString:
+		if g, ok := r.gradients[c.GradientID]; ok {
+			g.addColorStop(colorStop{offset: c.Offset, color: c.Color, opacity: 1})
+		}
+	case command.ReleaseGradient:
+		delete(r.gradients, c.GradientID)
+	case command.CreatePattern:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.patterns[c.ID] = &pattern{image: img, repetition: c.Repetition}
+		}
+	case command.ReleasePattern:
+		delete(r.patterns, c.PatternID)
+
+	// Images
+	case command.CreateImageData:
+		r.images[c.ID] = r.imageDef(&image.NRGBA{
+			Pix:    c.Pix,
+			Stride: 4 * c.Width,
+			Rect:   image.Rect(0, 0, c.Width, c.Height),
+		})
+	case command.CreateImageFromEncoded:
+		// Like a web browser, images in unsupported formats are not
+		// drawn.
+		if img, err := c.Image(); err == nil {
+			r.images[c.ID] = r.imageDef(img)
+		}
+	case command.GetImageData:
+		w := int(math.Ceil(math.Abs(c.SW)))
+		h := int(math.Ceil(math.Abs(c.SH)))
+		r.images[c.ID] = r.imageDef(image.NewNRGBA(image.Rect(0, 0, w, h)))
+	case command.UpdateImageData:
+		// The patched image is a new definition, the image drawn before
+		// keeps its content.
+		if def, ok := r.images[c.ImageID]; ok {
+			r.images[c.ImageID] = r.imageDef(c.Patch(def.img))
+		}
+	case command.ReleaseImageData:
+		delete(r.images, c.ImageID)
+	case command.PutImageData:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.putImageData(img, c.DX, c.DY, 0, 0, float64(img.width), float64(img.height))
+		}
+	case command.PutImageDataDirty:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.putImageData(img, c.DX, c.DY, c.DirtyX, c.DirtyY, c.DirtyWidth, c.DirtyHeight)
+		}
+	case command.DrawImage:
+		if img, ok := r.images[c.ImageID]; ok {
+			w, h := float64(img.width), float64(img.height)
+			r.drawImage(img, 0, 0, w, h, c.DX, c.DY, w, h)
+		}
+	case command.DrawImageScaled:
+		if img, ok := r.images[c.ImageID]; ok {
+			w, h := float64(img.width), float64(img.height)
+			r.drawImage(img, 0, 0, w, h, c.DX, c.DY, c.DWidth, c.DHeight)
+		}
+	case command.DrawImageSubRectangle:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.drawImage(img, c.SX, c.SY, c.SWidth, c.SHeight, c.DX, c.DY, c.DWidth, c.DHeight)
+		}
+	case command.DrawImageInstances:
+		if img, ok := r.images[c.ImageID]; ok {
+			r.drawImageInstances(img, c.Instances)
+		}
+	}
+	// Shadows and the text input rectangle have no SVG equivalent.
+}
+
+func validPositive(v float64) bool {
+	return v > 0 && !math.IsInf(v, 0)
+}
+
+func normalizeDash(segments []float64) ([]float64, bool) {
+	var total float64
+	for _, l := range segments {
+		if l < 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+			return nil, false
+		}
+		total += l
+	}
+	if total == 0 {
+		return nil, true
+	}
+	if len(segments)%2 == 1 {
+		return append(segments[:len(segments):len(segments)], segments...), true
+	}
+	return segments, true
+}
+
+func colorStyle(c color.RGBA) style {
+	return style{
+		color:   fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B),
+		opacity: float64(c.A) / 255,
+	}
+}
+
+func (g *gradient) addColorStop(stop colorStop) {
+	if stop.offset < 0 || stop.offset > 1 || math.IsNaN(stop.offset) {
+		return
+	}
+	// Stops with equal offsets keep their insertion order, like in
+	// canvas gradients.
+	i := len(g.stops)
+	for i > 0 && g.stops[i-1].offset > stop.offset {
+		i--
+	}
+	g.stops = append(g.stops, colorStop{})
+	copy(g.stops[i+1:], g.stops[i:])
+	g.stops[i] = stop
+}
+
+func (r *Renderer) newID(prefix string) string {
+	id := prefix + strconv.Itoa(r.nextID)
+	r.nextID++
+	return id
+}
+
+func (r *Renderer) fill(p *geom.Path) {
+	r.shape(p, func(a *attrs) {
+		r.paintAttrs(a, "fill", r.state.fillStyle)
+	})
+}
+
+func (r *Renderer) stroke(p *geom.Path) {
+	s := &r.state
+	r.shape(p, func(a *attrs) {
+		a.add("fill", "none")
+		r.paintAttrs(a, "stroke", s.strokeStyle)
+		r.lineAttrs(a)
+	})
+}
+
+func (r *Renderer) lineAttrs(a *attrs) {
+	s := &r.state
+	if s.lineWidth != 1 {
+		a.add("stroke-width", fmtNumber(s.lineWidth))
+	}
+	switch s.lineCap {
+	case canvas.CapRound:
+		a.add("stroke-linecap", "round")
+	case canvas.CapSquare:
+		a.add("stroke-linecap", "square")
+	}
+	switch s.lineJoin {
+	case canvas.JoinRound:
+		a.add("stroke-linejoin", "round")
+	case canvas.JoinBevel:
+		a.add("stroke-linejoin", "bevel")
+	default:
+		// The SVG default miter limit is 4, the canvas default is 10.
+		a.add("stroke-miterlimit", fmtNumber(s.miterLimit))
+	}
+	if s.lineDash != nil {
+		a.add("stroke-dasharray", fmtNumbers(s.lineDash, ","))
+		if s.dashOffset != 0 {
+			a.add("stroke-dashoffset", fmtNumber(s.dashOffset))
+		}
+	}
+}
+
+// shape writes a path element for the path in the current user space,
+// so that line widths, gradients and patterns are in the same coordinate
+// system as on the canvas.
+func (r *Renderer) shape(p *geom.Path, paint func(a *attrs)) {
+	if len(p.Segments) == 0 {
+		return
+	}
+	m := r.state.transform
+	inv, ok := m.Invert()
+	if !ok {
+		return
+	}
+	a := &attrs{}
+	a.add("d", pathData(p, inv))
+	a.transform(m)
+	paint(a)
+	r.element("path", a, "")
+}
+
+// element writes an element with the global alpha, blend mode and
+// clipping region of the current state to the body of the document.
+func (r *Renderer) element(name string, a *attrs, content string) {
+	s := &r.state
+	if s.globalAlpha != 1 {
+		a.add("opacity", fmtNumber(s.globalAlpha))
+	}
+	if mode, ok := blendModes[s.compositeOp]; ok {
+		a.addStyle("mix-blend-mode", mode)
+	}
+	if s.clipID != "" {
+		fmt.Fprintf(&r.body, `<g clip-path="url(#%s)">`, s.clipID)
+	}
+	writeElement(&r.body, name, a, content)
+	if s.clipID != "" {
+		r.body.WriteString("</g>")
+	}
+	r.body.WriteByte('\n')
+}
+
+func writeElement(w *bytes.Buffer, name string, a *attrs, content string) {
+	w.WriteString("<" + name)
+	w.WriteString(a.String())
+	if content == "" {
+		w.WriteString("/>")
+		return
+	}
+	w.WriteString(">" + content + "</" + name + ">")
+}
+
+var blendModes = map[canvas.CompositeOperation]string{
+	canvas.OpLighter:    "plus-lighter",
+	canvas.OpMultiply:   "multiply",
+	canvas.OpScreen:     "screen",
+	canvas.OpOverlay:    "overlay",
+	canvas.OpDarken:     "darken",
+	canvas.OpLighten:    "lighten",
+	canvas.OpColorDodge: "color-dodge",
+	canvas.OpColorBurn:  "color-burn",
+	canvas.OpHardLight:  "hard-light",
+	canvas.OpSoftLight:  "soft-light",
+	canvas.OpDifference: "difference",
+	canvas.OpExclusion:  "exclusion",
+	canvas.OpHue:        "hue",
+	canvas.OpSaturation: "saturation",
+	canvas.OpColor:      "color",
+	canvas.OpLuminosity: "luminosity",
+}
+
+// paintAttrs adds the attributes for a fill or stroke style. Gradients
+// and patterns are written to the defs section on each use, since their
+// color stops may change between uses.
+func (r *Renderer) paintAttrs(a *attrs, name string, st style) {
+	switch {
+	case st.gradient != nil:
+		a.add(name, "url(#"+r.gradientDef(st.gradient)+")")
+	case st.pattern != nil:
+		a.add(name, "url(#"+r.patternDef(st.pattern)+")")
+	default:
+		a.add(name, st.color)
+		if st.opacity != 1 {
+			a.add(name+"-opacity", fmtNumber(st.opacity))
+		}
+	}
+}
+
+func (r *Renderer) gradientDef(g *gradient) string {
+	id := r.newID("gradient")
+	a := &attrs{}
+	a.add("id", id)
+	a.add("gradientUnits", "userSpaceOnUse")
+	name := "linearGradient"
+	if g.radial {
+		name = "radialGradient"
+		a.add("cx", fmtNumber(g.p1.X))
+		a.add("cy", fmtNumber(g.p1.Y))
+		a.add("r", fmtNumber(g.r1))
+		a.add("fx", fmtNumber(g.p0.X))
+		a.add("fy", fmtNumber(g.p0.Y))
+		a.add("fr", fmtNumber(g.r0))
+	} else {
+		a.add("x1", fmtNumber(g.p0.X))
+		a.add("y1", fmtNumber(g.p0.Y))
+		a.add("x2", fmtNumber(g.p1.X))
+		a.add("y2", fmtNumber(g.p1.Y))
+	}
+	var stops strings.Builder
+	for _, stop := range g.stops {
+		sa := &attrs{}
+		sa.add("offset", fmtNumber(stop.offset))
+		sa.add("stop-color", stop.color)
+		if stop.opacity != 1 {
+			sa.add("stop-opacity", fmtNumber(stop.opacity))
+		}
+		stops.WriteString("<stop" + sa.String() + "/>")
+	}
+	if len(g.stops) == 0 {
+		// A canvas gradient without color stops is transparent black,
+		// an SVG gradient without stops would paint nothing at all,
+		// which has the same effect.
+		stops.WriteString(`<stop stop-opacity="0"/>`)
+	}
+	writeElement(&r.defs, name, a, stops.String())
+	r.defs.WriteByte('\n')
+	return id
+}
+
+// patternDef writes a pattern element. The SVG pattern tile is enlarged
+// in the directions in which a canvas pattern does not repeat.
+func (r *Renderer) patternDef(p *pattern) string {
+	const noRepeat = 1 << 20
+	id := r.newID("pattern")
+	w, h := p.image.width, p.image.height
+	if p.repetition == canvas.PatternRepeatY || p.repetition == canvas.PatternNoRepeat {
+		w = noRepeat
+	}
+	if p.repetition == canvas.PatternRepeatX || p.repetition == canvas.PatternNoRepeat {
+		h = noRepeat
+	}
+	a := &attrs{}
+	a.add("id", id)
+	a.add("patternUnits", "userSpaceOnUse")
+	a.add("width", strconv.Itoa(w))
+	a.add("height", strconv.Itoa(h))
+	writeElement(&r.defs, "pattern", a, r.use(p.image))
+	r.defs.WriteByte('\n')
+	return id
+}
+
+// imageDef writes an image element with the image encoded as PNG data URI
+// to the defs section.
+func (r *Renderer) imageDef(img *image.NRGBA) *imageDef {
+	def := &imageDef{
+		id:     r.newID("image"),
+		width:  img.Rect.Dx(),
+		height: img.Rect.Dy(),
+		img:    img,
+	}
+	var buf bytes.Buffer
+	if def.width > 0 && def.height > 0 {
+		if err := png.Encode(&buf, img); err != nil {
+			// Encoding an in-memory NRGBA image does not fail.
+			panic(err)
+		}
+	}
+	a := &attrs{}
+	a.add("id", def.id)
+	a.add("width", strconv.Itoa(def.width))
+	a.add("height", strconv.Itoa(def.height))
+	a.add("href", "data:image/png;base64,"+base64.StdEncoding.EncodeToString(buf.Bytes()))
+	writeElement(&r.defs, "image", a, "")
+	r.defs.WriteByte('\n')
+	return def
+}
+
+func (r *Renderer) use(img *imageDef) string {
+	a := &attrs{}
+	a.add("href", "#"+img.id)
+	if !r.state.smoothing {
+		a.add("image-rendering", "pixelated")
+	}
+	return "<use" + a.String() + "/>"
+}
+
+// drawImageInstances draws the image centered at the position of each
+// instance, with the transformation and global alpha of the current state
+// combined with those of the instance.
+func (r *Renderer) drawImageInstances(img *imageDef, instances []canvas.SpriteInstance) {
+	s := &r.state
+	transform, globalAlpha := s.transform, s.globalAlpha
+	defer func() {
+		s.transform, s.globalAlpha = transform, globalAlpha
+	}()
+	for _, in := range instances {
+		sp := geom.ResolveSprite(in, img.width, img.height)
+		s.transform = transform.Multiply(sp.Transform)
+		s.globalAlpha = globalAlpha * sp.Alpha
+		r.drawImage(img, sp.SX, sp.SY, sp.SW, sp.SH, -sp.SW/2, -sp.SH/2, sp.SW, sp.SH)
+	}
+}
+
+func (r *Renderer) drawImage(img *imageDef, sx, sy, sw, sh, dx, dy, dw, dh float64) {
+	if sw == 0 || sh == 0 || dw == 0 || dh == 0 {
+		return
+	}
+	va, content := r.imageViewport(img, sx, sy, sw, sh, dx, dy, dw, dh)
+	m := r.state.transform
+	if m == geom.Identity {
+		r.element("svg", va, content)
+		return
+	}
+	var buf bytes.Buffer
+	writeElement(&buf, "svg", va, content)
+	a := &attrs{}
+	a.transform(m)
+	r.element("g", a, buf.String())
+}
+
+// imageViewport returns the attributes and content of a nested svg
+// element that shows the source rectangle of the image in the destination
+// rectangle.
+func (r *Renderer) imageViewport(img *imageDef, sx, sy, sw, sh, dx, dy, dw, dh float64) (*attrs, string) {
+	if sw < 0 {
+		sx, sw = sx+sw, -sw
+	}
+	if sh < 0 {
+		sy, sh = sy+sh, -sh
+	}
+	if dw < 0 {
+		dx, dw = dx+dw, -dw
+	}
+	if dh < 0 {
+		dy, dh = dy+dh, -dh
+	}
+	a := &attrs{}
+	a.add("x", fmtNumber(dx))
+	a.add("y", fmtNumber(dy))
+	a.add("width", fmtNumber(dw))
+	a.add("height", fmtNumber(dh))
+	a.add("viewBox", fmtNumbers([]float64{sx, sy, sw, sh}, " "))
+	a.add("preserveAspectRatio", "none")
+	return a, r.use(img)
+}
+
+// putImageData draws the dirty rectangle of the image data at (dx, dy)
+// without transformation, global alpha, blending and clipping.
+func (r *Renderer) putImageData(img *imageDef, dx, dy, dirtyX, dirtyY, dirtyW, dirtyH float64) {
+	if dirtyW < 0 {
+		dirtyX, dirtyW = dirtyX+dirtyW, -dirtyW
+	}
+	if dirtyH < 0 {
+		dirtyY, dirtyH = dirtyY+dirtyH, -dirtyH
+	}
+	x0 := math.Max(math.Floor(dirtyX), 0)
+	y0 := math.Max(math.Floor(dirtyY), 0)
+	x1 := math.Min(math.Ceil(dirtyX+dirtyW), float64(img.width))
+	y1 := math.Min(math.Ceil(dirtyY+dirtyH), float64(img.height))
+	if x1 <= x0 || y1 <= y0 {
+		return
+	}
+	dx, dy = math.Floor(dx), math.Floor(dy)
+	saved := r.state.smoothing
+	r.state.smoothing = false
+	a, content := r.imageViewport(img, x0, y0, x1-x0, y1-y0, dx+x0, dy+y0, x1-x0, y1-y0)
+	r.state.smoothing = saved
+	writeElement(&r.body, "svg", a, content)
+	r.body.WriteByte('\n')
+}
+
+func (r *Renderer) text(x, y float64, text string, stroke bool) {
+	s := &r.state
+	a := &attrs{}
+	a.add("x", fmtNumber(x))
+	a.add("y", fmtNumber(y))
+	a.transform(s.transform)
+	a.addStyle("font", s.font)
+	if anchor := textAnchors[s.textAlign]; anchor != "" {
+		a.add("text-anchor", anchor)
+	}
+	if baseline := dominantBaselines[s.baseline]; baseline != "" {
+		a.add("dominant-baseline", baseline)
+	}
+	a.add("xml:space", "preserve")
+	if stroke {
+		a.add("fill", "none")
+		r.paintAttrs(a, "stroke", s.strokeStyle)
+		r.lineAttrs(a)
+	} else {
+		r.paintAttrs(a, "fill", s.fillStyle)
+	}
+	var buf bytes.Buffer
+	escape(&buf, text)
+	r.element("text", a, buf.String())
+}
+
+var textAnchors = map[canvas.TextAlign]string{
+	canvas.AlignEnd:    "end",
+	canvas.AlignRight:  "end",
+	canvas.AlignCenter: "middle",
+}
+
+var dominantBaselines = map[canvas.TextBaseline]string{
+	canvas.BaselineIdeographic: "ideographic",
+	canvas.BaselineTop:         "text-before-edge",
+	canvas.BaselineBottom:      "text-after-edge",
+	canvas.BaselineHanging:     "hanging",
+	canvas.BaselineMiddle:      "middle",
+}
+
+func (r *Renderer) clip() {
+	if len(r.path.Segments) == 0 {
+		return
+	}
+	s := &r.state
+	id := r.newID("clip")
+	a := &attrs{}
+	a.add("id", id)
+	if s.clipID != "" {
+		a.add("clip-path", "url(#"+s.clipID+")")
+	}
+	pa := &attrs{}
+	pa.add("d", pathData(&r.path, geom.Identity))
+	var buf bytes.Buffer
+	writeElement(&buf, "path", pa, "")
+	writeElement(&r.defs, "clipPath", a, buf.String())
+	r.defs.WriteByte('\n')
+	s.clipID = id
+}
+
+// clearRect discards the elements drawn so far if the rectangle covers
+// the whole canvas. Clearing parts of the canvas cannot be expressed in
+// SVG.
+func (r *Renderer) clearRect(x, y, w, h float64) {
+	s := &r.state
+	if s.clipID != "" {
+		return
+	}
+	m := s.transform
+	corners := []geom.Point{
+		geom.Pt(0, 0),
+		geom.Pt(float64(r.width), 0),
+		geom.Pt(0, float64(r.height)),
+		geom.Pt(float64(r.width), float64(r.height)),
+	}
+	inv, ok := m.Invert()
+	if !ok {
+		return
+	}
+	// A transformed rectangle covers the canvas if it contains
+	// all corners of the canvas.
+	x0, x1 := math.Min(x, x+w), math.Max(x, x+w)
+	y0, y1 := math.Min(y, y+h), math.Max(y, y+h)
+	for _, c := range corners {
+		p := inv.Apply(c)
+		if p.X < x0-1e-9 || p.X > x1+1e-9 || p.Y < y0-1e-9 || p.Y > y1+1e-9 {
+			return
+		}
+	}
+	r.body.Reset()
+}
+
+// attrs is an ordered list of XML attributes. CSS properties without
+// an equivalent presentation attribute are collected in a style
+// attribute, which comes last.
+type attrs struct {
+	names  []string
+	values []string
+	style  []string
+}
+
+func (a *attrs) add(name, value string) {
+	a.names = append(a.names, name)
+	a.values = append(a.values, value)
+}
+
+func (a *attrs) addStyle(property, value string) {
+	a.style = append(a.style, property+":"+value)
+}
+
+func (a *attrs) transform(m geom.Matrix) {
+	if m == geom.Identity {
+		return
+	}
+	a.add("transform", "matrix("+fmtNumbers([]float64{m.A, m.B, m.C, m.D, m.E, m.F}, " ")+")")
+}
+
+func (a *attrs) String() string {
+	var buf bytes.Buffer
+	for i, name := range a.names {
+		buf.WriteString(" " + name + `="`)
+		escape(&buf, a.values[i])
+		buf.WriteByte('"')
+	}
+	if len(a.style) > 0 {
+		buf.WriteString(` style="`)
+		escape(&buf, strings.Join(a.style, ";"))
+		buf.WriteByte('"')
+	}
+	return buf.String()
+}
+
+func escape(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '"':
+			buf.WriteString("&quot;")
+		default:
+			if !isXMLChar(r) {
+				// Like xml.EscapeText, characters that are not allowed
+				// in XML documents are replaced.
+				r = '\uFFFD'
+			}
+			buf.WriteRune(r)
+		}
+	}
+}
+
+// isXMLChar reports whether r is in the character range of the XML
+// specification, which excludes most control characters.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xd7ff ||
+		r >= 0xe000 && r <= 0xfffd ||
+		r >= 0x10000 && r <= 0x10ffff
+}
+
+// fmtNumber formats a number with at most three decimal places, which is
+// precise enough for coordinates in pixel units.
+func fmtNumber(v float64) string {
+	v = math.Round(v*1000) / 1000
+	if v == 0 {
+		// Avoid "-0"
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func fmtNumbers(vs []float64, sep string) string {
+	s := make([]string, len(vs))
+	for i, v := range vs {
+		s[i] = fmtNumber(v)
+	}
+	return strings.Join(s, sep)
+}
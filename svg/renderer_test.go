@@ -0,0 +1,320 @@
+// Copyright 2026 Frederik Zipp. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package svg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/fzipp/canvas"
+	"github.com/fzipp/canvas/command"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDraw(t *testing.T) {
+	tests := []struct {
+		name     string
+		draw     func(ctx *canvas.Context)
+		wantDefs []string
+		wantBody []string
+	}{
+		{
+			"fill rect",
+			func(ctx *canvas.Context) {
+				ctx.SetFillStyle(color.RGBA{R: 0xff, G: 0x80, A: 0x80})
+				ctx.FillRect(10, 10, 20, 10)
+			},
+			nil,
+			[]string{
+				`<path d="M10 10L30 10L30 20L10 20Z" fill="#ff8000" fill-opacity="0.502"/>`,
+			},
+		},
+		{
+			"path",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.MoveTo(0, 0)
+				ctx.LineTo(10, 0)
+				ctx.QuadraticCurveTo(20, 0, 20, 10)
+				ctx.BezierCurveTo(20, 20, 10, 30, 0, 30)
+				ctx.ClosePath()
+				ctx.SetFillStyleString("steelblue")
+				ctx.Fill()
+			},
+			nil,
+			[]string{
+				`<path d="M0 0L10 0Q20 0 20 10C20 20 10 30 0 30Z" fill="steelblue"/>`,
+			},
+		},
+		{
+			"arc",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.Arc(50, 50, 10, 0, math.Pi, false)
+				ctx.Fill()
+			},
+			nil,
+			[]string{
+				`<path d="M60 50C60 55.523 55.523 60 50 60C44.477 60 40 55.523 40 50" fill="#000000"/>`,
+			},
+		},
+		{
+			"stroke with transformation",
+			func(ctx *canvas.Context) {
+				ctx.Translate(10, 20)
+				ctx.Scale(2, 2)
+				ctx.BeginPath()
+				ctx.MoveTo(0, 0)
+				ctx.LineTo(5, 5)
+				ctx.SetLineWidth(3)
+				ctx.SetLineCap(canvas.CapRound)
+				ctx.SetLineJoin(canvas.JoinBevel)
+				ctx.SetLineDash([]float64{1, 2, 3})
+				ctx.SetLineDashOffset(1.5)
+				ctx.SetStrokeStyle(color.RGBA{B: 0xff, A: 0xff})
+				ctx.Stroke()
+			},
+			nil,
+			[]string{
+				`<path d="M0 0L5 5" transform="matrix(2 0 0 2 10 20)" fill="none" stroke="#0000ff" stroke-width="3" stroke-linecap="round" stroke-linejoin="bevel" stroke-dasharray="1,2,3,1,2,3" stroke-dashoffset="1.5"/>`,
+			},
+		},
+		{
+			"path points keep transformation of their time",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.MoveTo(10, 10)
+				ctx.Scale(2, 2)
+				ctx.LineTo(10, 10)
+				ctx.Stroke()
+			},
+			nil,
+			[]string{
+				`<path d="M5 5L10 10" transform="matrix(2 0 0 2 0 0)" fill="none" stroke="#000000" stroke-miterlimit="10"/>`,
+			},
+		},
+		{
+			"save and restore",
+			func(ctx *canvas.Context) {
+				ctx.Save()
+				ctx.SetGlobalAlpha(0.5)
+				ctx.SetGlobalCompositeOperation(canvas.OpMultiply)
+				ctx.FillRect(0, 0, 1, 1)
+				ctx.Restore()
+				ctx.FillRect(0, 0, 1, 1)
+			},
+			nil,
+			[]string{
+				`<path d="M0 0L1 0L1 1L0 1Z" fill="#000000" opacity="0.5" style="mix-blend-mode:multiply"/>`,
+				`<path d="M0 0L1 0L1 1L0 1Z" fill="#000000"/>`,
+			},
+		},
+		{
+			"clip",
+			func(ctx *canvas.Context) {
+				ctx.BeginPath()
+				ctx.Rect(0, 0, 10, 10)
+				ctx.Clip()
+				ctx.BeginPath()
+				ctx.Rect(5, 5, 10, 10)
+				ctx.Clip()
+				ctx.FillRect(0, 0, 20, 20)
+			},
+			[]string{
+				`<clipPath id="clip0"><path d="M0 0L10 0L10 10L0 10Z"/></clipPath>`,
+				`<clipPath id="clip1" clip-path="url(#clip0)"><path d="M5 5L15 5L15 15L5 15Z"/></clipPath>`,
+			},
+			[]string{
+				`<g clip-path="url(#clip1)"><path d="M0 0L20 0L20 20L0 20Z" fill="#000000"/></g>`,
+			},
+		},
+		{
+			"gradients",
+			func(ctx *canvas.Context) {
+				lg := ctx.CreateLinearGradient(0, 0, 100, 0)
+				lg.AddColorStop(1, color.RGBA{B: 0xff, A: 0xff})
+				lg.AddColorStopString(0, "red")
+				ctx.SetFillStyleGradient(lg)
+				ctx.FillRect(0, 0, 100, 10)
+				rg := ctx.CreateRadialGradient(10, 10, 5, 20, 20, 30)
+				ctx.SetStrokeStyleGradient(rg)
+				ctx.StrokeRect(0, 0, 100, 10)
+			},
+			[]string{
+				`<linearGradient id="gradient0" gradientUnits="userSpaceOnUse" x1="0" y1="0" x2="100" y2="0"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="#0000ff"/></linearGradient>`,
+				`<radialGradient id="gradient1" gradientUnits="userSpaceOnUse" cx="20" cy="20" r="30" fx="10" fy="10" fr="5"><stop stop-opacity="0"/></radialGradient>`,
+			},
+			[]string{
+				`<path d="M0 0L100 0L100 10L0 10Z" fill="url(#gradient0)"/>`,
+				`<path d="M0 0L100 0L100 10L0 10Z" fill="none" stroke="url(#gradient1)" stroke-miterlimit="10"/>`,
+			},
+		},
+		{
+			"images and patterns",
+			func(ctx *canvas.Context) {
+				img := ctx.CreateImageData(image.NewRGBA(image.Rect(0, 0, 2, 3)))
+				ctx.DrawImage(img, 5, 6)
+				ctx.Rotate(math.Pi)
+				ctx.SetImageSmoothingEnabled(false)
+				ctx.DrawImageSubRectangle(img, 1, 1, 1, 2, 10, 10, 4, 8)
+				ctx.PutImageData(img, 7, 8)
+				ctx.SetFillStylePattern(ctx.CreatePattern(img, canvas.PatternRepeatX))
+				ctx.Fill()
+				ctx.FillRect(0, 0, 10, 10)
+			},
+			[]string{
+				`<image id="image0" width="2" height="3" href="` + pngDataURI(image.NewNRGBA(image.Rect(0, 0, 2, 3))) + `"/>`,
+				`<pattern id="pattern1" patternUnits="userSpaceOnUse" width="2" height="1048576"><use href="#image0" image-rendering="pixelated"/></pattern>`,
+			},
+			[]string{
+				`<svg x="5" y="6" width="2" height="3" viewBox="0 0 2 3" preserveAspectRatio="none"><use href="#image0"/></svg>`,
+				`<g transform="matrix(-1 0 0 -1 0 0)"><svg x="10" y="10" width="4" height="8" viewBox="1 1 1 2" preserveAspectRatio="none"><use href="#image0" image-rendering="pixelated"/></svg></g>`,
+				`<svg x="7" y="8" width="2" height="3" viewBox="0 0 2 3" preserveAspectRatio="none"><use href="#image0" image-rendering="pixelated"/></svg>`,
+				`<path d="M0 0L10 0L10 10L0 10Z" transform="matrix(-1 0 0 -1 0 0)" fill="url(#pattern1)"/>`,
+			},
+		},
+		{
+			"text",
+			func(ctx *canvas.Context) {
+				ctx.SetFont("bold 16px serif")
+				ctx.SetTextAlign(canvas.AlignCenter)
+				ctx.SetTextBaseline(canvas.BaselineMiddle)
+				ctx.FillText(`<a & "b">`, 10, 20)
+				ctx.SetTextAlign(canvas.AlignStart)
+				ctx.SetTextBaseline(canvas.BaselineAlphabetic)
+				ctx.StrokeTextMaxWidth("x", 1, 2, 50)
+			},
+			nil,
+			[]string{
+				`<text x="10" y="20" text-anchor="middle" dominant-baseline="middle" xml:space="preserve" fill="#000000" style="font:bold 16px serif">&lt;a &amp; &quot;b&quot;&gt;</text>`,
+				`<text x="1" y="2" xml:space="preserve" fill="none" stroke="#000000" stroke-miterlimit="10" style="font:bold 16px serif">x</text>`,
+			},
+		},
+		{
+			"text with control characters",
+			func(ctx *canvas.Context) {
+				ctx.FillText("a\x00b\x1bc\td", 1, 2)
+			},
+			nil,
+			[]string{
+				"<text x=\"1\" y=\"2\" xml:space=\"preserve\" fill=\"#000000\" style=\"font:10px sans-serif\">a\uFFFDb\uFFFDc\td</text>",
+			},
+		},
+		{
+			"clear whole canvas",
+			func(ctx *canvas.Context) {
+				ctx.FillRect(0, 0, 1, 1)
+				ctx.ClearRect(10, 10, 5, 5)
+				ctx.FillRect(1, 1, 1, 1)
+				ctx.ClearRect(0, 0, 100, 100)
+				ctx.FillRect(2, 2, 1, 1)
+			},
+			nil,
+			[]string{
+				`<path d="M2 2L3 2L3 3L2 3Z" fill="#000000"/>`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Draw(100, 100, tt.draw)
+			if err := checkWellFormed(doc); err != nil {
+				t.Fatalf("document is not well-formed XML: %s\n%s", err, doc)
+			}
+			defs, body := split(t, string(doc))
+			if diff := cmp.Diff(tt.wantDefs, defs); diff != "" {
+				t.Errorf("defs mismatch (-want, +got)\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantBody, body); diff != "" {
+				t.Errorf("body mismatch (-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRendererWriteTo(t *testing.T) {
+	r := NewRenderer(640, 480)
+	r.Render(command.FillRect{X: 1, Y: 2, Width: 3, Height: 4})
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("did not expect error, but got error: %s", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("got n: %d, want: %d", n, buf.Len())
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" width="640" height="480" viewBox="0 0 640 480">
+<path d="M1 2L4 2L4 6L1 6Z" fill="#000000"/>
+</svg>
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("mismatch (-want, +got)\n%s", diff)
+	}
+}
+
+func TestRendererRenderFrameError(t *testing.T) {
+	r := NewRenderer(10, 10)
+	frame := command.Encode([]command.Command{command.FillRect{X: 1, Y: 2, Width: 3, Height: 4}})
+	frame = append(frame, 0x00)
+	var decodeErr *command.DecodeError
+	if err := r.RenderFrame(frame); !errors.As(err, &decodeErr) {
+		t.Errorf("expected %T error, but got: %#v", decodeErr, err)
+	}
+	var buf bytes.Buffer
+	_, _ = r.WriteTo(&buf)
+	if !strings.Contains(buf.String(), `<path d="M1 2L4 2L4 6L1 6Z" fill="#000000"/>`) {
+		t.Errorf("commands before error were not rendered:\n%s", buf.String())
+	}
+}
+
+// split returns the lines of the defs and the body of an SVG document.
+func split(t *testing.T, doc string) (defs, body []string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(doc, "\n"), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "<svg ") || lines[len(lines)-1] != "</svg>" {
+		t.Fatalf("unexpected document structure:\n%s", doc)
+	}
+	lines = lines[1 : len(lines)-1]
+	if len(lines) > 0 && lines[0] == "<defs>" {
+		end := 0
+		for end < len(lines) && lines[end] != "</defs>" {
+			end++
+		}
+		defs = lines[1:end]
+		lines = lines[end+1:]
+	}
+	if len(lines) > 0 {
+		body = lines
+	}
+	return defs, body
+}
+
+func pngDataURI(img image.Image) string {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func checkWellFormed(doc []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
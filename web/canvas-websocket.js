@@ -5,10 +5,16 @@
 document.addEventListener("DOMContentLoaded", function () {
     "use strict";
 
+    // protocolVersion must match protocolVersion in handshake.go.
+    const protocolVersion = 6;
+    const capCompactEncoding = 1;
+    const closeVersionMismatch = 4000;
+
     const allocImageData = {};
     const allocOffscreenCanvas = {};
     const allocGradient = {};
     const allocPattern = {};
+    const textInputs = new WeakMap();
 
     const enumRepetition = ["repeat", "repeat-x", "repeat-y", "no-repeat"];
 
@@ -36,6 +42,11 @@ document.addEventListener("DOMContentLoaded", function () {
         const canvas = canvases[i];
         const config = configFrom(canvas.dataset);
         if (config.drawUrl) {
+            if (config.eventMask & (8192 | 16384 | 32768 | 65536)) {
+                textInputs.set(canvas, createTextInput(canvas));
+            } else if (config.eventMask & (131072 | 262144)) {
+                makeFocusable(canvas);
+            }
             webSocketCanvas(canvas, config);
             if (config.contextMenuDisabled) {
                 disableContextMenu(canvas);
@@ -48,7 +59,10 @@ document.addEventListener("DOMContentLoaded", function () {
             drawUrl: absoluteWebSocketUrl(dataset["websocketDrawUrl"]),
             eventMask: parseInt(dataset["websocketEventMask"], 10) || 0,
             reconnectInterval: parseInt(dataset["websocketReconnectInterval"], 10) || 0,
-            contextMenuDisabled: (dataset["disableContextMenu"] === "true")
+            maxFileSize: parseInt(dataset["websocketMaxFileSize"], 10) || 0,
+            contextMenuDisabled: (dataset["disableContextMenu"] === "true"),
+            browserShortcutsEnabled: (dataset["enableBrowserShortcuts"] === "true"),
+            replay: (dataset["replay"] === "true")
         };
     }
 
@@ -64,19 +78,93 @@ document.addEventListener("DOMContentLoaded", function () {
         return wsUrl.href;
     }
 
+    function createTextInput(canvas) {
+        const textInput = document.createElement("input");
+        textInput.type = "text";
+        textInput.autocomplete = "off";
+        textInput.spellcheck = false;
+        textInput.setAttribute("autocapitalize", "off");
+        textInput.setAttribute("aria-hidden", "true");
+        const style = textInput.style;
+        style.position = "absolute";
+        style.left = "0";
+        style.top = "0";
+        style.width = "1px";
+        style.height = "1px";
+        style.padding = "0";
+        style.border = "0";
+        style.opacity = "0";
+        style.pointerEvents = "none";
+        document.body.appendChild(textInput);
+        canvas.addEventListener("pointerdown", function () {
+            setTimeout(function () {
+                textInput.focus({preventScroll: true});
+            }, 0);
+        });
+        textInput.focus({preventScroll: true});
+        return textInput;
+    }
+
+    function makeFocusable(canvas) {
+        if (!canvas.hasAttribute("tabindex")) {
+            canvas.tabIndex = 0;
+        }
+        canvas.style.outline = "none";
+        canvas.addEventListener("pointerdown", function () {
+            setTimeout(function () {
+                canvas.focus({preventScroll: true});
+            }, 0);
+        });
+        canvas.focus({preventScroll: true});
+    }
+
+    function positionTextInput(canvas, x, y, width, height) {
+        const textInput = textInputs.get(canvas);
+        if (!textInput) {
+            return;
+        }
+        const rect = canvas.getBoundingClientRect();
+        const scaleX = rect.width / canvas.width;
+        const scaleY = rect.height / canvas.height;
+        const style = textInput.style;
+        style.left = (window.scrollX + rect.left + x * scaleX) + "px";
+        style.top = (window.scrollY + rect.top + y * scaleY) + "px";
+        style.width = Math.max(1, width * scaleX) + "px";
+        style.height = Math.max(1, height * scaleY) + "px";
+        style.fontSize = Math.max(1, height * scaleY) + "px";
+    }
+
     function webSocketCanvas(canvas, config) {
         const ctx = canvas.getContext("2d");
         const webSocket = new WebSocket(config.drawUrl);
         let handlers = {};
+        let replayControls = null;
         webSocket.binaryType = "arraybuffer";
         webSocket.addEventListener("open", function () {
-            handlers = addEventListeners(canvas, config.eventMask, webSocket);
+            sendHello(webSocket);
+            if (config.replay) {
+                replayControls = createReplayControls(canvas, webSocket);
+                return;
+            }
+            handlers = addEventListeners(canvas, config, webSocket);
         });
         webSocket.addEventListener("error", function () {
             webSocket.close();
         });
-        webSocket.addEventListener("close", function () {
+        webSocket.addEventListener("close", function (event) {
             removeEventListeners(canvas, handlers);
+            if (replayControls) {
+                replayControls.remove();
+            }
+            if (event.code === closeVersionMismatch) {
+                console.error(event.reason);
+                if (config.reconnectInterval) {
+                    // Reloading the page loads the script version that
+                    // matches the server.
+                    location.reload();
+                }
+                return;
+            }
             if (!config.reconnectInterval) {
                 return;
             }
@@ -86,15 +174,109 @@ document.addEventListener("DOMContentLoaded", function () {
         });
         webSocket.addEventListener("message", function (event) {
             const data = event.data;
-            let offset = 0;
-            const len = data.byteLength;
-            while (offset < len) {
-                offset += draw(ctx, new DataView(data, offset));
+            if (typeof data === "string") {
+                if (replayControls) {
+                    replayControls.update(JSON.parse(data));
+                }
+                return;
+            }
+            const r = newReader(data);
+            while (r.remaining() > 0) {
+                draw(ctx, r, webSocket);
             }
         });
     }
 
-    function addEventListeners(canvas, eventMask, webSocket) {
+    function sendHello(webSocket) {
+        const data = new DataView(new ArrayBuffer(9));
+        data.setUint8(0, 129);
+        data.setUint32(1, protocolVersion);
+        data.setUint32(5, capCompactEncoding);
+        webSocket.send(data.buffer);
+    }
+
+    function createReplayControls(canvas, webSocket) {
+        const controls = document.createElement("div");
+        controls.className = "replay-controls";
+        const button = document.createElement("button");
+        const slider = document.createElement("input");
+        slider.type = "range";
+        slider.min = "0";
+        slider.step = "any";
+        const label = document.createElement("span");
+        controls.append(button, slider, label);
+        document.body.appendChild(controls);
+
+        let paused = false;
+        let seeking = false;
+        let time = 0;
+        let duration = 0;
+        let since = performance.now();
+        let frame = requestAnimationFrame(tick);
+
+        button.addEventListener("click", function () {
+            send({type: paused ? "play" : "pause"});
+        });
+        slider.addEventListener("input", function () {
+            seeking = true;
+            label.textContent = formatTimes(slider.valueAsNumber, duration);
+        });
+        slider.addEventListener("change", function () {
+            seeking = false;
+            send({type: "seek", time: slider.valueAsNumber});
+        });
+
+        function send(control) {
+            if (webSocket.readyState === WebSocket.OPEN) {
+                webSocket.send(JSON.stringify(control));
+            }
+        }
+
+        function tick() {
+            if (!seeking) {
+                const now = paused ? time : Math.min(time + performance.now() - since, duration);
+                slider.value = String(now);
+                label.textContent = formatTimes(now, duration);
+            }
+            frame = requestAnimationFrame(tick);
+        }
+
+        function formatTimes(t, d) {
+            return formatTime(t) + " / " + formatTime(d);
+        }
+
+        function formatTime(ms) {
+            const s = Math.floor(ms / 1000);
+            return Math.floor(s / 60) + ":" + String(s % 60).padStart(2, "0");
+        }
+
+        return {
+            update: function (status) {
+                switch (status.type) {
+                    case "reset":
+                        // Setting the size clears the canvas and resets
+                        // the drawing state.
+                        canvas.width = canvas.width;
+                        break;
+                    case "status":
+                        time = status.time || 0;
+                        duration = status.duration || 0;
+                        paused = !!status.paused;
+                        since = performance.now();
+                        slider.max = String(duration);
+                        button.textContent = paused ? "Play" : "Pause";
+                        break;
+                }
+            },
+            remove: function () {
+                cancelAnimationFrame(frame);
+                controls.remove();
+            }
+        };
+    }
+
+    function addEventListeners(canvas, config, webSocket) {
+        const eventMask = config.eventMask;
         const handlers = {};
 
         if (eventMask & 1) {
@@ -136,10 +318,55 @@ document.addEventListener("DOMContentLoaded", function () {
         if (eventMask & 4096) {
             handlers["touchcancel"] = sendTouchEvent(13);
         }
+        if (eventMask & 8192) {
+            handlers["input"] = sendTextInputEvent(14);
+        }
+        if (eventMask & 16384) {
+            handlers["compositionstart"] = sendCompositionEvent(15);
+        }
+        if (eventMask & 32768) {
+            handlers["compositionupdate"] = sendCompositionEvent(16);
+        }
+        if (eventMask & (8192 | 65536)) {
+            handlers["compositionend"] = sendCompositionEndEvent(17, 14);
+        }
+        if (eventMask & 131072) {
+            handlers["focus"] = sendFocusEvent(18);
+        }
+        if (eventMask & 262144) {
+            handlers["blur"] = sendFocusEvent(19);
+        }
+        if (eventMask & 524288) {
+            handlers["visibilitychange"] = sendVisibilityChangeEvent(20);
+        }
+        if (eventMask & 1048576) {
+            handlers["mouseenter"] = sendMouseEvent(21);
+        }
+        if (eventMask & 2097152) {
+            handlers["mouseleave"] = sendMouseEvent(22);
+        }
+        if (eventMask & 4194304) {
+            handlers["gamepadconnected"] = sendGamepadEvent(23);
+        }
+        if (eventMask & 8388608) {
+            handlers["gamepaddisconnected"] = sendGamepadEvent(24);
+        }
+        if (eventMask & 16777216) {
+            pollGamepads(25);
+        }
+        if (eventMask & 33554432) {
+            handlers["dragover"] = allowDrop;
+            handlers["drop"] = sendDropEvent(26);
+        }
+        if (eventMask & 67108864) {
+            handlers["paste"] = sendPasteEvent(27);
+        }
 
+        const textInput = textInputs.get(canvas);
         Object.keys(handlers).forEach(function (type) {
-            const target = (type.indexOf("key") !== 0) ? canvas : document;
-            target.addEventListener(type, handlers[type], {passive: false});
+            eventTargets(canvas, textInput, type).forEach(function (target) {
+                target.addEventListener(type, handlers[type], {passive: false});
+            });
         });
 
         const rect = canvas.getBoundingClientRect();
@@ -225,30 +452,290 @@ document.addEventListener("DOMContentLoaded", function () {
 
         function sendKeyEvent(eventType) {
             return function (event) {
-                event.preventDefault();
+                if (!config.browserShortcutsEnabled && event.target !== textInput) {
+                    event.preventDefault();
+                }
                 const keyBytes = new TextEncoder().encode(event.key);
-                const eventMessage = new ArrayBuffer(6 + keyBytes.byteLength);
+                const codeBytes = new TextEncoder().encode(event.code);
+                const eventMessage = new ArrayBuffer(
+                    2 +
+                    4 + keyBytes.byteLength +
+                    4 + codeBytes.byteLength +
+                    2);
                 const data = new DataView(eventMessage);
-                data.setUint8(0, eventType);
-                data.setUint8(1, encodeModifierKeys(event));
-                data.setUint32(2, keyBytes.byteLength);
-                for (let i = 0; i < keyBytes.length; i++) {
-                    data.setUint8(6 + i, keyBytes[i]);
+                let offset = 0;
+                data.setUint8(offset, eventType);
+                offset++;
+                data.setUint8(offset, encodeModifierKeys(event));
+                offset++;
+                offset = setBytes(data, offset, keyBytes);
+                offset = setBytes(data, offset, codeBytes);
+                data.setUint8(offset, event.location);
+                offset++;
+                data.setUint8(offset, encodeKeyFlags(event));
+                webSocket.send(eventMessage);
+            };
+        }
+
+        function sendTextInputEvent(eventType) {
+            return function (event) {
+                if (event.isComposing || event.inputType === "insertCompositionText") {
+                    return;
+                }
+                const text = textInput.value;
+                textInput.value = "";
+                if (text) {
+                    sendStringEvent(eventType, text);
+                }
+            };
+        }
+
+        function sendCompositionEvent(eventType) {
+            return function (event) {
+                sendStringEvent(eventType, event.data || "");
+            };
+        }
+
+        function sendCompositionEndEvent(compositionEndType, textInputType) {
+            return function (event) {
+                const text = event.data || "";
+                textInput.value = "";
+                if (eventMask & 65536) {
+                    sendStringEvent(compositionEndType, text);
+                }
+                if ((eventMask & 8192) && text) {
+                    sendStringEvent(textInputType, text);
                 }
+            };
+        }
+
+        function sendFocusEvent(eventType) {
+            return function (event) {
+                // Moving the focus between the canvas and its hidden text
+                // input doesn't change the focus of the canvas as a whole.
+                if (event.relatedTarget &&
+                    (event.relatedTarget === canvas || event.relatedTarget === textInput)) {
+                    return;
+                }
+                const eventMessage = new ArrayBuffer(1);
+                const data = new DataView(eventMessage);
+                data.setUint8(0, eventType);
                 webSocket.send(eventMessage);
             };
         }
 
+        function sendVisibilityChangeEvent(eventType) {
+            return function () {
+                const eventMessage = new ArrayBuffer(2);
+                const data = new DataView(eventMessage);
+                data.setUint8(0, eventType);
+                data.setUint8(1, document.hidden ? 1 : 0);
+                webSocket.send(eventMessage);
+            };
+        }
+
+        function sendGamepadEvent(eventType) {
+            return function (event) {
+                webSocket.send(encodeGamepad(eventType, event.gamepad));
+            };
+        }
+
+        function pollGamepads(eventType) {
+            const lastStates = {};
+
+            function poll() {
+                if (webSocket.readyState !== WebSocket.OPEN) {
+                    return;
+                }
+                const gamepads = navigator.getGamepads ? navigator.getGamepads() : [];
+                for (let i = 0; i < gamepads.length; i++) {
+                    const gamepad = gamepads[i];
+                    if (!gamepad) {
+                        delete lastStates[i];
+                        continue;
+                    }
+                    const state = gamepadState(gamepad);
+                    if (state !== lastStates[gamepad.index]) {
+                        lastStates[gamepad.index] = state;
+                        webSocket.send(encodeGamepad(eventType, gamepad));
+                    }
+                }
+                window.requestAnimationFrame(poll);
+            }
+
+            window.requestAnimationFrame(poll);
+        }
+
+        function gamepadState(gamepad) {
+            const values = [];
+            for (let i = 0; i < gamepad.buttons.length; i++) {
+                const button = gamepad.buttons[i];
+                values.push(button.pressed ? 1 : 0, button.value);
+            }
+            for (let i = 0; i < gamepad.axes.length; i++) {
+                values.push(gamepad.axes[i]);
+            }
+            return values.join(",");
+        }
+
+        function encodeGamepad(eventType, gamepad) {
+            const idBytes = new TextEncoder().encode(gamepad.id);
+            const mappingBytes = new TextEncoder().encode(gamepad.mapping);
+            const buttons = gamepad.buttons;
+            const axes = gamepad.axes;
+            const eventMessage = new ArrayBuffer(
+                2 +
+                4 + idBytes.byteLength +
+                4 + mappingBytes.byteLength +
+                1 + (buttons.length * 9) +
+                1 + (axes.length * 8));
+            const data = new DataView(eventMessage);
+            let offset = 0;
+            data.setUint8(offset, eventType);
+            offset++;
+            data.setUint8(offset, gamepad.index);
+            offset++;
+            offset = setBytes(data, offset, idBytes);
+            offset = setBytes(data, offset, mappingBytes);
+            data.setUint8(offset, buttons.length);
+            offset++;
+            for (let i = 0; i < buttons.length; i++) {
+                data.setUint8(offset, buttons[i].pressed ? 1 : 0);
+                offset++;
+                data.setFloat64(offset, buttons[i].value);
+                offset += 8;
+            }
+            data.setUint8(offset, axes.length);
+            offset++;
+            for (let i = 0; i < axes.length; i++) {
+                data.setFloat64(offset, axes[i]);
+                offset += 8;
+            }
+            return eventMessage;
+        }
+
+        function allowDrop(event) {
+            event.preventDefault();
+            event.dataTransfer.dropEffect = "copy";
+        }
+
+        function sendDropEvent(eventType) {
+            return function (event) {
+                event.preventDefault();
+                const mouseBytes = new Uint8Array(11);
+                setMouseEvent(new DataView(mouseBytes.buffer), eventType, event);
+                readFiles(event.dataTransfer.files).then(function (files) {
+                    webSocket.send(concatBytes([mouseBytes].concat(files)));
+                });
+            };
+        }
+
+        function sendPasteEvent(eventType) {
+            return function (event) {
+                event.preventDefault();
+                const header = new Uint8Array([eventType]);
+                const text = encodeString(event.clipboardData.getData("text/plain"));
+                readFiles(event.clipboardData.files).then(function (files) {
+                    webSocket.send(concatBytes([header, text].concat(files)));
+                });
+            };
+        }
+
+        function readFiles(fileList) {
+            const files = Array.prototype.slice.call(fileList, 0, 255);
+            let budget = config.maxFileSize;
+            return Promise.all(files.map(function (file) {
+                const hasData = (file.size <= budget);
+                if (hasData) {
+                    budget -= file.size;
+                }
+                return readFile(file, hasData);
+            })).then(function (encodedFiles) {
+                return [new Uint8Array([encodedFiles.length])].concat(encodedFiles);
+            });
+        }
+
+        function readFile(file, hasData) {
+            const content = hasData ? file.arrayBuffer() : Promise.resolve(new ArrayBuffer(0));
+            return content.then(function (buffer) {
+                const name = encodeString(file.name);
+                const type = encodeString(file.type);
+                const header = new Uint8Array(8 + 1 + 4);
+                const data = new DataView(header.buffer);
+                data.setBigUint64(0, BigInt(file.size));
+                data.setUint8(8, hasData ? 1 : 0);
+                data.setUint32(9, buffer.byteLength);
+                return concatBytes([name, type, header, new Uint8Array(buffer)]);
+            });
+        }
+
+        function encodeString(s) {
+            const bytes = new TextEncoder().encode(s);
+            const encoded = new Uint8Array(4 + bytes.byteLength);
+            setBytes(new DataView(encoded.buffer), 0, bytes);
+            return encoded;
+        }
+
+        function concatBytes(arrays) {
+            let length = 0;
+            arrays.forEach(function (array) {
+                length += array.byteLength;
+            });
+            const result = new Uint8Array(length);
+            let offset = 0;
+            arrays.forEach(function (array) {
+                result.set(array, offset);
+                offset += array.byteLength;
+            });
+            return result;
+        }
+
+        function sendStringEvent(eventType, text) {
+            const bytes = new TextEncoder().encode(text);
+            const eventMessage = new ArrayBuffer(1 + 4 + bytes.byteLength);
+            const data = new DataView(eventMessage);
+            data.setUint8(0, eventType);
+            setBytes(data, 1, bytes);
+            webSocket.send(eventMessage);
+        }
+
+        function setBytes(data, offset, bytes) {
+            data.setUint32(offset, bytes.byteLength);
+            offset += 4;
+            for (let i = 0; i < bytes.length; i++) {
+                data.setUint8(offset + i, bytes[i]);
+            }
+            return offset + bytes.byteLength;
+        }
+
         return handlers;
     }
 
     function removeEventListeners(canvas, handlers) {
+        const textInput = textInputs.get(canvas);
         Object.keys(handlers).forEach(function (type) {
-            const target = (type.indexOf("key") !== 0) ? canvas : document;
-            target.removeEventListener(type, handlers[type]);
+            eventTargets(canvas, textInput, type).forEach(function (target) {
+                target.removeEventListener(type, handlers[type]);
+            });
         });
     }
 
+    function eventTargets(canvas, textInput, type) {
+        if (type.indexOf("key") === 0 || type === "visibilitychange" || type === "paste") {
+            return [document];
+        }
+        if (type.indexOf("gamepad") === 0) {
+            return [window];
+        }
+        if (type === "input" || type.indexOf("composition") === 0) {
+            return [textInput];
+        }
+        if ((type === "focus" || type === "blur") && textInput) {
+            return [canvas, textInput];
+        }
+        return [canvas];
+    }
+
     function disableContextMenu(canvas) {
         canvas.addEventListener("contextmenu", function (e) {
             e.preventDefault();
@@ -272,44 +759,109 @@ document.addEventListener("DOMContentLoaded", function () {
         return modifiers;
     }
 
-    function draw(ctx, data) {
-        switch (data.getUint8(0)) {
+    function encodeKeyFlags(event) {
+        let flags = 0;
+        if (event.repeat) {
+            flags |= 1;
+        }
+        if (event.isComposing) {
+            flags |= 2;
+        }
+        return flags;
+    }
+
+    // newReader returns a reader for the draw commands of a message. After
+    // the compact encoding marker (opcode 71) numbers are encoded as
+    // float32, and ids and lengths as variable-length integers.
+    function newReader(buffer) {
+        const data = new DataView(buffer);
+        return {
+            offset: 0,
+            compact: false,
+            remaining: function () {
+                return data.byteLength - this.offset;
+            },
+            uint8: function () {
+                return data.getUint8(this.offset++);
+            },
+            bool: function () {
+                return !!this.uint8();
+            },
+            uint32: function () {
+                if (this.compact) {
+                    return this.uvarint();
+                }
+                const value = data.getUint32(this.offset);
+                this.offset += 4;
+                return value;
+            },
+            uvarint: function () {
+                let value = 0;
+                let shift = 0;
+                let b;
+                do {
+                    b = this.uint8();
+                    value += (b & 0x7f) * Math.pow(2, shift);
+                    shift += 7;
+                } while (b & 0x80);
+                return value;
+            },
+            float: function () {
+                if (this.compact) {
+                    const value = data.getFloat32(this.offset);
+                    this.offset += 4;
+                    return value;
+                }
+                const value = data.getFloat64(this.offset);
+                this.offset += 8;
+                return value;
+            },
+            bytes: function (len) {
+                const begin = this.offset;
+                this.offset += len;
+                return buffer.slice(begin, begin + len);
+            },
+            string: function () {
+                return new TextDecoder().decode(this.bytes(this.uint32()));
+            },
+            rgba: function () {
+                return "rgba(" +
+                    this.uint8() + ", " +
+                    this.uint8() + ", " +
+                    this.uint8() + ", " +
+                    this.uint8() / 255 + ")";
+            }
+        };
+    }
+
+    function draw(ctx, r, webSocket) {
+        switch (r.uint8()) {
             case 1:
-                ctx.arc(
-                    data.getFloat64(1), data.getFloat64(9), data.getFloat64(17),
-                    data.getFloat64(25), data.getFloat64(33), !!data.getUint8(41));
-                return 42;
+                ctx.arc(r.float(), r.float(), r.float(), r.float(), r.float(), r.bool());
+                break;
             case 2:
-                ctx.arcTo(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25),
-                    data.getFloat64(33));
-                return 41;
+                ctx.arcTo(r.float(), r.float(), r.float(), r.float(), r.float());
+                break;
             case 3:
                 ctx.beginPath();
-                return 1;
+                break;
             case 4:
-                ctx.bezierCurveTo(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25),
-                    data.getFloat64(33), data.getFloat64(41));
-                return 49;
+                ctx.bezierCurveTo(r.float(), r.float(), r.float(), r.float(), r.float(), r.float());
+                break;
             case 5:
-                ctx.clearRect(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25));
-                return 33;
+                ctx.clearRect(r.float(), r.float(), r.float(), r.float());
+                break;
             case 6:
                 ctx.clip();
-                return 1;
+                break;
+            case 7:
+                ctx.closePath();
+                break;
             case 8: {
-                const id = data.getUint32(1);
-                const width = data.getUint32(5);
-                const height = data.getUint32(9);
-                const len = width * height * 4;
-                const bufferOffset = data.byteOffset + 13;
-                const buffer = data.buffer.slice(bufferOffset, bufferOffset + len);
-                const array = new Uint8ClampedArray(buffer);
+                const id = r.uint32();
+                const width = r.uint32();
+                const height = r.uint32();
+                const array = new Uint8ClampedArray(r.bytes(width * height * 4));
                 const imageData = new ImageData(array, width, height);
                 allocImageData[id] = imageData;
                 const offCanvas = document.createElement("canvas");
@@ -317,294 +869,472 @@ document.addEventListener("DOMContentLoaded", function () {
                 offCanvas.height = height;
                 offCanvas.getContext("2d").putImageData(imageData, 0, 0);
                 allocOffscreenCanvas[id] = offCanvas;
-                return 13 + len;
+                break;
             }
             case 9: {
-                const id = data.getUint32(1);
-                const x0 = data.getFloat64(5);
-                const y0 = data.getFloat64(13);
-                const x1 = data.getFloat64(21);
-                const y1 = data.getFloat64(29);
-                allocGradient[id] = ctx.createLinearGradient(x0, y0, x1, y1);
-                return 37;
+                const id = r.uint32();
+                allocGradient[id] = ctx.createLinearGradient(r.float(), r.float(), r.float(), r.float());
+                break;
             }
             case 10: {
-                const id = data.getUint32(1);
-                const image = allocOffscreenCanvas[data.getUint32(5)];
-                const repetition = enumRepetition[data.getUint8(9)];
+                const id = r.uint32();
+                const image = allocOffscreenCanvas[r.uint32()];
+                const repetition = enumRepetition[r.uint8()];
                 allocPattern[id] = ctx.createPattern(image, repetition);
-                return 10;
+                break;
             }
             case 11: {
-                const id = data.getUint32(1);
-                const x0 = data.getFloat64(5);
-                const y0 = data.getFloat64(13);
-                const r0 = data.getFloat64(21);
-                const x1 = data.getFloat64(29);
-                const y1 = data.getFloat64(37);
-                const r1 = data.getFloat64(45);
-                allocGradient[id] = ctx.createRadialGradient(x0, y0, r0, x1, y1, r1);
-                return 53;
+                const id = r.uint32();
+                allocGradient[id] = ctx.createRadialGradient(
+                    r.float(), r.float(), r.float(), r.float(), r.float(), r.float());
+                break;
             }
             case 13:
-                ctx.drawImage(allocOffscreenCanvas[data.getUint32(1)],
-                    data.getFloat64(5), data.getFloat64(13));
-                return 21;
+                ctx.drawImage(allocOffscreenCanvas[r.uint32()], r.float(), r.float());
+                break;
             case 14:
-                ctx.ellipse(
-                    data.getFloat64(1), data.getFloat64(9), data.getFloat64(17),
-                    data.getFloat64(25), data.getFloat64(33), data.getFloat64(41),
-                    data.getFloat64(49), !!data.getUint8(57));
-                return 58;
+                ctx.ellipse(r.float(), r.float(), r.float(), r.float(), r.float(), r.float(), r.float(), r.bool());
+                break;
             case 15:
                 ctx.fill();
-                return 1;
+                break;
             case 16:
-                ctx.fillRect(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25));
-                return 33;
-            case 17: {
-                ctx.fillStyle = getRGBA(data, 1);
-                return 5;
-            }
+                ctx.fillRect(r.float(), r.float(), r.float(), r.float());
+                break;
+            case 17:
+                ctx.fillStyle = r.rgba();
+                break;
             case 18: {
-                const text = getString(data, 17);
-                ctx.fillText(text.value, data.getFloat64(1), data.getFloat64(9));
-                return 17 + text.byteLen;
-            }
-            case 19: {
-                const font = getString(data, 1);
-                ctx.font = font.value;
-                return 1 + font.byteLen;
+                const x = r.float();
+                const y = r.float();
+                ctx.fillText(r.string(), x, y);
+                break;
             }
+            case 19:
+                ctx.font = r.string();
+                break;
             case 20: {
-                const id = data.getUint32(1);
-                const gradient = allocGradient[id];
-                gradient.addColorStop(data.getFloat64(5), getRGBA(data, 13));
-                return 17;
+                const gradient = allocGradient[r.uint32()];
+                gradient.addColorStop(r.float(), r.rgba());
+                break;
             }
             case 21: {
-                const id = data.getUint32(1);
-                const offset = data.getFloat64(5);
-                const color = getString(data, 13);
-                const gradient = allocGradient[id];
-                gradient.addColorStop(offset, color.value);
-                return 13 + color.byteLen;
+                const gradient = allocGradient[r.uint32()];
+                gradient.addColorStop(r.float(), r.string());
+                break;
             }
             case 22:
-                ctx.fillStyle = allocGradient[data.getUint32(1)];
-                return 5;
+                ctx.fillStyle = allocGradient[r.uint32()];
+                break;
             case 23:
-                ctx.globalAlpha = data.getFloat64(1);
-                return 9;
+                ctx.globalAlpha = r.float();
+                break;
             case 24:
-                ctx.globalCompositeOperation = enumCompositeOperation[data.getUint8(1)];
-                return 2;
+                ctx.globalCompositeOperation = enumCompositeOperation[r.uint8()];
+                break;
             case 25:
-                ctx.imageSmoothingEnabled = !!data.getUint8(1);
-                return 2;
+                ctx.imageSmoothingEnabled = r.bool();
+                break;
             case 26:
-                ctx.strokeStyle = allocGradient[data.getUint32(1)];
-                return 5;
-            case 27: {
-                const id = data.getUint32(1);
-                allocPattern[id] = null;
-                return 5;
-            }
+                ctx.strokeStyle = allocGradient[r.uint32()];
+                break;
+            case 27:
+                allocPattern[r.uint32()] = null;
+                break;
             case 28:
-                ctx.lineCap = enumLineCap[data.getUint8(1)];
-                return 2;
+                ctx.lineCap = enumLineCap[r.uint8()];
+                break;
             case 29:
-                ctx.lineDashOffset = data.getFloat64(1);
-                return 9;
+                ctx.lineDashOffset = r.float();
+                break;
             case 30:
-                ctx.lineJoin = enumLineJoin[data.getUint8(1)];
-                return 2;
+                ctx.lineJoin = enumLineJoin[r.uint8()];
+                break;
             case 31:
-                ctx.lineTo(data.getFloat64(1), data.getFloat64(9));
-                return 17;
+                ctx.lineTo(r.float(), r.float());
+                break;
             case 32:
-                ctx.lineWidth = data.getFloat64(1);
-                return 9;
-            case 33: {
-                const id = data.getUint32(1);
-                allocGradient[id] = null;
-                return 5;
-            }
+                ctx.lineWidth = r.float();
+                break;
+            case 33:
+                allocGradient[r.uint32()] = null;
+                break;
             case 34:
-                ctx.miterLimit = data.getFloat64(1);
-                return 9;
+                ctx.miterLimit = r.float();
+                break;
             case 35:
-                ctx.moveTo(data.getFloat64(1), data.getFloat64(9));
-                return 17;
+                ctx.moveTo(r.float(), r.float());
+                break;
             case 36:
-                ctx.putImageData(allocImageData[data.getUint32(1)],
-                    data.getFloat64(5), data.getFloat64(13));
-                return 21;
+                ctx.putImageData(imageData(r.uint32()), r.float(), r.float());
+                break;
             case 37:
-                ctx.quadraticCurveTo(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25));
-                return 33;
+                ctx.quadraticCurveTo(r.float(), r.float(), r.float(), r.float());
+                break;
             case 38:
-                ctx.rect(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25));
-                return 33;
+                ctx.rect(r.float(), r.float(), r.float(), r.float());
+                break;
             case 39:
                 ctx.restore();
-                return 1;
+                break;
             case 40:
-                ctx.rotate(data.getFloat64(1));
-                return 9;
+                ctx.rotate(r.float());
+                break;
             case 41:
                 ctx.save();
-                return 1;
+                break;
             case 42:
-                ctx.scale(data.getFloat64(1), data.getFloat64(9));
-                return 17;
+                ctx.scale(r.float(), r.float());
+                break;
             case 43: {
                 const segments = [];
-                const len = data.getUint32(1);
+                const len = r.uint32();
                 for (let i = 0; i < len; i++) {
-                    segments.push(data.getFloat64(5 + i * 8));
+                    segments.push(r.float());
                 }
                 ctx.setLineDash(segments);
-                return 5 + (len * 8);
+                break;
             }
             case 44:
-                ctx.setTransform(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25),
-                    data.getFloat64(33), data.getFloat64(41));
-                return 49;
+                ctx.setTransform(r.float(), r.float(), r.float(), r.float(), r.float(), r.float());
+                break;
             case 45:
-                ctx.shadowBlur = data.getFloat64(1);
-                return 9;
-            case 46: {
-                ctx.shadowColor = getRGBA(data, 1);
-                return 5;
-            }
+                ctx.shadowBlur = r.float();
+                break;
+            case 46:
+                ctx.shadowColor = r.rgba();
+                break;
             case 47:
-                ctx.shadowOffsetX = data.getFloat64(1);
-                return 9;
+                ctx.shadowOffsetX = r.float();
+                break;
             case 48:
-                ctx.shadowOffsetY = data.getFloat64(1);
-                return 9;
+                ctx.shadowOffsetY = r.float();
+                break;
             case 49:
                 ctx.stroke();
-                return 1;
+                break;
             case 50:
-                ctx.strokeRect(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25));
-                return 33;
-            case 51: {
-                ctx.strokeStyle = getRGBA(data, 1);
-                return 5;
-            }
+                ctx.strokeRect(r.float(), r.float(), r.float(), r.float());
+                break;
+            case 51:
+                ctx.strokeStyle = r.rgba();
+                break;
             case 52: {
-                const text = getString(data, 17);
-                ctx.strokeText(text.value, data.getFloat64(1), data.getFloat64(9));
-                return 17 + text.byteLen;
+                const x = r.float();
+                const y = r.float();
+                ctx.strokeText(r.string(), x, y);
+                break;
             }
             case 53:
-                ctx.textAlign = enumTextAlign[data.getUint8(1)];
-                return 2;
+                ctx.textAlign = enumTextAlign[r.uint8()];
+                break;
             case 54:
-                ctx.textBaseline = enumTextBaseline[data.getUint8(1)];
-                return 2;
+                ctx.textBaseline = enumTextBaseline[r.uint8()];
+                break;
             case 55:
-                ctx.transform(
-                    data.getFloat64(1), data.getFloat64(9),
-                    data.getFloat64(17), data.getFloat64(25),
-                    data.getFloat64(33), data.getFloat64(41));
-                return 49;
+                ctx.transform(r.float(), r.float(), r.float(), r.float(), r.float(), r.float());
+                break;
             case 56:
-                ctx.translate(data.getFloat64(1), data.getFloat64(9));
-                return 17;
+                ctx.translate(r.float(), r.float());
+                break;
             case 57: {
-                const text = getString(data, 25);
-                ctx.fillText(text.value, data.getFloat64(1), data.getFloat64(9), data.getFloat64(17));
-                return 25 + text.byteLen;
+                const x = r.float();
+                const y = r.float();
+                const maxWidth = r.float();
+                ctx.fillText(r.string(), x, y, maxWidth);
+                break;
             }
             case 58: {
-                const text = getString(data, 25);
-                ctx.strokeText(text.value, data.getFloat64(1), data.getFloat64(9), data.getFloat64(17));
-                return 25 + text.byteLen;
-            }
-            case 59: {
-                const color = getString(data, 1);
-                ctx.fillStyle = color.value;
-                return 1 + color.byteLen;
-            }
-            case 60: {
-                const color = getString(data, 1);
-                ctx.strokeStyle = color.value;
-                return 1 + color.byteLen;
-            }
-            case 61: {
-                const color = getString(data, 1);
-                ctx.shadowColor = color.value;
-                return 1 + color.byteLen;
+                const x = r.float();
+                const y = r.float();
+                const maxWidth = r.float();
+                ctx.strokeText(r.string(), x, y, maxWidth);
+                break;
             }
+            case 59:
+                ctx.fillStyle = r.string();
+                break;
+            case 60:
+                ctx.strokeStyle = r.string();
+                break;
+            case 61:
+                ctx.shadowColor = r.string();
+                break;
             case 62:
-                ctx.putImageData(allocImageData[data.getUint32(1)],
-                    data.getFloat64(5), data.getFloat64(13),
-                    data.getFloat64(21), data.getFloat64(29),
-                    data.getFloat64(37), data.getFloat64(45));
-                return 53;
+                ctx.putImageData(imageData(r.uint32()),
+                    r.float(), r.float(), r.float(), r.float(), r.float(), r.float());
+                break;
             case 63:
-                ctx.drawImage(allocOffscreenCanvas[data.getUint32(1)],
-                    data.getFloat64(5), data.getFloat64(13),
-                    data.getFloat64(21), data.getFloat64(29));
-                return 37;
+                ctx.drawImage(allocOffscreenCanvas[r.uint32()],
+                    r.float(), r.float(), r.float(), r.float());
+                break;
             case 64:
-                ctx.drawImage(allocOffscreenCanvas[data.getUint32(1)],
-                    data.getFloat64(5), data.getFloat64(13),
-                    data.getFloat64(21), data.getFloat64(29),
-                    data.getFloat64(37), data.getFloat64(45),
-                    data.getFloat64(53), data.getFloat64(61));
-                return 69;
+                ctx.drawImage(allocOffscreenCanvas[r.uint32()],
+                    r.float(), r.float(), r.float(), r.float(),
+                    r.float(), r.float(), r.float(), r.float());
+                break;
             case 65: {
-                const id = data.getUint32(1);
+                const id = r.uint32();
                 allocImageData[id] = null;
                 allocOffscreenCanvas[id] = null;
-                return 5;
+                break;
             }
             case 66:
-                ctx.fillStyle = allocPattern[data.getUint32(1)];
-                return 5;
+                ctx.fillStyle = allocPattern[r.uint32()];
+                break;
             case 67:
-                ctx.strokeStyle = allocPattern[data.getUint32(1)];
-                return 5;
+                ctx.strokeStyle = allocPattern[r.uint32()];
+                break;
             case 68: {
-                const id = data.getUint32(1);
-                allocImageData[id] = ctx.getImageData(
-                    data.getFloat64(5), data.getFloat64(13),
-                    data.getFloat64(21), data.getFloat64(29));
-                return 37;
+                const id = r.uint32();
+                allocImageData[id] = ctx.getImageData(r.float(), r.float(), r.float(), r.float());
+                break;
+            }
+            case 69:
+                positionTextInput(ctx.canvas, r.float(), r.float(), r.float(), r.float());
+                break;
+            case 70: {
+                const id = r.uint32();
+                const type = r.string();
+                const quality = r.float();
+                sendSnapshot(ctx.canvas, webSocket, id, type, quality);
+                break;
+            }
+            case 71:
+                r.compact = true;
+                break;
+            case 72:
+                polyline(ctx, r);
+                break;
+            case 73:
+                polyline(ctx, r);
+                ctx.closePath();
+                break;
+            case 74: {
+                const radius = r.float();
+                const n = r.uint32();
+                for (let i = 0; i < n; i += 2) {
+                    const x = r.float();
+                    const y = r.float();
+                    if (radius >= 0) {
+                        ctx.moveTo(x + radius, y);
+                        ctx.arc(x, y, radius, 0, 2 * Math.PI);
+                    }
+                }
+                break;
+            }
+            case 75: {
+                const image = allocOffscreenCanvas[r.uint32()];
+                drawImageInstances(ctx, r, image, r.uint32());
+                break;
+            }
+            case 76: {
+                const id = r.uint32();
+                const type = r.string();
+                const data = r.bytes(r.uint32());
+                decodeImage(id, new Blob([data], {type: type})).then(function (size) {
+                    sendImageReady(webSocket, id, size.width, size.height, "");
+                }, function (err) {
+                    sendImageReady(webSocket, id, 0, 0, String(err));
+                });
+                break;
+            }
+            case 77: {
+                const id = r.uint32();
+                const url = r.string();
+                decodeImage(id, fetchImage(url)).then(function (size) {
+                    sendImageLoaded(webSocket, id, size.width, size.height);
+                }, function (err) {
+                    sendImageError(webSocket, id, String(err));
+                });
+                break;
             }
+            case 78:
+                updateImageData(r, r.uint32(), r.uint32());
+                break;
         }
-        return 1;
     }
 
-    function getString(data, offset) {
-        const stringLen = data.getUint32(offset);
-        const stringBegin = data.byteOffset + offset + 4;
-        const stringEnd = stringBegin + stringLen;
-        return {
-            value: new TextDecoder().decode(data.buffer.slice(stringBegin, stringEnd)),
-            byteLen: 4 + stringLen
-        };
+    function drawImageInstances(ctx, r, image, n) {
+        const transform = ctx.getTransform();
+        const globalAlpha = ctx.globalAlpha;
+        for (let i = 0; i < n; i++) {
+            const x = r.float();
+            const y = r.float();
+            const scale = r.float();
+            const rotation = r.float();
+            const alpha = r.float();
+            let sx = r.float();
+            let sy = r.float();
+            let sw = r.float();
+            let sh = r.float();
+            if (sw === 0 || sh === 0) {
+                sx = 0;
+                sy = 0;
+                sw = image.width;
+                sh = image.height;
+            }
+            const cos = Math.cos(rotation) * scale;
+            const sin = Math.sin(rotation) * scale;
+            ctx.setTransform(transform);
+            ctx.transform(cos, sin, -sin, cos, x, y);
+            ctx.globalAlpha = globalAlpha * Math.min(Math.max(alpha, 0), 1);
+            ctx.drawImage(image, sx, sy, sw, sh, -sw / 2, -sh / 2, sw, sh);
+        }
+        ctx.setTransform(transform);
+        ctx.globalAlpha = globalAlpha;
+    }
+
+    function polyline(ctx, r) {
+        const n = r.uint32();
+        if (n >= 2) {
+            ctx.moveTo(r.float(), r.float());
+        }
+        for (let i = 2; i < n; i += 2) {
+            ctx.lineTo(r.float(), r.float());
+        }
     }
 
-    function getRGBA(data, offset) {
-        return "rgba(" +
-            data.getUint8(offset) + ", " +
-            data.getUint8(offset + 1) + ", " +
-            data.getUint8(offset + 2) + ", " +
-            data.getUint8(offset + 3) / 255 + ")";
+    // updateImageData patches n tiles of pixels into the image with the
+    // given ID, both into its offscreen canvas and into its ImageData.
+    function updateImageData(r, id, n) {
+        const offCtx = allocOffscreenCanvas[id].getContext("2d");
+        const image = allocImageData[id];
+        for (let i = 0; i < n; i++) {
+            const x = r.uint32();
+            const y = r.uint32();
+            const width = r.uint32();
+            const height = r.uint32();
+            const array = new Uint8ClampedArray(r.bytes(width * height * 4));
+            offCtx.putImageData(new ImageData(array, width, height), x, y);
+            if (!image) {
+                continue;
+            }
+            for (let row = 0; row < height; row++) {
+                const begin = row * width * 4;
+                image.data.set(array.subarray(begin, begin + width * 4), ((y + row) * image.width + x) * 4);
+            }
+        }
+    }
+
+    function imageData(id) {
+        // The ImageData of an image created from encoded data is only
+        // extracted when it is needed.
+        const offCanvas = allocOffscreenCanvas[id];
+        if (!allocImageData[id] && offCanvas) {
+            allocImageData[id] = offCanvas.getContext("2d").getImageData(0, 0, offCanvas.width, offCanvas.height);
+        }
+        return allocImageData[id];
+    }
+
+    // decodeImage decodes the given blob, or the blob a promise resolves to,
+    // into the image with the given ID. It returns a promise of the size of
+    // the image.
+    function decodeImage(id, blob) {
+        // Until the image is decoded it is a single transparent pixel.
+        const offCanvas = document.createElement("canvas");
+        offCanvas.width = 1;
+        offCanvas.height = 1;
+        allocOffscreenCanvas[id] = offCanvas;
+        allocImageData[id] = null;
+        return Promise.resolve(blob).then(function (blob) {
+            return createImageBitmap(blob);
+        }).then(function (bitmap) {
+            const width = bitmap.width;
+            const height = bitmap.height;
+            if (allocOffscreenCanvas[id] === offCanvas) {
+                offCanvas.width = width;
+                offCanvas.height = height;
+                offCanvas.getContext("2d").drawImage(bitmap, 0, 0);
+                allocImageData[id] = null;
+            }
+            bitmap.close();
+            return {width: width, height: height};
+        });
+    }
+
+    // fetchImage loads an image via HTTP, relative to the URL of the page.
+    // The request is cached by the browser like any other resource.
+    function fetchImage(url) {
+        return fetch(url).then(function (response) {
+            if (!response.ok) {
+                throw new Error("loading " + response.url + ": " + response.status + " " + response.statusText);
+            }
+            return response.blob();
+        });
+    }
+
+    function sendImageReady(webSocket, id, width, height, error) {
+        if (webSocket.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        const bytes = new TextEncoder().encode(error);
+        const message = new Uint8Array(1 + 4 + 4 + 4 + 4 + bytes.byteLength);
+        const data = new DataView(message.buffer);
+        data.setUint8(0, 28);
+        data.setUint32(1, id);
+        data.setUint32(5, width);
+        data.setUint32(9, height);
+        data.setUint32(13, bytes.byteLength);
+        message.set(bytes, 17);
+        webSocket.send(message.buffer);
+    }
+
+    function sendImageLoaded(webSocket, id, width, height) {
+        if (webSocket.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        const message = new Uint8Array(1 + 4 + 4 + 4);
+        const data = new DataView(message.buffer);
+        data.setUint8(0, 29);
+        data.setUint32(1, id);
+        data.setUint32(5, width);
+        data.setUint32(9, height);
+        webSocket.send(message.buffer);
+    }
+
+    function sendImageError(webSocket, id, error) {
+        if (webSocket.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        const bytes = new TextEncoder().encode(error);
+        const message = new Uint8Array(1 + 4 + 4 + bytes.byteLength);
+        const data = new DataView(message.buffer);
+        data.setUint8(0, 30);
+        data.setUint32(1, id);
+        data.setUint32(5, bytes.byteLength);
+        message.set(bytes, 9);
+        webSocket.send(message.buffer);
+    }
+
+    function sendSnapshot(canvas, webSocket, id, type, quality) {
+        canvas.toBlob(function (blob) {
+            if (!blob) {
+                sendSnapshotReply(webSocket, id, null, "canvas could not be encoded");
+                return;
+            }
+            blob.arrayBuffer().then(function (buffer) {
+                sendSnapshotReply(webSocket, id, new Uint8Array(buffer), null);
+            }, function (err) {
+                sendSnapshotReply(webSocket, id, null, String(err));
+            });
+        }, type || "image/png", quality);
+    }
+
+    function sendSnapshotReply(webSocket, id, bytes, error) {
+        if (webSocket.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        if (!bytes) {
+            bytes = new TextEncoder().encode(error);
+        }
+        const message = new Uint8Array(1 + 4 + 1 + 4 + bytes.byteLength);
+        const data = new DataView(message.buffer);
+        data.setUint8(0, 128);
+        data.setUint32(1, id);
+        data.setUint8(5, error ? 0 : 1);
+        data.setUint32(6, bytes.byteLength);
+        message.set(bytes, 10);
+        webSocket.send(message.buffer);
     }
 });